@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 
+	"github.com/labbs/zotion/pkg/cmd/export"
 	"github.com/labbs/zotion/pkg/cmd/migration"
 	"github.com/labbs/zotion/pkg/cmd/server"
 	"github.com/urfave/cli/v2"
@@ -20,6 +21,7 @@ func main() {
 	app.Commands = []*cli.Command{
 		server.NewInstance(),
 		migration.NewInstance(),
+		export.NewInstance(),
 	}
 
 	err := app.Run(os.Args)
@@ -1,6 +1,7 @@
 package database
 
 import (
+	mysqldriver "github.com/go-sql-driver/mysql"
 	zerologadapter "github.com/labbs/zotion/internal/logger/zerolog"
 	"github.com/rs/zerolog"
 	"gorm.io/driver/mysql"
@@ -24,7 +25,10 @@ func NewGorm(logger zerolog.Logger, dialect, dsn string) *gorm.DB {
 	case "postgres":
 		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: gormLogger})
 	case "mysql":
-		db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: gormLogger})
+		dsn, err = MySQLDSN(dsn)
+		if err == nil {
+			db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: gormLogger})
+		}
 	default:
 		logger.Fatal().Msg("Invalid database type")
 	}
@@ -34,3 +38,22 @@ func NewGorm(logger zerolog.Logger, dialect, dsn string) *gorm.DB {
 
 	return db
 }
+
+// MySQLDSN returns the dsn with the connection options the queries rely on: the dates are parsed as times,
+// the migrations can run several statements at once and the identifiers can be quoted with double quotes
+// like on sqlite and postgres (ANSI_QUOTES), the sql mode of the server is kept otherwise.
+func MySQLDSN(dsn string) (string, error) {
+	cfg, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	cfg.ParseTime = true
+	cfg.MultiStatements = true
+	if cfg.Params == nil {
+		cfg.Params = map[string]string{}
+	}
+	if _, ok := cfg.Params["sql_mode"]; !ok {
+		cfg.Params["sql_mode"] = "CONCAT(@@sql_mode, ',ANSI_QUOTES')"
+	}
+	return cfg.FormatDSN(), nil
+}
@@ -0,0 +1,42 @@
+package mergepatch
+
+import "github.com/goccy/go-json"
+
+// Apply applies a JSON merge patch (RFC 7396) on the original document.
+// Objects are merged recursively, a null value removes the key and any other value replaces it.
+func Apply(original, patch []byte) ([]byte, error) {
+	var target any
+	if len(original) > 0 {
+		if err := json.Unmarshal(original, &target); err != nil {
+			return nil, err
+		}
+	}
+
+	var p any
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(merge(target, p))
+}
+
+func merge(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = merge(targetObject[key], value)
+	}
+	return targetObject
+}
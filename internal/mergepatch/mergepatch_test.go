@@ -0,0 +1,76 @@
+package mergepatch
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/goccy/go-json"
+)
+
+func TestApply(t *testing.T) {
+	// the cases of RFC 7396 appendix A and the edge cases of the documents
+	tests := []struct {
+		name     string
+		original string
+		patch    string
+		want     string
+	}{
+		{"replace value", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"add key", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"null deletes key", `{"a":"b"}`, `{"a":null}`, `{}`},
+		{"null deletes only its key", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"array replaces value", `{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"value replaces array", `{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{"nested merge", `{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{"arrays are not merged", `{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{"array document", `["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{"object replaces array document", `{"a":"b"}`, `["c"]`, `["c"]`},
+		{"null patch", `{"a":"foo"}`, `null`, `null`},
+		{"string patch", `{"a":"foo"}`, `"bar"`, `"bar"`},
+		{"null in nested patch is kept out", `{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{"object patch on array", `[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{"nested null on missing object", `{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		{"empty original", ``, `{"a":{"b":null,"c":1}}`, `{"a":{"c":1}}`},
+		{"empty patch object", `{"a":1}`, `{}`, `{"a":1}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Apply([]byte(tt.original), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got, want any
+			if err := json.Unmarshal(result, &got); err != nil {
+				t.Fatalf("invalid result %s: %v", result, err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatalf("invalid expected value %s: %v", tt.want, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %s, want %s", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		patch    string
+	}{
+		{"invalid original", `{"a":`, `{"a":1}`},
+		{"invalid patch", `{"a":1}`, `{"a":`},
+		{"empty patch", `{"a":1}`, ``},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Apply([]byte(tt.original), []byte(tt.patch)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
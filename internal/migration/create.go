@@ -0,0 +1,70 @@
+package migration
+
+import (
+	"text/template"
+
+	"github.com/labbs/zotion/internal/logger/zerolog"
+	"github.com/pressly/goose/v3"
+	z "github.com/rs/zerolog"
+)
+
+// migrationTemplate is the go migration created by Create, each direction has the query of each dialect
+var migrationTemplate = template.Must(template.New("migration").Parse(`package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(up{{.CamelName}}, down{{.CamelName}})
+}
+
+func up{{.CamelName}}(ctx context.Context, tx *sql.Tx) error {
+	var query string
+	switch config.Database.Dialect {
+	case "sqlite":
+		query = ` + "``" + `
+	case "postgres":
+		query = ` + "``" + `
+	case "mysql":
+		query = ` + "``" + `
+	default:
+		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
+	}
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func down{{.CamelName}}(ctx context.Context, tx *sql.Tx) error {
+	var query string
+	switch config.Database.Dialect {
+	case "sqlite":
+		query = ` + "``" + `
+	case "postgres":
+		query = ` + "``" + `
+	case "mysql":
+		query = ` + "``" + `
+	default:
+		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
+	}
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+`))
+
+// Create writes a new go migration named after the name in the directory, the version is the current timestamp
+func Create(l z.Logger, dir, name string) error {
+	logger := l.With().Str("event", "migration").Logger()
+	goose.SetLogger(&zerolog.ZerologGooseAdapter{Logger: logger})
+
+	if err := goose.CreateWithTemplate(nil, dir, migrationTemplate, name, "go"); err != nil {
+		logger.Error().Err(err).Msg("Failed to create the migration")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/pressly/goose/v3"
+	z "github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// DryRunAction is the migration command printed by DryRun
+type DryRunAction string
+
+const (
+	DryRunUp     DryRunAction = "up"
+	DryRunDown   DryRunAction = "down"
+	DryRunDownTo DryRunAction = "down-to"
+	DryRunRedo   DryRunAction = "redo"
+)
+
+// DryRun prints the statements the action would run on the dialect, nothing is written to the database.
+// The migrations are run against a connection that records the statements, the queries reading data
+// return no rows so the statements depending on existing rows are not printed.
+// Without a database (nil) no migration is considered applied for up and all of them for the rollbacks.
+func DryRun(l z.Logger, w io.Writer, db *gorm.DB, dialect string, action DryRunAction, version int64) error {
+	goose.SetBaseFS(migrationFiles)
+
+	migrations, err := goose.CollectMigrations("files", 0, goose.MaxVersion)
+	if err != nil {
+		return err
+	}
+
+	applied := make(map[int64]bool, len(migrations))
+	if db != nil {
+		statuses, err := Status(l, db)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied[status.Source.Version] = status.State == goose.StateApplied
+		}
+	} else if action != DryRunUp {
+		for _, m := range migrations {
+			applied[m.Version] = true
+		}
+	}
+
+	type step struct {
+		migration *goose.Migration
+		up        bool
+	}
+	var steps []step
+	switch action {
+	case DryRunUp:
+		for _, m := range migrations {
+			if !applied[m.Version] {
+				steps = append(steps, step{migration: m, up: true})
+			}
+		}
+	case DryRunDown, DryRunRedo:
+		for i := len(migrations) - 1; i >= 0; i-- {
+			if applied[migrations[i].Version] {
+				steps = append(steps, step{migration: migrations[i]})
+				if action == DryRunRedo {
+					steps = append(steps, step{migration: migrations[i], up: true})
+				}
+				break
+			}
+		}
+	case DryRunDownTo:
+		for i := len(migrations) - 1; i >= 0; i-- {
+			if migrations[i].Version > version && applied[migrations[i].Version] {
+				steps = append(steps, step{migration: migrations[i]})
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported migration action: %s", action)
+	}
+
+	fmt.Fprintf(w, "-- dry run of migration %s (%s), %d migration(s)\n", action, dialect, len(steps))
+	fmt.Fprintln(w, "-- the queries reading data return no rows, the statements depending on them are not printed")
+
+	recorder := &statementRecorder{}
+	recordDB := sql.OpenDB(recorder)
+	defer recordDB.Close()
+
+	ctx := context.Background()
+	for _, s := range steps {
+		direction := "down"
+		if s.up {
+			direction = "up"
+		}
+		fmt.Fprintf(w, "\n-- %s %s\n", path.Base(s.migration.Source), direction)
+
+		recorder.statements = nil
+		if err := runRecorded(ctx, recordDB, s.migration, s.up); err != nil {
+			fmt.Fprintf(w, "-- error: %s\n", err)
+		}
+		for _, statement := range recorder.statements {
+			fmt.Fprintln(w, statement)
+		}
+	}
+
+	return nil
+}
+
+// runRecorded runs the go function of the migration against the recording database
+func runRecorded(ctx context.Context, db *sql.DB, m *goose.Migration, up bool) error {
+	if m.Type != goose.TypeGo {
+		return fmt.Errorf("the sql migrations are not printed, see %s", m.Source)
+	}
+
+	if !m.UseTx {
+		fn := m.DownFnNoTxContext
+		if up {
+			fn = m.UpFnNoTxContext
+		}
+		if fn == nil {
+			return nil
+		}
+		return fn(ctx, db)
+	}
+
+	fn := m.DownFnContext
+	if up {
+		fn = m.UpFnContext
+	}
+	if fn == nil {
+		return nil
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return fn(ctx, tx)
+}
+
+// statementRecorder is a database driver keeping the executed statements instead of running them
+type statementRecorder struct {
+	statements []string
+}
+
+func (r *statementRecorder) Connect(context.Context) (driver.Conn, error) {
+	return &recorderConn{recorder: r}, nil
+}
+
+func (r *statementRecorder) Driver() driver.Driver {
+	return recorderDriver{recorder: r}
+}
+
+func (r *statementRecorder) record(query string, args []driver.NamedValue) {
+	statement := dedent(query)
+	if !strings.HasSuffix(statement, ";") {
+		statement += ";"
+	}
+	if len(args) > 0 {
+		values := make([]string, len(args))
+		for i, arg := range args {
+			values[i] = fmt.Sprintf("%v", arg.Value)
+		}
+		statement += "\n-- args: " + strings.Join(values, ", ")
+	}
+	r.statements = append(r.statements, statement)
+}
+
+type recorderDriver struct {
+	recorder *statementRecorder
+}
+
+func (d recorderDriver) Open(string) (driver.Conn, error) {
+	return &recorderConn{recorder: d.recorder}, nil
+}
+
+type recorderConn struct {
+	recorder *statementRecorder
+}
+
+func (c *recorderConn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("prepared statements are not supported by the dry run")
+}
+
+func (c *recorderConn) Close() error {
+	return nil
+}
+
+func (c *recorderConn) Begin() (driver.Tx, error) {
+	return recorderTx{}, nil
+}
+
+func (c *recorderConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.recorder.record(query, args)
+	return driver.RowsAffected(0), nil
+}
+
+func (c *recorderConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return emptyRows{}, nil
+}
+
+type recorderTx struct{}
+
+func (recorderTx) Commit() error {
+	return nil
+}
+
+func (recorderTx) Rollback() error {
+	return nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string {
+	return nil
+}
+
+func (emptyRows) Close() error {
+	return nil
+}
+
+func (emptyRows) Next([]driver.Value) error {
+	return io.EOF
+}
+
+// dedent trims the query and removes the indentation shared by its lines
+func dedent(query string) string {
+	lines := strings.Split(strings.Trim(query, "\n"), "\n")
+
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent == -1 || n < indent {
+			indent = n
+		}
+	}
+
+	for i, line := range lines {
+		if len(line) >= indent && indent > 0 {
+			lines[i] = line[indent:]
+		}
+		lines[i] = strings.TrimRight(lines[i], " \t")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -36,7 +36,7 @@ func upUser(ctx context.Context, tx *sql.Tx) error {
 		`
 	case "postgres":
 		query = `
-		CREATE TABLE IF NOT EXISTS 'user' (
+		CREATE TABLE IF NOT EXISTS "user" (
 			id uuid PRIMARY KEY,
 			name varchar NOT NULL,
 			email varchar NOT NULL,
@@ -47,12 +47,27 @@ func upUser(ctx context.Context, tx *sql.Tx) error {
 			created_at timestamp NOT NULL,
 			updated_at timestamp NOT NULL
 		);
-		CREATE UNIQUE INDEX IF NOT EXISTS idx_user_name ON user (name);
-		CREATE UNIQUE INDEX IF NOT EXISTS idx_user_email ON user (email);
-		CREATE INDEX IF NOT EXISTS idx_user_active ON user (active);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_user_name ON "user" (name);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_user_email ON "user" (email);
+		CREATE INDEX IF NOT EXISTS idx_user_active ON "user" (active);
 		`
 	case "mysql":
-		return fmt.Errorf("mysql dialect is not supported yet")
+		query = `
+		CREATE TABLE IF NOT EXISTS "user" (
+			id varchar(36) PRIMARY KEY,
+			name varchar(255) NOT NULL,
+			email varchar(255) NOT NULL,
+			password varchar(255) NOT NULL,
+			avatar_url text,
+			preferences json,
+			active bool NOT NULL,
+			created_at datetime(3) NOT NULL,
+			updated_at datetime(3) NOT NULL,
+			UNIQUE KEY idx_user_name (name),
+			UNIQUE KEY idx_user_email (email),
+			KEY idx_user_active (active)
+		) ` + mysqlTableOptions + `;
+		`
 	default:
 		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
 	}
@@ -61,6 +76,6 @@ func upUser(ctx context.Context, tx *sql.Tx) error {
 }
 
 func downUser(ctx context.Context, tx *sql.Tx) error {
-	_, err := tx.Exec("DROP TABLE IF EXISTS user;")
+	_, err := tx.Exec(`DROP TABLE IF EXISTS "user";`)
 	return err
 }
@@ -42,7 +42,17 @@ func upGroup(ctx context.Context, tx *sql.Tx) error {
 		CREATE UNIQUE INDEX IF NOT EXISTS idx_group_name ON "group" (name);
 		`
 	case "mysql":
-		return fmt.Errorf("mysql dialect is not supported yet")
+		query = `
+		CREATE TABLE IF NOT EXISTS "group" (
+			id varchar(36) PRIMARY KEY,
+			name varchar(255) NOT NULL,
+			description text,
+			role varchar(255),
+			created_at datetime(3) NOT NULL,
+			updated_at datetime(3) NOT NULL,
+			UNIQUE KEY idx_group_name (name)
+		) ` + mysqlTableOptions + `;
+		`
 	default:
 		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
 	}
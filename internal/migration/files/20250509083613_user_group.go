@@ -40,7 +40,16 @@ func upUserGroup(ctx context.Context, tx *sql.Tx) error {
 		CREATE INDEX IF NOT EXISTS idx_user_group_group_id ON user_group (group_id);
 		`
 	case "mysql":
-		return fmt.Errorf("mysql dialect is not supported yet")
+		query = `
+		CREATE TABLE IF NOT EXISTS user_group (
+			user_id varchar(36) NOT NULL,
+			group_id varchar(36) NOT NULL,
+			created_at datetime(3) NOT NULL,
+			PRIMARY KEY (user_id, group_id),
+			KEY idx_user_group_user_id (user_id),
+			KEY idx_user_group_group_id (group_id)
+		) ` + mysqlTableOptions + `;
+		`
 	default:
 		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
 	}
@@ -55,7 +55,24 @@ func upSpace(ctx context.Context, tx *sql.Tx) error {
 		CREATE INDEX IF NOT EXISTS idx_space_deleted_at ON space (deleted_at);
 		`
 	case "mysql":
-		return fmt.Errorf("mysql dialect is not supported yet")
+		query = `
+		CREATE TABLE IF NOT EXISTS space (
+			id varchar(36) PRIMARY KEY,
+			name varchar(255) NOT NULL,
+			slug varchar(255) NOT NULL,
+			icon varchar(255),
+			icon_color varchar(255),
+			description text,
+			type varchar(255),
+			members json,
+			created_at datetime(3) NOT NULL,
+			updated_at datetime(3) NOT NULL,
+			deleted_at datetime(3),
+			KEY idx_space_name (name),
+			UNIQUE KEY idx_space_slug (slug),
+			KEY idx_space_deleted_at (deleted_at)
+		) ` + mysqlTableOptions + `;
+		`
 	default:
 		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
 	}
@@ -65,7 +65,29 @@ func upDocument(ctx context.Context, tx *sql.Tx) error {
 		CREATE INDEX IF NOT EXISTS idx_document_space_id ON document (space_id);
 		`
 	case "mysql":
-		return fmt.Errorf("mysql dialect is not supported yet")
+		query = `
+		CREATE TABLE IF NOT EXISTS document (
+			id varchar(36) PRIMARY KEY,
+			name varchar(255) NOT NULL,
+			slug varchar(255) NOT NULL,
+			type varchar(255) NOT NULL,
+			config json,
+			metadata json,
+			parent_id varchar(36),
+			properties json,
+			members json,
+			space_id varchar(36),
+			content longtext,
+			public boolean NOT NULL DEFAULT false,
+			created_at datetime(3) NOT NULL,
+			updated_at datetime(3) NOT NULL,
+			deleted_at datetime(3),
+			KEY idx_document_name (name),
+			UNIQUE KEY idx_document_slug (slug),
+			KEY idx_document_deleted_at (deleted_at),
+			KEY idx_document_space_id (space_id)
+		) ` + mysqlTableOptions + `;
+		`
 	default:
 		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
 	}
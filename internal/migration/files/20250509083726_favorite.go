@@ -41,7 +41,17 @@ func upFavorite(ctx context.Context, tx *sql.Tx) error {
 		CREATE INDEX IF NOT EXISTS idx_favorite_user ON favorite (user_id);
 		`
 	case "mysql":
-		return fmt.Errorf("mysql dialect is not supported yet")
+		query = `
+		CREATE TABLE IF NOT EXISTS favorite (
+			id varchar(36) PRIMARY KEY,
+			user_id varchar(36) NOT NULL,
+			document_id varchar(36) NOT NULL,
+			database_id varchar(36) NOT NULL,
+			position varchar(255) NOT NULL,
+			created_at datetime(3) NOT NULL,
+			KEY idx_favorite_user (user_id)
+		) ` + mysqlTableOptions + `;
+		`
 	default:
 		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
 	}
@@ -41,12 +41,24 @@ func upSession(ctx context.Context, tx *sql.Tx) error {
 			expires_at timestamp NOT NULL,
 			created_at timestamp NOT NULL,
 			updated_at timestamp NOT NULL,
-			FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+			FOREIGN KEY (user_id) REFERENCES "user"(id) ON DELETE CASCADE
 		);
 		CREATE INDEX IF NOT EXISTS idx_session_user_id ON session (user_id);
 		`
 	case "mysql":
-		return fmt.Errorf("mysql dialect is not supported yet")
+		query = `
+		CREATE TABLE IF NOT EXISTS session (
+			id varchar(36) PRIMARY KEY,
+			user_id varchar(36) NOT NULL,
+			user_agent text NOT NULL,
+			ip_address varchar(255) NOT NULL,
+			expires_at datetime(3) NOT NULL,
+			created_at datetime(3) NOT NULL,
+			updated_at datetime(3) NOT NULL,
+			KEY idx_session_user_id (user_id),
+			FOREIGN KEY (user_id) REFERENCES "user"(id) ON DELETE CASCADE
+		) ` + mysqlTableOptions + `;
+		`
 	default:
 		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
 	}
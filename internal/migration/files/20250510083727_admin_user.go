@@ -22,16 +22,16 @@ func upAdminUser(ctx context.Context, tx *sql.Tx) error {
 		return err
 	}
 
-	_, err = tx.ExecContext(ctx, `
-	INSERT INTO user (id, name, email, password, active, created_at, updated_at)
-	VALUES (?, ?, ?, ?, ?, datetime('now'), datetime('now'));
-	`, utils.UUIDv4(), nameAdminUser, emailAdminUser, bcryptHash, true)
+	_, err = tx.ExecContext(ctx, bindParams(`
+	INSERT INTO "user" (id, name, email, password, active, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+	`), utils.UUIDv4(), nameAdminUser, emailAdminUser, string(bcryptHash), true)
 	return err
 }
 
 func downAdminUser(ctx context.Context, tx *sql.Tx) error {
-	_, err := tx.ExecContext(ctx, `
-	DELETE FROM user WHERE email = ?;
-	`, emailAdminUser)
+	_, err := tx.ExecContext(ctx, bindParams(`
+	DELETE FROM "user" WHERE email = ?;
+	`), emailAdminUser)
 	return err
 }
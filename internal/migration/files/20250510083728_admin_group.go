@@ -15,16 +15,16 @@ func init() {
 const groupAdminGroup string = "admin"
 
 func upAdminGroup(ctx context.Context, tx *sql.Tx) error {
-	_, err := tx.ExecContext(ctx, `
+	_, err := tx.ExecContext(ctx, bindParams(`
 	INSERT INTO "group" (id, name, description, role, created_at, updated_at)
-	VALUES (?, ?, ?, ?, datetime('now'), datetime('now'));
-	`, utils.UUIDv4(), groupAdminGroup, "admin group", "admin")
+	VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+	`), utils.UUIDv4(), groupAdminGroup, "admin group", "admin")
 	return err
 }
 
 func downAdminGroup(ctx context.Context, tx *sql.Tx) error {
-	_, err := tx.ExecContext(ctx, `
+	_, err := tx.ExecContext(ctx, bindParams(`
 	DELETE FROM "group" WHERE name = ?;
-	`, groupAdminGroup)
+	`), groupAdminGroup)
 	return err
 }
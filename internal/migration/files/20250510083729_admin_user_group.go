@@ -14,24 +14,24 @@ func init() {
 func upAdminUserGroup(ctx context.Context, tx *sql.Tx) error {
 	var userId, groupId string
 
-	err := tx.QueryRowContext(ctx, `
-	SELECT id FROM user WHERE email = ?;
-	`, emailAdminUser).Scan(&userId)
+	err := tx.QueryRowContext(ctx, bindParams(`
+	SELECT id FROM "user" WHERE email = ?;
+	`), emailAdminUser).Scan(&userId)
 	if err != nil {
 		return err
 	}
 
-	err = tx.QueryRowContext(ctx, `
+	err = tx.QueryRowContext(ctx, bindParams(`
 	SELECT id FROM "group" WHERE name = ?;
-	`, groupAdminGroup).Scan(&groupId)
+	`), groupAdminGroup).Scan(&groupId)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.ExecContext(ctx, `
+	_, err = tx.ExecContext(ctx, bindParams(`
 	INSERT INTO user_group (user_id, group_id, created_at)
-	VALUES (?, ?, datetime('now'));
-	`, userId, groupId)
+	VALUES (?, ?, CURRENT_TIMESTAMP);
+	`), userId, groupId)
 
 	return err
 }
@@ -39,22 +39,22 @@ func upAdminUserGroup(ctx context.Context, tx *sql.Tx) error {
 func downAdminUserGroup(ctx context.Context, tx *sql.Tx) error {
 	var userId, groupId string
 
-	err := tx.QueryRowContext(ctx, `
-	SELECT id FROM user WHERE email = ?;
-	`, emailAdminUser).Scan(&userId)
+	err := tx.QueryRowContext(ctx, bindParams(`
+	SELECT id FROM "user" WHERE email = ?;
+	`), emailAdminUser).Scan(&userId)
 	if err != nil {
 		return err
 	}
 
-	err = tx.QueryRowContext(ctx, `
+	err = tx.QueryRowContext(ctx, bindParams(`
 	SELECT id FROM "group" WHERE name = ?;
-	`, groupAdminGroup).Scan(&groupId)
+	`), groupAdminGroup).Scan(&groupId)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.ExecContext(ctx, `
+	_, err = tx.ExecContext(ctx, bindParams(`
 	DELETE FROM user_group WHERE user_id = ? AND group_id = ?;
-	`, userId, groupId)
+	`), userId, groupId)
 	return err
 }
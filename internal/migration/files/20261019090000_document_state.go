@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upDocumentState, downDocumentState)
+}
+
+func upDocumentState(ctx context.Context, tx *sql.Tx) error {
+	var query string
+	switch config.Database.Dialect {
+	case "sqlite":
+		query = `
+		CREATE TABLE IF NOT EXISTS document_state (
+			document_id TEXT PRIMARY KEY,
+			state BLOB,
+			updated_at datetime NOT NULL
+		);
+		`
+	case "postgres":
+		query = `
+		CREATE TABLE IF NOT EXISTS document_state (
+			document_id uuid PRIMARY KEY,
+			state bytea,
+			updated_at timestamp NOT NULL
+		);
+		`
+	case "mysql":
+		query = `
+		CREATE TABLE IF NOT EXISTS document_state (
+			document_id varchar(36) PRIMARY KEY,
+			state longblob,
+			updated_at datetime(3) NOT NULL
+		) ` + mysqlTableOptions + `;
+		`
+	default:
+		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
+	}
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downDocumentState(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE IF EXISTS document_state`)
+	return err
+}
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upDocumentVersion, downDocumentVersion)
+}
+
+func upDocumentVersion(ctx context.Context, tx *sql.Tx) error {
+	var query string
+	switch config.Database.Dialect {
+	case "sqlite":
+		query = `ALTER TABLE document ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`
+	case "postgres":
+		query = `ALTER TABLE document ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;`
+	case "mysql":
+		query = `ALTER TABLE document ADD COLUMN version integer NOT NULL DEFAULT 1;`
+	default:
+		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
+	}
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downDocumentVersion(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE document DROP COLUMN version`)
+	return err
+}
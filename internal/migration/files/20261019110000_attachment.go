@@ -0,0 +1,77 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upAttachment, downAttachment)
+}
+
+func upAttachment(ctx context.Context, tx *sql.Tx) error {
+	var query string
+	switch config.Database.Dialect {
+	case "sqlite":
+		query = `
+		CREATE TABLE IF NOT EXISTS attachment (
+			id TEXT PRIMARY KEY,
+			document_id TEXT NOT NULL,
+			space_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			content_type TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			checksum TEXT NOT NULL,
+			storage_key TEXT NOT NULL,
+			created_by TEXT,
+			created_at datetime NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_attachment_document_id ON attachment (document_id);
+		`
+	case "postgres":
+		query = `
+		CREATE TABLE IF NOT EXISTS attachment (
+			id uuid PRIMARY KEY,
+			document_id uuid NOT NULL,
+			space_id uuid NOT NULL,
+			name varchar(255) NOT NULL,
+			content_type varchar(255) NOT NULL,
+			size bigint NOT NULL,
+			checksum varchar(64) NOT NULL,
+			storage_key varchar(255) NOT NULL,
+			created_by uuid,
+			created_at timestamp NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_attachment_document_id ON attachment (document_id);
+		`
+	case "mysql":
+		query = `
+		CREATE TABLE IF NOT EXISTS attachment (
+			id varchar(36) PRIMARY KEY,
+			document_id varchar(36) NOT NULL,
+			space_id varchar(36) NOT NULL,
+			name varchar(255) NOT NULL,
+			content_type varchar(255) NOT NULL,
+			size bigint NOT NULL,
+			checksum varchar(64) NOT NULL,
+			storage_key varchar(255) NOT NULL,
+			created_by varchar(36),
+			created_at datetime(3) NOT NULL,
+			KEY idx_attachment_document_id (document_id)
+		) ` + mysqlTableOptions + `;
+		`
+	default:
+		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
+	}
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downAttachment(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE IF EXISTS attachment`)
+	return err
+}
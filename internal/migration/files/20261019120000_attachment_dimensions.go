@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upAttachmentDimensions, downAttachmentDimensions)
+}
+
+func upAttachmentDimensions(ctx context.Context, tx *sql.Tx) error {
+	var queries []string
+	switch config.Database.Dialect {
+	case "sqlite", "postgres", "mysql":
+		queries = []string{
+			`ALTER TABLE attachment ADD COLUMN width INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE attachment ADD COLUMN height INTEGER NOT NULL DEFAULT 0`,
+		}
+	default:
+		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func downAttachmentDimensions(ctx context.Context, tx *sql.Tx) error {
+	for _, column := range []string{"width", "height"} {
+		if _, err := tx.ExecContext(ctx, `ALTER TABLE attachment DROP COLUMN `+column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
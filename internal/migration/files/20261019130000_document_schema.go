@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upDocumentSchema, downDocumentSchema)
+}
+
+func upDocumentSchema(ctx context.Context, tx *sql.Tx) error {
+	var query string
+	switch config.Database.Dialect {
+	case "sqlite":
+		query = `ALTER TABLE document ADD COLUMN schema JSONB;`
+	case "postgres":
+		query = `ALTER TABLE document ADD COLUMN IF NOT EXISTS schema jsonb;`
+	case "mysql":
+		query = `ALTER TABLE document ADD COLUMN "schema" json;`
+	default:
+		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
+	}
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downDocumentSchema(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE document DROP COLUMN schema`)
+	return err
+}
@@ -0,0 +1,81 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upDatabaseView, downDatabaseView)
+}
+
+func upDatabaseView(ctx context.Context, tx *sql.Tx) error {
+	var query string
+	switch config.Database.Dialect {
+	case "sqlite":
+		query = `
+		CREATE TABLE IF NOT EXISTS database_view (
+			id TEXT PRIMARY KEY,
+			database_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL,
+			config JSONB,
+			position INTEGER NOT NULL DEFAULT 0,
+			created_by TEXT,
+			created_at datetime NOT NULL,
+			updated_at datetime NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_database_view_database_id ON database_view (database_id);
+		CREATE INDEX IF NOT EXISTS idx_document_parent_id ON document (parent_id);
+		`
+	case "postgres":
+		query = `
+		CREATE TABLE IF NOT EXISTS database_view (
+			id uuid PRIMARY KEY,
+			database_id varchar NOT NULL,
+			name varchar NOT NULL,
+			type varchar NOT NULL,
+			config jsonb,
+			position integer NOT NULL DEFAULT 0,
+			created_by varchar,
+			created_at timestamp NOT NULL,
+			updated_at timestamp NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_database_view_database_id ON database_view (database_id);
+		CREATE INDEX IF NOT EXISTS idx_document_parent_id ON document (parent_id);
+		`
+	case "mysql":
+		query = `
+		CREATE TABLE IF NOT EXISTS database_view (
+			id varchar(36) PRIMARY KEY,
+			database_id varchar(36) NOT NULL,
+			name varchar(255) NOT NULL,
+			type varchar(255) NOT NULL,
+			config json,
+			position integer NOT NULL DEFAULT 0,
+			created_by varchar(36),
+			created_at datetime(3) NOT NULL,
+			updated_at datetime(3) NOT NULL,
+			KEY idx_database_view_database_id (database_id)
+		) ` + mysqlTableOptions + `;
+		CREATE INDEX idx_document_parent_id ON document (parent_id);
+		`
+	default:
+		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
+	}
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downDatabaseView(ctx context.Context, tx *sql.Tx) error {
+	query := `DROP TABLE IF EXISTS database_view; DROP INDEX IF EXISTS idx_document_parent_id;`
+	if config.Database.Dialect == "mysql" {
+		query = `DROP TABLE IF EXISTS database_view; DROP INDEX idx_document_parent_id ON document;`
+	}
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
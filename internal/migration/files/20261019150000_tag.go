@@ -0,0 +1,92 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upTag, downTag)
+}
+
+func upTag(ctx context.Context, tx *sql.Tx) error {
+	var query string
+	switch config.Database.Dialect {
+	case "sqlite":
+		query = `
+		CREATE TABLE IF NOT EXISTS tag (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			color TEXT NOT NULL DEFAULT '',
+			space_id TEXT NOT NULL DEFAULT '',
+			created_by TEXT,
+			created_at datetime NOT NULL,
+			updated_at datetime NOT NULL
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_tag_space_name ON tag (space_id, name COLLATE NOCASE);
+		CREATE TABLE IF NOT EXISTS document_tag (
+			document_id TEXT NOT NULL,
+			tag_id TEXT NOT NULL,
+			created_by TEXT,
+			created_at datetime NOT NULL,
+			PRIMARY KEY (document_id, tag_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_document_tag_tag_id ON document_tag (tag_id);
+		`
+	case "postgres":
+		query = `
+		CREATE TABLE IF NOT EXISTS tag (
+			id uuid PRIMARY KEY,
+			name varchar NOT NULL,
+			color varchar NOT NULL DEFAULT '',
+			space_id varchar NOT NULL DEFAULT '',
+			created_by varchar,
+			created_at timestamp NOT NULL,
+			updated_at timestamp NOT NULL
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_tag_space_name ON tag (space_id, lower(name));
+		CREATE TABLE IF NOT EXISTS document_tag (
+			document_id varchar NOT NULL,
+			tag_id varchar NOT NULL,
+			created_by varchar,
+			created_at timestamp NOT NULL,
+			PRIMARY KEY (document_id, tag_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_document_tag_tag_id ON document_tag (tag_id);
+		`
+	case "mysql":
+		query = `
+		CREATE TABLE IF NOT EXISTS tag (
+			id varchar(36) PRIMARY KEY,
+			name varchar(255) COLLATE utf8mb4_general_ci NOT NULL,
+			color varchar(255) NOT NULL DEFAULT '',
+			space_id varchar(36) NOT NULL DEFAULT '',
+			created_by varchar(36),
+			created_at datetime(3) NOT NULL,
+			updated_at datetime(3) NOT NULL,
+			UNIQUE KEY idx_tag_space_name (space_id, name)
+		) ` + mysqlTableOptions + `;
+		CREATE TABLE IF NOT EXISTS document_tag (
+			document_id varchar(36) NOT NULL,
+			tag_id varchar(36) NOT NULL,
+			created_by varchar(36),
+			created_at datetime(3) NOT NULL,
+			PRIMARY KEY (document_id, tag_id),
+			KEY idx_document_tag_tag_id (tag_id)
+		) ` + mysqlTableOptions + `;
+		`
+	default:
+		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
+	}
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downTag(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE IF EXISTS document_tag; DROP TABLE IF EXISTS tag;`)
+	return err
+}
@@ -0,0 +1,106 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upActivity, downActivity)
+}
+
+func upActivity(ctx context.Context, tx *sql.Tx) error {
+	var query string
+	switch config.Database.Dialect {
+	case "sqlite":
+		query = `
+		ALTER TABLE document ADD COLUMN created_by TEXT NOT NULL DEFAULT '';
+		ALTER TABLE document ADD COLUMN updated_by TEXT NOT NULL DEFAULT '';
+		CREATE TABLE IF NOT EXISTS activity (
+			id TEXT PRIMARY KEY,
+			type TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			space_id TEXT NOT NULL,
+			document_id TEXT NOT NULL,
+			document_name TEXT NOT NULL,
+			data JSONB,
+			created_at datetime NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_activity_space_created_at ON activity (space_id, created_at);
+		CREATE INDEX IF NOT EXISTS idx_activity_document_created_at ON activity (document_id, created_at);
+		CREATE TABLE IF NOT EXISTS recent_document (
+			user_id TEXT NOT NULL,
+			document_id TEXT NOT NULL,
+			viewed_at datetime,
+			edited_at datetime,
+			PRIMARY KEY (user_id, document_id)
+		);
+		`
+	case "postgres":
+		query = `
+		ALTER TABLE document ADD COLUMN IF NOT EXISTS created_by varchar NOT NULL DEFAULT '';
+		ALTER TABLE document ADD COLUMN IF NOT EXISTS updated_by varchar NOT NULL DEFAULT '';
+		CREATE TABLE IF NOT EXISTS activity (
+			id uuid PRIMARY KEY,
+			type varchar NOT NULL,
+			user_id varchar NOT NULL,
+			space_id varchar NOT NULL,
+			document_id varchar NOT NULL,
+			document_name varchar NOT NULL,
+			data jsonb,
+			created_at timestamp NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_activity_space_created_at ON activity (space_id, created_at);
+		CREATE INDEX IF NOT EXISTS idx_activity_document_created_at ON activity (document_id, created_at);
+		CREATE TABLE IF NOT EXISTS recent_document (
+			user_id varchar NOT NULL,
+			document_id varchar NOT NULL,
+			viewed_at timestamp,
+			edited_at timestamp,
+			PRIMARY KEY (user_id, document_id)
+		);
+		`
+	case "mysql":
+		query = `
+		ALTER TABLE document ADD COLUMN created_by varchar(36) NOT NULL DEFAULT '';
+		ALTER TABLE document ADD COLUMN updated_by varchar(36) NOT NULL DEFAULT '';
+		CREATE TABLE IF NOT EXISTS activity (
+			id varchar(36) PRIMARY KEY,
+			type varchar(255) NOT NULL,
+			user_id varchar(36) NOT NULL,
+			space_id varchar(36) NOT NULL,
+			document_id varchar(36) NOT NULL,
+			document_name varchar(255) NOT NULL,
+			data json,
+			created_at datetime(3) NOT NULL,
+			KEY idx_activity_space_created_at (space_id, created_at),
+			KEY idx_activity_document_created_at (document_id, created_at)
+		) ` + mysqlTableOptions + `;
+		CREATE TABLE IF NOT EXISTS recent_document (
+			user_id varchar(36) NOT NULL,
+			document_id varchar(36) NOT NULL,
+			viewed_at datetime(3),
+			edited_at datetime(3),
+			PRIMARY KEY (user_id, document_id)
+		) ` + mysqlTableOptions + `;
+		`
+	default:
+		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
+	}
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downActivity(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+		DROP TABLE IF EXISTS recent_document;
+		DROP TABLE IF EXISTS activity;
+		ALTER TABLE document DROP COLUMN updated_by;
+		ALTER TABLE document DROP COLUMN created_by;
+	`)
+	return err
+}
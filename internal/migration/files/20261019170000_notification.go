@@ -0,0 +1,141 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upNotification, downNotification)
+}
+
+func upNotification(ctx context.Context, tx *sql.Tx) error {
+	var query string
+	switch config.Database.Dialect {
+	case "sqlite":
+		query = `
+		CREATE TABLE IF NOT EXISTS notification (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			actor_id TEXT NOT NULL DEFAULT '',
+			space_id TEXT NOT NULL DEFAULT '',
+			document_id TEXT NOT NULL DEFAULT '',
+			document_name TEXT NOT NULL DEFAULT '',
+			data JSONB,
+			count INTEGER NOT NULL DEFAULT 1,
+			read_at datetime,
+			emailed_at datetime,
+			created_at datetime NOT NULL,
+			updated_at datetime NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_notification_user_created_at ON notification (user_id, created_at);
+		CREATE TABLE IF NOT EXISTS subscription (
+			user_id TEXT NOT NULL,
+			document_id TEXT NOT NULL,
+			created_at datetime NOT NULL,
+			PRIMARY KEY (user_id, document_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_subscription_document_id ON subscription (document_id);
+		CREATE TABLE IF NOT EXISTS reminder (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			document_id TEXT NOT NULL,
+			note TEXT NOT NULL DEFAULT '',
+			remind_at datetime NOT NULL,
+			fired_at datetime,
+			created_at datetime NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_reminder_remind_at ON reminder (remind_at);
+		`
+	case "postgres":
+		query = `
+		CREATE TABLE IF NOT EXISTS notification (
+			id uuid PRIMARY KEY,
+			user_id varchar NOT NULL,
+			type varchar NOT NULL,
+			actor_id varchar NOT NULL DEFAULT '',
+			space_id varchar NOT NULL DEFAULT '',
+			document_id varchar NOT NULL DEFAULT '',
+			document_name varchar NOT NULL DEFAULT '',
+			data jsonb,
+			count integer NOT NULL DEFAULT 1,
+			read_at timestamp,
+			emailed_at timestamp,
+			created_at timestamp NOT NULL,
+			updated_at timestamp NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_notification_user_created_at ON notification (user_id, created_at);
+		CREATE TABLE IF NOT EXISTS subscription (
+			user_id varchar NOT NULL,
+			document_id varchar NOT NULL,
+			created_at timestamp NOT NULL,
+			PRIMARY KEY (user_id, document_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_subscription_document_id ON subscription (document_id);
+		CREATE TABLE IF NOT EXISTS reminder (
+			id uuid PRIMARY KEY,
+			user_id varchar NOT NULL,
+			document_id varchar NOT NULL,
+			note text NOT NULL DEFAULT '',
+			remind_at timestamp NOT NULL,
+			fired_at timestamp,
+			created_at timestamp NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_reminder_remind_at ON reminder (remind_at);
+		`
+	case "mysql":
+		query = `
+		CREATE TABLE IF NOT EXISTS notification (
+			id varchar(36) PRIMARY KEY,
+			user_id varchar(36) NOT NULL,
+			type varchar(255) NOT NULL,
+			actor_id varchar(36) NOT NULL DEFAULT '',
+			space_id varchar(36) NOT NULL DEFAULT '',
+			document_id varchar(36) NOT NULL DEFAULT '',
+			document_name varchar(255) NOT NULL DEFAULT '',
+			data json,
+			count integer NOT NULL DEFAULT 1,
+			read_at datetime(3),
+			emailed_at datetime(3),
+			created_at datetime(3) NOT NULL,
+			updated_at datetime(3) NOT NULL,
+			KEY idx_notification_user_created_at (user_id, created_at)
+		) ` + mysqlTableOptions + `;
+		CREATE TABLE IF NOT EXISTS subscription (
+			user_id varchar(36) NOT NULL,
+			document_id varchar(36) NOT NULL,
+			created_at datetime(3) NOT NULL,
+			PRIMARY KEY (user_id, document_id),
+			KEY idx_subscription_document_id (document_id)
+		) ` + mysqlTableOptions + `;
+		CREATE TABLE IF NOT EXISTS reminder (
+			id varchar(36) PRIMARY KEY,
+			user_id varchar(36) NOT NULL,
+			document_id varchar(36) NOT NULL,
+			note text NOT NULL,
+			remind_at datetime(3) NOT NULL,
+			fired_at datetime(3),
+			created_at datetime(3) NOT NULL,
+			KEY idx_reminder_remind_at (remind_at)
+		) ` + mysqlTableOptions + `;
+		`
+	default:
+		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
+	}
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downNotification(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+		DROP TABLE IF EXISTS reminder;
+		DROP TABLE IF EXISTS subscription;
+		DROP TABLE IF EXISTS notification;
+	`)
+	return err
+}
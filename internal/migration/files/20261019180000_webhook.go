@@ -0,0 +1,152 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upWebhook, downWebhook)
+}
+
+func upWebhook(ctx context.Context, tx *sql.Tx) error {
+	var query string
+	switch config.Database.Dialect {
+	case "sqlite":
+		query = `
+		CREATE TABLE IF NOT EXISTS webhook (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			url TEXT NOT NULL,
+			space_id TEXT NOT NULL DEFAULT '',
+			events JSONB NOT NULL DEFAULT '[]',
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			secret TEXT NOT NULL,
+			failure_count INTEGER NOT NULL DEFAULT 0,
+			disabled_at datetime,
+			created_by TEXT NOT NULL DEFAULT '',
+			created_at datetime NOT NULL,
+			updated_at datetime NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_webhook_space_id ON webhook (space_id);
+		CREATE TABLE IF NOT EXISTS webhook_delivery (
+			id TEXT PRIMARY KEY,
+			webhook_id TEXT NOT NULL,
+			event_id TEXT NOT NULL,
+			event_type TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			dedup_key TEXT NOT NULL,
+			redelivery_of TEXT NOT NULL DEFAULT '',
+			response_code INTEGER NOT NULL DEFAULT 0,
+			response_body TEXT NOT NULL DEFAULT '',
+			error TEXT NOT NULL DEFAULT '',
+			duration_ms INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at datetime,
+			delivered_at datetime,
+			created_at datetime NOT NULL,
+			updated_at datetime NOT NULL
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_delivery_dedup_key ON webhook_delivery (dedup_key);
+		CREATE INDEX IF NOT EXISTS idx_webhook_delivery_webhook_created_at ON webhook_delivery (webhook_id, created_at);
+		CREATE INDEX IF NOT EXISTS idx_webhook_delivery_status_next_attempt_at ON webhook_delivery (status, next_attempt_at);
+		`
+	case "postgres":
+		query = `
+		CREATE TABLE IF NOT EXISTS webhook (
+			id uuid PRIMARY KEY,
+			name varchar NOT NULL,
+			url varchar NOT NULL,
+			space_id varchar NOT NULL DEFAULT '',
+			events jsonb NOT NULL DEFAULT '[]',
+			active boolean NOT NULL DEFAULT TRUE,
+			secret varchar NOT NULL,
+			failure_count integer NOT NULL DEFAULT 0,
+			disabled_at timestamp,
+			created_by varchar NOT NULL DEFAULT '',
+			created_at timestamp NOT NULL,
+			updated_at timestamp NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_webhook_space_id ON webhook (space_id);
+		CREATE TABLE IF NOT EXISTS webhook_delivery (
+			id uuid PRIMARY KEY,
+			webhook_id varchar NOT NULL,
+			event_id varchar NOT NULL,
+			event_type varchar NOT NULL,
+			payload text NOT NULL,
+			status varchar NOT NULL,
+			attempts integer NOT NULL DEFAULT 0,
+			dedup_key varchar NOT NULL,
+			redelivery_of varchar NOT NULL DEFAULT '',
+			response_code integer NOT NULL DEFAULT 0,
+			response_body text NOT NULL DEFAULT '',
+			error text NOT NULL DEFAULT '',
+			duration_ms bigint NOT NULL DEFAULT 0,
+			next_attempt_at timestamp,
+			delivered_at timestamp,
+			created_at timestamp NOT NULL,
+			updated_at timestamp NOT NULL
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_delivery_dedup_key ON webhook_delivery (dedup_key);
+		CREATE INDEX IF NOT EXISTS idx_webhook_delivery_webhook_created_at ON webhook_delivery (webhook_id, created_at);
+		CREATE INDEX IF NOT EXISTS idx_webhook_delivery_status_next_attempt_at ON webhook_delivery (status, next_attempt_at);
+		`
+	case "mysql":
+		query = `
+		CREATE TABLE IF NOT EXISTS webhook (
+			id varchar(36) PRIMARY KEY,
+			name varchar(255) NOT NULL,
+			url text NOT NULL,
+			space_id varchar(36) NOT NULL DEFAULT '',
+			events json NOT NULL,
+			active boolean NOT NULL DEFAULT TRUE,
+			secret varchar(255) NOT NULL,
+			failure_count integer NOT NULL DEFAULT 0,
+			disabled_at datetime(3),
+			created_by varchar(36) NOT NULL DEFAULT '',
+			created_at datetime(3) NOT NULL,
+			updated_at datetime(3) NOT NULL,
+			KEY idx_webhook_space_id (space_id)
+		) ` + mysqlTableOptions + `;
+		CREATE TABLE IF NOT EXISTS webhook_delivery (
+			id varchar(36) PRIMARY KEY,
+			webhook_id varchar(36) NOT NULL,
+			event_id varchar(36) NOT NULL,
+			event_type varchar(255) NOT NULL,
+			payload mediumtext NOT NULL,
+			status varchar(32) NOT NULL,
+			attempts integer NOT NULL DEFAULT 0,
+			dedup_key varchar(255) NOT NULL,
+			redelivery_of varchar(36) NOT NULL DEFAULT '',
+			response_code integer NOT NULL DEFAULT 0,
+			response_body text NOT NULL,
+			error text NOT NULL,
+			duration_ms bigint NOT NULL DEFAULT 0,
+			next_attempt_at datetime(3),
+			delivered_at datetime(3),
+			created_at datetime(3) NOT NULL,
+			updated_at datetime(3) NOT NULL,
+			UNIQUE KEY idx_webhook_delivery_dedup_key (dedup_key),
+			KEY idx_webhook_delivery_webhook_created_at (webhook_id, created_at),
+			KEY idx_webhook_delivery_status_next_attempt_at (status, next_attempt_at)
+		) ` + mysqlTableOptions + `;
+		`
+	default:
+		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
+	}
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downWebhook(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+		DROP TABLE IF EXISTS webhook_delivery;
+		DROP TABLE IF EXISTS webhook;
+	`)
+	return err
+}
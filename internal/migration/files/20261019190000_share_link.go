@@ -0,0 +1,92 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upShareLink, downShareLink)
+}
+
+func upShareLink(ctx context.Context, tx *sql.Tx) error {
+	var query string
+	switch config.Database.Dialect {
+	case "sqlite":
+		query = `
+		CREATE TABLE IF NOT EXISTS share_link (
+			id TEXT PRIMARY KEY,
+			token TEXT NOT NULL,
+			document_id TEXT NOT NULL,
+			space_id TEXT NOT NULL,
+			access TEXT NOT NULL,
+			include_subpages BOOLEAN NOT NULL DEFAULT FALSE,
+			password_hash TEXT NOT NULL DEFAULT '',
+			expires_at datetime,
+			view_count INTEGER NOT NULL DEFAULT 0,
+			last_viewed_at datetime,
+			revoked_at datetime,
+			created_by TEXT NOT NULL DEFAULT '',
+			created_at datetime NOT NULL,
+			updated_at datetime NOT NULL
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_share_link_token ON share_link (token);
+		CREATE INDEX IF NOT EXISTS idx_share_link_document_id ON share_link (document_id);
+		`
+	case "postgres":
+		query = `
+		CREATE TABLE IF NOT EXISTS share_link (
+			id uuid PRIMARY KEY,
+			token varchar NOT NULL,
+			document_id varchar NOT NULL,
+			space_id varchar NOT NULL,
+			access varchar NOT NULL,
+			include_subpages boolean NOT NULL DEFAULT FALSE,
+			password_hash varchar NOT NULL DEFAULT '',
+			expires_at timestamp,
+			view_count bigint NOT NULL DEFAULT 0,
+			last_viewed_at timestamp,
+			revoked_at timestamp,
+			created_by varchar NOT NULL DEFAULT '',
+			created_at timestamp NOT NULL,
+			updated_at timestamp NOT NULL
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_share_link_token ON share_link (token);
+		CREATE INDEX IF NOT EXISTS idx_share_link_document_id ON share_link (document_id);
+		`
+	case "mysql":
+		query = `
+		CREATE TABLE IF NOT EXISTS share_link (
+			id varchar(36) PRIMARY KEY,
+			token varchar(255) NOT NULL,
+			document_id varchar(36) NOT NULL,
+			space_id varchar(36) NOT NULL,
+			access varchar(32) NOT NULL,
+			include_subpages boolean NOT NULL DEFAULT FALSE,
+			password_hash varchar(255) NOT NULL DEFAULT '',
+			expires_at datetime(3),
+			view_count bigint NOT NULL DEFAULT 0,
+			last_viewed_at datetime(3),
+			revoked_at datetime(3),
+			created_by varchar(36) NOT NULL DEFAULT '',
+			created_at datetime(3) NOT NULL,
+			updated_at datetime(3) NOT NULL,
+			UNIQUE KEY idx_share_link_token (token),
+			KEY idx_share_link_document_id (document_id)
+		) ` + mysqlTableOptions + `;
+		`
+	default:
+		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
+	}
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downShareLink(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE IF EXISTS share_link;`)
+	return err
+}
@@ -0,0 +1,89 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upSiteExport, downSiteExport)
+}
+
+func upSiteExport(ctx context.Context, tx *sql.Tx) error {
+	var query string
+	switch config.Database.Dialect {
+	case "sqlite":
+		query = `
+		CREATE TABLE IF NOT EXISTS site_export (
+			id TEXT PRIMARY KEY,
+			space_id TEXT NOT NULL,
+			status TEXT NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			pages INTEGER NOT NULL DEFAULT 0,
+			attachments INTEGER NOT NULL DEFAULT 0,
+			size INTEGER NOT NULL DEFAULT 0,
+			storage_key TEXT NOT NULL DEFAULT '',
+			created_by TEXT NOT NULL DEFAULT '',
+			started_at datetime,
+			completed_at datetime,
+			created_at datetime NOT NULL,
+			updated_at datetime NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_site_export_space_id ON site_export (space_id);
+		CREATE INDEX IF NOT EXISTS idx_site_export_status ON site_export (status);
+		`
+	case "postgres":
+		query = `
+		CREATE TABLE IF NOT EXISTS site_export (
+			id uuid PRIMARY KEY,
+			space_id varchar NOT NULL,
+			status varchar NOT NULL,
+			error text NOT NULL DEFAULT '',
+			pages bigint NOT NULL DEFAULT 0,
+			attachments bigint NOT NULL DEFAULT 0,
+			size bigint NOT NULL DEFAULT 0,
+			storage_key varchar NOT NULL DEFAULT '',
+			created_by varchar NOT NULL DEFAULT '',
+			started_at timestamp,
+			completed_at timestamp,
+			created_at timestamp NOT NULL,
+			updated_at timestamp NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_site_export_space_id ON site_export (space_id);
+		CREATE INDEX IF NOT EXISTS idx_site_export_status ON site_export (status);
+		`
+	case "mysql":
+		query = `
+		CREATE TABLE IF NOT EXISTS site_export (
+			id varchar(36) PRIMARY KEY,
+			space_id varchar(36) NOT NULL,
+			status varchar(32) NOT NULL,
+			error text NOT NULL,
+			pages bigint NOT NULL DEFAULT 0,
+			attachments bigint NOT NULL DEFAULT 0,
+			size bigint NOT NULL DEFAULT 0,
+			storage_key varchar(255) NOT NULL DEFAULT '',
+			created_by varchar(36) NOT NULL DEFAULT '',
+			started_at datetime(3),
+			completed_at datetime(3),
+			created_at datetime(3) NOT NULL,
+			updated_at datetime(3) NOT NULL,
+			KEY idx_site_export_space_id (space_id),
+			KEY idx_site_export_status (status)
+		) ` + mysqlTableOptions + `;
+		`
+	default:
+		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
+	}
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downSiteExport(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `DROP TABLE IF EXISTS site_export;`)
+	return err
+}
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upDocumentInheritance, downDocumentInheritance)
+}
+
+func upDocumentInheritance(ctx context.Context, tx *sql.Tx) error {
+	var query string
+	switch config.Database.Dialect {
+	case "sqlite":
+		query = `ALTER TABLE document ADD COLUMN inheritance_broken BOOLEAN NOT NULL DEFAULT FALSE;`
+	case "postgres":
+		query = `ALTER TABLE document ADD COLUMN IF NOT EXISTS inheritance_broken boolean NOT NULL DEFAULT false;`
+	case "mysql":
+		query = `ALTER TABLE document ADD COLUMN inheritance_broken boolean NOT NULL DEFAULT false;`
+	default:
+		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
+	}
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+func downDocumentInheritance(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE document DROP COLUMN inheritance_broken`)
+	return err
+}
@@ -0,0 +1,217 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/goccy/go-json"
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upMemberTables, downMemberTables)
+}
+
+// memberOwners are the tables with members, the column of their member table referencing them and
+// the sqlite type of their former members column
+var memberOwners = []struct {
+	table        string
+	column       string
+	sqliteColumn string
+}{
+	{"space", "space_id", "TEXT"},
+	{"document", "document_id", "JSONB"},
+}
+
+// storedMember is a member as it was serialized in the members column
+type storedMember struct {
+	Id     string `json:"id"`
+	Type   string `json:"type"`
+	Access string `json:"access"`
+}
+
+func upMemberTables(ctx context.Context, tx *sql.Tx) error {
+	var query string
+	switch config.Database.Dialect {
+	case "sqlite":
+		query = `
+		CREATE TABLE IF NOT EXISTS space_member (
+			space_id TEXT NOT NULL,
+			member_type TEXT NOT NULL,
+			member_id TEXT NOT NULL,
+			access TEXT NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			created_at datetime NOT NULL,
+			PRIMARY KEY (space_id, member_type, member_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_space_member_member ON space_member (member_type, member_id);
+		CREATE TABLE IF NOT EXISTS document_member (
+			document_id TEXT NOT NULL,
+			member_type TEXT NOT NULL,
+			member_id TEXT NOT NULL,
+			access TEXT NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			created_at datetime NOT NULL,
+			PRIMARY KEY (document_id, member_type, member_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_document_member_member ON document_member (member_type, member_id);
+		`
+	case "postgres":
+		query = `
+		CREATE TABLE IF NOT EXISTS space_member (
+			space_id uuid NOT NULL,
+			member_type varchar NOT NULL,
+			member_id varchar NOT NULL,
+			access varchar NOT NULL,
+			position integer NOT NULL DEFAULT 0,
+			created_at timestamp NOT NULL,
+			PRIMARY KEY (space_id, member_type, member_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_space_member_member ON space_member (member_type, member_id);
+		CREATE TABLE IF NOT EXISTS document_member (
+			document_id uuid NOT NULL,
+			member_type varchar NOT NULL,
+			member_id varchar NOT NULL,
+			access varchar NOT NULL,
+			position integer NOT NULL DEFAULT 0,
+			created_at timestamp NOT NULL,
+			PRIMARY KEY (document_id, member_type, member_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_document_member_member ON document_member (member_type, member_id);
+		`
+	case "mysql":
+		query = `
+		CREATE TABLE IF NOT EXISTS space_member (
+			space_id varchar(36) NOT NULL,
+			member_type varchar(32) NOT NULL,
+			member_id varchar(36) NOT NULL,
+			access varchar(32) NOT NULL,
+			position integer NOT NULL DEFAULT 0,
+			created_at datetime(3) NOT NULL,
+			PRIMARY KEY (space_id, member_type, member_id),
+			KEY idx_space_member_member (member_type, member_id)
+		) ` + mysqlTableOptions + `;
+		CREATE TABLE IF NOT EXISTS document_member (
+			document_id varchar(36) NOT NULL,
+			member_type varchar(32) NOT NULL,
+			member_id varchar(36) NOT NULL,
+			access varchar(32) NOT NULL,
+			position integer NOT NULL DEFAULT 0,
+			created_at datetime(3) NOT NULL,
+			PRIMARY KEY (document_id, member_type, member_id),
+			KEY idx_document_member_member (member_type, member_id)
+		) ` + mysqlTableOptions + `;
+		`
+	default:
+		return fmt.Errorf("unsupported dialect: %s", config.Database.Dialect)
+	}
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	// the serialized members are moved to the member tables, then the members columns are dropped
+	for _, owner := range memberOwners {
+		members, err := readStoredMembers(ctx, tx, owner.table)
+		if err != nil {
+			return err
+		}
+		insert := bindParams(fmt.Sprintf(`INSERT INTO %s_member (%s, member_type, member_id, access, position, created_at)
+			VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP) ON CONFLICT DO NOTHING`, owner.table, owner.column))
+		if config.Database.Dialect == "mysql" {
+			insert = fmt.Sprintf(`INSERT IGNORE INTO %s_member (%s, member_type, member_id, access, position, created_at)
+			VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`, owner.table, owner.column)
+		}
+		for id, ownerMembers := range members {
+			for position, member := range ownerMembers {
+				if member.Id == "" || member.Type == "" {
+					continue
+				}
+				if _, err := tx.ExecContext(ctx, insert, id, member.Type, member.Id, member.Access, position); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s DROP COLUMN members`, owner.table)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func downMemberTables(ctx context.Context, tx *sql.Tx) error {
+	// the members are serialized back in the members columns before the member tables are dropped
+	for _, owner := range memberOwners {
+		columnType := "jsonb"
+		switch config.Database.Dialect {
+		case "sqlite":
+			columnType = owner.sqliteColumn
+		case "mysql":
+			columnType = "json"
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s ADD COLUMN members %s`, owner.table, columnType)); err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, fmt.Sprintf(`SELECT %s, member_type, member_id, access FROM %s_member ORDER BY %s, position`, owner.column, owner.table, owner.column))
+		if err != nil {
+			return err
+		}
+		members := map[string][]storedMember{}
+		for rows.Next() {
+			var id string
+			var member storedMember
+			if err := rows.Scan(&id, &member.Type, &member.Id, &member.Access); err != nil {
+				rows.Close()
+				return err
+			}
+			members[id] = append(members[id], member)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		update := bindParams(fmt.Sprintf(`UPDATE %s SET members = ? WHERE id = ?`, owner.table))
+		for id, ownerMembers := range members {
+			value, err := json.Marshal(ownerMembers)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, update, string(value), id); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := tx.ExecContext(ctx, `DROP TABLE IF EXISTS space_member; DROP TABLE IF EXISTS document_member;`)
+	return err
+}
+
+// readStoredMembers returns the serialized members of the rows of the table by id
+func readStoredMembers(ctx context.Context, tx *sql.Tx, table string) (map[string][]storedMember, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`SELECT id, members FROM %s WHERE members IS NOT NULL`, table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := map[string][]storedMember{}
+	for rows.Next() {
+		var id string
+		var value []byte
+		if err := rows.Scan(&id, &value); err != nil {
+			return nil, err
+		}
+		var stored []storedMember
+		if len(value) == 0 || string(value) == "null" {
+			continue
+		}
+		if err := json.Unmarshal(value, &stored); err != nil {
+			return nil, fmt.Errorf("invalid members of %s %s: %w", table, id, err)
+		}
+		members[id] = stored
+	}
+	return members, rows.Err()
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/labbs/zotion/pkg/config"
+)
+
+// mysqlTableOptions are the options of the mysql tables, the binary collation compares the texts
+// with their case like sqlite and postgres
+const mysqlTableOptions = "ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin"
+
+// bindParams replaces the ? placeholders of the query with the placeholders of the dialect
+func bindParams(query string) string {
+	if config.Database.Dialect != "postgres" {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -1,7 +1,11 @@
 package migration
 
 import (
+	"context"
 	"embed"
+	"fmt"
+	"io/fs"
+	"path"
 
 	"github.com/labbs/zotion/internal/logger/zerolog"
 
@@ -42,3 +46,115 @@ func RunMigration(l z.Logger, db *gorm.DB) error {
 
 	return nil
 }
+
+// newProvider returns the goose provider of the embedded migrations for the database, the go migrations
+// are registered globally by the files package
+func newProvider(l z.Logger, db *gorm.DB) (*goose.Provider, error) {
+	var dialect goose.Dialect
+	switch db.Dialector.Name() {
+	case "sqlite":
+		dialect = goose.DialectSQLite3
+	case "postgres":
+		dialect = goose.DialectPostgres
+	case "mysql":
+		dialect = goose.DialectMySQL
+	default:
+		return nil, fmt.Errorf("unsupported dialect: %s", db.Dialector.Name())
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		l.Error().Err(err).Msg("Failed to get sql db")
+		return nil, err
+	}
+
+	fsys, err := fs.Sub(migrationFiles, "files")
+	if err != nil {
+		return nil, err
+	}
+
+	return goose.NewProvider(dialect, sqlDB, fsys)
+}
+
+// Status returns the state of each migration, applied or pending
+func Status(l z.Logger, db *gorm.DB) ([]*goose.MigrationStatus, error) {
+	provider, err := newProvider(l, db)
+	if err != nil {
+		return nil, err
+	}
+	return provider.Status(context.Background())
+}
+
+// Down rolls back the last applied migration
+func Down(l z.Logger, db *gorm.DB) error {
+	logger := l.With().Str("event", "migration").Logger()
+
+	provider, err := newProvider(logger, db)
+	if err != nil {
+		return err
+	}
+
+	result, err := provider.Down(context.Background())
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to roll back the migration")
+		return err
+	}
+	logResult(logger, result)
+	return nil
+}
+
+// DownTo rolls back the applied migrations down to the version, the version itself stays applied.
+// The version 0 rolls back all the migrations.
+func DownTo(l z.Logger, db *gorm.DB, version int64) error {
+	logger := l.With().Str("event", "migration").Logger()
+
+	provider, err := newProvider(logger, db)
+	if err != nil {
+		return err
+	}
+
+	results, err := provider.DownTo(context.Background(), version)
+	if err != nil {
+		logger.Error().Err(err).Int64("version", version).Msg("Failed to roll back the migrations")
+		return err
+	}
+	for _, result := range results {
+		logResult(logger, result)
+	}
+	return nil
+}
+
+// Redo rolls back the last applied migration and applies it again
+func Redo(l z.Logger, db *gorm.DB) error {
+	logger := l.With().Str("event", "migration").Logger()
+
+	provider, err := newProvider(logger, db)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	result, err := provider.Down(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to roll back the migration")
+		return err
+	}
+	logResult(logger, result)
+
+	result, err = provider.ApplyVersion(ctx, result.Source.Version, true)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to apply the migration again")
+		return err
+	}
+	logResult(logger, result)
+	return nil
+}
+
+func logResult(l z.Logger, result *goose.MigrationResult) {
+	l.Info().
+		Int64("migration", result.Source.Version).
+		Str("source", path.Base(result.Source.Path)).
+		Str("direction", result.Direction).
+		Dur("duration", result.Duration).
+		Msg("Migration applied")
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/labbs/zotion/internal/tokenutil"
 	"github.com/labbs/zotion/pkg/config"
+	"github.com/labbs/zotion/pkg/events"
 	"github.com/labbs/zotion/pkg/models"
 	"github.com/rs/zerolog"
 )
@@ -13,6 +14,7 @@ import (
 type AuthController struct {
 	AuthService    models.AuthService
 	SessionService models.SessionService
+	Events         events.Bus
 	Logger         zerolog.Logger
 }
 
@@ -106,12 +108,23 @@ func (ac *AuthController) Register(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	_, err := ac.AuthService.Register(registerRequest)
+	response, err := ac.AuthService.Register(registerRequest)
 	if err != nil {
 		logger.Error().Err(err).Msg("Error registering user")
 		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "User already exists"})
 	}
 
+	if ac.Events != nil {
+		event := events.Event{
+			Type:    events.UserCreated,
+			ActorId: response.UserId,
+			Data:    fiber.Map{"id": response.UserId, "name": registerRequest.Name, "email": registerRequest.Email},
+		}
+		if err := ac.Events.Publish(event); err != nil {
+			logger.Warn().Err(err).Str("user", response.UserId).Msg("Error publishing the event")
+		}
+	}
+
 	logger.Info().Str("user", registerRequest.Email).Msg("User registered successfully")
 	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "User registered successfully"})
 }
@@ -40,6 +40,11 @@ func (c *Config) Check() fiber.Handler {
 			}
 		}
 
+		// the guests only access the documents they are invited to
+		if models.IsGuest(groups) {
+			ctx.Context().SetUserValue("is_guest", true)
+		}
+
 		// check if the path
 		if strings.HasPrefix(ctx.Path(), "/api/v1/admin") && !isAdmin {
 			_logger.Error().Str("event", "middleware.rbac_check_middleware.not_authorized").Msg("User is not authorized to access admin routes")
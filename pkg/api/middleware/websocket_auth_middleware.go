@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/internal/tokenutil"
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/rs/zerolog"
+)
+
+// WebsocketAuthMiddleware authenticates websocket upgrades with the session JWT.
+// Browsers can't set the Authorization header on a websocket, so the token is also accepted
+// in the "token" query parameter.
+func WebsocketAuthMiddleware(logger zerolog.Logger, sessionService models.SessionService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		_logger := logger.With().Str("request_id", fmt.Sprintf("%v", c.Locals("requestid"))).Logger()
+
+		if !websocket.IsWebSocketUpgrade(c) {
+			_logger.Error().Str("event", "middleware.websocket_auth_middleware.upgrade_required").Msg("Websocket upgrade required")
+			return c.Status(fiber.StatusUpgradeRequired).JSON(fiber.Map{
+				"message": "Websocket upgrade required",
+			})
+		}
+
+		return queryTokenAuth(c, _logger, sessionService, "middleware.websocket_auth_middleware")
+	}
+}
+
+// EventStreamAuthMiddleware authenticates the server-sent event streams with the session JWT.
+// Like the websockets, the EventSource of the browsers can't set the Authorization header,
+// so the token is also accepted in the "token" query parameter.
+func EventStreamAuthMiddleware(logger zerolog.Logger, sessionService models.SessionService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		_logger := logger.With().Str("request_id", fmt.Sprintf("%v", c.Locals("requestid"))).Logger()
+		return queryTokenAuth(c, _logger, sessionService, "middleware.event_stream_auth_middleware")
+	}
+}
+
+// queryTokenAuth authenticates the request with the token of the query or of the Authorization header
+func queryTokenAuth(c *fiber.Ctx, _logger zerolog.Logger, sessionService models.SessionService, event string) error {
+	token := c.Query("token")
+	if token == "" {
+		t := strings.Split(c.Get("Authorization"), " ")
+		if len(t) == 2 {
+			token = t[1]
+		}
+	}
+
+	if token == "" {
+		_logger.Error().Str("event", event+".missing_token").Msg("Missing authorization token")
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Missing authorization token",
+		})
+	}
+
+	if authorized, err := tokenutil.IsAuthorized(token); !authorized {
+		_logger.Error().Err(err).Str("event", event+".is_authorized").Msg("Error checking if token is authorized")
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid authorization token",
+		})
+	}
+
+	userId, sessionId, err := tokenutil.GetSessionInformationFromToken(token)
+	if err != nil {
+		_logger.Error().Err(err).Str("event", event+".get_session_id_from_token").Msg("Error getting session id from token")
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid authorization token",
+		})
+	}
+
+	session, err := sessionService.GetById(sessionId)
+	if err != nil || session.UserId != userId {
+		_logger.Error().Err(err).Str("event", event+".invalid_session").Msg("Invalid session")
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid authorization token",
+		})
+	}
+
+	c.Context().SetUserValue("session_id", sessionId)
+	c.Context().SetUserValue("user_id", userId)
+	return c.Next()
+}
@@ -2,7 +2,9 @@ package router
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/api/middleware"
 	"github.com/labbs/zotion/pkg/api/v1/controller"
+	appConfig "github.com/labbs/zotion/pkg/config"
 	"github.com/labbs/zotion/pkg/repository"
 	"github.com/labbs/zotion/pkg/service"
 )
@@ -16,18 +18,27 @@ func NewAdminRouter(config *Config, rbacMiddleware fiber.Handler) {
 	gr := repository.NewGroupRepository(config.Db)
 	sr := repository.NewSpaceRepository(config.Db)
 	dr := repository.NewDocumentRepository(config.Db)
+	ar := repository.NewAttachmentRepository(config.Db)
 
 	// initialize the user repository with the database connection
 	c := controller.AdminController{
-		UserService:     service.NewUserService(ur),
-		GroupService:    service.NewGroupService(gr),
-		SpaceService:    service.NewSpaceService(sr),
-		DocumentService: service.NewDocumentService(dr),
-		Logger:          config.Logger,
+		UserService:         service.NewUserService(ur),
+		GroupService:        service.NewGroupService(gr),
+		SpaceService:        service.NewSpaceService(sr),
+		DocumentService:     service.NewDocumentService(dr),
+		AttachmentService:   service.NewAttachmentService(ar, config.Storage, config.Thumbnails, int64(appConfig.Attachment.MaxSize)*1024*1024, appConfig.Attachment.AllowedTypes.Value()),
+		ActivityService:     service.NewActivityService(repository.NewActivityRepository(config.Db), sr, ur, dr),
+		NotificationService: config.NotificationService(),
+		GuestService:        service.NewGuestService(gr, dr, sr),
+		Events:              config.Events,
+		Logger:              config.Logger,
 	}
 
-	v1Admin := config.Fiber.Group(ApiV1Path+"/admin", rbacMiddleware)
+	v1Admin := config.Fiber.Group(ApiV1Path+"/admin", middleware.JwtAuthMiddleware(config.Logger, service.NewSessionService(repository.NewSessionRepository(config.Db))), rbacMiddleware)
 	v1Admin.Get("/users", c.GetUsers)
 	v1Admin.Get("/groups", c.GetGroups)
 	v1Admin.Get("/spaces", c.GetSpaces)
+	v1Admin.Get("/guests", c.GetGuests)
+	v1Admin.Delete("/documents/:documentId", c.PurgeDocument)
+	v1Admin.Post("/documents/:documentId/restore", c.RestoreDocument)
 }
@@ -0,0 +1,33 @@
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/api/middleware"
+	"github.com/labbs/zotion/pkg/api/v1/controller"
+	appConfig "github.com/labbs/zotion/pkg/config"
+	"github.com/labbs/zotion/pkg/repository"
+	"github.com/labbs/zotion/pkg/service"
+)
+
+func NewAttachmentRouter(config *Config, rbacMiddleware fiber.Handler) {
+	// Set up the attachment routes
+	config.Logger.Info().Msg("Setting up attachment routes")
+
+	// initialize the repositories
+	ar := repository.NewAttachmentRepository(config.Db)
+	dr := repository.NewDocumentRepository(config.Db)
+	sr := repository.NewSpaceRepository(config.Db)
+	ur := repository.NewUserRepository(config.Db)
+
+	c := controller.AttachmentController{
+		AttachmentService: service.NewAttachmentService(ar, config.Storage, config.Thumbnails, int64(appConfig.Attachment.MaxSize)*1024*1024, appConfig.Attachment.AllowedTypes.Value()),
+		AccessService:     service.NewAccessService(ur, sr, dr),
+		Logger:            config.Logger,
+	}
+
+	v1Attachment := config.Fiber.Group(ApiV1Path+"/attachment", middleware.JwtAuthMiddleware(config.Logger, service.NewSessionService(repository.NewSessionRepository(config.Db))), rbacMiddleware)
+	v1Attachment.Get("/document/:documentId", c.GetDocumentAttachments)
+	v1Attachment.Post("/document/:documentId", c.UploadAttachment)
+	v1Attachment.Get("/:attachmentId", c.GetAttachment)
+	v1Attachment.Delete("/:attachmentId", c.DeleteAttachment)
+}
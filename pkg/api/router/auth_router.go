@@ -28,6 +28,7 @@ func NewAuthRouter(config *Config, rbacMiddleware fiber.Handler) {
 	c := controller.AuthController{
 		AuthService:    service.NewAuthService(ur, ssr, dr),
 		SessionService: service.NewSessionService(sr),
+		Events:         config.Events,
 		Logger:         config.Logger,
 	}
 
@@ -0,0 +1,51 @@
+package router
+
+import (
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/labbs/zotion/pkg/api/middleware"
+	"github.com/labbs/zotion/pkg/api/v1/controller"
+	"github.com/labbs/zotion/pkg/collaboration"
+	appConfig "github.com/labbs/zotion/pkg/config"
+	"github.com/labbs/zotion/pkg/repository"
+	"github.com/labbs/zotion/pkg/service"
+)
+
+func NewCollaborationRouter(config *Config) {
+	if !appConfig.Collaboration.Enabled {
+		config.Logger.Info().Msg("Collaboration is disabled")
+		return
+	}
+
+	// Set up the collaboration routes
+	config.Logger.Info().Msg("Setting up collaboration routes")
+
+	// initialize the repositories
+	dr := repository.NewDocumentRepository(config.Db)
+	dsr := repository.NewDocumentStateRepository(config.Db)
+	sr := repository.NewSpaceRepository(config.Db)
+	ur := repository.NewUserRepository(config.Db)
+
+	// initialize the broker used to share the updates between instances
+	var broker collaboration.Broker
+	switch appConfig.Collaboration.Broker {
+	case "redis":
+		broker = collaboration.NewRedisBroker(config.Logger, appConfig.Cache.Redis.Addr, appConfig.Cache.Redis.Password, appConfig.Cache.Redis.DB)
+	default:
+		broker = collaboration.NewLocalBroker()
+	}
+
+	as := service.NewAccessService(ur, sr, dr)
+	config.Hub = collaboration.NewHub(config.Logger, broker, service.NewDocumentService(dr), service.NewDocumentStateService(dsr), as, time.Duration(appConfig.Collaboration.PersistInterval)*time.Second)
+
+	c := controller.CollaborationController{
+		AccessService: as,
+		Hub:           config.Hub,
+		Logger:        config.Logger,
+	}
+
+	// The websocket can't send the Authorization header, the rbac middleware is not used
+	v1Collaboration := config.Fiber.Group(ApiV1Path+"/collaboration", middleware.WebsocketAuthMiddleware(config.Logger, service.NewSessionService(repository.NewSessionRepository(config.Db))))
+	v1Collaboration.Get("/:documentId", c.Authorize, websocket.New(c.Connect))
+}
@@ -1,3 +1,11 @@
 package router
 
 const ApiV1Path string = "/api/v1"
+
+// WsV1Path is the prefix of the websocket routes, they use a dedicated
+// authentication middleware because browsers can't set the Authorization header
+const WsV1Path string = ApiV1Path + "/ws"
+
+// AttachmentUploadPath is the prefix of the attachment uploads, the only route accepting bodies
+// larger than the default limit
+const AttachmentUploadPath string = ApiV1Path + "/attachment/document/"
@@ -0,0 +1,36 @@
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/api/middleware"
+	"github.com/labbs/zotion/pkg/api/v1/controller"
+	"github.com/labbs/zotion/pkg/repository"
+	"github.com/labbs/zotion/pkg/service"
+)
+
+func NewDatabaseRouter(config *Config, rbacMiddleware fiber.Handler) {
+	// Set up the database routes
+	config.Logger.Info().Msg("Setting up database routes")
+
+	// initialize the repositories
+	dr := repository.NewDocumentRepository(config.Db)
+	sr := repository.NewSpaceRepository(config.Db)
+	ur := repository.NewUserRepository(config.Db)
+
+	c := controller.DatabaseController{
+		DatabaseService: service.NewDatabaseService(dr, repository.NewDatabaseViewRepository(config.Db), ur),
+		AccessService:   service.NewAccessService(ur, sr, dr),
+		Logger:          config.Logger,
+	}
+
+	v1Database := config.Fiber.Group(ApiV1Path+"/database", middleware.JwtAuthMiddleware(config.Logger, service.NewSessionService(repository.NewSessionRepository(config.Db))), rbacMiddleware)
+	v1Database.Get("/:databaseId/schema", c.GetSchema)
+	v1Database.Put("/:databaseId/schema", c.UpdateSchema)
+	v1Database.Post("/:databaseId/query", c.QueryDatabase)
+	v1Database.Post("/:databaseId/import", c.ImportCSV)
+	v1Database.Get("/:databaseId/export", c.ExportCSV)
+	v1Database.Get("/:databaseId/views", c.GetViews)
+	v1Database.Post("/:databaseId/views", c.CreateView)
+	v1Database.Put("/:databaseId/views/:viewId", c.UpdateView)
+	v1Database.Delete("/:databaseId/views/:viewId", c.DeleteView)
+}
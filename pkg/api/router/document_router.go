@@ -18,11 +18,23 @@ func NewDocumentRouter(config *Config, rbacMiddleware fiber.Handler) {
 	// initialize the favorite repository with the database connection
 	fr := repository.NewFavoriteRepository(config.Db)
 
+	// initialize the user and space repositories used to check the access
+	ur := repository.NewUserRepository(config.Db)
+	sr := repository.NewSpaceRepository(config.Db)
+
 	// initialize the user repository with the database connection
 	c := controller.DocumentController{
 		DocumentService: service.NewDocumentService(dr),
 		FavoriteService: service.NewFavoriteService(fr),
-		Logger:          config.Logger,
+		AccessService:   service.NewAccessService(ur, sr, dr),
+		DatabaseService: service.NewDatabaseService(dr, repository.NewDatabaseViewRepository(config.Db), ur),
+		ActivityService: service.NewActivityService(repository.NewActivityRepository(config.Db), sr, ur, dr),
+		GuestService:    service.NewGuestService(repository.NewGroupRepository(config.Db), dr, sr),
+
+		NotificationService: config.NotificationService(),
+		Hub:                 config.Hub,
+		Events:              config.Events,
+		Logger:              config.Logger,
 	}
 
 	v1Document := config.Fiber.Group(ApiV1Path+"/document", middleware.JwtAuthMiddleware(config.Logger, service.NewSessionService(repository.NewSessionRepository(config.Db))), rbacMiddleware)
@@ -32,5 +44,11 @@ func NewDocumentRouter(config *Config, rbacMiddleware fiber.Handler) {
 	v1Document.Get("/:documentId", c.GetDocumentById)
 	v1Document.Post("/", c.CreateDocument)
 	v1Document.Put("/:documentId", c.UpdateDocument)
+	v1Document.Patch("/:documentId", c.PatchDocument)
 	v1Document.Delete("/:documentId", c.DeleteDocument)
+	v1Document.Post("/:documentId/lock", c.LockDocument)
+	v1Document.Delete("/:documentId/lock", c.UnlockDocument)
+	v1Document.Put("/:documentId/members", c.UpdateDocumentMembers)
+	v1Document.Put("/:documentId/inheritance", c.UpdateDocumentInheritance)
+	v1Document.Get("/:documentId/access", c.ExplainDocumentAccess)
 }
@@ -0,0 +1,46 @@
+package router
+
+import (
+	"time"
+
+	"github.com/labbs/zotion/pkg/api/middleware"
+	"github.com/labbs/zotion/pkg/api/v1/controller"
+	appConfig "github.com/labbs/zotion/pkg/config"
+	"github.com/labbs/zotion/pkg/events"
+	"github.com/labbs/zotion/pkg/repository"
+	"github.com/labbs/zotion/pkg/service"
+)
+
+// newEventBus returns the bus configured to share the live events between instances,
+// the events stay local when the redis server can't be reached
+func newEventBus(config *Config) events.Bus {
+	if appConfig.Events.Broker == "redis" {
+		bus, err := events.NewRedisBus(config.Logger, appConfig.Cache.Redis.Addr, appConfig.Cache.Redis.Password, appConfig.Cache.Redis.DB)
+		if err == nil {
+			return bus
+		}
+		config.Logger.Error().Err(err).Msg("failed to subscribe to the redis events, the events are not shared between instances")
+	}
+	return events.NewMemoryBus()
+}
+
+func NewEventsRouter(config *Config) {
+	// Set up the events routes
+	config.Logger.Info().Msg("Setting up events routes")
+
+	// initialize the repositories used to check the access
+	ur := repository.NewUserRepository(config.Db)
+	sr := repository.NewSpaceRepository(config.Db)
+	dr := repository.NewDocumentRepository(config.Db)
+
+	c := controller.EventsController{
+		AccessService:     service.NewAccessService(ur, sr, dr),
+		Events:            config.Events,
+		HeartbeatInterval: time.Duration(max(appConfig.Events.HeartbeatInterval, 1)) * time.Second,
+		Logger:            config.Logger,
+	}
+
+	// The EventSource can't send the Authorization header, the rbac middleware is not used
+	v1Events := config.Fiber.Group(ApiV1Path+"/events", middleware.EventStreamAuthMiddleware(config.Logger, service.NewSessionService(repository.NewSessionRepository(config.Db))))
+	v1Events.Get("/", c.Stream)
+}
@@ -18,6 +18,9 @@ func NewMeRouter(config *Config, rbacMiddleware fiber.Handler) {
 	// initialize the space repository
 	sr := repository.NewSpaceRepository(config.Db)
 
+	// initialize the document repository
+	dr := repository.NewDocumentRepository(config.Db)
+
 	// initialize the favorite repository
 	fr := repository.NewFavoriteRepository(config.Db)
 
@@ -30,16 +33,40 @@ func NewMeRouter(config *Config, rbacMiddleware fiber.Handler) {
 		UserService:     us,
 		SpaceService:    ss,
 		FavoriteService: fs,
+		ActivityService: service.NewActivityService(repository.NewActivityRepository(config.Db), sr, ur, dr),
+		DocumentService: service.NewDocumentService(dr),
+		GuestService:    service.NewGuestService(repository.NewGroupRepository(config.Db), dr, sr),
+		Events:          config.Events,
 		Logger:          config.Logger,
 	}
 
+	nc := controller.NotificationController{
+		NotificationService: config.NotificationService(),
+		AccessService:       service.NewAccessService(ur, sr, dr),
+		Logger:              config.Logger,
+	}
+
 	v1Me := config.Fiber.Group(ApiV1Path+"/me", middleware.JwtAuthMiddleware(config.Logger, service.NewSessionService(repository.NewSessionRepository(config.Db))), rbacMiddleware)
 	v1Me.Get("/profile", c.GetMyProfile)
 	v1Me.Get("/favorites", c.GetMyFavorites)
+	v1Me.Get("/recent", c.GetMyRecentDocuments)
 	v1Me.Get("/spaces", c.GetMySpaces)
+	v1Me.Get("/documents", c.GetMySharedDocuments)
 	v1Me.Post("/favorites/:documentId", c.AddFavorite)
 	v1Me.Delete("/favorites/:documentId", c.UnFavorite)
 	v1Me.Get("/preferences", c.GetMyPreferences)
 	v1Me.Put("/preferences", c.UpdateMyPreferences)
 	v1Me.Put("/change-password", c.ChangeMyPassword)
+	v1Me.Get("/notifications", nc.GetMyNotifications)
+	v1Me.Post("/notifications/read", nc.MarkMyNotificationsRead)
+	v1Me.Post("/notifications/unread", nc.MarkMyNotificationsUnread)
+	v1Me.Get("/notifications/preferences", nc.GetMyNotificationPreferences)
+	v1Me.Put("/notifications/preferences", nc.UpdateMyNotificationPreferences)
+	v1Me.Get("/subscriptions", nc.GetMySubscriptions)
+	v1Me.Get("/subscriptions/:documentId", nc.GetMySubscription)
+	v1Me.Post("/subscriptions/:documentId", nc.Subscribe)
+	v1Me.Delete("/subscriptions/:documentId", nc.Unsubscribe)
+	v1Me.Get("/reminders", nc.GetMyReminders)
+	v1Me.Post("/reminders", nc.CreateReminder)
+	v1Me.Delete("/reminders/:reminderId", nc.DeleteReminder)
 }
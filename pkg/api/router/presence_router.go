@@ -0,0 +1,44 @@
+package router
+
+import (
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/api/middleware"
+	"github.com/labbs/zotion/pkg/api/v1/controller"
+	"github.com/labbs/zotion/pkg/caching"
+	appConfig "github.com/labbs/zotion/pkg/config"
+	"github.com/labbs/zotion/pkg/repository"
+	"github.com/labbs/zotion/pkg/service"
+)
+
+func NewPresenceRouter(config *Config, rbacMiddleware fiber.Handler) {
+	// Set up the presence routes
+	config.Logger.Info().Msg("Setting up presence routes")
+
+	// initialize the repositories
+	ur := repository.NewUserRepository(config.Db)
+	sr := repository.NewSpaceRepository(config.Db)
+	dr := repository.NewDocumentRepository(config.Db)
+
+	timeout := time.Duration(appConfig.Presence.Timeout) * time.Second
+
+	c := controller.PresenceController{
+		AccessService:     service.NewAccessService(ur, sr, dr),
+		UserService:       service.NewUserService(ur),
+		PresenceService:   service.NewPresenceService(caching.Cache, timeout),
+		HeartbeatInterval: time.Duration(appConfig.Presence.HeartbeatInterval) * time.Second,
+		Timeout:           timeout,
+		Logger:            config.Logger,
+	}
+
+	sessionService := service.NewSessionService(repository.NewSessionRepository(config.Db))
+
+	v1Presence := config.Fiber.Group(ApiV1Path+"/presence", middleware.JwtAuthMiddleware(config.Logger, sessionService), rbacMiddleware)
+	v1Presence.Get("/document/:documentId", c.GetDocumentPresence)
+	v1Presence.Get("/space/:spaceId", c.GetSpacePresence)
+
+	wsPresence := config.Fiber.Group(WsV1Path+"/presence", middleware.WebsocketAuthMiddleware(config.Logger, sessionService))
+	wsPresence.Get("/:documentId", c.Authorize, websocket.New(c.Connect))
+}
@@ -14,16 +14,15 @@ func NewPublicRouter(config *Config, rbacMiddleware fiber.Handler) {
 	// initialize the document repository
 	dr := repository.NewDocumentRepository(config.Db)
 
-	// initialize the document service
-	ds := service.NewDocumentService(dr)
-
-	// initialize the document controller
-	dc := controller.DocumentController{
-		DocumentService: ds,
-		Logger:          config.Logger,
+	// initialize the public controller, only the published documents are served
+	pc := controller.PublicController{
+		PublicService: service.NewPublicService(dr),
+		Logger:        config.Logger,
 	}
 
 	// Set up the public routes
 	public := config.Fiber.Group(ApiV1Path + "/public")
-	public.Get("/document/slug/:slug", dc.GetDocumentBySlug)
+	public.Get("/document/slug/:slug", pc.GetPublicDocumentBySlug)
+	public.Get("/document/:documentId", pc.GetPublicDocumentById)
+	public.Get("/document/:documentId/nav", pc.GetPublicNavigation)
 }
@@ -1,18 +1,58 @@
 package router
 
 import (
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/labbs/zotion/pkg/api/middleware/rbac"
+	"github.com/labbs/zotion/pkg/collaboration"
+	appConfig "github.com/labbs/zotion/pkg/config"
+	"github.com/labbs/zotion/pkg/events"
+	"github.com/labbs/zotion/pkg/mailer"
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/labbs/zotion/pkg/notification"
 	"github.com/labbs/zotion/pkg/repository"
 	"github.com/labbs/zotion/pkg/service"
+	"github.com/labbs/zotion/pkg/site"
+	"github.com/labbs/zotion/pkg/storage"
+	"github.com/labbs/zotion/pkg/thumbnail"
+	"github.com/labbs/zotion/pkg/webhook"
 	"github.com/rs/zerolog"
 	"gorm.io/gorm"
 )
 
+// siteExportInterval is the interval of the checks of the pending site exports, the runner is also
+// woken up when an export is created
+const siteExportInterval = 30 * time.Second
+
 type Config struct {
 	Fiber  *fiber.App
 	Logger zerolog.Logger
 	Db     *gorm.DB
+
+	// Storage stores the files of the attachments
+	Storage storage.Storage
+
+	// Thumbnails generates the variants of the images
+	Thumbnails *thumbnail.Generator
+
+	// Hub is the collaboration hub, nil when the collaboration is disabled
+	Hub *collaboration.Hub
+
+	// Events dispatches the live events to the streams of all the instances
+	Events events.Bus
+
+	// Mailer sends the emails, the notification digests
+	Mailer mailer.Mailer
+
+	// Notifications fires the reminders and sends the digests in background
+	Notifications *notification.Scheduler
+
+	// Webhooks queues the deliveries of the events and sends them in background
+	Webhooks *webhook.Dispatcher
+
+	// SiteExports runs the static site exports in background
+	SiteExports *site.ExportRunner
 }
 
 func (c *Config) Setup() {
@@ -21,6 +61,12 @@ func (c *Config) Setup() {
 	ssr := repository.NewSpaceRepository(c.Db)
 	dr := repository.NewDocumentRepository(c.Db)
 
+	c.Events = newEventBus(c)
+	c.Thumbnails = thumbnail.NewGenerator(c.Logger, c.Storage, appConfig.Attachment.Variants.Widths.Value(), appConfig.Attachment.Variants.Workers)
+	c.Notifications = notification.NewScheduler(c.Logger, c.NotificationService(), time.Duration(max(appConfig.Notification.Interval, 1))*time.Second)
+	c.Webhooks = webhook.NewDispatcher(c.Logger, c.WebhookService(), c.Events, time.Duration(max(appConfig.Webhook.Interval, 1))*time.Second)
+	c.SiteExports = site.NewExportRunner(c.Logger, c.SiteExportService(), siteExportInterval)
+
 	crbac := rbac.Config{
 		Logger:          c.Logger,
 		UserService:     service.NewUserService(ur),
@@ -29,10 +75,85 @@ func (c *Config) Setup() {
 		DocumentService: service.NewDocumentService(dr),
 	}
 
+	// the collaboration hub is used by the document routes
+	NewCollaborationRouter(c)
 	NewAuthRouter(c, crbac.Check())
 	NewMeRouter(c, crbac.Check())
 	NewDocumentRouter(c, crbac.Check())
 	NewAdminRouter(c, crbac.Check())
 	NewPublicRouter(c, crbac.Check())
+	NewShareRouter(c, crbac.Check())
+	NewSiteRouter(c)
 	NewSpaceRouter(c, crbac.Check())
+	NewPresenceRouter(c, crbac.Check())
+	NewAttachmentRouter(c, crbac.Check())
+	NewDatabaseRouter(c, crbac.Check())
+	NewTagRouter(c, crbac.Check())
+	NewWebhookRouter(c, crbac.Check())
+	NewSiteExportRouter(c, crbac.Check())
+	NewEventsRouter(c)
+}
+
+// Shutdown releases the resources opened by the routers
+func (c *Config) Shutdown() error {
+	if c.Thumbnails != nil {
+		c.Thumbnails.Close()
+	}
+	if c.Notifications != nil {
+		c.Notifications.Close()
+	}
+	if c.Webhooks != nil {
+		c.Webhooks.Close()
+	}
+	if c.SiteExports != nil {
+		c.SiteExports.Close()
+	}
+	if c.Events != nil {
+		if err := c.Events.Close(); err != nil {
+			c.Logger.Error().Err(err).Msg("failed to close the events bus")
+		}
+	}
+	if c.Hub != nil {
+		return c.Hub.Close()
+	}
+	return nil
+}
+
+// NotificationService returns the notification service shared by the routers
+func (c *Config) NotificationService() models.NotificationService {
+	m := c.Mailer
+	if m == nil {
+		m = mailer.New(c.Logger)
+	}
+	return service.NewNotificationService(
+		repository.NewNotificationRepository(c.Db),
+		repository.NewUserRepository(c.Db),
+		repository.NewSpaceRepository(c.Db),
+		repository.NewDocumentRepository(c.Db),
+		m,
+		c.Events,
+	)
+}
+
+// WebhookService returns the webhook service shared by the routers
+func (c *Config) WebhookService() models.WebhookService {
+	return service.NewWebhookService(
+		repository.NewWebhookRepository(c.Db),
+		time.Duration(max(appConfig.Webhook.Timeout, 1))*time.Second,
+		appConfig.Webhook.MaxAttempts,
+		appConfig.Webhook.FailureThreshold,
+		appConfig.Webhook.AllowPrivateNetworks,
+	)
+}
+
+// SiteExportService returns the site export service shared by the routers
+func (c *Config) SiteExportService() models.SiteExportService {
+	dr := repository.NewDocumentRepository(c.Db)
+	exporter := site.NewExporter(
+		service.NewPublicService(dr),
+		service.NewSpaceService(repository.NewSpaceRepository(c.Db)),
+		service.NewAttachmentService(repository.NewAttachmentRepository(c.Db), c.Storage, c.Thumbnails, int64(appConfig.Attachment.MaxSize)*1024*1024, appConfig.Attachment.AllowedTypes.Value()),
+		appConfig.Server.SiteName,
+	)
+	return service.NewSiteExportService(repository.NewSiteExportRepository(c.Db), exporter, c.Storage)
 }
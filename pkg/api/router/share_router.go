@@ -0,0 +1,37 @@
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/api/middleware"
+	"github.com/labbs/zotion/pkg/api/v1/controller"
+	"github.com/labbs/zotion/pkg/repository"
+	"github.com/labbs/zotion/pkg/service"
+)
+
+func NewShareRouter(config *Config, rbacMiddleware fiber.Handler) {
+	// Set up the share link routes
+	config.Logger.Info().Msg("Setting up share link routes")
+
+	// initialize the repositories
+	dr := repository.NewDocumentRepository(config.Db)
+	sr := repository.NewSpaceRepository(config.Db)
+	ur := repository.NewUserRepository(config.Db)
+
+	c := controller.ShareLinkController{
+		ShareLinkService: service.NewShareLinkService(repository.NewShareLinkRepository(config.Db), dr),
+		AccessService:    service.NewAccessService(ur, sr, dr),
+		Logger:           config.Logger,
+	}
+
+	v1ShareLink := config.Fiber.Group(ApiV1Path+"/share-link", middleware.JwtAuthMiddleware(config.Logger, service.NewSessionService(repository.NewSessionRepository(config.Db))), rbacMiddleware)
+	v1ShareLink.Get("/document/:documentId", c.GetShareLinks)
+	v1ShareLink.Post("/document/:documentId", c.CreateShareLink)
+	v1ShareLink.Put("/:linkId", c.UpdateShareLink)
+	v1ShareLink.Delete("/:linkId", c.RevokeShareLink)
+
+	// The shared documents are opened without an account
+	share := config.Fiber.Group(ApiV1Path + "/public/share")
+	share.Get("/:token", c.GetSharedDocument)
+	share.Get("/:token/pages", c.GetSharedPages)
+	share.Get("/:token/document/:documentId", c.GetSharedSubpage)
+}
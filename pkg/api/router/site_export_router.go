@@ -0,0 +1,33 @@
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/api/middleware"
+	"github.com/labbs/zotion/pkg/api/v1/controller"
+	"github.com/labbs/zotion/pkg/repository"
+	"github.com/labbs/zotion/pkg/service"
+)
+
+func NewSiteExportRouter(config *Config, rbacMiddleware fiber.Handler) {
+	// Set up the site export routes
+	config.Logger.Info().Msg("Setting up site export routes")
+
+	// initialize the repositories
+	dr := repository.NewDocumentRepository(config.Db)
+	sr := repository.NewSpaceRepository(config.Db)
+	ur := repository.NewUserRepository(config.Db)
+
+	c := controller.SiteExportController{
+		SiteExportService: config.SiteExportService(),
+		AccessService:     service.NewAccessService(ur, sr, dr),
+		Runner:            config.SiteExports,
+		Logger:            config.Logger,
+	}
+
+	v1SiteExport := config.Fiber.Group(ApiV1Path+"/site-export", middleware.JwtAuthMiddleware(config.Logger, service.NewSessionService(repository.NewSessionRepository(config.Db))), rbacMiddleware)
+	v1SiteExport.Get("/space/:spaceId", c.GetSiteExports)
+	v1SiteExport.Post("/space/:spaceId", c.CreateSiteExport)
+	v1SiteExport.Get("/:exportId", c.GetSiteExport)
+	v1SiteExport.Get("/:exportId/download", c.DownloadSiteExport)
+	v1SiteExport.Delete("/:exportId", c.DeleteSiteExport)
+}
@@ -0,0 +1,25 @@
+package router
+
+import (
+	"github.com/labbs/zotion/pkg/api/v1/controller"
+	"github.com/labbs/zotion/pkg/repository"
+	"github.com/labbs/zotion/pkg/service"
+)
+
+func NewSiteRouter(config *Config) {
+	// Set up the public site routes
+	config.Logger.Info().Msg("Setting up public site routes")
+
+	// initialize the site controller, only the published documents are rendered
+	sc := controller.SiteController{
+		PublicService: service.NewPublicService(repository.NewDocumentRepository(config.Db)),
+		SpaceService:  service.NewSpaceService(repository.NewSpaceRepository(config.Db)),
+		Logger:        config.Logger,
+	}
+
+	config.Fiber.Get("/p/:documentId/:slug?", sc.GetPage)
+	config.Fiber.Get("/s/:spaceId/rss.xml", sc.GetRSSFeed)
+	config.Fiber.Get("/s/:spaceId/atom.xml", sc.GetAtomFeed)
+	config.Fiber.Get("/sitemap.xml", sc.GetSitemap)
+	config.Fiber.Get("/robots.txt", sc.GetRobots)
+}
@@ -19,12 +19,18 @@ func NewSpaceRouter(config *Config, rbacMiddleware fiber.Handler) {
 	s := service.NewSpaceService(sr)
 
 	// initialize the space controller
+	ur := repository.NewUserRepository(config.Db)
+	dr := repository.NewDocumentRepository(config.Db)
 	sc := controller.SpaceController{
-		SpaceService: s,
-		Logger:       config.Logger,
+		SpaceService:    s,
+		AccessService:   service.NewAccessService(ur, sr, dr),
+		ActivityService: service.NewActivityService(repository.NewActivityRepository(config.Db), sr, ur, dr),
+		Events:          config.Events,
+		Logger:          config.Logger,
 	}
 
 	// Set up the space routes
-	space := config.Fiber.Group(ApiV1Path+"/space", middleware.JwtAuthMiddleware(config.Logger, service.NewSessionService(repository.NewSessionRepository(config.Db))))
+	space := config.Fiber.Group(ApiV1Path+"/space", middleware.JwtAuthMiddleware(config.Logger, service.NewSessionService(repository.NewSessionRepository(config.Db))), rbacMiddleware)
 	space.Post("/", sc.CreateSpace)
+	space.Get("/:spaceId/activity", sc.GetSpaceActivities)
 }
@@ -0,0 +1,37 @@
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/api/middleware"
+	"github.com/labbs/zotion/pkg/api/v1/controller"
+	"github.com/labbs/zotion/pkg/repository"
+	"github.com/labbs/zotion/pkg/service"
+)
+
+func NewTagRouter(config *Config, rbacMiddleware fiber.Handler) {
+	// Set up the tag routes
+	config.Logger.Info().Msg("Setting up tag routes")
+
+	// initialize the repositories
+	tr := repository.NewTagRepository(config.Db)
+	dr := repository.NewDocumentRepository(config.Db)
+	sr := repository.NewSpaceRepository(config.Db)
+	ur := repository.NewUserRepository(config.Db)
+
+	c := controller.TagController{
+		TagService:    service.NewTagService(tr, sr, ur, dr),
+		AccessService: service.NewAccessService(ur, sr, dr),
+		Logger:        config.Logger,
+	}
+
+	v1Tag := config.Fiber.Group(ApiV1Path+"/tag", middleware.JwtAuthMiddleware(config.Logger, service.NewSessionService(repository.NewSessionRepository(config.Db))), rbacMiddleware)
+	v1Tag.Get("/", c.GetTags)
+	v1Tag.Post("/", c.CreateTag)
+	v1Tag.Get("/document/:documentId", c.GetDocumentTags)
+	v1Tag.Post("/document/:documentId/:tagId", c.AddDocumentTag)
+	v1Tag.Delete("/document/:documentId/:tagId", c.RemoveDocumentTag)
+	v1Tag.Put("/:tagId", c.UpdateTag)
+	v1Tag.Delete("/:tagId", c.DeleteTag)
+	v1Tag.Post("/:tagId/merge", c.MergeTag)
+	v1Tag.Get("/:tagId/documents", c.GetTaggedDocuments)
+}
@@ -0,0 +1,35 @@
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/api/middleware"
+	"github.com/labbs/zotion/pkg/api/v1/controller"
+	"github.com/labbs/zotion/pkg/repository"
+	"github.com/labbs/zotion/pkg/service"
+)
+
+func NewWebhookRouter(config *Config, rbacMiddleware fiber.Handler) {
+	// Set up the webhook routes
+	config.Logger.Info().Msg("Setting up webhook routes")
+
+	// initialize the repositories
+	dr := repository.NewDocumentRepository(config.Db)
+	sr := repository.NewSpaceRepository(config.Db)
+	ur := repository.NewUserRepository(config.Db)
+
+	c := controller.WebhookController{
+		WebhookService: config.WebhookService(),
+		AccessService:  service.NewAccessService(ur, sr, dr),
+		Logger:         config.Logger,
+	}
+
+	v1Webhook := config.Fiber.Group(ApiV1Path+"/webhook", middleware.JwtAuthMiddleware(config.Logger, service.NewSessionService(repository.NewSessionRepository(config.Db))), rbacMiddleware)
+	v1Webhook.Get("/", c.GetWebhooks)
+	v1Webhook.Post("/", c.CreateWebhook)
+	v1Webhook.Get("/:webhookId", c.GetWebhook)
+	v1Webhook.Put("/:webhookId", c.UpdateWebhook)
+	v1Webhook.Delete("/:webhookId", c.DeleteWebhook)
+	v1Webhook.Post("/:webhookId/rotate-secret", c.RotateWebhookSecret)
+	v1Webhook.Get("/:webhookId/deliveries", c.GetWebhookDeliveries)
+	v1Webhook.Post("/:webhookId/deliveries/:deliveryId/redeliver", c.RedeliverWebhookDelivery)
+}
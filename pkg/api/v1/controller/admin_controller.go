@@ -1,17 +1,26 @@
 package controller
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/events"
 	"github.com/labbs/zotion/pkg/models"
 	"github.com/rs/zerolog"
+	"gorm.io/gorm"
 )
 
 type AdminController struct {
-	UserService     models.UserService
-	GroupService    models.GroupService
-	SpaceService    models.SpaceService
-	DocumentService models.DocumentService
-	Logger          zerolog.Logger
+	UserService         models.UserService
+	GroupService        models.GroupService
+	SpaceService        models.SpaceService
+	DocumentService     models.DocumentService
+	AttachmentService   models.AttachmentService
+	ActivityService     models.ActivityService
+	NotificationService models.NotificationService
+	GuestService        models.GuestService
+	Events              events.Bus
+	Logger              zerolog.Logger
 }
 
 // GetUsers godoc
@@ -31,6 +40,11 @@ func (ac *AdminController) GetUsers(ctx *fiber.Ctx) error {
 		logger.Error().Err(err).Msg("Error getting users")
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
 	}
+	users, err = ac.GuestService.MarkGuestUsers(users)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error marking the guest users")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
 
 	logger.Debug().Int("count", len(users)).Msg("Users retrieved successfully")
 	return ctx.Status(fiber.StatusOK).JSON(users)
@@ -75,6 +89,12 @@ func (ac *AdminController) GetSpaces(ctx *fiber.Ctx) error {
 	}
 
 	for i, space := range spaces {
+		space.Members, err = ac.GuestService.MarkGuests(space.Members)
+		if err != nil {
+			logger.Error().Err(err).Msg("Error marking the guest members")
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+		}
+
 		membersWithUsersOrGroups := models.MembersWithUsersOrGroups{}
 		for _, member := range space.Members {
 			switch member.Type {
@@ -84,6 +104,7 @@ func (ac *AdminController) GetSpaces(ctx *fiber.Ctx) error {
 					logger.Error().Err(err).Str("member_id", member.Id).Msg("Error getting user for space member")
 					return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
 				}
+				user.IsGuest = member.Guest
 				membersWithUsersOrGroups = append(membersWithUsersOrGroups, models.MemberWithUsersOrGroups{
 					Member: member,
 					User:   user,
@@ -110,3 +131,107 @@ func (ac *AdminController) GetSpaces(ctx *fiber.Ctx) error {
 	logger.Debug().Int("count", len(spaces)).Interface("spaces", spaces).Msg("Spaces retrieved successfully")
 	return ctx.Status(fiber.StatusOK).JSON(spaces)
 }
+
+// GetGuests godoc
+// @Summary Get guests report
+// @Description Get all the guests, the users of a group with the guest role, with the documents they are invited to
+// @Description and their access, directly or through their groups
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.GuestReport
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/admin/guests [get]
+func (ac *AdminController) GetGuests(ctx *fiber.Ctx) error {
+	logger := ac.Logger.With().Str("event", "api.admin.get_guests").Logger()
+
+	report, err := ac.GuestService.GetGuestReport()
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting guests report")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	logger.Debug().Int("count", len(report)).Msg("Guests report retrieved successfully")
+	return ctx.Status(fiber.StatusOK).JSON(report)
+}
+
+// PurgeDocument godoc
+// @Summary Purge document
+// @Description Permanently delete a document, its child documents and their attachments
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Success 204
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/admin/documents/{documentId} [delete]
+func (ac *AdminController) PurgeDocument(ctx *fiber.Ctx) error {
+	logger := ac.Logger.With().Str("event", "api.admin.purge_document").Logger()
+
+	documentId := ctx.Params("documentId")
+	purged, err := ac.DocumentService.PurgeDocument(documentId)
+
+	// the attachments of the documents already purged are removed even if the purge failed
+	for _, id := range purged {
+		if err := ac.AttachmentService.DeleteDocumentAttachments(id); err != nil {
+			logger.Error().Err(err).Str("document", id).Msg("Error deleting the attachments of the purged document")
+		}
+	}
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Error purging document")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	logger.Debug().Int("count", len(purged)).Msg("Documents purged successfully")
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// RestoreDocument godoc
+// @Summary Restore document
+// @Description Restore a deleted document
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Success 200 {object} models.Document
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/admin/documents/{documentId}/restore [post]
+func (ac *AdminController) RestoreDocument(ctx *fiber.Ctx) error {
+	logger := ac.Logger.With().Str("event", "api.admin.restore_document").Logger()
+
+	documentId := ctx.Params("documentId")
+	err := ac.DocumentService.RestoreDocument(documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Document not found"})
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Error restoring document")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	document, err := ac.DocumentService.GetDocumentById(documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Document not found"})
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting document by id")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	userId, _ := ctx.Locals("user_id").(string)
+	activity := models.NewDocumentActivity(models.ActivityTypeRestored, userId, document)
+	if err := ac.ActivityService.Record(activity); err != nil {
+		logger.Warn().Err(err).Str("document", document.Id).Msg("Error recording the activity")
+	}
+	if err := ac.NotificationService.NotifySubscribers(activity, document); err != nil {
+		logger.Warn().Err(err).Str("document", document.Id).Msg("Error notifying the subscribers")
+	}
+	if err := ac.Events.Publish(documentEvent(events.DocumentRestored, userId, document)); err != nil {
+		logger.Warn().Err(err).Str("document", document.Id).Msg("Error publishing the event")
+	}
+
+	logger.Debug().Str("document", document.Id).Msg("Document restored successfully")
+	return ctx.Status(fiber.StatusOK).JSON(document)
+}
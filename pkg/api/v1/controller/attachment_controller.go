@@ -0,0 +1,245 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/labbs/zotion/pkg/storage"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+type AttachmentController struct {
+	AttachmentService models.AttachmentService
+	AccessService     models.AccessService
+	Logger            zerolog.Logger
+}
+
+// inlineContentTypes are served inline, the other attachments are downloaded
+// to avoid running active content (html, svg, ...) from the application origin
+var inlineContentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"image/bmp":       true,
+	"application/pdf": true,
+	"video/mp4":       true,
+	"video/webm":      true,
+	"audio/mpeg":      true,
+	"audio/wave":      true,
+	"audio/ogg":       true,
+}
+
+// UploadAttachment godoc
+// @Summary Upload attachment
+// @Description Upload a file in a document with a multipart form, the content type is detected from the file.
+// @Description The metadata of the images, like the exif location, are removed.
+// @Tags attachment
+// @Accept multipart/form-data
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Param file formData file true "File"
+// @Success 201 {object} models.Attachment
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Failure 423 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/attachment/document/{documentId} [post]
+func (ac *AttachmentController) UploadAttachment(ctx *fiber.Ctx) error {
+	logger := ac.Logger.With().Str("event", "api.attachments.upload").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	documentId := ctx.Params("documentId")
+
+	document, ok, err := checkDocumentAccess(ctx, logger, ac.AccessService, userId, documentId, true)
+	if !ok {
+		return err
+	}
+
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting the file from the form")
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing file"})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		logger.Error().Err(err).Msg("Error opening the uploaded file")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	defer file.Close()
+
+	attachment, err := ac.AttachmentService.CreateAttachment(models.Attachment{
+		DocumentId: document.Id,
+		SpaceId:    document.SpaceId,
+		Name:       fileHeader.Filename,
+		Size:       fileHeader.Size,
+		CreatedBy:  userId,
+	}, file)
+	switch {
+	case errors.Is(err, models.ErrAttachmentTooLarge):
+		logger.Warn().Int64("size", fileHeader.Size).Msg("Attachment too large")
+		return ctx.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": "File too large"})
+	case errors.Is(err, models.ErrAttachmentTypeNotAllowed):
+		logger.Warn().Str("content_type", attachment.ContentType).Msg("Attachment type not allowed")
+		return ctx.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{"error": "File type not allowed"})
+	case err != nil:
+		logger.Error().Err(err).Msg("Error creating attachment")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	logger.Debug().Str("attachment", attachment.Id).Str("document", document.Id).Msg("Attachment uploaded successfully")
+	return ctx.Status(fiber.StatusCreated).JSON(attachment)
+}
+
+// GetDocumentAttachments godoc
+// @Summary Get document attachments
+// @Description Get the attachments of a document
+// @Tags attachment
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Success 200 {array} models.Attachment
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/attachment/document/{documentId} [get]
+func (ac *AttachmentController) GetDocumentAttachments(ctx *fiber.Ctx) error {
+	logger := ac.Logger.With().Str("event", "api.attachments.get_document_attachments").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	documentId := ctx.Params("documentId")
+
+	if _, ok, err := checkDocumentAccess(ctx, logger, ac.AccessService, userId, documentId, false); !ok {
+		return err
+	}
+
+	attachments, err := ac.AttachmentService.GetAttachmentsByDocumentId(documentId)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting attachments")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(attachments)
+}
+
+// GetAttachment godoc
+// @Summary Get attachment
+// @Description Download the file of an attachment, the access follows the permissions of its document.
+// @Description For the images, the w parameter returns a variant resized to one of the configured widths.
+// @Tags attachment
+// @Produce octet-stream
+// @Param attachmentId path string true "Attachment Id"
+// @Param w query int false "Maximum width of the image"
+// @Success 200 {file} file
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/attachment/{attachmentId} [get]
+func (ac *AttachmentController) GetAttachment(ctx *fiber.Ctx) error {
+	logger := ac.Logger.With().Str("event", "api.attachments.get").Logger()
+
+	attachment, ok, err := ac.attachment(ctx, logger, false)
+	if !ok {
+		return err
+	}
+
+	// the attachments are immutable, a new file is a new attachment
+	width := ctx.QueryInt("w")
+	etag := attachment.Checksum
+	if width > 0 {
+		etag += "-w" + strconv.Itoa(width)
+	}
+	ctx.Set(fiber.HeaderCacheControl, "private, max-age=31536000, immutable")
+	ctx.Set(fiber.HeaderETag, strconv.Quote(etag))
+	if etagMatch(ctx.Get(fiber.HeaderIfNoneMatch), strconv.Quote(etag)) {
+		return ctx.SendStatus(fiber.StatusNotModified)
+	}
+
+	size := int(attachment.Size)
+	contentType := attachment.ContentType
+
+	var file io.ReadCloser
+	if width > 0 {
+		file, contentType, err = ac.AttachmentService.OpenAttachmentVariant(attachment, width)
+		size = -1
+	} else {
+		file, err = ac.AttachmentService.OpenAttachment(attachment)
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		logger.Error().Str("attachment", attachment.Id).Msg("Attachment file is missing in the storage")
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Attachment not found"})
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Error opening attachment")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	disposition := "attachment"
+	if mediaType, _, _ := mime.ParseMediaType(contentType); inlineContentTypes[mediaType] {
+		disposition = "inline"
+	}
+
+	ctx.Set(fiber.HeaderContentType, contentType)
+	ctx.Set(fiber.HeaderContentDisposition, mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Name}))
+	ctx.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+
+	// fasthttp closes the file once the response is sent
+	return ctx.Status(fiber.StatusOK).SendStream(file, size)
+}
+
+// DeleteAttachment godoc
+// @Summary Delete attachment
+// @Description Delete an attachment and its file
+// @Tags attachment
+// @Accept json
+// @Produce json
+// @Param attachmentId path string true "Attachment Id"
+// @Success 204
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 423 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/attachment/{attachmentId} [delete]
+func (ac *AttachmentController) DeleteAttachment(ctx *fiber.Ctx) error {
+	logger := ac.Logger.With().Str("event", "api.attachments.delete").Logger()
+
+	attachment, ok, err := ac.attachment(ctx, logger, true)
+	if !ok {
+		return err
+	}
+
+	if err := ac.AttachmentService.DeleteAttachment(attachment); err != nil {
+		logger.Error().Err(err).Msg("Error deleting attachment")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// attachment returns the attachment of the request if the user can access its document, or edit it when edit is true.
+// Otherwise the error response is sent and ok is false.
+func (ac *AttachmentController) attachment(ctx *fiber.Ctx, logger zerolog.Logger, edit bool) (attachment models.Attachment, ok bool, err error) {
+	userId := ctx.Locals("user_id").(string)
+	attachmentId := ctx.Params("attachmentId")
+
+	attachment, err = ac.AttachmentService.GetAttachmentById(attachmentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return attachment, false, ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Attachment not found"})
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting attachment")
+		return attachment, false, ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	if _, ok, err := checkDocumentAccess(ctx, logger, ac.AccessService, userId, attachment.DocumentId, edit); !ok {
+		return attachment, false, err
+	}
+	return attachment, true, nil
+}
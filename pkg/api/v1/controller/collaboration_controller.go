@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"errors"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/collaboration"
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/rs/zerolog"
+)
+
+type CollaborationController struct {
+	AccessService models.AccessService
+	Hub           *collaboration.Hub
+	Logger        zerolog.Logger
+}
+
+// Authorize godoc
+// @Summary Check the access to the collaborative document before the websocket upgrade
+// @Description Check the access to the collaborative document before the websocket upgrade
+// @Tags collaboration
+// @Param documentId path string true "Document Id"
+// @Param token query string false "Session token"
+// @Success 101
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/collaboration/{documentId} [get]
+func (cc *CollaborationController) Authorize(ctx *fiber.Ctx) error {
+	logger := cc.Logger.With().Str("event", "api.collaboration.authorize").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	documentId := ctx.Params("documentId")
+
+	_, access, err := cc.AccessService.GetDocumentAccess(userId, documentId)
+	if errors.Is(err, models.ErrAccessDenied) {
+		logger.Warn().Str("user", userId).Str("document", documentId).Msg("User is not a member of the document")
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to access this resource"})
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting document access")
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Document not found"})
+	}
+
+	ctx.Context().SetUserValue("read_only", !access.CanEdit())
+	return ctx.Next()
+}
+
+// Connect handles the websocket connection of a collaborative document.
+// The connection speaks the y-websocket protocol, messages are binary.
+func (cc *CollaborationController) Connect(conn *websocket.Conn) {
+	logger := cc.Logger.With().Str("event", "api.collaboration.connect").Logger()
+
+	userId := conn.Locals("user_id").(string)
+	readOnly := conn.Locals("read_only").(bool)
+	documentId := conn.Params("documentId")
+
+	client := collaboration.NewClient(userId, readOnly)
+	if err := cc.Hub.Join(documentId, client); err != nil {
+		logger.Error().Err(err).Str("document", documentId).Msg("Error joining collaborative document")
+		return
+	}
+	// write the messages queued by the hub, the connection must not be used
+	// once the handler returned so the writer is awaited before leaving
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for message := range client.Messages() {
+			if err := conn.WriteMessage(websocket.BinaryMessage, message); err != nil {
+				logger.Debug().Err(err).Msg("Error writing collaboration message")
+				conn.Close()
+				return
+			}
+		}
+		conn.Close()
+	}()
+
+	logger.Debug().Str("user", userId).Str("document", documentId).Bool("read_only", readOnly).Msg("User joined collaborative document")
+
+	for {
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logger.Debug().Err(err).Msg("Unexpected close of collaboration connection")
+			}
+			break
+		}
+		if messageType != websocket.BinaryMessage {
+			continue
+		}
+		if err := cc.Hub.HandleMessage(documentId, client, message); err != nil {
+			logger.Warn().Err(err).Str("document", documentId).Msg("Invalid collaboration message")
+		}
+	}
+
+	cc.Hub.Leave(documentId, client)
+	<-done
+
+	logger.Debug().Str("user", userId).Str("document", documentId).Msg("User left collaborative document")
+}
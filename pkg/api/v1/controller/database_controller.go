@@ -0,0 +1,418 @@
+package controller
+
+import (
+	"bufio"
+	"errors"
+	"mime"
+	"unicode/utf8"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gosimple/slug"
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+type DatabaseController struct {
+	DatabaseService models.DatabaseService
+	AccessService   models.AccessService
+	Logger          zerolog.Logger
+}
+
+// GetSchema godoc
+// @Summary Get database schema
+// @Description Get the property schema of a database document
+// @Tags database
+// @Accept json
+// @Produce json
+// @Param databaseId path string true "Database Id"
+// @Success 200 {array} models.PropertyDefinition
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/database/{databaseId}/schema [get]
+func (dbc *DatabaseController) GetSchema(ctx *fiber.Ctx) error {
+	logger := dbc.Logger.With().Str("event", "api.databases.get_schema").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	database, ok, err := checkDocumentAccess(ctx, logger, dbc.AccessService, userId, ctx.Params("databaseId"), false)
+	if !ok {
+		return err
+	}
+	if database.Type != models.DocumentTypeDatabase {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Document is not a database"})
+	}
+
+	ctx.Set(fiber.HeaderETag, database.ETag())
+	return ctx.Status(fiber.StatusOK).JSON(database.Schema)
+}
+
+// UpdateSchema godoc
+// @Summary Update database schema
+// @Description Replace the property schema of a database document, the rows are migrated.
+// @Description The values of the properties whose type changed are converted, or emptied when it's not possible.
+// @Tags database
+// @Accept json
+// @Produce json
+// @Param databaseId path string true "Database Id"
+// @Param schema body []models.PropertyDefinition true "Schema"
+// @Success 200 {array} models.PropertyDefinition
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 423 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/database/{databaseId}/schema [put]
+func (dbc *DatabaseController) UpdateSchema(ctx *fiber.Ctx) error {
+	logger := dbc.Logger.With().Str("event", "api.databases.update_schema").Logger()
+
+	var schema models.PropertySchema
+	if err := ctx.BodyParser(&schema); err != nil {
+		logger.Error().Err(err).Msg("Error parsing request body")
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	userId := ctx.Locals("user_id").(string)
+	database, ok, err := checkDocumentAccess(ctx, logger, dbc.AccessService, userId, ctx.Params("databaseId"), true)
+	if !ok {
+		return err
+	}
+
+	if ifMatch := ctx.Get(fiber.HeaderIfMatch); ifMatch != "" && !etagMatch(ifMatch, database.ETag()) {
+		logger.Warn().Str("database", database.Id).Msg("Database version conflict")
+		return documentConflict(ctx, database)
+	}
+
+	database, err = dbc.DatabaseService.UpdateSchema(database.Id, schema)
+	if errors.Is(err, models.ErrNotDatabase) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Document is not a database"})
+	}
+	if ok, response := invalidProperties(ctx, logger, err); !ok {
+		return response
+	}
+
+	ctx.Set(fiber.HeaderETag, database.ETag())
+	logger.Debug().Str("database", database.Id).Msg("Database schema updated successfully")
+	return ctx.Status(fiber.StatusOK).JSON(database.Schema)
+}
+
+// QueryDatabase godoc
+// @Summary Query database rows
+// @Description Query the rows of a database with filters, sorts and grouping, the properties are referenced by id or name.
+// @Description With a view_id, the filter is combined with the one of the view and its sorts and grouping are used by default.
+// @Description The rows breaking the inheritance are only returned to their members.
+// @Tags database
+// @Accept json
+// @Produce json
+// @Param databaseId path string true "Database Id"
+// @Param query body models.DatabaseQuery true "Query"
+// @Success 200 {object} models.DatabaseQueryResult
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/database/{databaseId}/query [post]
+func (dbc *DatabaseController) QueryDatabase(ctx *fiber.Ctx) error {
+	logger := dbc.Logger.With().Str("event", "api.databases.query").Logger()
+
+	var query models.DatabaseQuery
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&query); err != nil {
+			logger.Error().Err(err).Msg("Error parsing request body")
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+
+	userId := ctx.Locals("user_id").(string)
+	database, ok, err := checkDocumentAccess(ctx, logger, dbc.AccessService, userId, ctx.Params("databaseId"), false)
+	if !ok {
+		return err
+	}
+
+	result, err := dbc.DatabaseService.QueryDatabase(userId, database.Id, query)
+	if ok, response := databaseError(ctx, logger, err); !ok {
+		return response
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(result)
+}
+
+// GetViews godoc
+// @Summary Get database views
+// @Description Get the saved views of a database
+// @Tags database
+// @Accept json
+// @Produce json
+// @Param databaseId path string true "Database Id"
+// @Success 200 {array} models.DatabaseView
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/database/{databaseId}/views [get]
+func (dbc *DatabaseController) GetViews(ctx *fiber.Ctx) error {
+	logger := dbc.Logger.With().Str("event", "api.databases.get_views").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	database, ok, err := checkDocumentAccess(ctx, logger, dbc.AccessService, userId, ctx.Params("databaseId"), false)
+	if !ok {
+		return err
+	}
+
+	views, err := dbc.DatabaseService.GetViews(database.Id)
+	if ok, response := databaseError(ctx, logger, err); !ok {
+		return response
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(views)
+}
+
+// CreateView godoc
+// @Summary Create database view
+// @Description Save a view of a database, a board must be grouped by a property and a calendar requires a date property
+// @Tags database
+// @Accept json
+// @Produce json
+// @Param databaseId path string true "Database Id"
+// @Param view body models.DatabaseView true "View"
+// @Success 201 {object} models.DatabaseView
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 423 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/database/{databaseId}/views [post]
+func (dbc *DatabaseController) CreateView(ctx *fiber.Ctx) error {
+	logger := dbc.Logger.With().Str("event", "api.databases.create_view").Logger()
+
+	var view models.DatabaseView
+	if err := ctx.BodyParser(&view); err != nil {
+		logger.Error().Err(err).Msg("Error parsing request body")
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	userId := ctx.Locals("user_id").(string)
+	database, ok, err := checkDocumentAccess(ctx, logger, dbc.AccessService, userId, ctx.Params("databaseId"), true)
+	if !ok {
+		return err
+	}
+
+	view.DatabaseId = database.Id
+	view.CreatedBy = userId
+	view, err = dbc.DatabaseService.CreateView(view)
+	if ok, response := databaseError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Debug().Str("database", database.Id).Str("view", view.Id).Msg("Database view created successfully")
+	return ctx.Status(fiber.StatusCreated).JSON(view)
+}
+
+// UpdateView godoc
+// @Summary Update database view
+// @Description Update a saved view of a database
+// @Tags database
+// @Accept json
+// @Produce json
+// @Param databaseId path string true "Database Id"
+// @Param viewId path string true "View Id"
+// @Param view body models.DatabaseView true "View"
+// @Success 200 {object} models.DatabaseView
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 423 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/database/{databaseId}/views/{viewId} [put]
+func (dbc *DatabaseController) UpdateView(ctx *fiber.Ctx) error {
+	logger := dbc.Logger.With().Str("event", "api.databases.update_view").Logger()
+
+	var view models.DatabaseView
+	if err := ctx.BodyParser(&view); err != nil {
+		logger.Error().Err(err).Msg("Error parsing request body")
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	userId := ctx.Locals("user_id").(string)
+	database, ok, err := checkDocumentAccess(ctx, logger, dbc.AccessService, userId, ctx.Params("databaseId"), true)
+	if !ok {
+		return err
+	}
+
+	view.Id = ctx.Params("viewId")
+	view.DatabaseId = database.Id
+	view, err = dbc.DatabaseService.UpdateView(view)
+	if ok, response := databaseError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Debug().Str("database", database.Id).Str("view", view.Id).Msg("Database view updated successfully")
+	return ctx.Status(fiber.StatusOK).JSON(view)
+}
+
+// DeleteView godoc
+// @Summary Delete database view
+// @Description Delete a saved view of a database
+// @Tags database
+// @Accept json
+// @Produce json
+// @Param databaseId path string true "Database Id"
+// @Param viewId path string true "View Id"
+// @Success 204
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 423 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/database/{databaseId}/views/{viewId} [delete]
+func (dbc *DatabaseController) DeleteView(ctx *fiber.Ctx) error {
+	logger := dbc.Logger.With().Str("event", "api.databases.delete_view").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	database, ok, err := checkDocumentAccess(ctx, logger, dbc.AccessService, userId, ctx.Params("databaseId"), true)
+	if !ok {
+		return err
+	}
+
+	err = dbc.DatabaseService.DeleteView(database.Id, ctx.Params("viewId"))
+	if ok, response := databaseError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Debug().Str("database", database.Id).Str("view", ctx.Params("viewId")).Msg("Database view deleted successfully")
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// ImportCSV godoc
+// @Summary Import database rows from csv
+// @Description Create a row for each line of a csv file, the first line is the header. The columns are matched to the properties by name,
+// @Description or with the mapping of the column titles to property ids or names, "name" for the name of the rows and an empty target to skip a column.
+// @Description The missing properties are created with a type inferred from the values. The lines failing the validation are reported.
+// @Tags database
+// @Accept multipart/form-data
+// @Produce json
+// @Param databaseId path string true "Database Id"
+// @Param file formData file true "CSV file"
+// @Param mapping formData string false "JSON object of the column titles to the properties"
+// @Param delimiter formData string false "Field delimiter, a comma by default"
+// @Success 200 {object} models.CSVImport
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 423 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/database/{databaseId}/import [post]
+func (dbc *DatabaseController) ImportCSV(ctx *fiber.Ctx) error {
+	logger := dbc.Logger.With().Str("event", "api.databases.import_csv").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	database, ok, err := checkDocumentAccess(ctx, logger, dbc.AccessService, userId, ctx.Params("databaseId"), true)
+	if !ok {
+		return err
+	}
+
+	options := models.CSVImportOptions{UserId: userId}
+	if mapping := ctx.FormValue("mapping"); mapping != "" {
+		if err := json.Unmarshal([]byte(mapping), &options.Mapping); err != nil {
+			logger.Error().Err(err).Msg("Error parsing the mapping")
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid mapping"})
+		}
+	}
+	if delimiter := ctx.FormValue("delimiter"); delimiter != "" {
+		r, size := utf8.DecodeRuneInString(delimiter)
+		if size != len(delimiter) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid delimiter"})
+		}
+		options.Delimiter = r
+	}
+
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting the file from the form")
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing file"})
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		logger.Error().Err(err).Msg("Error opening the uploaded file")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	defer file.Close()
+
+	report, err := dbc.DatabaseService.ImportCSV(database.Id, file, options)
+	if ok, response := databaseError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Debug().Str("database", database.Id).Int("imported", report.Imported).Int("failed", report.Failed).Msg("Database rows imported successfully")
+	return ctx.Status(fiber.StatusOK).JSON(report)
+}
+
+// ExportCSV godoc
+// @Summary Export database rows as csv
+// @Description Export the rows of a database as csv. With a view_id, the rows are filtered and sorted like in the view
+// @Description and the columns are its visible properties, otherwise all the rows and properties are exported.
+// @Description The rows breaking the inheritance are only exported for their members.
+// @Tags database
+// @Produce text/csv
+// @Param databaseId path string true "Database Id"
+// @Param view_id query string false "View Id"
+// @Success 200 {file} file
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/database/{databaseId}/export [get]
+func (dbc *DatabaseController) ExportCSV(ctx *fiber.Ctx) error {
+	logger := dbc.Logger.With().Str("event", "api.databases.export_csv").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	database, ok, err := checkDocumentAccess(ctx, logger, dbc.AccessService, userId, ctx.Params("databaseId"), false)
+	if !ok {
+		return err
+	}
+	if database.Type != models.DocumentTypeDatabase {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Document is not a database"})
+	}
+
+	// the errors can't be sent once the rows are streamed, the view is checked first
+	viewId := ctx.Query("view_id")
+	if viewId != "" {
+		_, err := dbc.DatabaseService.GetView(database.Id, viewId)
+		if ok, response := databaseError(ctx, logger, err); !ok {
+			return response
+		}
+	}
+
+	filename := slug.Make(database.Name)
+	if filename == "" {
+		filename = "database"
+	}
+	ctx.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	ctx.Set(fiber.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": filename + ".csv"}))
+	ctx.Status(fiber.StatusOK).Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := dbc.DatabaseService.ExportCSV(userId, database.Id, viewId, w); err != nil {
+			logger.Error().Err(err).Str("database", database.Id).Msg("Error exporting database rows")
+			return
+		}
+		logger.Debug().Str("database", database.Id).Msg("Database rows exported successfully")
+	})
+	return nil
+}
+
+// databaseError writes the response of the errors of the database service, ok is true when there is no error
+func databaseError(ctx *fiber.Ctx, logger zerolog.Logger, err error) (bool, error) {
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, models.ErrNotDatabase):
+		return false, ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Document is not a database"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return false, ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "View not found"})
+	case errors.As(err, new(*models.ValidationError)):
+		return invalidProperties(ctx, logger, err)
+	}
+	logger.Error().Err(err).Msg("Error processing database request")
+	return false, ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+}
@@ -1,19 +1,45 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gosimple/slug"
+	"github.com/labbs/zotion/internal/mergepatch"
 	"github.com/labbs/zotion/internal/shortuuid"
+	"github.com/labbs/zotion/pkg/collaboration"
+	"github.com/labbs/zotion/pkg/events"
 	"github.com/labbs/zotion/pkg/models"
 	"github.com/rs/zerolog"
+	"gorm.io/gorm"
 )
 
 type DocumentController struct {
 	DocumentService models.DocumentService
 	FavoriteService models.FavoriteService
-	Logger          zerolog.Logger
+	AccessService   models.AccessService
+	DatabaseService models.DatabaseService
+	ActivityService models.ActivityService
+
+	// NotificationService notifies the mentioned users and the subscribers of the documents, optional
+	NotificationService models.NotificationService
+
+	// GuestService marks the guests in the members of the documents, optional
+	GuestService models.GuestService
+
+	// Hub drops the collaborative rooms of the documents whose content is updated, nil when the collaboration is disabled
+	Hub *collaboration.Hub
+
+	// Events streams the changes of the tree to the clients, optional
+	Events events.Bus
+	Logger zerolog.Logger
 }
 
 // GetDocumentsFromSpace godoc
@@ -24,18 +50,30 @@ type DocumentController struct {
 // @Produce json
 // @Param spaceId path string true "Space Id"
 // @Success 200 {array} models.Document
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /api/v1/document/space/{spaceId} [get]
 func (dc *DocumentController) GetDocumentsFromSpace(ctx *fiber.Ctx) error {
 	logger := dc.Logger.With().Str("event", "api.documents.get").Logger()
 
 	spaceId := ctx.Params("spaceId")
+	if isGuest(ctx) {
+		logger.Warn().Str("space", spaceId).Msg("Guests can't list the documents of a space")
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to access this resource"})
+	}
+
 	documents, err := dc.DocumentService.GetDocumentsFirstLevelForSpace(spaceId)
 	if err != nil {
 		logger.Error().Err(err).Msg("Error getting documents from space")
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
 	}
 
+	documents, err = dc.accessibleDocuments(ctx.Locals("user_id").(string), documents)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting document access")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
 	logger.Debug().Str("space", spaceId).Msg("Documents retrieved successfully")
 	return ctx.Status(fiber.StatusOK).JSON(documents)
 }
@@ -49,6 +87,7 @@ func (dc *DocumentController) GetDocumentsFromSpace(ctx *fiber.Ctx) error {
 // @Param spaceId path string true "Space Id"
 // @Param documentId path string true "Document Id"
 // @Success 200 {array} models.Document
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /api/v1/document/{spaceId}/{documentId} [get]
 func (dc *DocumentController) GetDocumentsFromParentDocument(ctx *fiber.Ctx) error {
@@ -61,6 +100,12 @@ func (dc *DocumentController) GetDocumentsFromParentDocument(ctx *fiber.Ctx) err
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
 	}
 
+	documents, err = dc.accessibleDocuments(ctx.Locals("user_id").(string), documents)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting document access")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
 	logger.Debug().Str("document", documentId).Msg("Documents retrieved successfully")
 	return ctx.Status(fiber.StatusOK).JSON(documents)
 }
@@ -73,6 +118,7 @@ func (dc *DocumentController) GetDocumentsFromParentDocument(ctx *fiber.Ctx) err
 // @Produce json
 // @Param documentId path string true "Document Id"
 // @Success 200 {object} models.Document
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /api/v1/document/{documentId} [get]
 func (dc *DocumentController) GetDocumentById(ctx *fiber.Ctx) error {
@@ -85,6 +131,18 @@ func (dc *DocumentController) GetDocumentById(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
 	}
 
+	if _, ok, err := checkDocumentAccess(ctx, logger, dc.AccessService, ctx.Locals("user_id").(string), document.Id, false); !ok {
+		return err
+	}
+	dc.markGuests(logger, &document)
+
+	ctx.Set(fiber.HeaderETag, document.ETag())
+	if etagMatch(ctx.Get(fiber.HeaderIfNoneMatch), document.ETag()) {
+		return ctx.SendStatus(fiber.StatusNotModified)
+	}
+
+	dc.recordView(ctx, logger, document)
+
 	logger.Debug().Str("document", documentId).Msg("Document retrieved successfully")
 	return ctx.Status(fiber.StatusOK).JSON(document)
 }
@@ -97,6 +155,7 @@ func (dc *DocumentController) GetDocumentById(ctx *fiber.Ctx) error {
 // @Produce json
 // @Param slug path string true "Document Slug"
 // @Success 200 {object} models.Document
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /api/v1/document/slug/{slug} [get]
 func (dc *DocumentController) GetDocumentBySlug(ctx *fiber.Ctx) error {
@@ -109,6 +168,18 @@ func (dc *DocumentController) GetDocumentBySlug(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
 	}
 
+	if _, ok, err := checkDocumentAccess(ctx, logger, dc.AccessService, ctx.Locals("user_id").(string), document.Id, false); !ok {
+		return err
+	}
+	dc.markGuests(logger, &document)
+
+	ctx.Set(fiber.HeaderETag, document.ETag())
+	if etagMatch(ctx.Get(fiber.HeaderIfNoneMatch), document.ETag()) {
+		return ctx.SendStatus(fiber.StatusNotModified)
+	}
+
+	dc.recordView(ctx, logger, document)
+
 	logger.Debug().Str("document", slug).Msg("Document retrieved successfully")
 	return ctx.Status(fiber.StatusOK).JSON(document)
 }
@@ -122,6 +193,9 @@ func (dc *DocumentController) GetDocumentBySlug(ctx *fiber.Ctx) error {
 // @Param document body models.Document true "Document"
 // @Success 201 {object} models.Document
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /api/v1/document [post]
 func (dc *DocumentController) CreateDocument(ctx *fiber.Ctx) error {
@@ -133,12 +207,96 @@ func (dc *DocumentController) CreateDocument(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	document, err := dc.DocumentService.CreateDocument(document)
+	userId := ctx.Locals("user_id").(string)
+	document.CreatedBy = userId
+	document.UpdatedBy = userId
+
+	// the new documents inherit the access of their parent, the inheritance is broken afterwards
+	document.InheritanceBroken = false
+
+	// the subpages are created in the documents the user can edit, in the space of their parent.
+	// The documents at the root of a space are created by its editors, the guests can only create subpages.
+	if document.ParentId == "" && isGuest(ctx) {
+		logger.Warn().Str("user", userId).Msg("Guests can't create documents at the root of a space")
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to access this resource"})
+	}
+	if document.ParentId == "" {
+		_, access, err := dc.AccessService.GetSpaceAccess(userId, document.SpaceId)
+		if errors.Is(err, models.ErrAccessDenied) || (err == nil && !access.CanEdit()) {
+			logger.Warn().Str("user", userId).Str("space", document.SpaceId).Msg("User can't create documents in the space")
+			return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to access this resource"})
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Space not found"})
+		}
+		if err != nil {
+			logger.Error().Err(err).Msg("Error getting space access")
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+		}
+	}
+	if document.ParentId != "" {
+		parent, ok, err := checkDocumentAccess(ctx, logger, dc.AccessService, userId, document.ParentId, true)
+		if !ok {
+			return err
+		}
+		document.SpaceId = parent.SpaceId
+	}
+	if isGuest(ctx) {
+		document.Members = nil
+	}
+
+	if document.Type == models.DocumentTypeDatabase {
+		if document.Schema == nil {
+			document.Schema = models.PropertySchema{}
+		}
+		if err := document.Schema.Normalize(); err != nil {
+			logger.Warn().Err(err).Msg("Invalid database schema")
+			return ctx.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
+	} else {
+		document.Schema = nil
+	}
+
+	properties, err := dc.DatabaseService.ValidateRow(document)
+	if ok, response := invalidProperties(ctx, logger, err); !ok {
+		return response
+	}
+	document.Properties = properties
+
+	// the relations and the rollups of a new database are resolved once it's created
+	schema := document.Schema
+	if len(schema) > 0 {
+		document.Schema = models.PropertySchema{}
+	}
+
+	document, err = dc.DocumentService.CreateDocument(document)
 	if err != nil {
 		logger.Error().Err(err).Msg("Error creating document")
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
 	}
 
+	if len(schema) > 0 {
+		database, err := dc.DatabaseService.UpdateSchema(document.Id, schema)
+		if err != nil {
+			if _, err := dc.DocumentService.PurgeDocument(document.Id); err != nil {
+				logger.Error().Err(err).Msg("Error removing the database")
+			}
+			_, response := invalidProperties(ctx, logger, err)
+			return response
+		}
+		document = database
+	}
+
+	if err := dc.DatabaseService.SyncRow(document, nil); err != nil {
+		logger.Error().Err(err).Msg("Error syncing the related rows")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	dc.recordActivity(logger, models.ActivityTypeCreated, userId, document)
+	dc.publishDocumentEvent(logger, events.DocumentCreated, userId, document)
+	dc.notifyMentions(logger, userId, document, "")
+	dc.subscribe(logger, userId, document)
+
 	logger.Debug().Str("document", document.Id).Msg("Document created successfully")
 	return ctx.Status(fiber.StatusCreated).JSON(document)
 }
@@ -151,8 +309,13 @@ func (dc *DocumentController) CreateDocument(ctx *fiber.Ctx) error {
 // @Produce json
 // @Param documentId path string true "Document Id"
 // @Param document body models.Document true "Document"
+// @Param If-Match header string false "ETag of the document version being updated"
 // @Success 200 {object} models.Document
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 423 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /api/v1/document/{documentId} [put]
 func (dc *DocumentController) UpdateDocument(ctx *fiber.Ctx) error {
@@ -165,12 +328,18 @@ func (dc *DocumentController) UpdateDocument(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	document, err := dc.DocumentService.GetDocumentById(documentId)
-	if err != nil {
-		logger.Error().Err(err).Msg("Error getting document by id")
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	userId := ctx.Locals("user_id").(string)
+	document, ok, err := checkDocumentAccess(ctx, logger, dc.AccessService, userId, documentId, true)
+	if !ok {
+		return err
+	}
+
+	if ifMatch := ctx.Get(fiber.HeaderIfMatch); ifMatch != "" && !etagMatch(ifMatch, document.ETag()) {
+		logger.Warn().Str("document", documentId).Msg("Document version conflict")
+		return documentConflict(ctx, document)
 	}
 
+	previous := document
 	if documentRequest.Name == "" {
 		logger.Error().Msg("Document name is required")
 		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Document name is required"})
@@ -182,19 +351,619 @@ func (dc *DocumentController) UpdateDocument(ctx *fiber.Ctx) error {
 	}
 
 	document.Content = documentRequest.Content
-	document.Config = documentRequest.Config
+	document.Config = mergeLockConfig(document.Config, documentRequest.Config, userId)
 	document.Public = documentRequest.Public
 
+	return dc.saveDocument(ctx, logger, document, previous)
+}
+
+// PatchDocument godoc
+// @Summary Patch document
+// @Description Partially update a document with a JSON merge patch (RFC 7386) on name, content, public, config, properties and metadata
+// @Tags document
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Param patch body models.DocumentPatch true "JSON merge patch"
+// @Param If-Match header string false "ETag of the document version being updated"
+// @Success 200 {object} models.Document
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 423 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/document/{documentId} [patch]
+func (dc *DocumentController) PatchDocument(ctx *fiber.Ctx) error {
+	logger := dc.Logger.With().Str("event", "api.documents.patch").Logger()
+
+	documentId := ctx.Params("documentId")
+	userId := ctx.Locals("user_id").(string)
+	document, ok, err := checkDocumentAccess(ctx, logger, dc.AccessService, userId, documentId, true)
+	if !ok {
+		return err
+	}
+
+	if ifMatch := ctx.Get(fiber.HeaderIfMatch); ifMatch != "" && !etagMatch(ifMatch, document.ETag()) {
+		logger.Warn().Str("document", documentId).Msg("Document version conflict")
+		return documentConflict(ctx, document)
+	}
+
+	original, err := json.Marshal(models.DocumentPatch{
+		Name:       document.Name,
+		Content:    document.Content,
+		Public:     document.Public,
+		Config:     document.Config,
+		Properties: document.Properties,
+		Metadata:   document.Metadata,
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("Error encoding document")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	patched, err := mergepatch.Apply(original, ctx.Body())
+	if err != nil {
+		logger.Error().Err(err).Msg("Error applying merge patch")
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merge patch"})
+	}
+
+	var patch models.DocumentPatch
+	if err := json.Unmarshal(patched, &patch); err != nil {
+		logger.Error().Err(err).Msg("Error decoding patched document")
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid merge patch"})
+	}
+
+	previous := document
+	if patch.Name == "" {
+		logger.Error().Msg("Document name is required")
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Document name is required"})
+	}
+
+	if patch.Name != document.Name {
+		document.Slug = slug.Make(patch.Name + "-" + shortuuid.GenerateShortUUID())
+		document.Name = patch.Name
+	}
+
+	document.Content = patch.Content
+	document.Public = patch.Public
+	document.Config = mergeLockConfig(document.Config, patch.Config, userId)
+	document.Properties = patch.Properties
+	document.Metadata = patch.Metadata
+
+	return dc.saveDocument(ctx, logger, document, previous)
+}
+
+// LockDocument godoc
+// @Summary Lock document
+// @Description Lock a document, only the locker and the space members with a full access can edit or unlock it.
+// @Description A duration in seconds creates a check-out lock released automatically.
+// @Tags document
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Param lock body models.LockRequest false "Lock"
+// @Success 200 {object} models.Document
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 423 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/document/{documentId}/lock [post]
+func (dc *DocumentController) LockDocument(ctx *fiber.Ctx) error {
+	logger := dc.Logger.With().Str("event", "api.documents.lock").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	documentId := ctx.Params("documentId")
+
+	var lockRequest models.LockRequest
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&lockRequest); err != nil || lockRequest.Duration < 0 {
+			logger.Error().Err(err).Msg("Error parsing request body")
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+
+	document, ok, err := checkDocumentAccess(ctx, logger, dc.AccessService, userId, documentId, true)
+	if !ok {
+		return err
+	}
+
+	previous := document
+	document.Config.SetLock(userId, time.Duration(lockRequest.Duration)*time.Second)
+
+	return dc.saveDocument(ctx, logger, document, previous)
+}
+
+// UnlockDocument godoc
+// @Summary Unlock document
+// @Description Unlock a document, only the locker and the space members with a full access can unlock it
+// @Tags document
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Success 200 {object} models.Document
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 423 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/document/{documentId}/lock [delete]
+func (dc *DocumentController) UnlockDocument(ctx *fiber.Ctx) error {
+	logger := dc.Logger.With().Str("event", "api.documents.unlock").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	documentId := ctx.Params("documentId")
+
+	document, ok, err := checkDocumentAccess(ctx, logger, dc.AccessService, userId, documentId, true)
+	if !ok {
+		return err
+	}
+
+	previous := document
+	document.Config.ClearLock()
+
+	return dc.saveDocument(ctx, logger, document, previous)
+}
+
+// UpdateDocumentMembers godoc
+// @Summary Update document members
+// @Description Replace the members of a document, the users and the groups invited to the document in addition to the members
+// @Description inherited from its parent documents and its space. The members are inherited by the descendants of the document.
+// @Description The guests can only access the documents they are invited to and their descendants. Only the members with a full access
+// @Description to the document can update its members, the guests can't invite other members.
+// @Tags document
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Param members body models.Members true "Members"
+// @Success 200 {object} models.Document
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/document/{documentId}/members [put]
+func (dc *DocumentController) UpdateDocumentMembers(ctx *fiber.Ctx) error {
+	logger := dc.Logger.With().Str("event", "api.documents.update_members").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	documentId := ctx.Params("documentId")
+
+	var members models.Members
+	if err := ctx.BodyParser(&members); err != nil {
+		logger.Error().Err(err).Msg("Error parsing request body")
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	seen := map[string]bool{}
+	for _, member := range members {
+		if member.Id == "" || (member.Type != models.MemberTypeUser && member.Type != models.MemberTypeGroup) || !member.Access.Valid() {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid member"})
+		}
+		key := string(member.Type) + ":" + member.Id
+		if seen[key] {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Duplicate member"})
+		}
+		seen[key] = true
+	}
+
+	document, ok, err := dc.checkDocumentManager(ctx, logger, userId, documentId)
+	if !ok {
+		return err
+	}
+
+	if members == nil {
+		members = models.Members{}
+	}
+	if err := dc.DocumentService.UpdateDocumentMembers(document.Id, members); err != nil {
+		logger.Error().Err(err).Msg("Error updating document members")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	document.Members = members
+	dc.markGuests(logger, &document)
+
+	logger.Info().Str("document", document.Id).Int("count", len(members)).Msg("Document members updated successfully")
+	return ctx.Status(fiber.StatusOK).JSON(document)
+}
+
+// UpdateDocumentInheritance godoc
+// @Summary Update document inheritance
+// @Description Break or restore the inheritance of the access of a document. A document inherits the members of its parent
+// @Description documents and of its space, once the inheritance is broken only the members of the document and of its descendants
+// @Description can access it. The inherited members can be copied to the document when the inheritance is broken, the user
+// @Description breaking the inheritance always keeps a full access. Only the members with a full access can update it.
+// @Tags document
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Param inheritance body models.InheritanceRequest true "Inheritance"
+// @Success 200 {object} models.Document
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/document/{documentId}/inheritance [put]
+func (dc *DocumentController) UpdateDocumentInheritance(ctx *fiber.Ctx) error {
+	logger := dc.Logger.With().Str("event", "api.documents.update_inheritance").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	documentId := ctx.Params("documentId")
+
+	var request models.InheritanceRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		logger.Error().Err(err).Msg("Error parsing request body")
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	document, ok, err := dc.checkDocumentManager(ctx, logger, userId, documentId)
+	if !ok {
+		return err
+	}
+
+	members := document.Members
+	if request.InheritanceBroken && !document.InheritanceBroken {
+		if request.CopyMembers {
+			chain, err := dc.AccessService.GetDocumentAccessChain(document.Id)
+			if err != nil {
+				logger.Error().Err(err).Msg("Error getting document access chain")
+				return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+			}
+			for _, ancestor := range chain.Documents[1:] {
+				members = members.Merge(ancestor.Members)
+			}
+			if chain.SpaceInherited {
+				members = members.Merge(chain.Space.Members)
+			}
+		}
+		members = members.Merge(models.Members{{Id: userId, Type: models.MemberTypeUser, Access: models.AccessTypeFull}})
+	}
+	if members == nil {
+		members = models.Members{}
+	}
+
+	if err := dc.DocumentService.UpdateDocumentInheritance(document.Id, request.InheritanceBroken, members); err != nil {
+		logger.Error().Err(err).Msg("Error updating document inheritance")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	document.InheritanceBroken = request.InheritanceBroken
+	document.Members = members
+	dc.markGuests(logger, &document)
+
+	logger.Info().Str("document", document.Id).Bool("inheritance_broken", document.InheritanceBroken).Msg("Document inheritance updated successfully")
+	return ctx.Status(fiber.StatusOK).JSON(document)
+}
+
+// ExplainDocumentAccess godoc
+// @Summary Explain document access
+// @Description Explain the access of a user on a document: the effective access with the members granting it, directly or through
+// @Description the groups of the user, along the chain of the document, its parent documents up to the first one breaking
+// @Description the inheritance, and its space. The users can explain their own access, the members with a full access and
+// @Description the administrators can explain the access of any user.
+// @Tags document
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Param user_id query string false "User Id, the authenticated user by default"
+// @Success 200 {object} models.AccessExplanation
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/document/{documentId}/access [get]
+func (dc *DocumentController) ExplainDocumentAccess(ctx *fiber.Ctx) error {
+	logger := dc.Logger.With().Str("event", "api.documents.explain_access").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	documentId := ctx.Params("documentId")
+	targetId := ctx.Query("user_id", userId)
+
+	if isAdmin, _ := ctx.Locals("is_admin").(bool); targetId != userId && !isAdmin {
+		if _, ok, err := dc.checkDocumentManager(ctx, logger, userId, documentId); !ok {
+			return err
+		}
+	}
+
+	explanation, err := dc.AccessService.ExplainDocumentAccess(targetId, documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Document or user not found"})
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Error explaining document access")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	logger.Debug().Str("document", documentId).Str("user", targetId).Str("access", string(explanation.Access)).Msg("Document access explained successfully")
+	return ctx.Status(fiber.StatusOK).JSON(explanation)
+}
+
+// checkDocumentManager returns the document if the user has a full access on it and isn't a guest,
+// the guests can't manage the access of the documents. Otherwise the error response is sent and ok is false.
+func (dc *DocumentController) checkDocumentManager(ctx *fiber.Ctx, logger zerolog.Logger, userId, documentId string) (models.Document, bool, error) {
+	document, access, err := dc.AccessService.GetDocumentAccess(userId, documentId)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return document, false, ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Document not found"})
+	case errors.Is(err, models.ErrAccessDenied) || (err == nil && (access != models.AccessTypeFull || isGuest(ctx))):
+		logger.Warn().Str("user", userId).Str("document", documentId).Msg("User can't manage the access of the document")
+		return document, false, ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to access this resource"})
+	case err != nil:
+		logger.Error().Err(err).Msg("Error getting document access")
+		return document, false, ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	return document, true, nil
+}
+
+// accessibleDocuments returns the documents the user can access, the documents breaking the inheritance
+// and the documents of the spaces the user isn't a member of are hidden
+func (dc *DocumentController) accessibleDocuments(userId string, documents []models.Document) ([]models.Document, error) {
+	accessible := []models.Document{}
+	for _, document := range documents {
+		_, _, err := dc.AccessService.GetDocumentAccess(userId, document.Id)
+		if errors.Is(err, models.ErrAccessDenied) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		accessible = append(accessible, document)
+	}
+	return accessible, nil
+}
+
+// isGuest returns true when the user of the request is a guest, it's set by the rbac middleware
+func isGuest(ctx *fiber.Ctx) bool {
+	guest, _ := ctx.Locals("is_guest").(bool)
+	return guest
+}
+
+// markGuests marks the guests in the members of the document, the errors are only logged
+func (dc *DocumentController) markGuests(logger zerolog.Logger, document *models.Document) {
+	if dc.GuestService == nil {
+		return
+	}
+	members, err := dc.GuestService.MarkGuests(document.Members)
+	if err != nil {
+		logger.Warn().Err(err).Str("document", document.Id).Msg("Error marking the guest members")
+		return
+	}
+	document.Members = members
+}
+
+// checkDocumentAccess returns the document if the user can access it, or edit it when edit is true.
+// Otherwise the error response is sent and ok is false.
+func checkDocumentAccess(ctx *fiber.Ctx, logger zerolog.Logger, accessService models.AccessService, userId, documentId string, edit bool) (document models.Document, ok bool, err error) {
+	document, access, err := accessService.GetDocumentAccess(userId, documentId)
+	if errors.Is(err, models.ErrAccessDenied) {
+		logger.Warn().Str("user", userId).Str("document", documentId).Msg("User is not a member of the document")
+		return document, false, ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to access this resource"})
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting document access")
+		return document, false, ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	if edit && !access.CanEdit() {
+		if document.Config.IsLocked() {
+			logger.Warn().Str("user", userId).Str("document", documentId).Msg("Document is locked")
+			return document, false, ctx.Status(fiber.StatusLocked).JSON(fiber.Map{
+				"error":           "Document is locked",
+				"locked_by":       document.Config.LockedBy,
+				"locked_at":       document.Config.LockedAt,
+				"lock_expires_at": document.Config.LockExpiresAt,
+			})
+		}
+		logger.Warn().Str("user", userId).Str("document", documentId).Msg("User can't edit the document")
+		return document, false, ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to access this resource"})
+	}
+
+	return document, true, nil
+}
+
+// mergeLockConfig applies the requested configuration but keeps the lock managed by the server.
+// Switching the lock flag locks the document for the user or removes the lock.
+func mergeLockConfig(current, requested models.DocumentConfig, userId string) models.DocumentConfig {
+	config := requested
+	config.Lock = current.Lock
+	config.LockedBy = current.LockedBy
+	config.LockedAt = current.LockedAt
+	config.LockExpiresAt = current.LockExpiresAt
+
+	switch {
+	case requested.Lock && !current.IsLocked():
+		config.SetLock(userId, 0)
+	case !requested.Lock && current.IsLocked():
+		config.ClearLock()
+	}
+	return config
+}
+
+// saveDocument updates the document and answers with the new version,
+// a conflict is returned when the document was modified since it was read.
+// The rows related to the document are synced in the same transaction and the activity is recorded.
+func (dc *DocumentController) saveDocument(ctx *fiber.Ctx, logger zerolog.Logger, document models.Document, previous models.Document) error {
+	userId := ctx.Locals("user_id").(string)
+	document.UpdatedBy = userId
+
+	properties, err := dc.DatabaseService.ValidateRow(document)
+	if ok, response := invalidProperties(ctx, logger, err); !ok {
+		return response
+	}
+	document.Properties = properties
+
 	document, err = dc.DocumentService.UpdateDocument(document)
+	if errors.Is(err, models.ErrVersionConflict) {
+		logger.Warn().Str("document", document.Id).Msg("Document version conflict")
+		current, err := dc.DocumentService.GetDocumentById(document.Id)
+		if err != nil {
+			logger.Error().Err(err).Msg("Error getting document by id")
+			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+		}
+		return documentConflict(ctx, current)
+	}
 	if err != nil {
 		logger.Error().Err(err).Msg("Error updating document")
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
 	}
 
+	// the collaborative editors would write the previous content back
+	if document.Content != previous.Content && dc.Hub != nil {
+		dc.Hub.Reset(document.Id)
+	}
+
+	if activityType, ok := documentActivity(previous, document); ok {
+		dc.recordActivity(logger, activityType, userId, document)
+		dc.publishDocumentEvent(logger, documentEventType(previous, document), userId, document)
+	}
+	if document.Content != previous.Content {
+		dc.notifyMentions(logger, userId, document, previous.Content)
+	}
+
+	ctx.Set(fiber.HeaderETag, document.ETag())
 	logger.Debug().Str("document", document.Id).Msg("Document updated successfully")
 	return ctx.Status(fiber.StatusOK).JSON(document)
 }
 
+// documentActivity returns the activity of an update of the document, ok is false when only the lock changed
+func documentActivity(previous models.Document, document models.Document) (models.ActivityType, bool) {
+	switch {
+	case document.ParentId != previous.ParentId:
+		return models.ActivityTypeMoved, true
+	case document.Public && !previous.Public:
+		return models.ActivityTypeShared, true
+	}
+
+	previousConfig, config := previous.Config, document.Config
+	previousConfig.ClearLock()
+	config.ClearLock()
+	edited := document.Name != previous.Name ||
+		document.Content != previous.Content ||
+		document.Public != previous.Public ||
+		config != previousConfig ||
+		!slices.Equal(document.Properties, previous.Properties) ||
+		!reflect.DeepEqual(document.Metadata, previous.Metadata)
+	return models.ActivityTypeEdited, edited
+}
+
+// documentEventType returns the type of the event of an update of the document
+func documentEventType(previous models.Document, document models.Document) events.Type {
+	switch {
+	case document.ParentId != previous.ParentId:
+		return events.DocumentMoved
+	case document.Name != previous.Name:
+		return events.DocumentRenamed
+	}
+	return events.DocumentUpdated
+}
+
+// publishDocumentEvent streams the change of the document to the members of its space, the errors are only logged
+func (dc *DocumentController) publishDocumentEvent(logger zerolog.Logger, eventType events.Type, userId string, document models.Document) {
+	if dc.Events == nil {
+		return
+	}
+	if err := dc.Events.Publish(documentEvent(eventType, userId, document)); err != nil {
+		logger.Warn().Err(err).Str("document", document.Id).Str("type", string(eventType)).Msg("Error publishing the event")
+	}
+}
+
+// documentEvent returns the event of a change of the document, the event has the fields of the tree but not the content
+func documentEvent(eventType events.Type, userId string, document models.Document) events.Event {
+	return events.Event{
+		Type:       eventType,
+		SpaceId:    document.SpaceId,
+		DocumentId: document.Id,
+		ActorId:    userId,
+		Data: fiber.Map{
+			"id":        document.Id,
+			"name":      document.Name,
+			"slug":      document.Slug,
+			"type":      document.Type,
+			"parent_id": document.ParentId,
+			"space_id":  document.SpaceId,
+			"metadata":  document.Metadata,
+			"version":   document.Version,
+		},
+	}
+}
+
+// recordView updates the recent documents of the authenticated user, the errors are only logged
+func (dc *DocumentController) recordView(ctx *fiber.Ctx, logger zerolog.Logger, document models.Document) {
+	userId, ok := ctx.Locals("user_id").(string)
+	if !ok || dc.ActivityService == nil {
+		return
+	}
+	if err := dc.ActivityService.RecordView(userId, document.Id); err != nil {
+		logger.Warn().Err(err).Str("document", document.Id).Msg("Error recording the document view")
+	}
+}
+
+// recordActivity appends an activity of the user on the document to the log and notifies the subscribers
+// of the document, the errors are only logged
+func (dc *DocumentController) recordActivity(logger zerolog.Logger, activityType models.ActivityType, userId string, document models.Document) {
+	activity := models.NewDocumentActivity(activityType, userId, document)
+	if err := dc.ActivityService.Record(activity); err != nil {
+		logger.Warn().Err(err).Str("document", activity.DocumentId).Str("activity", string(activity.Type)).Msg("Error recording the activity")
+	}
+	if dc.NotificationService == nil {
+		return
+	}
+	if err := dc.NotificationService.NotifySubscribers(activity, document); err != nil {
+		logger.Warn().Err(err).Str("document", activity.DocumentId).Msg("Error notifying the subscribers")
+	}
+}
+
+// notifyMentions notifies the users newly mentioned in the content of the document, the errors are only logged
+func (dc *DocumentController) notifyMentions(logger zerolog.Logger, userId string, document models.Document, previousContent string) {
+	if dc.NotificationService == nil {
+		return
+	}
+	if err := dc.NotificationService.NotifyMentions(userId, document, previousContent); err != nil {
+		logger.Warn().Err(err).Str("document", document.Id).Msg("Error notifying the mentioned users")
+	}
+}
+
+// subscribe makes the user follow the document, the errors are only logged
+func (dc *DocumentController) subscribe(logger zerolog.Logger, userId string, document models.Document) {
+	if dc.NotificationService == nil {
+		return
+	}
+	if err := dc.NotificationService.Subscribe(userId, document.Id); err != nil {
+		logger.Warn().Err(err).Str("document", document.Id).Msg("Error subscribing to the document")
+	}
+}
+
+// documentConflict answers with the current version of the document
+func documentConflict(ctx *fiber.Ctx, current models.Document) error {
+	ctx.Set(fiber.HeaderETag, current.ETag())
+	return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+		"error":    "Document has been modified",
+		"version":  current.Version,
+		"document": current,
+	})
+}
+
+// invalidProperties sends the error response when the properties of a row are not valid, ok is false in this case
+func invalidProperties(ctx *fiber.Ctx, logger zerolog.Logger, err error) (ok bool, response error) {
+	var validationError *models.ValidationError
+	if errors.As(err, &validationError) {
+		logger.Warn().Err(err).Msg("Invalid document properties")
+		return false, ctx.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": "Invalid properties", "errors": validationError.Errors})
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Error validating document properties")
+		return false, ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	return true, nil
+}
+
+// etagMatch checks an If-Match or If-None-Match header against the entity tag,
+// weak validators are compared as strong ones.
+func etagMatch(header string, etag string) bool {
+	if header == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
 // DeleteDocument godoc
 // @Summary Delete document
 // @Description Delete document
@@ -203,13 +972,21 @@ func (dc *DocumentController) UpdateDocument(ctx *fiber.Ctx) error {
 // @Produce json
 // @Param documentId path string true "Document Id"
 // @Success 204
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 423 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /api/v1/document/{documentId} [delete]
 func (dc *DocumentController) DeleteDocument(ctx *fiber.Ctx) error {
 	logger := dc.Logger.With().Str("event", "api.documents.delete").Logger()
 
+	userId := ctx.Locals("user_id").(string)
 	documentId := ctx.Params("documentId")
-	err := dc.DocumentService.DeleteDocument(documentId)
+	document, ok, err := checkDocumentAccess(ctx, logger, dc.AccessService, userId, documentId, true)
+	if !ok {
+		return err
+	}
+
+	err = dc.DocumentService.DeleteDocument(documentId)
 	if err != nil {
 		logger.Error().Err(err).Msg("Error deleting document")
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
@@ -225,6 +1002,9 @@ func (dc *DocumentController) DeleteDocument(ctx *fiber.Ctx) error {
 
 	fmt.Println("Favorites deleted successfully")
 
+	dc.recordActivity(logger, models.ActivityTypeDeleted, userId, document)
+	dc.publishDocumentEvent(logger, events.DocumentDeleted, userId, document)
+
 	logger.Debug().Str("document", documentId).Msg("Document deleted successfully")
 	return ctx.SendStatus(fiber.StatusNoContent)
 }
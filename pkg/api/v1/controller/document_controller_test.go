@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// spaceViewerAccess gives the viewer access to the space v, the space gone doesn't exist
+type spaceViewerAccess struct {
+	models.AccessService
+}
+
+func (spaceViewerAccess) GetSpaceAccess(userId string, spaceId string) (models.Space, models.AccessType, error) {
+	switch spaceId {
+	case "v":
+		return models.Space{Id: spaceId}, models.AccessTypeViewer, nil
+	case "gone":
+		return models.Space{}, "", gorm.ErrRecordNotFound
+	}
+	return models.Space{}, "", models.ErrAccessDenied
+}
+
+func TestCreateRootDocumentAccess(t *testing.T) {
+	tests := []struct {
+		name       string
+		spaceId    string
+		wantStatus int
+	}{
+		{"viewer of the space", "v", fiber.StatusForbidden},
+		{"space of other users", "other", fiber.StatusForbidden},
+		{"missing space", "gone", fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			dc := &DocumentController{AccessService: spaceViewerAccess{}, Logger: zerolog.Nop()}
+			app.Post("/document", func(ctx *fiber.Ctx) error {
+				ctx.Locals("user_id", "u")
+				return ctx.Next()
+			}, dc.CreateDocument)
+
+			request := httptest.NewRequest(fiber.MethodPost, "/document", strings.NewReader(`{"name":"page","space_id":"`+tt.spaceId+`"}`))
+			request.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			response, err := app.Test(request)
+			if err != nil {
+				t.Fatalf("POST /document unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", response.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
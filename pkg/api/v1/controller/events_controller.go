@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/events"
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/rs/zerolog"
+)
+
+type EventsController struct {
+	AccessService     models.AccessService
+	Events            events.Bus
+	HeartbeatInterval time.Duration
+	Logger            zerolog.Logger
+}
+
+const (
+	// eventsBufferSize is the number of events waiting to be sent to a stream, the next ones are dropped
+	eventsBufferSize = 64
+	// spaceAccessTTL is the time during which the access of a stream to a space is cached
+	spaceAccessTTL = time.Minute
+)
+
+// Stream godoc
+// @Summary Stream the live events
+// @Description Stream as server-sent events the changes of the documents of the spaces I can see,
+// @Description of my favorites and my new notifications
+// @Tags events
+// @Produce text/event-stream
+// @Param token query string false "Session token"
+// @Success 200
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/events [get]
+func (ec *EventsController) Stream(ctx *fiber.Ctx) error {
+	logger := ec.Logger.With().Str("event", "api.events.stream").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+
+	queue := make(chan events.Event, eventsBufferSize)
+	unsubscribe := ec.Events.Subscribe(func(event events.Event) {
+		select {
+		case queue <- event:
+		default:
+			logger.Warn().Str("user", userId).Str("type", string(event.Type)).Msg("Event stream is full, event dropped")
+		}
+	})
+
+	ctx.Set(fiber.HeaderContentType, "text/event-stream")
+	ctx.Set(fiber.HeaderCacheControl, "no-cache")
+	ctx.Set(fiber.HeaderConnection, "keep-alive")
+	ctx.Set("X-Accel-Buffering", "no")
+
+	filter := eventFilter{userId: userId, accessService: ec.AccessService, spaces: map[string]bool{}}
+	ctx.Status(fiber.StatusOK).Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		heartbeat := time.NewTicker(ec.HeartbeatInterval)
+		defer heartbeat.Stop()
+
+		logger.Debug().Str("user", userId).Msg("Event stream opened")
+		fmt.Fprintf(w, "retry: %d\n\n", (3 * time.Second).Milliseconds())
+		for {
+			if err := w.Flush(); err != nil {
+				logger.Debug().Str("user", userId).Msg("Event stream closed")
+				return
+			}
+
+			select {
+			case event := <-queue:
+				if !filter.allows(event) {
+					continue
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					logger.Error().Err(err).Msg("Error encoding the event")
+					continue
+				}
+				fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			}
+		}
+	})
+	return nil
+}
+
+// eventFilter selects the events sent to the stream of a user. The access to the spaces is cached
+// for a while to avoid a query per event, the access to the documents is checked for each event.
+type eventFilter struct {
+	userId        string
+	accessService models.AccessService
+	spaces        map[string]bool
+	refreshedAt   time.Time
+}
+
+// allows returns true when the user can see the event, the events of the documents are sent when the user
+// can access the document, deleted or not, and the other events of the spaces when the user is a member of the space
+func (f *eventFilter) allows(event events.Event) bool {
+	if event.UserId != "" {
+		return event.UserId == f.userId
+	}
+	if event.SpaceId == "" {
+		return false
+	}
+	if event.DocumentId != "" {
+		_, _, err := f.accessService.GetDocumentAccessUnscoped(f.userId, event.DocumentId)
+		return err == nil
+	}
+
+	if time.Since(f.refreshedAt) > spaceAccessTTL {
+		clear(f.spaces)
+		f.refreshedAt = time.Now()
+	}
+	member, ok := f.spaces[event.SpaceId]
+	if !ok {
+		_, _, err := f.accessService.GetSpaceAccess(f.userId, event.SpaceId)
+		member = err == nil
+		if err != nil && !errors.Is(err, models.ErrAccessDenied) {
+			return false
+		}
+		f.spaces[event.SpaceId] = member
+	}
+	return member
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/labbs/zotion/pkg/events"
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+// spaceMemberAccess gives the space s to its member, the document r is restricted and the document gone doesn't exist
+type spaceMemberAccess struct {
+	models.AccessService
+}
+
+func (spaceMemberAccess) GetSpaceAccess(userId string, spaceId string) (models.Space, models.AccessType, error) {
+	if spaceId != "s" {
+		return models.Space{}, "", models.ErrAccessDenied
+	}
+	return models.Space{Id: spaceId}, models.AccessTypeFull, nil
+}
+
+func (spaceMemberAccess) GetDocumentAccessUnscoped(userId string, documentId string) (models.Document, models.AccessType, error) {
+	switch documentId {
+	case "r":
+		return models.Document{}, "", models.ErrAccessDenied
+	case "gone":
+		return models.Document{}, "", gorm.ErrRecordNotFound
+	}
+	return models.Document{Id: documentId}, models.AccessTypeFull, nil
+}
+
+func (a spaceMemberAccess) GetDocumentAccess(userId string, documentId string) (models.Document, models.AccessType, error) {
+	return a.GetDocumentAccessUnscoped(userId, documentId)
+}
+
+func TestEventFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		event events.Event
+		want  bool
+	}{
+		{"event of the user", events.Event{UserId: "u"}, true},
+		{"event of another user", events.Event{UserId: "v"}, false},
+		{"event without space", events.Event{}, false},
+		{"event of the space", events.Event{SpaceId: "s"}, true},
+		{"event of another space", events.Event{SpaceId: "other"}, false},
+		{"document of the space", events.Event{SpaceId: "s", DocumentId: "d"}, true},
+		{"restricted document of the space", events.Event{SpaceId: "s", DocumentId: "r"}, false},
+		{"shared document of another space", events.Event{SpaceId: "other", DocumentId: "d"}, true},
+		{"purged document", events.Event{SpaceId: "s", DocumentId: "gone"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := &eventFilter{userId: "u", accessService: spaceMemberAccess{}, spaces: map[string]bool{}}
+			if got := filter.allows(tt.event); got != tt.want {
+				t.Errorf("allows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/events"
 	"github.com/labbs/zotion/pkg/models"
 	"github.com/rs/zerolog"
 )
@@ -10,9 +11,18 @@ type MeController struct {
 	SpaceService    models.SpaceService
 	UserService     models.UserService
 	FavoriteService models.FavoriteService
+	ActivityService models.ActivityService
+	DocumentService models.DocumentService
+	GuestService    models.GuestService
+	Events          events.Bus
 	Logger          zerolog.Logger
 }
 
+const (
+	defaultRecentDocumentsLimit = 20
+	maxRecentDocumentsLimit     = 50
+)
+
 // GetMySpaces godoc
 // @Summary Get my spaces
 // @Description Get my spaces
@@ -27,6 +37,12 @@ func (mc *MeController) GetMySpaces(ctx *fiber.Ctx) error {
 	logger := mc.Logger.With().Str("event", "api.spaces.get").Logger()
 
 	userId := ctx.Locals("user_id").(string)
+
+	// the guests don't see the spaces, only the documents they are invited to
+	if isGuest(ctx) {
+		return ctx.Status(fiber.StatusOK).JSON([]models.Space{})
+	}
+
 	groups, err := mc.UserService.GetGroupsByUserId(userId)
 	if err != nil {
 		logger.Error().Err(err).Msg("Error getting user groups")
@@ -37,11 +53,50 @@ func (mc *MeController) GetMySpaces(ctx *fiber.Ctx) error {
 		logger.Error().Err(err).Msg("Error getting user spaces")
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
 	}
+	if mc.GuestService != nil {
+		for i := range spaces {
+			members, err := mc.GuestService.MarkGuests(spaces[i].Members)
+			if err != nil {
+				logger.Warn().Err(err).Msg("Error marking the guest members")
+				break
+			}
+			spaces[i].Members = members
+		}
+	}
 
 	logger.Debug().Str("user", userId).Msg("User spaces retrieved successfully")
 	return ctx.Status(fiber.StatusOK).JSON(spaces)
 }
 
+// GetMySharedDocuments godoc
+// @Summary Get my shared documents
+// @Description Get the documents I'm invited to as a member of the document, directly or through my groups.
+// @Description It's the entry point of the guests who don't see the spaces.
+// @Tags me
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.Document
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/me/documents [get]
+func (mc *MeController) GetMySharedDocuments(ctx *fiber.Ctx) error {
+	logger := mc.Logger.With().Str("event", "api.me.shared_documents").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	groups, err := mc.UserService.GetGroupsByUserId(userId)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting user groups")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	documents, err := mc.DocumentService.GetDocumentsForMember(userId, groups)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting user shared documents")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	logger.Debug().Str("user", userId).Int("count", len(documents)).Msg("User shared documents retrieved successfully")
+	return ctx.Status(fiber.StatusOK).JSON(documents)
+}
+
 // GetMyProfile godoc
 // @Summary Get my profile
 // @Description Get my profile
@@ -68,6 +123,7 @@ func (mc *MeController) GetMyProfile(ctx *fiber.Ctx) error {
 			user.IsAdmin = true
 		}
 	}
+	user.IsGuest = isGuest(ctx)
 
 	user.Password = ""
 	logger.Debug().Str("user", userId).Msg("User profile retrieved successfully")
@@ -130,6 +186,8 @@ func (mc *MeController) AddFavorite(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
 	}
 
+	mc.publishFavoriteEvent(logger, events.FavoriteAdded, userId, documentId)
+
 	logger.Debug().Str("user", userId).Msg("User favorite added successfully")
 	return ctx.Status(fiber.StatusOK).JSON(favorites)
 }
@@ -163,6 +221,8 @@ func (mc *MeController) UnFavorite(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
 	}
 
+	mc.publishFavoriteEvent(logger, events.FavoriteRemoved, userId, documentId)
+
 	logger.Debug().Str("user", userId).Msg("User favorite removed successfully")
 	return ctx.Status(fiber.StatusOK).JSON(favorites)
 }
@@ -252,3 +312,47 @@ func (mc *MeController) ChangeMyPassword(ctx *fiber.Ctx) error {
 	logger.Debug().Str("user", userId).Msg("User password changed successfully")
 	return ctx.SendStatus(fiber.StatusOK)
 }
+
+// GetMyRecentDocuments godoc
+// @Summary Get my recent documents
+// @Description Get the documents I last viewed or edited, the most recent first
+// @Tags me
+// @Accept json
+// @Produce json
+// @Param kind query string false "viewed (default) or edited"
+// @Param limit query int false "Limit, 20 by default and 50 at most"
+// @Success 200 {array} models.RecentDocument
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/me/recent [get]
+func (mc *MeController) GetMyRecentDocuments(ctx *fiber.Ctx) error {
+	logger := mc.Logger.With().Str("event", "api.me.get_recent").Logger()
+
+	kind := models.RecentKind(ctx.Query("kind", string(models.RecentKindViewed)))
+	if kind != models.RecentKindViewed && kind != models.RecentKindEdited {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "The kind must be viewed or edited"})
+	}
+	limit := ctx.QueryInt("limit", defaultRecentDocumentsLimit)
+	if limit <= 0 {
+		limit = defaultRecentDocumentsLimit
+	}
+	limit = min(limit, maxRecentDocumentsLimit)
+
+	userId := ctx.Locals("user_id").(string)
+	recents, err := mc.ActivityService.GetRecentDocuments(userId, kind, limit)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting recent documents")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	logger.Debug().Str("user", userId).Int("count", len(recents)).Msg("Recent documents retrieved successfully")
+	return ctx.Status(fiber.StatusOK).JSON(recents)
+}
+
+// publishFavoriteEvent streams the change of the favorites to the other sessions of the user, the errors are only logged
+func (mc *MeController) publishFavoriteEvent(logger zerolog.Logger, eventType events.Type, userId string, documentId string) {
+	event := events.Event{Type: eventType, DocumentId: documentId, UserId: userId, ActorId: userId}
+	if err := mc.Events.Publish(event); err != nil {
+		logger.Warn().Err(err).Str("document", documentId).Msg("Error publishing the event")
+	}
+}
@@ -0,0 +1,376 @@
+package controller
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+type NotificationController struct {
+	NotificationService models.NotificationService
+	AccessService       models.AccessService
+	Logger              zerolog.Logger
+}
+
+const (
+	defaultNotificationsLimit = 50
+	maxNotificationsLimit     = 200
+	maxReminderNoteLength     = 500
+)
+
+// GetMyNotifications godoc
+// @Summary Get my notifications
+// @Description Get the notifications of my inbox, the most recent first, with the number of unread ones
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Param unread query bool false "Only the unread notifications"
+// @Param limit query int false "Limit, 50 by default and 200 at most"
+// @Param offset query int false "Offset"
+// @Success 200 {object} models.NotificationPage
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/me/notifications [get]
+func (nc *NotificationController) GetMyNotifications(ctx *fiber.Ctx) error {
+	logger := nc.Logger.With().Str("event", "api.notifications.get").Logger()
+
+	limit := ctx.QueryInt("limit", defaultNotificationsLimit)
+	if limit <= 0 {
+		limit = defaultNotificationsLimit
+	}
+	limit = min(limit, maxNotificationsLimit)
+	offset := max(ctx.QueryInt("offset"), 0)
+
+	userId := ctx.Locals("user_id").(string)
+	page, err := nc.NotificationService.GetNotifications(userId, ctx.QueryBool("unread"), limit, offset)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting notifications")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(page)
+}
+
+// MarkMyNotificationsRead godoc
+// @Summary Mark my notifications as read
+// @Description Mark the notifications as read, all the notifications when no id is given
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Param request body models.NotificationReadRequest false "Notification ids"
+// @Success 204
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/me/notifications/read [post]
+func (nc *NotificationController) MarkMyNotificationsRead(ctx *fiber.Ctx) error {
+	logger := nc.Logger.With().Str("event", "api.notifications.read").Logger()
+
+	var request models.NotificationReadRequest
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&request); err != nil {
+			logger.Error().Err(err).Msg("Error parsing request body")
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+
+	userId := ctx.Locals("user_id").(string)
+	if err := nc.NotificationService.MarkRead(userId, request.Ids); err != nil {
+		logger.Error().Err(err).Msg("Error marking notifications as read")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// MarkMyNotificationsUnread godoc
+// @Summary Mark my notifications as unread
+// @Description Mark the notifications as unread, all the notifications when no id is given
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Param request body models.NotificationReadRequest false "Notification ids"
+// @Success 204
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/me/notifications/unread [post]
+func (nc *NotificationController) MarkMyNotificationsUnread(ctx *fiber.Ctx) error {
+	logger := nc.Logger.With().Str("event", "api.notifications.unread").Logger()
+
+	var request models.NotificationReadRequest
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&request); err != nil {
+			logger.Error().Err(err).Msg("Error parsing request body")
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	}
+
+	userId := ctx.Locals("user_id").(string)
+	if err := nc.NotificationService.MarkUnread(userId, request.Ids); err != nil {
+		logger.Error().Err(err).Msg("Error marking notifications as unread")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// GetMyNotificationPreferences godoc
+// @Summary Get my notification preferences
+// @Description Get the events notifying me and the frequency of the email digests
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.NotificationPreferences
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/me/notifications/preferences [get]
+func (nc *NotificationController) GetMyNotificationPreferences(ctx *fiber.Ctx) error {
+	logger := nc.Logger.With().Str("event", "api.notifications.get_preferences").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	preferences, err := nc.NotificationService.GetPreferences(userId)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting notification preferences")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(preferences)
+}
+
+// UpdateMyNotificationPreferences godoc
+// @Summary Update my notification preferences
+// @Description Update the events notifying me and the frequency of the email digests, they are stored in my preferences
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Param preferences body models.NotificationPreferences true "Notification preferences"
+// @Success 200 {object} models.NotificationPreferences
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/me/notifications/preferences [put]
+func (nc *NotificationController) UpdateMyNotificationPreferences(ctx *fiber.Ctx) error {
+	logger := nc.Logger.With().Str("event", "api.notifications.update_preferences").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+
+	// the omitted fields keep their current value
+	preferences, err := nc.NotificationService.GetPreferences(userId)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting notification preferences")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	if err := ctx.BodyParser(&preferences); err != nil {
+		logger.Error().Err(err).Msg("Error parsing request body")
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	switch preferences.EmailDigest {
+	case models.DigestFrequencyOff, models.DigestFrequencyHourly, models.DigestFrequencyDaily:
+	default:
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "The email digest must be off, hourly or daily"})
+	}
+
+	if err := nc.NotificationService.UpdatePreferences(userId, preferences); err != nil {
+		logger.Error().Err(err).Msg("Error updating notification preferences")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	logger.Debug().Str("user", userId).Msg("Notification preferences updated successfully")
+	return ctx.Status(fiber.StatusOK).JSON(preferences)
+}
+
+// GetMySubscriptions godoc
+// @Summary Get my subscriptions
+// @Description Get the pages I follow, their changes notify me
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.Subscription
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/me/subscriptions [get]
+func (nc *NotificationController) GetMySubscriptions(ctx *fiber.Ctx) error {
+	logger := nc.Logger.With().Str("event", "api.subscriptions.get").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	subscriptions, err := nc.NotificationService.GetSubscriptions(userId)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting subscriptions")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(subscriptions)
+}
+
+// GetMySubscription godoc
+// @Summary Get my subscription to a page
+// @Description Get whether I follow the page
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Success 200 {object} models.JSONB
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/me/subscriptions/{documentId} [get]
+func (nc *NotificationController) GetMySubscription(ctx *fiber.Ctx) error {
+	logger := nc.Logger.With().Str("event", "api.subscriptions.get_one").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	documentId := ctx.Params("documentId")
+	subscribed, err := nc.NotificationService.IsSubscribed(userId, documentId)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting subscription")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"document_id": documentId, "subscribed": subscribed})
+}
+
+// Subscribe godoc
+// @Summary Follow a page
+// @Description Follow a page, its changes notify me
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Success 204
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/me/subscriptions/{documentId} [post]
+func (nc *NotificationController) Subscribe(ctx *fiber.Ctx) error {
+	logger := nc.Logger.With().Str("event", "api.subscriptions.subscribe").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	documentId := ctx.Params("documentId")
+	if _, ok, response := checkDocumentAccess(ctx, logger, nc.AccessService, userId, documentId, false); !ok {
+		return response
+	}
+
+	if err := nc.NotificationService.Subscribe(userId, documentId); err != nil {
+		logger.Error().Err(err).Msg("Error subscribing to the document")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	logger.Debug().Str("user", userId).Str("document", documentId).Msg("Subscribed to the document")
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// Unsubscribe godoc
+// @Summary Unfollow a page
+// @Description Unfollow a page
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Success 204
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/me/subscriptions/{documentId} [delete]
+func (nc *NotificationController) Unsubscribe(ctx *fiber.Ctx) error {
+	logger := nc.Logger.With().Str("event", "api.subscriptions.unsubscribe").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	documentId := ctx.Params("documentId")
+	if err := nc.NotificationService.Unsubscribe(userId, documentId); err != nil {
+		logger.Error().Err(err).Msg("Error unsubscribing from the document")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	logger.Debug().Str("user", userId).Str("document", documentId).Msg("Unsubscribed from the document")
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// GetMyReminders godoc
+// @Summary Get my reminders
+// @Description Get my reminders, the next ones first
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.Reminder
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/me/reminders [get]
+func (nc *NotificationController) GetMyReminders(ctx *fiber.Ctx) error {
+	logger := nc.Logger.With().Str("event", "api.reminders.get").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	reminders, err := nc.NotificationService.GetReminders(userId)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting reminders")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(reminders)
+}
+
+// CreateReminder godoc
+// @Summary Create a reminder
+// @Description Create a reminder about a document, it notifies me at the given time
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Param reminder body models.ReminderRequest true "Reminder"
+// @Success 201 {object} models.Reminder
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/me/reminders [post]
+func (nc *NotificationController) CreateReminder(ctx *fiber.Ctx) error {
+	logger := nc.Logger.With().Str("event", "api.reminders.create").Logger()
+
+	var request models.ReminderRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		logger.Error().Err(err).Msg("Error parsing request body")
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if request.DocumentId == "" || request.RemindAt.IsZero() {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "The document and the time of the reminder are required"})
+	}
+	if request.RemindAt.Before(time.Now()) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "The time of the reminder must be in the future"})
+	}
+	if len(request.Note) > maxReminderNoteLength {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "The note is too long"})
+	}
+
+	userId := ctx.Locals("user_id").(string)
+	if _, ok, response := checkDocumentAccess(ctx, logger, nc.AccessService, userId, request.DocumentId, false); !ok {
+		return response
+	}
+
+	reminder, err := nc.NotificationService.CreateReminder(userId, request)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error creating reminder")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	logger.Debug().Str("reminder", reminder.Id).Msg("Reminder created successfully")
+	return ctx.Status(fiber.StatusCreated).JSON(reminder)
+}
+
+// DeleteReminder godoc
+// @Summary Delete a reminder
+// @Description Delete one of my reminders
+// @Tags notification
+// @Accept json
+// @Produce json
+// @Param reminderId path string true "Reminder Id"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/me/reminders/{reminderId} [delete]
+func (nc *NotificationController) DeleteReminder(ctx *fiber.Ctx) error {
+	logger := nc.Logger.With().Str("event", "api.reminders.delete").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	reminderId := ctx.Params("reminderId")
+	err := nc.NotificationService.DeleteReminder(userId, reminderId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Reminder not found"})
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Error deleting reminder")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	logger.Debug().Str("reminder", reminderId).Msg("Reminder deleted successfully")
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,278 @@
+package controller
+
+import (
+	"errors"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+type PresenceController struct {
+	AccessService     models.AccessService
+	UserService       models.UserService
+	PresenceService   models.PresenceService
+	HeartbeatInterval time.Duration
+	Timeout           time.Duration
+	Logger            zerolog.Logger
+}
+
+// Authorize godoc
+// @Summary Check the access to the document before the presence websocket upgrade
+// @Description Check the access to the document before the presence websocket upgrade
+// @Tags presence
+// @Param documentId path string true "Document Id"
+// @Param token query string false "Session token"
+// @Success 101
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/ws/presence/{documentId} [get]
+func (pc *PresenceController) Authorize(ctx *fiber.Ctx) error {
+	logger := pc.Logger.With().Str("event", "api.presence.authorize").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	documentId := ctx.Params("documentId")
+
+	document, access, err := pc.AccessService.GetDocumentAccess(userId, documentId)
+	if errors.Is(err, models.ErrAccessDenied) {
+		logger.Warn().Str("user", userId).Str("document", documentId).Msg("User is not a member of the document")
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to access this resource"})
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting document access")
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Document not found"})
+	}
+
+	ctx.Context().SetUserValue("space_id", document.SpaceId)
+	ctx.Context().SetUserValue("read_only", !access.CanEdit())
+	return ctx.Next()
+}
+
+// Connect handles the presence websocket of a document.
+// The client sends {"type":"heartbeat","mode":"editing","cursor":{...}} at least once per timeout,
+// the server sends {"type":"presence","users":[...]} when the presences of the document change.
+func (pc *PresenceController) Connect(conn *websocket.Conn) {
+	logger := pc.Logger.With().Str("event", "api.presence.connect").Logger()
+
+	userId := conn.Locals("user_id").(string)
+	readOnly := conn.Locals("read_only").(bool)
+
+	user, err := pc.UserService.GetById(userId)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting user")
+		return
+	}
+
+	presence := models.Presence{
+		ConnectionId: utils.UUIDv4(),
+		UserId:       user.Id,
+		UserName:     user.Name,
+		AvatarUrl:    user.AvatarUrl,
+		DocumentId:   conn.Params("documentId"),
+		SpaceId:      conn.Locals("space_id").(string),
+		Mode:         models.PresenceModeViewing,
+	}
+
+	if err := pc.PresenceService.Touch(presence); err != nil {
+		logger.Error().Err(err).Msg("Error registering presence")
+		return
+	}
+	defer func() {
+		if err := pc.PresenceService.Remove(presence); err != nil {
+			logger.Error().Err(err).Msg("Error removing presence")
+		}
+	}()
+
+	// read the client messages, the connection is dropped without heartbeat before the timeout
+	messages := make(chan models.PresenceMessage)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer close(messages)
+		for {
+			var message models.PresenceMessage
+			conn.SetReadDeadline(time.Now().Add(pc.Timeout))
+			if err := conn.ReadJSON(&message); err != nil {
+				return
+			}
+			messages <- message
+		}
+	}()
+
+	ticker := time.NewTicker(pc.HeartbeatInterval)
+	defer ticker.Stop()
+
+	var lastSignature string
+	send := func() error {
+		users, err := pc.PresenceService.GetDocumentPresence(presence.DocumentId)
+		if err != nil {
+			return err
+		}
+		signature := presenceSignature(users)
+		if signature == lastSignature {
+			return nil
+		}
+		lastSignature = signature
+		return conn.WriteJSON(models.PresenceMessage{Type: "presence", DocumentId: presence.DocumentId, Users: users})
+	}
+
+	if err := send(); err != nil {
+		logger.Debug().Err(err).Msg("Error sending presences")
+	}
+
+loop:
+	for {
+		select {
+		case message, ok := <-messages:
+			if !ok {
+				break loop
+			}
+			if message.Type != "heartbeat" {
+				continue
+			}
+			presence.Mode = models.PresenceModeViewing
+			if message.Mode == models.PresenceModeEditing && !readOnly {
+				presence.Mode = models.PresenceModeEditing
+			}
+			presence.Cursor = message.Cursor
+			if err := pc.PresenceService.Touch(presence); err != nil {
+				logger.Error().Err(err).Msg("Error updating presence")
+			}
+			if err := send(); err != nil {
+				logger.Debug().Err(err).Msg("Error sending presences")
+				break loop
+			}
+		case <-ticker.C:
+			if err := pc.PresenceService.Touch(presence); err != nil {
+				logger.Error().Err(err).Msg("Error updating presence")
+			}
+			if err := send(); err != nil {
+				logger.Debug().Err(err).Msg("Error sending presences")
+				break loop
+			}
+		}
+	}
+
+	// the reader must be stopped before the connection is released
+	conn.Close()
+	for range messages {
+	}
+	<-done
+}
+
+// presenceSignature identifies a list of presences without the heartbeat times
+func presenceSignature(presences []models.Presence) string {
+	entries := make([]models.Presence, len(presences))
+	for i, presence := range presences {
+		presence.LastSeen = time.Time{}
+		entries[i] = presence
+	}
+	signature, _ := json.Marshal(entries)
+	return string(signature)
+}
+
+// GetDocumentPresence godoc
+// @Summary Get the users on a document
+// @Description Get the users viewing or editing a document
+// @Tags presence
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Success 200 {array} models.Presence
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/presence/document/{documentId} [get]
+func (pc *PresenceController) GetDocumentPresence(ctx *fiber.Ctx) error {
+	logger := pc.Logger.With().Str("event", "api.presence.get_document").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	documentId := ctx.Params("documentId")
+
+	if _, _, err := pc.AccessService.GetDocumentAccess(userId, documentId); err != nil {
+		if errors.Is(err, models.ErrAccessDenied) {
+			logger.Warn().Str("user", userId).Str("document", documentId).Msg("User is not a member of the document")
+			return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to access this resource"})
+		}
+		logger.Error().Err(err).Msg("Error getting document access")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	presences, err := pc.PresenceService.GetDocumentPresence(documentId)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting document presences")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	logger.Debug().Str("document", documentId).Int("count", len(presences)).Msg("Document presences retrieved successfully")
+	return ctx.Status(fiber.StatusOK).JSON(presences)
+}
+
+// GetSpacePresence godoc
+// @Summary Get the viewers of a space
+// @Description Get the users viewing or editing a document of a space
+// @Tags presence
+// @Accept json
+// @Produce json
+// @Param spaceId path string true "Space Id"
+// @Success 200 {array} models.Presence
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/presence/space/{spaceId} [get]
+func (pc *PresenceController) GetSpacePresence(ctx *fiber.Ctx) error {
+	logger := pc.Logger.With().Str("event", "api.presence.get_space").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	spaceId := ctx.Params("spaceId")
+
+	if _, _, err := pc.AccessService.GetSpaceAccess(userId, spaceId); err != nil {
+		if errors.Is(err, models.ErrAccessDenied) {
+			logger.Warn().Str("user", userId).Str("space", spaceId).Msg("User is not a member of the space")
+			return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to access this resource"})
+		}
+		logger.Error().Err(err).Msg("Error getting space access")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	presences, err := pc.PresenceService.GetSpacePresence(spaceId)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting space presences")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	presences, err = pc.accessiblePresences(userId, presences)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting document access")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	logger.Debug().Str("space", spaceId).Int("count", len(presences)).Msg("Space presences retrieved successfully")
+	return ctx.Status(fiber.StatusOK).JSON(presences)
+}
+
+// accessiblePresences returns the presences on the documents the user can access,
+// the viewers of the documents breaking the inheritance are hidden from the other members of the space
+func (pc *PresenceController) accessiblePresences(userId string, presences []models.Presence) ([]models.Presence, error) {
+	allowed := make(map[string]bool)
+	accessible := []models.Presence{}
+	for _, presence := range presences {
+		ok, checked := allowed[presence.DocumentId]
+		if !checked {
+			// the document may have been deleted since the user opened it
+			_, _, err := pc.AccessService.GetDocumentAccess(userId, presence.DocumentId)
+			if err != nil && !errors.Is(err, models.ErrAccessDenied) && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, err
+			}
+			ok = err == nil
+			allowed[presence.DocumentId] = ok
+		}
+		if ok {
+			accessible = append(accessible, presence)
+		}
+	}
+	return accessible, nil
+}
@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/labbs/zotion/pkg/models"
+)
+
+func TestAccessiblePresences(t *testing.T) {
+	tests := []struct {
+		name     string
+		presence models.Presence
+		want     bool
+	}{
+		{"document of the space", models.Presence{UserId: "v", DocumentId: "d", SpaceId: "s"}, true},
+		{"restricted document", models.Presence{UserId: "v", DocumentId: "r", SpaceId: "s"}, false},
+		{"deleted document", models.Presence{UserId: "v", DocumentId: "gone", SpaceId: "s"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc := &PresenceController{AccessService: spaceMemberAccess{}}
+			presences, err := pc.accessiblePresences("u", []models.Presence{tt.presence, tt.presence})
+			if err != nil {
+				t.Fatalf("accessiblePresences() unexpected error: %v", err)
+			}
+			if got := len(presences) == 2; got != tt.want {
+				t.Errorf("accessiblePresences() = %v, want visible %v", presences, tt.want)
+			}
+		})
+	}
+}
@@ -1,24 +1,28 @@
 package controller
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/labbs/zotion/pkg/models"
 	"github.com/rs/zerolog"
+	"gorm.io/gorm"
 )
 
 type PublicController struct {
-	DocumentController models.DocumentService
-	Logger             zerolog.Logger
+	PublicService models.PublicService
+	Logger        zerolog.Logger
 }
 
 // GetPublicDocumentBySlug godoc
 // @Summary Get public document by slug
-// @Description Get public document by slug
-// @Tags document
+// @Description Get a published document by slug, a document is published when it's public or when an ancestor publishes its subpages.
+// @Description The members, the authors and the metadata are not returned.
+// @Tags public
 // @Accept json
 // @Produce json
 // @Param slug path string true "Document Slug"
-// @Success 200 {object} models.Document
+// @Success 200 {object} models.PublicDocument
 // @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /api/v1/public/document/slug/{slug} [get]
@@ -26,17 +30,71 @@ func (pc *PublicController) GetPublicDocumentBySlug(ctx *fiber.Ctx) error {
 	logger := pc.Logger.With().Str("event", "api.public_documents.get").Logger()
 
 	slug := ctx.Params("slug")
-	document, err := pc.DocumentController.GetDocumentBySlug(slug)
-	if err != nil {
-		logger.Error().Err(err).Msg("Error getting document by slug")
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	document, err := pc.PublicService.GetPublishedDocumentBySlug(slug)
+	if ok, response := publicError(ctx, logger, err); !ok {
+		return response
 	}
 
-	if !document.Public {
-		logger.Error().Msg("Document is not public")
-		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Document not exist"})
+	logger.Debug().Str("document", slug).Msg("Document retrieved successfully")
+	return ctx.Status(fiber.StatusOK).JSON(models.NewPublicDocument(document))
+}
+
+// GetPublicDocumentById godoc
+// @Summary Get public document
+// @Description Get a published document, the members, the authors and the metadata are not returned
+// @Tags public
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Success 200 {object} models.PublicDocument
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/public/document/{documentId} [get]
+func (pc *PublicController) GetPublicDocumentById(ctx *fiber.Ctx) error {
+	logger := pc.Logger.With().Str("event", "api.public_documents.get_by_id").Logger()
+
+	documentId := ctx.Params("documentId")
+	document, err := pc.PublicService.GetPublishedDocumentById(documentId)
+	if ok, response := publicError(ctx, logger, err); !ok {
+		return response
 	}
 
-	logger.Debug().Str("document", slug).Msg("Document retrieved successfully")
-	return ctx.Status(fiber.StatusOK).JSON(document)
+	logger.Debug().Str("document", documentId).Msg("Document retrieved successfully")
+	return ctx.Status(fiber.StatusOK).JSON(models.NewPublicDocument(document))
+}
+
+// GetPublicNavigation godoc
+// @Summary Get public navigation
+// @Description Get the navigation of the published tree of a document, from its highest published ancestor down to the published pages
+// @Tags public
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Success 200 {object} models.PublicNavNode
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/public/document/{documentId}/nav [get]
+func (pc *PublicController) GetPublicNavigation(ctx *fiber.Ctx) error {
+	logger := pc.Logger.With().Str("event", "api.public_documents.nav").Logger()
+
+	documentId := ctx.Params("documentId")
+	tree, err := pc.PublicService.GetPublishedTree(documentId)
+	if ok, response := publicError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Debug().Str("document", documentId).Str("root", tree.Id).Msg("Navigation retrieved successfully")
+	return ctx.Status(fiber.StatusOK).JSON(tree)
+}
+
+// publicError writes the response of the errors of the public service, the unpublished documents are not found
+func publicError(ctx *fiber.Ctx, logger zerolog.Logger, err error) (bool, error) {
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return false, ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Document not exist"})
+	}
+	logger.Error().Err(err).Msg("Error getting public document")
+	return false, ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
 }
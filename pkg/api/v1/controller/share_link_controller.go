@@ -0,0 +1,295 @@
+package controller
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// sharePasswordHeader is the header sending the password of a protected share link
+const sharePasswordHeader = "X-Share-Password"
+
+type ShareLinkController struct {
+	ShareLinkService models.ShareLinkService
+	AccessService    models.AccessService
+	Logger           zerolog.Logger
+}
+
+// GetShareLinks godoc
+// @Summary Get document share links
+// @Description Get the share links of a document with their views, the revoked links included. The editors of the document can see them.
+// @Tags share
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Success 200 {array} models.ShareLink
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/share-link/document/{documentId} [get]
+func (slc *ShareLinkController) GetShareLinks(ctx *fiber.Ctx) error {
+	logger := slc.Logger.With().Str("event", "api.share_links.get").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	document, ok, err := checkDocumentAccess(ctx, logger, slc.AccessService, userId, ctx.Params("documentId"), true)
+	if !ok {
+		return err
+	}
+
+	links, err := slc.ShareLinkService.GetShareLinks(document.Id)
+	if ok, response := shareLinkError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Debug().Str("document", document.Id).Int("count", len(links)).Msg("Share links retrieved successfully")
+	return ctx.Status(fiber.StatusOK).JSON(links)
+}
+
+// CreateShareLink godoc
+// @Summary Create document share link
+// @Description Create a link giving access to a document without an account, with a viewer or comment access,
+// @Description an optional expiry date and password, and optionally the subpages of the document
+// @Tags share
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Param link body models.ShareLinkRequest true "Share link"
+// @Success 201 {object} models.ShareLink
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/share-link/document/{documentId} [post]
+func (slc *ShareLinkController) CreateShareLink(ctx *fiber.Ctx) error {
+	logger := slc.Logger.With().Str("event", "api.share_links.create").Logger()
+
+	var request models.ShareLinkRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		logger.Error().Err(err).Msg("Error parsing request body")
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	userId := ctx.Locals("user_id").(string)
+	document, ok, err := checkDocumentAccess(ctx, logger, slc.AccessService, userId, ctx.Params("documentId"), true)
+	if !ok {
+		return err
+	}
+
+	link, err := slc.ShareLinkService.CreateShareLink(userId, document, request)
+	if ok, response := shareLinkError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Info().Str("document", document.Id).Str("link", link.Id).Str("user", userId).Msg("Share link created")
+	return ctx.Status(fiber.StatusCreated).JSON(link)
+}
+
+// UpdateShareLink godoc
+// @Summary Update share link
+// @Description Change the access, the expiry date, the password or the subpages of a share link, the token is kept.
+// @Description Without password the current password is kept, an empty password removes it.
+// @Tags share
+// @Accept json
+// @Produce json
+// @Param linkId path string true "Share link Id"
+// @Param link body models.ShareLinkRequest true "Share link"
+// @Success 200 {object} models.ShareLink
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/share-link/{linkId} [put]
+func (slc *ShareLinkController) UpdateShareLink(ctx *fiber.Ctx) error {
+	logger := slc.Logger.With().Str("event", "api.share_links.update").Logger()
+
+	var request models.ShareLinkRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		logger.Error().Err(err).Msg("Error parsing request body")
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	userId := ctx.Locals("user_id").(string)
+	link, ok, err := slc.getManagedShareLink(ctx, logger, userId, ctx.Params("linkId"))
+	if !ok {
+		return err
+	}
+	if link.RevokedAt != nil {
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "The share link is revoked"})
+	}
+
+	link, err = slc.ShareLinkService.UpdateShareLink(link, request)
+	if ok, response := shareLinkError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Debug().Str("link", link.Id).Msg("Share link updated successfully")
+	return ctx.Status(fiber.StatusOK).JSON(link)
+}
+
+// RevokeShareLink godoc
+// @Summary Revoke share link
+// @Description Revoke a share link, it can't be opened anymore but it's kept with its views
+// @Tags share
+// @Accept json
+// @Produce json
+// @Param linkId path string true "Share link Id"
+// @Success 200 {object} models.ShareLink
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/share-link/{linkId} [delete]
+func (slc *ShareLinkController) RevokeShareLink(ctx *fiber.Ctx) error {
+	logger := slc.Logger.With().Str("event", "api.share_links.revoke").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	link, ok, err := slc.getManagedShareLink(ctx, logger, userId, ctx.Params("linkId"))
+	if !ok {
+		return err
+	}
+
+	link, err = slc.ShareLinkService.RevokeShareLink(link)
+	if ok, response := shareLinkError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Info().Str("link", link.Id).Str("user", userId).Msg("Share link revoked")
+	return ctx.Status(fiber.StatusOK).JSON(link)
+}
+
+// GetSharedDocument godoc
+// @Summary Open share link
+// @Description Get the document of a share link without an account. The password of a protected link is sent in the X-Share-Password header.
+// @Tags public
+// @Accept json
+// @Produce json
+// @Param token path string true "Share link token"
+// @Param X-Share-Password header string false "Password of the share link"
+// @Success 200 {object} models.SharedDocument
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/public/share/{token} [get]
+func (slc *ShareLinkController) GetSharedDocument(ctx *fiber.Ctx) error {
+	return slc.sharedDocument(ctx, "")
+}
+
+// GetSharedSubpage godoc
+// @Summary Get shared subpage
+// @Description Get a subpage of the document of a share link including its subpages
+// @Tags public
+// @Accept json
+// @Produce json
+// @Param token path string true "Share link token"
+// @Param documentId path string true "Document Id"
+// @Param X-Share-Password header string false "Password of the share link"
+// @Success 200 {object} models.SharedDocument
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/public/share/{token}/document/{documentId} [get]
+func (slc *ShareLinkController) GetSharedSubpage(ctx *fiber.Ctx) error {
+	return slc.sharedDocument(ctx, ctx.Params("documentId"))
+}
+
+// GetSharedPages godoc
+// @Summary Get shared pages
+// @Description Get the children of a page of a share link to build the navigation, the children of the shared document without parent_id.
+// @Description The list is empty when the link doesn't include the subpages.
+// @Tags public
+// @Accept json
+// @Produce json
+// @Param token path string true "Share link token"
+// @Param parent_id query string false "Parent page Id"
+// @Param X-Share-Password header string false "Password of the share link"
+// @Success 200 {array} models.PublicPage
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/public/share/{token}/pages [get]
+func (slc *ShareLinkController) GetSharedPages(ctx *fiber.Ctx) error {
+	logger := slc.Logger.With().Str("event", "api.share_links.pages").Logger()
+
+	link, err := slc.ShareLinkService.OpenShareLink(ctx.Params("token"), ctx.Get(sharePasswordHeader))
+	if ok, response := shareLinkError(ctx, logger, err); !ok {
+		return response
+	}
+
+	documents, err := slc.ShareLinkService.GetSharedPages(link, ctx.Query("parent_id"))
+	if ok, response := shareLinkError(ctx, logger, err); !ok {
+		return response
+	}
+
+	pages := make([]models.PublicPage, 0, len(documents))
+	for _, document := range documents {
+		pages = append(pages, models.NewPublicPage(document))
+	}
+
+	logger.Debug().Str("link", link.Id).Int("count", len(pages)).Msg("Shared pages retrieved successfully")
+	return ctx.Status(fiber.StatusOK).JSON(pages)
+}
+
+// sharedDocument writes the shared document, or its subpage, and counts the view
+func (slc *ShareLinkController) sharedDocument(ctx *fiber.Ctx, documentId string) error {
+	logger := slc.Logger.With().Str("event", "api.share_links.open").Logger()
+
+	link, err := slc.ShareLinkService.OpenShareLink(ctx.Params("token"), ctx.Get(sharePasswordHeader))
+	if ok, response := shareLinkError(ctx, logger, err); !ok {
+		return response
+	}
+
+	document, err := slc.ShareLinkService.GetSharedDocument(link, documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Document not found"})
+	}
+	if ok, response := shareLinkError(ctx, logger, err); !ok {
+		return response
+	}
+
+	if err := slc.ShareLinkService.RecordView(link); err != nil {
+		logger.Warn().Err(err).Str("link", link.Id).Msg("Error counting the view of the share link")
+	}
+
+	ctx.Set(fiber.HeaderCacheControl, "private, no-store")
+	logger.Debug().Str("link", link.Id).Str("document", document.Id).Msg("Shared document retrieved successfully")
+	return ctx.Status(fiber.StatusOK).JSON(models.SharedDocument{
+		Access:          link.Access,
+		IncludeSubpages: link.IncludeSubpages,
+		RootId:          link.DocumentId,
+		ExpiresAt:       link.ExpiresAt,
+		Document:        models.NewPublicDocument(document),
+	})
+}
+
+// getManagedShareLink returns the share link if the user can edit its document
+func (slc *ShareLinkController) getManagedShareLink(ctx *fiber.Ctx, logger zerolog.Logger, userId string, linkId string) (link models.ShareLink, ok bool, err error) {
+	link, err = slc.ShareLinkService.GetShareLinkById(linkId)
+	if ok, response := shareLinkError(ctx, logger, err); !ok {
+		return link, false, response
+	}
+	if _, ok, response := checkDocumentAccess(ctx, logger, slc.AccessService, userId, link.DocumentId, true); !ok {
+		return link, false, response
+	}
+	return link, true, nil
+}
+
+// shareLinkError writes the response of the errors of the share link service, ok is true when there is no error
+func shareLinkError(ctx *fiber.Ctx, logger zerolog.Logger, err error) (bool, error) {
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return false, ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Share link not found"})
+	case errors.Is(err, models.ErrShareLinkExpired):
+		return false, ctx.Status(fiber.StatusGone).JSON(fiber.Map{"error": "The share link is expired or revoked"})
+	case errors.Is(err, models.ErrShareLinkPassword):
+		return false, ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "A valid password is required", "password_required": true})
+	case errors.Is(err, models.ErrShareLinkAccess):
+		return false, ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	logger.Error().Err(err).Msg("Error processing share link request")
+	return false, ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+}
@@ -0,0 +1,263 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/labbs/zotion/pkg/site"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+const (
+	// feedLength is the number of pages of the feeds
+	feedLength = 30
+	// siteCacheControl lets the caches and the crawlers keep the public pages for a while
+	siteCacheControl = "public, max-age=300"
+	// sitePrivateCacheControl keeps the pages with the urls of the request host out of the shared caches
+	sitePrivateCacheControl = "private, max-age=300"
+)
+
+// SiteController renders the published documents as html pages with their sitemap and feeds
+type SiteController struct {
+	PublicService models.PublicService
+	SpaceService  models.SpaceService
+	Logger        zerolog.Logger
+}
+
+// GetPage godoc
+// @Summary Get public page
+// @Description Render a published document as an html page with its SEO and Open Graph metadata.
+// @Description The url without slug or with an outdated slug redirects to the canonical url.
+// @Tags site
+// @Produce html
+// @Param documentId path string true "Document Id"
+// @Param slug path string false "Document Slug"
+// @Success 200
+// @Success 301
+// @Success 304
+// @Failure 404
+// @Failure 500
+// @Router /p/{documentId}/{slug} [get]
+func (sc *SiteController) GetPage(ctx *fiber.Ctx) error {
+	logger := sc.Logger.With().Str("event", "site.page").Logger()
+
+	document, err := sc.PublicService.GetPublishedDocumentById(ctx.Params("documentId"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).SendString("Page not found")
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting public document")
+		return ctx.Status(fiber.StatusInternalServerError).SendString("Internal server error")
+	}
+
+	baseUrl := siteBaseUrl(ctx)
+	canonicalUrl := site.PageUrl(baseUrl, document.Id, document.Slug)
+	if slug, _ := url.PathUnescape(ctx.Params("slug")); slug != document.Slug {
+		setSiteCacheControl(ctx)
+		return ctx.Redirect(canonicalUrl, fiber.StatusMovedPermanently)
+	}
+
+	page := site.Page{
+		SiteName:     config.Server.SiteName,
+		BaseUrl:      baseUrl,
+		Title:        document.Name,
+		Description:  site.PlainText(document.Content, site.DescriptionLength),
+		Icon:         document.Config.Icon,
+		CanonicalUrl: canonicalUrl,
+		FeedUrl:      site.FeedUrl(baseUrl, document.SpaceId, "atom"),
+		Content:      site.RenderHTML(document.Content),
+		UpdatedAt:    document.UpdatedAt,
+		CurrentId:    document.Id,
+	}
+	tree, err := sc.PublicService.GetPublishedTree(document.Id)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting public navigation")
+		return ctx.Status(fiber.StatusInternalServerError).SendString("Internal server error")
+	}
+	if len(tree.Children) > 0 {
+		page.Nav = []models.PublicNavNode{tree}
+	}
+
+	var body bytes.Buffer
+	if err := site.RenderPage(&body, page); err != nil {
+		logger.Error().Err(err).Msg("Error rendering public page")
+		return ctx.Status(fiber.StatusInternalServerError).SendString("Internal server error")
+	}
+
+	logger.Debug().Str("document", document.Id).Msg("Public page rendered successfully")
+	return sendCached(ctx, fiber.MIMETextHTMLCharsetUTF8, body.Bytes(), document.UpdatedAt)
+}
+
+// GetSitemap godoc
+// @Summary Get sitemap
+// @Description Get the sitemap of the published documents of all the spaces
+// @Tags site
+// @Produce xml
+// @Success 200
+// @Success 304
+// @Failure 500
+// @Router /sitemap.xml [get]
+func (sc *SiteController) GetSitemap(ctx *fiber.Ctx) error {
+	logger := sc.Logger.With().Str("event", "site.sitemap").Logger()
+
+	documents, err := sc.PublicService.GetPublishedDocuments("", 0)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting published documents")
+		return ctx.Status(fiber.StatusInternalServerError).SendString("Internal server error")
+	}
+
+	body, err := site.NewSitemap(siteBaseUrl(ctx), documents).Marshal()
+	if err != nil {
+		logger.Error().Err(err).Msg("Error encoding sitemap")
+		return ctx.Status(fiber.StatusInternalServerError).SendString("Internal server error")
+	}
+
+	logger.Debug().Int("count", len(documents)).Msg("Sitemap generated successfully")
+	return sendCached(ctx, fiber.MIMEApplicationXMLCharsetUTF8, body, lastModified(documents))
+}
+
+// GetRobots godoc
+// @Summary Get robots.txt
+// @Description Get the robots.txt of the instance, the crawlers are allowed on the public pages only
+// @Tags site
+// @Produce plain
+// @Success 200
+// @Router /robots.txt [get]
+func (sc *SiteController) GetRobots(ctx *fiber.Ctx) error {
+	robots := "User-agent: *\nAllow: /p/\nAllow: /s/\nDisallow: /api/\n\nSitemap: " + siteBaseUrl(ctx) + "/sitemap.xml\n"
+	setSiteCacheControl(ctx)
+	ctx.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+	return ctx.SendString(robots)
+}
+
+// GetRSSFeed godoc
+// @Summary Get space RSS feed
+// @Description Get the RSS feed of the recently updated published documents of a space
+// @Tags site
+// @Produce xml
+// @Param spaceId path string true "Space Id"
+// @Success 200
+// @Success 304
+// @Failure 404
+// @Failure 500
+// @Router /s/{spaceId}/rss.xml [get]
+func (sc *SiteController) GetRSSFeed(ctx *fiber.Ctx) error {
+	return sc.feed(ctx, "rss", fiber.MIMEApplicationXMLCharsetUTF8)
+}
+
+// GetAtomFeed godoc
+// @Summary Get space Atom feed
+// @Description Get the Atom feed of the recently updated published documents of a space
+// @Tags site
+// @Produce xml
+// @Param spaceId path string true "Space Id"
+// @Success 200
+// @Success 304
+// @Failure 404
+// @Failure 500
+// @Router /s/{spaceId}/atom.xml [get]
+func (sc *SiteController) GetAtomFeed(ctx *fiber.Ctx) error {
+	return sc.feed(ctx, "atom", "application/atom+xml; charset=utf-8")
+}
+
+// feed writes the feed of the space in the format, the spaces without published document are not found
+func (sc *SiteController) feed(ctx *fiber.Ctx, format string, contentType string) error {
+	logger := sc.Logger.With().Str("event", "site.feed").Str("format", format).Logger()
+
+	spaceId := ctx.Params("spaceId")
+	documents, err := sc.PublicService.GetPublishedDocuments(spaceId, feedLength)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting published documents")
+		return ctx.Status(fiber.StatusInternalServerError).SendString("Internal server error")
+	}
+	if len(documents) == 0 {
+		return ctx.Status(fiber.StatusNotFound).SendString("Feed not found")
+	}
+
+	space, err := sc.SpaceService.GetSpaceById(spaceId)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting space")
+		return ctx.Status(fiber.StatusInternalServerError).SendString("Internal server error")
+	}
+
+	baseUrl := siteBaseUrl(ctx)
+	feed := site.Feed{
+		Title:   space.Name + " - " + config.Server.SiteName,
+		Link:    site.PageUrl(baseUrl, documents[0].Id, documents[0].Slug),
+		SelfUrl: site.FeedUrl(baseUrl, spaceId, format),
+		Updated: documents[0].UpdatedAt,
+	}
+	for _, document := range documents {
+		feed.Items = append(feed.Items, site.NewFeedItem(baseUrl, document))
+	}
+
+	var body []byte
+	if format == "rss" {
+		body, err = feed.RSS()
+	} else {
+		body, err = feed.Atom()
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Error encoding feed")
+		return ctx.Status(fiber.StatusInternalServerError).SendString("Internal server error")
+	}
+
+	logger.Debug().Str("space", spaceId).Int("count", len(documents)).Msg("Feed generated successfully")
+	return sendCached(ctx, contentType, body, feed.Updated)
+}
+
+// siteBaseUrl returns the configured public url of the instance, the url of the request when it's not set
+func siteBaseUrl(ctx *fiber.Ctx) string {
+	if config.Server.PublicUrl != "" {
+		return strings.TrimRight(config.Server.PublicUrl, "/")
+	}
+	return ctx.BaseURL()
+}
+
+// setSiteCacheControl lets the shared caches keep the pages only when their urls come from the configured public url,
+// the urls built from the request host would be served to the clients of the other hosts
+func setSiteCacheControl(ctx *fiber.Ctx) {
+	if config.Server.PublicUrl != "" {
+		ctx.Set(fiber.HeaderCacheControl, siteCacheControl)
+		return
+	}
+	ctx.Vary(fiber.HeaderHost)
+	ctx.Set(fiber.HeaderCacheControl, sitePrivateCacheControl)
+}
+
+// sendCached sends the body with its ETag and Last-Modified headers, or 304 when the client has the same version
+func sendCached(ctx *fiber.Ctx, contentType string, body []byte, modified time.Time) error {
+	sum := sha256.Sum256(body)
+	ctx.Set(fiber.HeaderETag, `"`+hex.EncodeToString(sum[:16])+`"`)
+	if !modified.IsZero() {
+		ctx.Set(fiber.HeaderLastModified, modified.UTC().Format(http.TimeFormat))
+	}
+	setSiteCacheControl(ctx)
+	ctx.Set(fiber.HeaderContentType, contentType)
+
+	if ctx.Fresh() {
+		return ctx.SendStatus(fiber.StatusNotModified)
+	}
+	return ctx.Status(fiber.StatusOK).Send(body)
+}
+
+// lastModified returns the last update of the documents
+func lastModified(documents []models.Document) time.Time {
+	var modified time.Time
+	for _, document := range documents {
+		if document.UpdatedAt.After(modified) {
+			modified = document.UpdatedAt
+		}
+	}
+	return modified
+}
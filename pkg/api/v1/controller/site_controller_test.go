@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/rs/zerolog"
+)
+
+// publishedDocuments returns a single published document
+type publishedDocuments struct {
+	models.PublicService
+}
+
+func (publishedDocuments) GetPublishedDocuments(spaceId string, limit int) ([]models.Document, error) {
+	return []models.Document{{Id: "d1", Slug: "page", UpdatedAt: time.Now()}}, nil
+}
+
+func TestSiteCacheControl(t *testing.T) {
+	tests := []struct {
+		name             string
+		publicUrl        string
+		wantCacheControl string
+		wantVary         string
+		wantUrl          string
+	}{
+		{"public url", "https://notes.example.com/", siteCacheControl, "", "https://notes.example.com/p/d1/page"},
+		{"request host", "", sitePrivateCacheControl, fiber.HeaderHost, "http://evil.example.com/p/d1/page"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := config.Server.PublicUrl
+			config.Server.PublicUrl = tt.publicUrl
+			defer func() { config.Server.PublicUrl = previous }()
+
+			app := fiber.New()
+			sc := &SiteController{PublicService: publishedDocuments{}, Logger: zerolog.Nop()}
+			app.Get("/sitemap.xml", sc.GetSitemap)
+
+			request := httptest.NewRequest(fiber.MethodGet, "/sitemap.xml", nil)
+			request.Host = "evil.example.com"
+			response, err := app.Test(request)
+			if err != nil {
+				t.Fatalf("GET /sitemap.xml unexpected error: %v", err)
+			}
+			body, _ := io.ReadAll(response.Body)
+
+			if got := response.Header.Get(fiber.HeaderCacheControl); got != tt.wantCacheControl {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCacheControl)
+			}
+			if got := response.Header.Get(fiber.HeaderVary); got != tt.wantVary {
+				t.Errorf("Vary = %q, want %q", got, tt.wantVary)
+			}
+			if !strings.Contains(string(body), tt.wantUrl) {
+				t.Errorf("sitemap = %s, want %s", body, tt.wantUrl)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package controller
+
+import (
+	"errors"
+	"mime"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gosimple/slug"
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/labbs/zotion/pkg/site"
+	"github.com/labbs/zotion/pkg/storage"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+type SiteExportController struct {
+	SiteExportService models.SiteExportService
+	AccessService     models.AccessService
+	Runner            *site.ExportRunner
+	Logger            zerolog.Logger
+}
+
+// GetSiteExports godoc
+// @Summary Get site exports
+// @Description Get the static site exports of a space, the most recent first. The members who can edit the space can see them.
+// @Tags site-export
+// @Accept json
+// @Produce json
+// @Param spaceId path string true "Space Id"
+// @Success 200 {array} models.SiteExport
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/site-export/space/{spaceId} [get]
+func (sec *SiteExportController) GetSiteExports(ctx *fiber.Ctx) error {
+	logger := sec.Logger.With().Str("event", "api.site_exports.get").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	spaceId := ctx.Params("spaceId")
+	if _, ok, response := sec.checkSiteExportAccess(ctx, logger, userId, spaceId); !ok {
+		return response
+	}
+
+	exports, err := sec.SiteExportService.GetExportsBySpaceId(spaceId)
+	if ok, response := siteExportError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Debug().Str("space", spaceId).Int("count", len(exports)).Msg("Site exports retrieved successfully")
+	return ctx.Status(fiber.StatusOK).JSON(exports)
+}
+
+// CreateSiteExport godoc
+// @Summary Create site export
+// @Description Queue the export of the published documents of a space as a static site. The site is rendered in background
+// @Description in a zip file with the pages, a navigation sidebar, a search index and the attachments, linked with relative links.
+// @Description The zip file can be downloaded once the export is completed.
+// @Tags site-export
+// @Accept json
+// @Produce json
+// @Param spaceId path string true "Space Id"
+// @Success 202 {object} models.SiteExport
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/site-export/space/{spaceId} [post]
+func (sec *SiteExportController) CreateSiteExport(ctx *fiber.Ctx) error {
+	logger := sec.Logger.With().Str("event", "api.site_exports.create").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	spaceId := ctx.Params("spaceId")
+	if _, ok, response := sec.checkSiteExportAccess(ctx, logger, userId, spaceId); !ok {
+		return response
+	}
+
+	export, err := sec.SiteExportService.CreateExport(spaceId, userId)
+	if ok, response := siteExportError(ctx, logger, err); !ok {
+		return response
+	}
+	if sec.Runner != nil {
+		sec.Runner.Wake()
+	}
+
+	logger.Debug().Str("space", spaceId).Str("export", export.Id).Msg("Site export queued successfully")
+	return ctx.Status(fiber.StatusAccepted).JSON(export)
+}
+
+// GetSiteExport godoc
+// @Summary Get site export
+// @Description Get a static site export with its status
+// @Tags site-export
+// @Accept json
+// @Produce json
+// @Param exportId path string true "Export Id"
+// @Success 200 {object} models.SiteExport
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/site-export/{exportId} [get]
+func (sec *SiteExportController) GetSiteExport(ctx *fiber.Ctx) error {
+	logger := sec.Logger.With().Str("event", "api.site_exports.get_one").Logger()
+
+	export, _, ok, err := sec.siteExport(ctx, logger)
+	if !ok {
+		return err
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(export)
+}
+
+// DownloadSiteExport godoc
+// @Summary Download site export
+// @Description Download the zip file of a completed static site export, the site can be served by any web server
+// @Tags site-export
+// @Produce application/zip
+// @Param exportId path string true "Export Id"
+// @Success 200 {file} file
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/site-export/{exportId}/download [get]
+func (sec *SiteExportController) DownloadSiteExport(ctx *fiber.Ctx) error {
+	logger := sec.Logger.With().Str("event", "api.site_exports.download").Logger()
+
+	export, space, ok, err := sec.siteExport(ctx, logger)
+	if !ok {
+		return err
+	}
+
+	file, err := sec.SiteExportService.OpenExportArchive(export)
+	if ok, response := siteExportError(ctx, logger, err); !ok {
+		return response
+	}
+
+	filename := slug.Make(space.Name)
+	if filename == "" {
+		filename = "site"
+	}
+	ctx.Set(fiber.HeaderContentType, "application/zip")
+	ctx.Set(fiber.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": filename + ".zip"}))
+
+	// fasthttp closes the file once the response is sent
+	return ctx.Status(fiber.StatusOK).SendStream(file, int(export.Size))
+}
+
+// DeleteSiteExport godoc
+// @Summary Delete site export
+// @Description Delete a static site export and its zip file, the running exports can't be deleted
+// @Tags site-export
+// @Accept json
+// @Produce json
+// @Param exportId path string true "Export Id"
+// @Success 204
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/site-export/{exportId} [delete]
+func (sec *SiteExportController) DeleteSiteExport(ctx *fiber.Ctx) error {
+	logger := sec.Logger.With().Str("event", "api.site_exports.delete").Logger()
+
+	export, _, ok, err := sec.siteExport(ctx, logger)
+	if !ok {
+		return err
+	}
+
+	err = sec.SiteExportService.DeleteExport(export)
+	if ok, response := siteExportError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Debug().Str("export", export.Id).Msg("Site export deleted successfully")
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// siteExport returns the export of the request with its space if the user can access it.
+// Otherwise the error response is sent and ok is false.
+func (sec *SiteExportController) siteExport(ctx *fiber.Ctx, logger zerolog.Logger) (export models.SiteExport, space models.Space, ok bool, err error) {
+	userId := ctx.Locals("user_id").(string)
+
+	export, err = sec.SiteExportService.GetExportById(ctx.Params("exportId"))
+	if ok, response := siteExportError(ctx, logger, err); !ok {
+		return export, space, false, response
+	}
+	space, ok, err = sec.checkSiteExportAccess(ctx, logger, userId, export.SpaceId)
+	return export, space, ok, err
+}
+
+// checkSiteExportAccess checks that the user can export the space, the members who can edit the space and the
+// administrators can export it
+func (sec *SiteExportController) checkSiteExportAccess(ctx *fiber.Ctx, logger zerolog.Logger, userId string, spaceId string) (models.Space, bool, error) {
+	space, access, err := sec.AccessService.GetSpaceAccess(userId, spaceId)
+	if isAdmin, _ := ctx.Locals("is_admin").(bool); isAdmin && errors.Is(err, models.ErrAccessDenied) {
+		return space, true, nil
+	}
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return space, false, ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Space not found"})
+	case errors.Is(err, models.ErrAccessDenied) || (err == nil && !access.CanEdit()):
+		logger.Warn().Str("user", userId).Str("space", spaceId).Msg("User can't export the space")
+		return space, false, ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to access this resource"})
+	case err != nil:
+		logger.Error().Err(err).Msg("Error getting space access")
+		return space, false, ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	return space, true, nil
+}
+
+// siteExportError writes the response of a site export service error, ok is true when there is no error
+func siteExportError(ctx *fiber.Ctx, logger zerolog.Logger, err error) (bool, error) {
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return false, ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Site export not found"})
+	case errors.Is(err, storage.ErrNotFound):
+		logger.Error().Msg("Site export file is missing in the storage")
+		return false, ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Site export not found"})
+	case errors.Is(err, models.ErrSiteExportNotReady), errors.Is(err, models.ErrSiteExportRunning):
+		return false, ctx.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	logger.Error().Err(err).Msg("Error processing site export request")
+	return false, ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+}
@@ -1,16 +1,28 @@
 package controller
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/events"
 	"github.com/labbs/zotion/pkg/models"
 	"github.com/rs/zerolog"
+	"gorm.io/gorm"
 )
 
 type SpaceController struct {
-	SpaceService models.SpaceService
-	Logger       zerolog.Logger
+	SpaceService    models.SpaceService
+	AccessService   models.AccessService
+	ActivityService models.ActivityService
+	Events          events.Bus
+	Logger          zerolog.Logger
 }
 
+const (
+	defaultActivitiesLimit = 50
+	maxActivitiesLimit     = 200
+)
+
 // GetSpaceById godoc
 // @Summary Get space by id
 // @Description Get space by id
@@ -43,6 +55,7 @@ func (sc *SpaceController) GetSpaceById(ctx *fiber.Ctx) error {
 // @Produce json
 // @Param space body models.CreateSpaceRequest true "Create space"
 // @Success 201 {object} models.Space
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /api/v1/space [post]
 func (sc *SpaceController) CreateSpace(ctx *fiber.Ctx) error {
@@ -55,6 +68,10 @@ func (sc *SpaceController) CreateSpace(ctx *fiber.Ctx) error {
 	}
 
 	userId := ctx.Locals("user_id").(string)
+	if isGuest(ctx) {
+		logger.Warn().Str("user", userId).Msg("Guests can't create spaces")
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to access this resource"})
+	}
 
 	newSpace := models.Space{
 		Name: spaceRequest.Name,
@@ -74,6 +91,66 @@ func (sc *SpaceController) CreateSpace(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
 	}
 
+	if sc.Events != nil {
+		event := events.Event{
+			Type:    events.SpaceCreated,
+			SpaceId: space.Id,
+			ActorId: userId,
+			Data:    fiber.Map{"name": space.Name, "type": space.Type},
+		}
+		if err := sc.Events.Publish(event); err != nil {
+			logger.Warn().Err(err).Str("space", space.Id).Msg("Error publishing the event")
+		}
+	}
+
 	logger.Debug().Str("space", space.Id).Msg("Space created successfully")
 	return ctx.Status(fiber.StatusCreated).JSON(space)
 }
+
+// GetSpaceActivities godoc
+// @Summary Get space activity feed
+// @Description Get the activities on the documents of a space, the most recent first. The activities of the documents the user can't access are left out.
+// @Tags space
+// @Accept json
+// @Produce json
+// @Param spaceId path string true "Space Id"
+// @Param limit query int false "Limit, 50 by default and 200 at most"
+// @Param offset query int false "Offset"
+// @Success 200 {object} models.ActivityPage
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/space/{spaceId}/activity [get]
+func (sc *SpaceController) GetSpaceActivities(ctx *fiber.Ctx) error {
+	logger := sc.Logger.With().Str("event", "api.spaces.get_activities").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	spaceId := ctx.Params("spaceId")
+
+	if _, _, err := sc.AccessService.GetSpaceAccess(userId, spaceId); err != nil {
+		if errors.Is(err, models.ErrAccessDenied) {
+			logger.Warn().Str("user", userId).Str("space", spaceId).Msg("User is not a member of the space")
+			return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to access this resource"})
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Space not found"})
+		}
+		logger.Error().Err(err).Msg("Error getting space access")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	limit := ctx.QueryInt("limit", defaultActivitiesLimit)
+	if limit <= 0 {
+		limit = defaultActivitiesLimit
+	}
+	limit = min(limit, maxActivitiesLimit)
+	offset := max(ctx.QueryInt("offset"), 0)
+
+	page, err := sc.ActivityService.GetSpaceActivities(userId, spaceId, limit, offset)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error getting space activities")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(page)
+}
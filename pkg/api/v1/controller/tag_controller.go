@@ -0,0 +1,403 @@
+package controller
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxTagNameLength is the maximum number of characters of a tag name
+	maxTagNameLength = 64
+
+	defaultTaggedDocumentsLimit = 50
+	maxTaggedDocumentsLimit     = 200
+)
+
+type TagController struct {
+	TagService    models.TagService
+	AccessService models.AccessService
+	Logger        zerolog.Logger
+}
+
+// GetTags godoc
+// @Summary Get tags
+// @Description Get the instance tags and the tags of the spaces of the user with the number of documents readable by the user.
+// @Description With a space_id, only the tags available in the space are returned and the documents of the space are counted.
+// @Tags tag
+// @Accept json
+// @Produce json
+// @Param space_id query string false "Space Id"
+// @Success 200 {array} models.Tag
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/tag [get]
+func (tc *TagController) GetTags(ctx *fiber.Ctx) error {
+	logger := tc.Logger.With().Str("event", "api.tags.get").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	tags, err := tc.TagService.GetTagsForUser(userId, ctx.Query("space_id"))
+	if ok, response := tagError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Debug().Int("count", len(tags)).Msg("Tags retrieved successfully")
+	return ctx.Status(fiber.StatusOK).JSON(tags)
+}
+
+// CreateTag godoc
+// @Summary Create tag
+// @Description Create a tag in a space, the editors of the space can create its tags.
+// @Description Without space_id, the tag is available in all the spaces and only the administrators can create it.
+// @Tags tag
+// @Accept json
+// @Produce json
+// @Param tag body models.TagRequest true "Tag"
+// @Success 201 {object} models.Tag
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/tag [post]
+func (tc *TagController) CreateTag(ctx *fiber.Ctx) error {
+	logger := tc.Logger.With().Str("event", "api.tags.create").Logger()
+
+	var request models.TagRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		logger.Error().Err(err).Msg("Error parsing request body")
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if ok, response := checkTagName(ctx, request.Name); !ok {
+		return response
+	}
+
+	userId := ctx.Locals("user_id").(string)
+	if ok, response := tc.checkTagManagement(ctx, logger, userId, request.SpaceId); !ok {
+		return response
+	}
+
+	tag, err := tc.TagService.CreateTag(userId, request)
+	if ok, response := tagError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Debug().Str("tag", tag.Id).Msg("Tag created successfully")
+	return ctx.Status(fiber.StatusCreated).JSON(tag)
+}
+
+// UpdateTag godoc
+// @Summary Update tag
+// @Description Rename a tag or change its color, the space of a tag can't be changed
+// @Tags tag
+// @Accept json
+// @Produce json
+// @Param tagId path string true "Tag Id"
+// @Param tag body models.TagRequest true "Tag"
+// @Success 200 {object} models.Tag
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/tag/{tagId} [put]
+func (tc *TagController) UpdateTag(ctx *fiber.Ctx) error {
+	logger := tc.Logger.With().Str("event", "api.tags.update").Logger()
+
+	var request models.TagRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		logger.Error().Err(err).Msg("Error parsing request body")
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if ok, response := checkTagName(ctx, request.Name); !ok {
+		return response
+	}
+
+	userId := ctx.Locals("user_id").(string)
+	tag, ok, err := tc.getManagedTag(ctx, logger, userId, ctx.Params("tagId"))
+	if !ok {
+		return err
+	}
+
+	tag, err = tc.TagService.UpdateTag(tag, request)
+	if ok, response := tagError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Debug().Str("tag", tag.Id).Msg("Tag updated successfully")
+	return ctx.Status(fiber.StatusOK).JSON(tag)
+}
+
+// DeleteTag godoc
+// @Summary Delete tag
+// @Description Delete a tag, it's removed from the documents
+// @Tags tag
+// @Accept json
+// @Produce json
+// @Param tagId path string true "Tag Id"
+// @Success 204
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/tag/{tagId} [delete]
+func (tc *TagController) DeleteTag(ctx *fiber.Ctx) error {
+	logger := tc.Logger.With().Str("event", "api.tags.delete").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	tag, ok, err := tc.getManagedTag(ctx, logger, userId, ctx.Params("tagId"))
+	if !ok {
+		return err
+	}
+
+	err = tc.TagService.DeleteTag(tag.Id)
+	if ok, response := tagError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Debug().Str("tag", tag.Id).Msg("Tag deleted successfully")
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// MergeTag godoc
+// @Summary Merge tags
+// @Description Move the documents of a tag to the target tag and delete it.
+// @Description The target must be an instance tag or a tag of the same space.
+// @Tags tag
+// @Accept json
+// @Produce json
+// @Param tagId path string true "Tag Id"
+// @Param merge body models.TagMergeRequest true "Target tag"
+// @Success 200 {object} models.Tag
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/tag/{tagId}/merge [post]
+func (tc *TagController) MergeTag(ctx *fiber.Ctx) error {
+	logger := tc.Logger.With().Str("event", "api.tags.merge").Logger()
+
+	var request models.TagMergeRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		logger.Error().Err(err).Msg("Error parsing request body")
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if request.TargetId == "" || request.TargetId == ctx.Params("tagId") {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "A different target tag is required"})
+	}
+
+	userId := ctx.Locals("user_id").(string)
+	source, ok, err := tc.getManagedTag(ctx, logger, userId, ctx.Params("tagId"))
+	if !ok {
+		return err
+	}
+	target, ok, err := tc.getManagedTag(ctx, logger, userId, request.TargetId)
+	if !ok {
+		return err
+	}
+
+	err = tc.TagService.MergeTag(source, target)
+	if ok, response := tagError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Debug().Str("tag", source.Id).Str("target", target.Id).Msg("Tags merged successfully")
+	return ctx.Status(fiber.StatusOK).JSON(target)
+}
+
+// GetTaggedDocuments godoc
+// @Summary Get tagged documents
+// @Description Get the documents with a tag the user can access, in the spaces of the user and in the documents the user is invited to, the last updated first
+// @Tags tag
+// @Accept json
+// @Produce json
+// @Param tagId path string true "Tag Id"
+// @Param limit query int false "Limit, 50 by default and 200 at most"
+// @Param offset query int false "Offset"
+// @Success 200 {object} models.TaggedDocuments
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/tag/{tagId}/documents [get]
+func (tc *TagController) GetTaggedDocuments(ctx *fiber.Ctx) error {
+	logger := tc.Logger.With().Str("event", "api.tags.get_documents").Logger()
+
+	limit := ctx.QueryInt("limit", defaultTaggedDocumentsLimit)
+	if limit <= 0 {
+		limit = defaultTaggedDocumentsLimit
+	}
+	limit = min(limit, maxTaggedDocumentsLimit)
+	offset := max(ctx.QueryInt("offset"), 0)
+
+	userId := ctx.Locals("user_id").(string)
+	documents, err := tc.TagService.GetTaggedDocuments(userId, ctx.Params("tagId"), limit, offset)
+	if ok, response := tagError(ctx, logger, err); !ok {
+		return response
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(documents)
+}
+
+// GetDocumentTags godoc
+// @Summary Get document tags
+// @Description Get the tags of a document
+// @Tags tag
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Success 200 {array} models.Tag
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/tag/document/{documentId} [get]
+func (tc *TagController) GetDocumentTags(ctx *fiber.Ctx) error {
+	logger := tc.Logger.With().Str("event", "api.tags.get_document_tags").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	document, ok, err := checkDocumentAccess(ctx, logger, tc.AccessService, userId, ctx.Params("documentId"), false)
+	if !ok {
+		return err
+	}
+
+	tags, err := tc.TagService.GetDocumentTags(document.Id)
+	if ok, response := tagError(ctx, logger, err); !ok {
+		return response
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(tags)
+}
+
+// AddDocumentTag godoc
+// @Summary Tag document
+// @Description Add a tag to a document, the tags of a space can only be added to its documents
+// @Tags tag
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Param tagId path string true "Tag Id"
+// @Success 204
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 423 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/tag/document/{documentId}/{tagId} [post]
+func (tc *TagController) AddDocumentTag(ctx *fiber.Ctx) error {
+	logger := tc.Logger.With().Str("event", "api.tags.add_document_tag").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	document, ok, err := checkDocumentAccess(ctx, logger, tc.AccessService, userId, ctx.Params("documentId"), true)
+	if !ok {
+		return err
+	}
+
+	tag, err := tc.TagService.GetTagById(ctx.Params("tagId"))
+	if ok, response := tagError(ctx, logger, err); !ok {
+		return response
+	}
+
+	err = tc.TagService.AddDocumentTag(userId, document, tag)
+	if ok, response := tagError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Debug().Str("document", document.Id).Str("tag", tag.Id).Msg("Document tagged successfully")
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// RemoveDocumentTag godoc
+// @Summary Untag document
+// @Description Remove a tag from a document
+// @Tags tag
+// @Accept json
+// @Produce json
+// @Param documentId path string true "Document Id"
+// @Param tagId path string true "Tag Id"
+// @Success 204
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 423 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/tag/document/{documentId}/{tagId} [delete]
+func (tc *TagController) RemoveDocumentTag(ctx *fiber.Ctx) error {
+	logger := tc.Logger.With().Str("event", "api.tags.remove_document_tag").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	document, ok, err := checkDocumentAccess(ctx, logger, tc.AccessService, userId, ctx.Params("documentId"), true)
+	if !ok {
+		return err
+	}
+
+	err = tc.TagService.RemoveDocumentTag(document.Id, ctx.Params("tagId"))
+	if ok, response := tagError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Debug().Str("document", document.Id).Str("tag", ctx.Params("tagId")).Msg("Document untagged successfully")
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// getManagedTag returns the tag if the user can manage it
+func (tc *TagController) getManagedTag(ctx *fiber.Ctx, logger zerolog.Logger, userId string, tagId string) (tag models.Tag, ok bool, err error) {
+	tag, err = tc.TagService.GetTagById(tagId)
+	if ok, response := tagError(ctx, logger, err); !ok {
+		return tag, false, response
+	}
+	if ok, response := tc.checkTagManagement(ctx, logger, userId, tag.SpaceId); !ok {
+		return tag, false, response
+	}
+	return tag, true, nil
+}
+
+// checkTagManagement checks that the user can manage the tags of the space, the editors of the space
+// manage its tags and the administrators manage the instance tags
+func (tc *TagController) checkTagManagement(ctx *fiber.Ctx, logger zerolog.Logger, userId string, spaceId string) (bool, error) {
+	if spaceId == "" {
+		if isAdmin, _ := ctx.Locals("is_admin").(bool); !isAdmin {
+			logger.Warn().Str("user", userId).Msg("User can't manage the instance tags")
+			return false, ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to access this resource"})
+		}
+		return true, nil
+	}
+
+	_, access, err := tc.AccessService.GetSpaceAccess(userId, spaceId)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return false, ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Space not found"})
+	case errors.Is(err, models.ErrAccessDenied) || (err == nil && !access.CanEdit()):
+		logger.Warn().Str("user", userId).Str("space", spaceId).Msg("User can't manage the tags of the space")
+		return false, ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to access this resource"})
+	case err != nil:
+		logger.Error().Err(err).Msg("Error getting space access")
+		return false, ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	return true, nil
+}
+
+// checkTagName checks the name of a tag, ok is true when it's valid
+func checkTagName(ctx *fiber.Ctx, name string) (bool, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxTagNameLength {
+		return false, ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "The tag name is required and limited to 64 characters"})
+	}
+	return true, nil
+}
+
+// tagError writes the response of the errors of the tag service, ok is true when there is no error
+func tagError(ctx *fiber.Ctx, logger zerolog.Logger, err error) (bool, error) {
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return false, ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Tag not found"})
+	case errors.Is(err, models.ErrAccessDenied):
+		return false, ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to access this resource"})
+	case errors.Is(err, models.ErrTagExists):
+		return false, ctx.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "A tag with this name already exists"})
+	case errors.Is(err, models.ErrTagScope):
+		return false, ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "The tag is not available in this space"})
+	}
+	logger.Error().Err(err).Msg("Error processing tag request")
+	return false, ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+}
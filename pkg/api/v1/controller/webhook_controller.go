@@ -0,0 +1,380 @@
+package controller
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxWebhookNameLength is the maximum number of characters of a webhook name
+	maxWebhookNameLength = 128
+
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 200
+)
+
+type WebhookController struct {
+	WebhookService models.WebhookService
+	AccessService  models.AccessService
+	Logger         zerolog.Logger
+}
+
+// GetWebhooks godoc
+// @Summary Get webhooks
+// @Description Get the webhooks of a space, the members of the space with full access can see them.
+// @Description Without space_id, the instance webhooks are returned to the administrators.
+// @Tags webhook
+// @Accept json
+// @Produce json
+// @Param space_id query string false "Space Id"
+// @Success 200 {array} models.Webhook
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/webhook [get]
+func (wc *WebhookController) GetWebhooks(ctx *fiber.Ctx) error {
+	logger := wc.Logger.With().Str("event", "api.webhooks.get").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	spaceId := ctx.Query("space_id")
+	if ok, response := wc.checkWebhookManagement(ctx, logger, userId, spaceId); !ok {
+		return response
+	}
+
+	webhooks, err := wc.WebhookService.GetWebhooks(spaceId)
+	if ok, response := webhookError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Debug().Int("count", len(webhooks)).Msg("Webhooks retrieved successfully")
+	return ctx.Status(fiber.StatusOK).JSON(webhooks)
+}
+
+// CreateWebhook godoc
+// @Summary Create webhook
+// @Description Create a webhook receiving the events of a space, the members of the space with full access can create it.
+// @Description Without space_id, the webhook receives the events of all the spaces and of the users, only the administrators can create it.
+// @Description The events are patterns like "document.updated" or "document.*", all the events are sent when empty.
+// @Description The secret signing the payloads is only returned in the response.
+// @Tags webhook
+// @Accept json
+// @Produce json
+// @Param webhook body models.WebhookRequest true "Webhook"
+// @Success 201 {object} models.Webhook
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/webhook [post]
+func (wc *WebhookController) CreateWebhook(ctx *fiber.Ctx) error {
+	logger := wc.Logger.With().Str("event", "api.webhooks.create").Logger()
+
+	var request models.WebhookRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		logger.Error().Err(err).Msg("Error parsing request body")
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if ok, response := checkWebhookName(ctx, request.Name); !ok {
+		return response
+	}
+
+	userId := ctx.Locals("user_id").(string)
+	if ok, response := wc.checkWebhookManagement(ctx, logger, userId, request.SpaceId); !ok {
+		return response
+	}
+
+	webhook, err := wc.WebhookService.CreateWebhook(userId, request)
+	if ok, response := webhookError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Debug().Str("webhook", webhook.Id).Msg("Webhook created successfully")
+	return ctx.Status(fiber.StatusCreated).JSON(webhook)
+}
+
+// GetWebhook godoc
+// @Summary Get webhook
+// @Description Get a webhook with its number of consecutive failures
+// @Tags webhook
+// @Accept json
+// @Produce json
+// @Param webhookId path string true "Webhook Id"
+// @Success 200 {object} models.Webhook
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/webhook/{webhookId} [get]
+func (wc *WebhookController) GetWebhook(ctx *fiber.Ctx) error {
+	logger := wc.Logger.With().Str("event", "api.webhooks.get_one").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	webhook, ok, err := wc.getManagedWebhook(ctx, logger, userId, ctx.Params("webhookId"))
+	if !ok {
+		return err
+	}
+
+	logger.Debug().Str("webhook", webhook.Id).Msg("Webhook retrieved successfully")
+	return ctx.Status(fiber.StatusOK).JSON(webhook)
+}
+
+// UpdateWebhook godoc
+// @Summary Update webhook
+// @Description Change the name, the url, the events or the state of a webhook, the space of a webhook can't be changed.
+// @Description Enabling a webhook disabled after too many failures resets its failures.
+// @Tags webhook
+// @Accept json
+// @Produce json
+// @Param webhookId path string true "Webhook Id"
+// @Param webhook body models.WebhookRequest true "Webhook"
+// @Success 200 {object} models.Webhook
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/webhook/{webhookId} [put]
+func (wc *WebhookController) UpdateWebhook(ctx *fiber.Ctx) error {
+	logger := wc.Logger.With().Str("event", "api.webhooks.update").Logger()
+
+	var request models.WebhookRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		logger.Error().Err(err).Msg("Error parsing request body")
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if ok, response := checkWebhookName(ctx, request.Name); !ok {
+		return response
+	}
+
+	userId := ctx.Locals("user_id").(string)
+	webhook, ok, err := wc.getManagedWebhook(ctx, logger, userId, ctx.Params("webhookId"))
+	if !ok {
+		return err
+	}
+
+	webhook, err = wc.WebhookService.UpdateWebhook(webhook, request)
+	if ok, response := webhookError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Debug().Str("webhook", webhook.Id).Msg("Webhook updated successfully")
+	return ctx.Status(fiber.StatusOK).JSON(webhook)
+}
+
+// DeleteWebhook godoc
+// @Summary Delete webhook
+// @Description Delete a webhook and its delivery log
+// @Tags webhook
+// @Accept json
+// @Produce json
+// @Param webhookId path string true "Webhook Id"
+// @Success 204
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/webhook/{webhookId} [delete]
+func (wc *WebhookController) DeleteWebhook(ctx *fiber.Ctx) error {
+	logger := wc.Logger.With().Str("event", "api.webhooks.delete").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	webhook, ok, err := wc.getManagedWebhook(ctx, logger, userId, ctx.Params("webhookId"))
+	if !ok {
+		return err
+	}
+
+	if ok, response := webhookError(ctx, logger, wc.WebhookService.DeleteWebhook(webhook.Id)); !ok {
+		return response
+	}
+
+	logger.Debug().Str("webhook", webhook.Id).Msg("Webhook deleted successfully")
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// RotateWebhookSecret godoc
+// @Summary Rotate webhook secret
+// @Description Replace the secret signing the payloads of a webhook, the new secret is only returned in the response
+// @Tags webhook
+// @Accept json
+// @Produce json
+// @Param webhookId path string true "Webhook Id"
+// @Success 200 {object} models.Webhook
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/webhook/{webhookId}/rotate-secret [post]
+func (wc *WebhookController) RotateWebhookSecret(ctx *fiber.Ctx) error {
+	logger := wc.Logger.With().Str("event", "api.webhooks.rotate_secret").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	webhook, ok, err := wc.getManagedWebhook(ctx, logger, userId, ctx.Params("webhookId"))
+	if !ok {
+		return err
+	}
+
+	webhook, err = wc.WebhookService.RotateSecret(webhook)
+	if ok, response := webhookError(ctx, logger, err); !ok {
+		return response
+	}
+
+	logger.Info().Str("webhook", webhook.Id).Str("user", userId).Msg("Webhook secret rotated")
+	return ctx.Status(fiber.StatusOK).JSON(webhook)
+}
+
+// GetWebhookDeliveries godoc
+// @Summary Get webhook deliveries
+// @Description Get the delivery log of a webhook, the most recent first, with the response of the last attempt of each delivery.
+// @Description The response bodies are only returned to the administrators.
+// @Tags webhook
+// @Accept json
+// @Produce json
+// @Param webhookId path string true "Webhook Id"
+// @Param limit query int false "Number of deliveries, 50 by default and 200 at most"
+// @Param offset query int false "Number of deliveries to skip"
+// @Success 200 {object} models.WebhookDeliveryPage
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/webhook/{webhookId}/deliveries [get]
+func (wc *WebhookController) GetWebhookDeliveries(ctx *fiber.Ctx) error {
+	logger := wc.Logger.With().Str("event", "api.webhooks.deliveries").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	webhook, ok, err := wc.getManagedWebhook(ctx, logger, userId, ctx.Params("webhookId"))
+	if !ok {
+		return err
+	}
+
+	limit := ctx.QueryInt("limit", defaultDeliveriesLimit)
+	if limit <= 0 || limit > maxDeliveriesLimit {
+		limit = defaultDeliveriesLimit
+	}
+	offset := max(ctx.QueryInt("offset", 0), 0)
+
+	page, err := wc.WebhookService.GetDeliveries(webhook.Id, limit, offset)
+	if ok, response := webhookError(ctx, logger, err); !ok {
+		return response
+	}
+
+	for i := range page.Results {
+		hideResponseBody(ctx, &page.Results[i])
+	}
+
+	logger.Debug().Str("webhook", webhook.Id).Int("count", len(page.Results)).Msg("Webhook deliveries retrieved successfully")
+	return ctx.Status(fiber.StatusOK).JSON(page)
+}
+
+// RedeliverWebhookDelivery godoc
+// @Summary Redeliver webhook delivery
+// @Description Send again the payload of a delivery as a new delivery, it's attempted right away even when the webhook is disabled
+// @Tags webhook
+// @Accept json
+// @Produce json
+// @Param webhookId path string true "Webhook Id"
+// @Param deliveryId path string true "Delivery Id"
+// @Success 201 {object} models.WebhookDelivery
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/webhook/{webhookId}/deliveries/{deliveryId}/redeliver [post]
+func (wc *WebhookController) RedeliverWebhookDelivery(ctx *fiber.Ctx) error {
+	logger := wc.Logger.With().Str("event", "api.webhooks.redeliver").Logger()
+
+	userId := ctx.Locals("user_id").(string)
+	webhook, ok, err := wc.getManagedWebhook(ctx, logger, userId, ctx.Params("webhookId"))
+	if !ok {
+		return err
+	}
+
+	delivery, err := wc.WebhookService.GetDeliveryById(ctx.Params("deliveryId"))
+	if err == nil && delivery.WebhookId != webhook.Id {
+		err = gorm.ErrRecordNotFound
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Delivery not found"})
+	}
+	if ok, response := webhookError(ctx, logger, err); !ok {
+		return response
+	}
+
+	delivery, err = wc.WebhookService.Redeliver(delivery)
+	if ok, response := webhookError(ctx, logger, err); !ok {
+		return response
+	}
+
+	hideResponseBody(ctx, &delivery)
+
+	logger.Debug().Str("webhook", webhook.Id).Str("delivery", delivery.Id).Str("status", string(delivery.Status)).Msg("Webhook delivery redelivered")
+	return ctx.Status(fiber.StatusCreated).JSON(delivery)
+}
+
+// hideResponseBody removes the response body of the delivery for the users who are not administrators,
+// a webhook must not be a way to read the responses of the services the server can reach
+func hideResponseBody(ctx *fiber.Ctx, delivery *models.WebhookDelivery) {
+	if isAdmin, _ := ctx.Locals("is_admin").(bool); !isAdmin {
+		delivery.ResponseBody = ""
+	}
+}
+
+// getManagedWebhook returns the webhook if the user can manage it
+func (wc *WebhookController) getManagedWebhook(ctx *fiber.Ctx, logger zerolog.Logger, userId string, webhookId string) (webhook models.Webhook, ok bool, err error) {
+	webhook, err = wc.WebhookService.GetWebhookById(webhookId)
+	if ok, response := webhookError(ctx, logger, err); !ok {
+		return webhook, false, response
+	}
+	if ok, response := wc.checkWebhookManagement(ctx, logger, userId, webhook.SpaceId); !ok {
+		return webhook, false, response
+	}
+	return webhook, true, nil
+}
+
+// checkWebhookManagement checks that the user can manage the webhooks of the space, the members with full access
+// manage the webhooks of a space and the administrators manage all the webhooks
+func (wc *WebhookController) checkWebhookManagement(ctx *fiber.Ctx, logger zerolog.Logger, userId string, spaceId string) (bool, error) {
+	if isAdmin, _ := ctx.Locals("is_admin").(bool); isAdmin {
+		return true, nil
+	}
+	if spaceId == "" {
+		logger.Warn().Str("user", userId).Msg("User can't manage the instance webhooks")
+		return false, ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to access this resource"})
+	}
+
+	_, access, err := wc.AccessService.GetSpaceAccess(userId, spaceId)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return false, ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Space not found"})
+	case errors.Is(err, models.ErrAccessDenied) || (err == nil && !access.Includes(models.AccessTypeFull)):
+		logger.Warn().Str("user", userId).Str("space", spaceId).Msg("User can't manage the webhooks of the space")
+		return false, ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not authorized to access this resource"})
+	case err != nil:
+		logger.Error().Err(err).Msg("Error getting space access")
+		return false, ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+	}
+	return true, nil
+}
+
+// checkWebhookName checks the name of a webhook, ok is true when it's valid
+func checkWebhookName(ctx *fiber.Ctx, name string) (bool, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxWebhookNameLength {
+		return false, ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "The webhook name is required and limited to 128 characters"})
+	}
+	return true, nil
+}
+
+// webhookError writes the response of a webhook service error, ok is true when there is no error
+func webhookError(ctx *fiber.Ctx, logger zerolog.Logger, err error) (bool, error) {
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return false, ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Webhook not found"})
+	case errors.Is(err, models.ErrWebhookUrl), errors.Is(err, models.ErrWebhookEvents):
+		return false, ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	logger.Error().Err(err).Msg("Error processing webhook request")
+	return false, ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Internal server error"})
+}
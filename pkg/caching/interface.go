@@ -8,6 +8,13 @@ type CacheInterface interface {
 	SetWithTTL(key string, value interface{}, ttl time.Duration)
 	Get(key string) (interface{}, bool)
 	Delete(key string) bool
+
+	// SetField sets a field of the hash stored under the key and refreshes the ttl of the key,
+	// the fields are written atomically so concurrent writers of different fields don't lose updates
+	SetField(key, field, value string, ttl time.Duration)
+	GetFields(key string) map[string]string
+	DeleteField(key, field string)
+
 	Clear()
 	Close() error
 }
@@ -39,7 +39,7 @@ func (c *MemoryCache) SetWithTTL(key string, value interface{}, ttl time.Duratio
 	defer c.mutex.Unlock()
 
 	// If the cache is full and the key does not exist, remove the oldest entry
-	if len(c.data) >= c.maxSize && c.data[key] == (CacheEntry{}) {
+	if _, exists := c.data[key]; !exists && len(c.data) >= c.maxSize {
 		c.removeOldest()
 	}
 
@@ -77,6 +77,61 @@ func (c *MemoryCache) Delete(key string) bool {
 	return false
 }
 
+func (c *MemoryCache) SetField(key, field, value string, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	fields := c.fields(key)
+	if fields == nil {
+		if len(c.data) >= c.maxSize {
+			c.removeOldest()
+		}
+		fields = make(map[string]string)
+	}
+	fields[field] = value
+
+	c.data[key] = CacheEntry{
+		value:      fields,
+		expiration: time.Now().Add(ttl),
+	}
+}
+
+func (c *MemoryCache) GetFields(key string) map[string]string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	fields := make(map[string]string)
+	for field, value := range c.fields(key) {
+		fields[field] = value
+	}
+	return fields
+}
+
+func (c *MemoryCache) DeleteField(key, field string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	fields := c.fields(key)
+	if fields == nil {
+		return
+	}
+	delete(fields, field)
+	if len(fields) == 0 {
+		delete(c.data, key)
+	}
+}
+
+// fields returns the hash stored under the key, nil when the key is missing or expired.
+// The cache must be locked.
+func (c *MemoryCache) fields(key string) map[string]string {
+	entry, exists := c.data[key]
+	if !exists || time.Now().After(entry.expiration) {
+		return nil
+	}
+	fields, _ := entry.value.(map[string]string)
+	return fields
+}
+
 func (c *MemoryCache) Clear() {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
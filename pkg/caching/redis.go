@@ -61,6 +61,26 @@ func (c *RedisCache) Delete(key string) bool {
 	return result.Val() > 0
 }
 
+func (c *RedisCache) SetField(key, field, value string, ttl time.Duration) {
+	c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(c.ctx, key, field, value)
+		pipe.Expire(c.ctx, key, ttl)
+		return nil
+	})
+}
+
+func (c *RedisCache) GetFields(key string) map[string]string {
+	fields, err := c.client.HGetAll(c.ctx, key).Result()
+	if err != nil {
+		return map[string]string{}
+	}
+	return fields
+}
+
+func (c *RedisCache) DeleteField(key, field string) {
+	c.client.HDel(c.ctx, key, field)
+}
+
 func (c *RedisCache) Clear() {
 	c.client.FlushDB(c.ctx)
 }
@@ -0,0 +1,107 @@
+package export
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/labbs/zotion/internal/database"
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/labbs/zotion/pkg/flags"
+	logger "github.com/labbs/zotion/pkg/logger"
+	"github.com/labbs/zotion/pkg/repository"
+	"github.com/labbs/zotion/pkg/service"
+	"github.com/labbs/zotion/pkg/site"
+	"github.com/labbs/zotion/pkg/storage"
+
+	"github.com/urfave/cli/v2"
+	"github.com/urfave/cli/v2/altsrc"
+)
+
+func NewInstance() *cli.Command {
+	exportFlags := getFlags()
+
+	return &cli.Command{
+		Name:      "export",
+		Usage:     "Export the published documents of a space as a static site",
+		UsageText: "export --space SPACE_ID --output DIRECTORY|FILE.zip",
+		Flags:     exportFlags,
+		Before:    altsrc.InitInputSourceWithContext(exportFlags, altsrc.NewYamlSourceFromFlagFunc("config")),
+		Action:    runExport,
+	}
+}
+
+func getFlags() (list []cli.Flag) {
+	list = append(list, flags.GenericFlags()...)
+	list = append(list, flags.ServerFlags()...)
+	list = append(list, flags.DatabaseFlags()...)
+	list = append(list, flags.LoggerFlags()...)
+	list = append(list, flags.AttachmentFlags()...)
+	list = append(list,
+		&cli.StringFlag{
+			Name:     "space",
+			Usage:    "Id of the exported space",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "output",
+			Aliases:  []string{"o"},
+			Usage:    "Directory of the site, or zip file when the path ends with .zip",
+			Required: true,
+		},
+	)
+	return
+}
+
+func runExport(c *cli.Context) error {
+	l := logger.NewLogger(config.Logger.Level, config.Logger.Pretty, c.App.Version)
+
+	if config.Database.DSN == "" {
+		return errors.New("database gorm dsn is required")
+	}
+
+	db := database.NewGorm(l, config.Database.Dialect, config.Database.DSN)
+
+	attachmentStorage, err := storage.New(l)
+	if err != nil {
+		return err
+	}
+
+	dr := repository.NewDocumentRepository(db)
+	exporter := site.NewExporter(
+		service.NewPublicService(dr),
+		service.NewSpaceService(repository.NewSpaceRepository(db)),
+		service.NewAttachmentService(repository.NewAttachmentRepository(db), attachmentStorage, nil, int64(config.Attachment.MaxSize)*1024*1024, config.Attachment.AllowedTypes.Value()),
+		config.Server.SiteName,
+	)
+
+	output := c.String("output")
+	var archive site.Archive
+	if strings.EqualFold(filepath.Ext(output), ".zip") {
+		file, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		archive = site.NewZipArchive(file)
+	} else {
+		archive, err = site.NewDirArchive(output)
+		if err != nil {
+			return err
+		}
+	}
+
+	spaceId := c.String("space")
+	result, err := exporter.Export(spaceId, archive)
+	if err != nil {
+		archive.Close()
+		return err
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	l.Info().Str("space", spaceId).Str("output", output).Int("pages", result.Pages).Int("attachments", result.Attachments).Msg("Site exported successfully")
+	return nil
+}
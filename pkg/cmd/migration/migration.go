@@ -2,11 +2,19 @@ package migration
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"text/tabwriter"
+	"time"
 
 	"github.com/labbs/zotion/internal/database"
 	"github.com/labbs/zotion/internal/migration"
 	"github.com/labbs/zotion/pkg/config"
 	logger "github.com/labbs/zotion/pkg/logger"
+	"github.com/pressly/goose/v3"
+	"github.com/rs/zerolog"
 	"gorm.io/gorm"
 
 	"github.com/labbs/zotion/pkg/flags"
@@ -22,8 +30,47 @@ func NewInstance() *cli.Command {
 		Name:   "migration",
 		Usage:  "Run Alfred migrations",
 		Flags:  migrationFlags,
-		Before: altsrc.InitInputSourceWithContext(migrationFlags, altsrc.NewYamlSourceFromFlagFunc("config")),
+		Before: loadConfig(migrationFlags),
 		Action: runMigration,
+		Subcommands: []*cli.Command{
+			newCommand("up", "Apply the pending migrations", "up [--dry-run]", true, runMigration),
+			newCommand("status", "Print the applied and pending migrations", "status", false, runStatus),
+			newCommand("down", "Roll back the last applied migration", "down [--dry-run]", true, runDown),
+			newCommand("down-to", "Roll back the migrations applied after the version, 0 rolls back all the migrations", "down-to [--dry-run] VERSION", true, runDownTo),
+			newCommand("redo", "Roll back the last applied migration and apply it again", "redo [--dry-run]", true, runRedo),
+			{
+				Name:      "create",
+				Usage:     "Create a new go migration with a query for each dialect",
+				UsageText: "create [--dir DIRECTORY] NAME",
+				Flags: append(flags.LoggerFlags(),
+					&cli.StringFlag{
+						Name:  "dir",
+						Usage: "Directory of the migration",
+						Value: "internal/migration/files",
+					},
+				),
+				Action: runCreate,
+			},
+		},
+	}
+}
+
+func newCommand(name, usage, usageText string, dryRun bool, action cli.ActionFunc) *cli.Command {
+	commandFlags := getFlags()
+	if dryRun {
+		commandFlags = append(commandFlags, &cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Print the statements of the configured dialect without running them, the dsn is optional",
+		})
+	}
+
+	return &cli.Command{
+		Name:      name,
+		Usage:     usage,
+		UsageText: usageText,
+		Flags:     commandFlags,
+		Before:    loadConfig(commandFlags),
+		Action:    action,
 	}
 }
 
@@ -34,16 +81,54 @@ func getFlags() (list []cli.Flag) {
 	return
 }
 
-func runMigration(c *cli.Context) error {
-	var db *gorm.DB
+// loadConfig loads the configuration file of the command. The subcommands load it themselves: the file
+// is the one given to the subcommand, or to the migration command (migration -c FILE status).
+func loadConfig(commandFlags []cli.Flag) cli.BeforeFunc {
+	load := altsrc.InitInputSourceWithContext(commandFlags, func(c *cli.Context) (altsrc.InputSourceContext, error) {
+		return altsrc.NewYamlSourceFromFile(configFile(c))
+	})
 
+	return func(c *cli.Context) error {
+		if len(c.Command.Subcommands) > 0 && c.Args().Present() && c.Command.Command(c.Args().First()) != nil {
+			return nil
+		}
+		return load(c)
+	}
+}
+
+// configFile returns the first configuration file set in the command lineage, the default file otherwise
+func configFile(c *cli.Context) string {
+	for _, ctx := range c.Lineage() {
+		if ctx.Command != nil && ctx.Command.Name != "" && ctx.IsSet("config") {
+			return ctx.String("config")
+		}
+	}
+	return c.String("config")
+}
+
+// openDatabase returns the logger and the database of the command, the database is nil on a dry run without dsn
+func openDatabase(c *cli.Context) (zerolog.Logger, *gorm.DB, error) {
 	l := logger.NewLogger(config.Logger.Level, config.Logger.Pretty, c.App.Version)
 
 	if config.Database.DSN == "" {
-		return errors.New("database gorm dsn is required")
+		if c.Bool("dry-run") {
+			return l, nil, nil
+		}
+		return l, nil, errors.New("database gorm dsn is required")
 	}
 
-	db = database.NewGorm(l, config.Database.Dialect, config.Database.DSN)
+	return l, database.NewGorm(l, config.Database.Dialect, config.Database.DSN), nil
+}
+
+func runMigration(c *cli.Context) error {
+	l, db, err := openDatabase(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("dry-run") {
+		return migration.DryRun(l, c.App.Writer, db, config.Database.Dialect, migration.DryRunUp, 0)
+	}
 
 	// Run migrations
 	if err := migration.RunMigration(l, db); err != nil {
@@ -52,3 +137,96 @@ func runMigration(c *cli.Context) error {
 
 	return nil
 }
+
+func runStatus(c *cli.Context) error {
+	l, db, err := openDatabase(c)
+	if err != nil {
+		return err
+	}
+
+	statuses, err := migration.Status(l, db)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.App.Writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tMIGRATION")
+	pending := 0
+	for _, status := range statuses {
+		appliedAt := "-"
+		if status.State == goose.StateApplied {
+			appliedAt = status.AppliedAt.Local().Format(time.DateTime)
+		} else {
+			pending++
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Source.Version, status.State, appliedAt, path.Base(status.Source.Path))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.App.Writer, "\n%d migration(s), %d pending\n", len(statuses), pending)
+	return nil
+}
+
+func runDown(c *cli.Context) error {
+	l, db, err := openDatabase(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("dry-run") {
+		return migration.DryRun(l, c.App.Writer, db, config.Database.Dialect, migration.DryRunDown, 0)
+	}
+
+	return migration.Down(l, db)
+}
+
+func runDownTo(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("the version to roll back to is required")
+	}
+	version, err := strconv.ParseInt(c.Args().First(), 10, 64)
+	if err != nil || version < 0 {
+		return fmt.Errorf("invalid migration version: %s", c.Args().First())
+	}
+
+	l, db, err := openDatabase(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("dry-run") {
+		return migration.DryRun(l, c.App.Writer, db, config.Database.Dialect, migration.DryRunDownTo, version)
+	}
+
+	return migration.DownTo(l, db, version)
+}
+
+func runRedo(c *cli.Context) error {
+	l, db, err := openDatabase(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("dry-run") {
+		return migration.DryRun(l, c.App.Writer, db, config.Database.Dialect, migration.DryRunRedo, 0)
+	}
+
+	return migration.Redo(l, db)
+}
+
+func runCreate(c *cli.Context) error {
+	l := logger.NewLogger(config.Logger.Level, config.Logger.Pretty, c.App.Version)
+
+	if c.NArg() != 1 {
+		return errors.New("the name of the migration is required")
+	}
+
+	dir := c.String("dir")
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("invalid migration directory: %w", err)
+	}
+
+	return migration.Create(l, dir, c.Args().First())
+}
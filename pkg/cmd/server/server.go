@@ -12,6 +12,8 @@ import (
 	"github.com/labbs/zotion/pkg/flags"
 	htserver "github.com/labbs/zotion/pkg/httpserver"
 	logger "github.com/labbs/zotion/pkg/logger"
+	"github.com/labbs/zotion/pkg/mailer"
+	"github.com/labbs/zotion/pkg/storage"
 
 	"github.com/urfave/cli/v2"
 	"github.com/urfave/cli/v2/altsrc"
@@ -40,6 +42,13 @@ func getFlags() (list []cli.Flag) {
 	list = append(list, flags.DocumentFlags()...)
 	list = append(list, flags.CachingFlags()...)
 	list = append(list, flags.RegistrationFlags()...)
+	list = append(list, flags.CollaborationFlags()...)
+	list = append(list, flags.PresenceFlags()...)
+	list = append(list, flags.EventsFlags()...)
+	list = append(list, flags.AttachmentFlags()...)
+	list = append(list, flags.WebhookFlags()...)
+	list = append(list, flags.MailFlags()...)
+	list = append(list, flags.NotificationFlags()...)
 	return
 }
 
@@ -59,6 +68,15 @@ func runServer(c *cli.Context) error {
 		l.Fatal().Err(err).Msg("failed to configure caching")
 	}
 
+	// Attachment storage configuration
+	attachmentStorage, err := storage.New(l)
+	if err != nil {
+		l.Fatal().Err(err).Msg("failed to configure attachment storage")
+	}
+
+	// Mailer configuration
+	mail := mailer.New(l)
+
 	// Start the HTTP server
 	var httpServer htserver.Config
 	httpServer.Port = config.Server.Port
@@ -66,6 +84,10 @@ func runServer(c *cli.Context) error {
 	httpServer.Logger = l
 	httpServer.Stop = stopChan
 	httpServer.Db = db
+	httpServer.Storage = attachmentStorage
+	httpServer.Mailer = mail
+	// the uploads need a body larger than the attachments, 1MB is kept for the multipart envelope
+	httpServer.UploadBodyLimit = (config.Attachment.MaxSize + 1) * 1024 * 1024
 
 	if err := auth.DisableAdminAccount(db); err != nil {
 		l.Error().Err(err).Msg("failed to disable/enable admin account")
@@ -0,0 +1,100 @@
+package collaboration
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+)
+
+// Broker forwards collaboration messages between server instances
+type Broker interface {
+	// Publish sends a message of a document to the other instances
+	Publish(documentId string, message []byte) error
+	// Subscribe registers the handler called for messages published by other instances
+	Subscribe(handler func(documentId string, message []byte)) error
+	Close() error
+}
+
+// localBroker is used when a single instance is running, nothing has to be forwarded
+type localBroker struct{}
+
+func NewLocalBroker() *localBroker {
+	return &localBroker{}
+}
+
+func (b *localBroker) Publish(documentId string, message []byte) error {
+	return nil
+}
+
+func (b *localBroker) Subscribe(handler func(documentId string, message []byte)) error {
+	return nil
+}
+
+func (b *localBroker) Close() error {
+	return nil
+}
+
+const redisChannelPrefix = "zotion:collaboration:"
+
+// redisBroker uses redis pub/sub to fan out the messages across instances.
+// Each payload is prefixed by the instance id to ignore our own messages.
+type redisBroker struct {
+	client     *redis.Client
+	pubsub     *redis.PubSub
+	instanceId string
+	logger     zerolog.Logger
+	ctx        context.Context
+}
+
+func NewRedisBroker(logger zerolog.Logger, addr, password string, db int) *redisBroker {
+	return &redisBroker{
+		client: redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: password,
+			DB:       db,
+		}),
+		instanceId: utils.UUIDv4(),
+		logger:     logger,
+		ctx:        context.Background(),
+	}
+}
+
+func (b *redisBroker) Publish(documentId string, message []byte) error {
+	payload := make([]byte, 0, len(b.instanceId)+len(message))
+	payload = append(payload, b.instanceId...)
+	payload = append(payload, message...)
+	return b.client.Publish(b.ctx, redisChannelPrefix+documentId, payload).Err()
+}
+
+func (b *redisBroker) Subscribe(handler func(documentId string, message []byte)) error {
+	b.pubsub = b.client.PSubscribe(b.ctx, redisChannelPrefix+"*")
+	if _, err := b.pubsub.Receive(b.ctx); err != nil {
+		return err
+	}
+
+	go func() {
+		for msg := range b.pubsub.Channel() {
+			payload := msg.Payload
+			if len(payload) < len(b.instanceId) || payload[:len(b.instanceId)] == b.instanceId {
+				continue
+			}
+			documentId := strings.TrimPrefix(msg.Channel, redisChannelPrefix)
+			handler(documentId, []byte(payload[len(b.instanceId):]))
+		}
+		b.logger.Debug().Msg("Collaboration redis subscription closed")
+	}()
+
+	return nil
+}
+
+func (b *redisBroker) Close() error {
+	if b.pubsub != nil {
+		if err := b.pubsub.Close(); err != nil {
+			return err
+		}
+	}
+	return b.client.Close()
+}
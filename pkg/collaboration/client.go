@@ -0,0 +1,71 @@
+package collaboration
+
+import "time"
+
+// clientBufferSize is the number of pending messages before a slow client is disconnected
+const clientBufferSize = 256
+
+// Client is a connection to a collaborative document
+type Client struct {
+	UserId   string
+	ReadOnly bool
+
+	// accessCheckedAt is the last check of the edit access of the client, the client is switched to
+	// read-only once it can't edit the document (locked document, revoked access).
+	// ReadOnly and accessCheckedAt are only used by the connection handling the messages of the client.
+	accessCheckedAt time.Time
+
+	// send is closed once with closed, both are guarded by the lock of the room
+	send   chan []byte
+	closed bool
+
+	// awareness keeps the yjs client ids (and their clock) announced by this connection,
+	// they are removed from the other peers when the connection is closed
+	awareness     map[uint64]uint64
+	lastAwareness []byte
+
+	// awaitingState is set when the server asked the client for its full state,
+	// logMark and generation identify the update log sent to the client before the request
+	awaitingState bool
+	logMark       int
+	generation    int
+}
+
+// NewClient creates a client for the given user
+func NewClient(userId string, readOnly bool) *Client {
+	return &Client{
+		UserId:          userId,
+		ReadOnly:        readOnly,
+		accessCheckedAt: time.Now(),
+		send:            make(chan []byte, clientBufferSize),
+		awareness:       make(map[uint64]uint64),
+	}
+}
+
+// Messages returns the messages to write on the connection.
+// The channel is closed when the client is removed from the hub.
+func (c *Client) Messages() <-chan []byte {
+	return c.send
+}
+
+// push queues a message without blocking. A client that can't keep up is closed,
+// it will resync its state on reconnection. The room of the client must be locked.
+func (c *Client) push(message []byte) {
+	if c.closed {
+		return
+	}
+	select {
+	case c.send <- message:
+	default:
+		c.close()
+	}
+}
+
+// close closes the messages of the client, the room of the client must be locked
+func (c *Client) close() {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	close(c.send)
+}
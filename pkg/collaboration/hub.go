@@ -0,0 +1,476 @@
+package collaboration
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/rs/zerolog"
+)
+
+// accessCheckInterval is the interval between two checks of the edit access of a client,
+// a document locked while being edited is read-only after at most this interval
+const accessCheckInterval = 5 * time.Second
+
+// Hub manages the collaborative rooms of the running instance
+type Hub struct {
+	mu    sync.Mutex
+	rooms map[string]*room
+
+	// instanceId identifies the instance in the messages exchanged through the broker
+	instanceId string
+
+	broker               Broker
+	documentService      models.DocumentService
+	documentStateService models.DocumentStateService
+	accessService        models.AccessService
+	logger               zerolog.Logger
+
+	stop chan struct{}
+}
+
+// NewHub creates a hub and starts the periodic persistence of the rooms
+func NewHub(logger zerolog.Logger, broker Broker, ds models.DocumentService, dss models.DocumentStateService, as models.AccessService, persistInterval time.Duration) *Hub {
+	h := &Hub{
+		rooms:                make(map[string]*room),
+		instanceId:           utils.UUIDv4(),
+		broker:               broker,
+		documentService:      ds,
+		documentStateService: dss,
+		accessService:        as,
+		logger:               logger.With().Str("event", "collaboration.hub").Logger(),
+		stop:                 make(chan struct{}),
+	}
+
+	if err := broker.Subscribe(h.handleRemote); err != nil {
+		h.logger.Error().Err(err).Msg("failed to subscribe to the collaboration broker")
+	}
+
+	go h.persistLoop(persistInterval)
+
+	return h
+}
+
+// Join adds a client to the room of the document, the room is loaded from the database if needed
+func (h *Hub) Join(documentId string, client *Client) error {
+	for {
+		r, err := h.loadRoom(documentId)
+		if err != nil {
+			return err
+		}
+
+		r.mu.Lock()
+		if r.unloaded {
+			// the last client left while the room was loaded, it is loaded again from the database
+			r.mu.Unlock()
+			h.unloadRoom(r)
+			continue
+		}
+
+		r.clients[client] = struct{}{}
+		for peer := range r.clients {
+			if peer != client && peer.lastAwareness != nil {
+				client.push(peer.lastAwareness)
+			}
+		}
+		r.mu.Unlock()
+
+		return nil
+	}
+}
+
+// loadRoom returns the room of the document, the state is read from the database
+// without holding the lock of the hub
+func (h *Hub) loadRoom(documentId string) (*room, error) {
+	h.mu.Lock()
+	r, ok := h.rooms[documentId]
+	h.mu.Unlock()
+	if ok {
+		return r, nil
+	}
+
+	state, err := h.documentStateService.GetState(documentId)
+	if err != nil {
+		return nil, err
+	}
+	updates, err := decodeUpdateLog(state)
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// another client loaded the room in the meantime
+	if r, ok := h.rooms[documentId]; ok {
+		return r, nil
+	}
+	r = newRoom(documentId, updates)
+	h.rooms[documentId] = r
+
+	// ask the other instances for the updates not yet persisted
+	h.publish(documentId, encodeStateRequest(h.instanceId))
+
+	return r, nil
+}
+
+// unloadRoom removes the room from the hub if it wasn't replaced yet
+func (h *Hub) unloadRoom(r *room) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.rooms[r.documentId] == r {
+		delete(h.rooms, r.documentId)
+	}
+}
+
+// room returns the loaded room of the document
+func (h *Hub) room(documentId string) (*room, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r, ok := h.rooms[documentId]
+	return r, ok
+}
+
+// Leave removes a client from the room. The room is persisted and unloaded when the last client leaves,
+// a room that couldn't be persisted is kept for the periodic persistence to retry.
+func (h *Hub) Leave(documentId string, client *Client) {
+	r, ok := h.room(documentId)
+	if !ok {
+		return
+	}
+
+	r.mu.Lock()
+	delete(r.clients, client)
+	client.close()
+
+	if message := r.removeAwareness(client); message != nil {
+		r.broadcast(message, nil)
+		h.publish(documentId, message)
+	}
+
+	unload := len(r.clients) == 0 && !r.unloaded && h.persist(r)
+	if unload {
+		r.unloaded = true
+	}
+	r.mu.Unlock()
+
+	if unload {
+		h.unloadRoom(r)
+	}
+}
+
+// Reset drops the room of a document whose content was replaced outside of the collaboration. The changes
+// not persisted are discarded and the clients are disconnected to reload the document, the other instances
+// drop their room too.
+func (h *Hub) Reset(documentId string) {
+	h.reset(documentId)
+	h.publish(documentId, encodeStateReset())
+}
+
+func (h *Hub) reset(documentId string) {
+	r, ok := h.room(documentId)
+	if !ok {
+		return
+	}
+
+	r.mu.Lock()
+	for client := range r.clients {
+		client.close()
+	}
+	r.clients = make(map[*Client]struct{})
+	r.unloaded = true
+	r.mu.Unlock()
+
+	h.unloadRoom(r)
+}
+
+// HandleMessage processes a message received from a client
+func (h *Hub) HandleMessage(documentId string, client *Client, message []byte) error {
+	r, ok := h.room(documentId)
+	if !ok {
+		return nil
+	}
+
+	// the document may have been locked since the client joined, the access is checked before the changes are applied
+	revoked := false
+	if !client.ReadOnly && time.Since(client.accessCheckedAt) >= accessCheckInterval {
+		client.accessCheckedAt = time.Now()
+		if !h.canEdit(documentId, client.UserId) {
+			client.ReadOnly = true
+			revoked = true
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the client was closed by the room, the connection is being closed
+	if client.closed || r.unloaded {
+		return nil
+	}
+
+	if revoked {
+		client.push(encodePermissionDenied("the document is locked"))
+	}
+
+	d := newDecoder(message)
+	messageType, err := d.readVarUint()
+	if err != nil {
+		return err
+	}
+
+	switch messageType {
+	case messageSync:
+		step, err := d.readVarUint()
+		if err != nil {
+			return err
+		}
+		payload, err := d.readVarUint8Array()
+		if err != nil {
+			return err
+		}
+		switch step {
+		case syncStep1:
+			r.sendLog(client)
+			r.requestState(client)
+		case syncStep2, syncUpdate:
+			if client.ReadOnly {
+				client.awaitingState = false
+				return nil
+			}
+			update := append([]byte(nil), payload...)
+			r.applyUpdate(client, step, update)
+			broadcast := encodeSyncMessage(syncUpdate, update)
+			r.broadcast(broadcast, client)
+			h.publish(documentId, broadcast)
+		}
+	case messageAwareness:
+		payload, err := d.readVarUint8Array()
+		if err != nil {
+			return err
+		}
+		entries, err := decodeAwarenessUpdate(payload)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.State == "null" {
+				delete(client.awareness, entry.ClientId)
+			} else {
+				client.awareness[entry.ClientId] = entry.Clock
+			}
+		}
+		client.lastAwareness = append([]byte(nil), message...)
+		r.broadcast(client.lastAwareness, client)
+		h.publish(documentId, client.lastAwareness)
+	case messageQueryAwareness:
+		for peer := range r.clients {
+			if peer != client && peer.lastAwareness != nil {
+				client.push(peer.lastAwareness)
+			}
+		}
+	case messageSnapshot:
+		if client.ReadOnly {
+			return nil
+		}
+		content, err := d.readVarString()
+		if err != nil {
+			return err
+		}
+		r.content = content
+		r.hasContent = true
+		r.dirty = true
+		r.editors[client.UserId] = struct{}{}
+		h.publish(documentId, message)
+	case messageAuth:
+		// permissions are checked before the connection is upgraded
+	default:
+		h.logger.Debug().Uint64("type", messageType).Msg("unknown collaboration message")
+	}
+
+	return nil
+}
+
+// handleRemote processes a message published by another instance
+func (h *Hub) handleRemote(documentId string, message []byte) {
+	d := newDecoder(message)
+	messageType, err := d.readVarUint()
+	if err != nil {
+		return
+	}
+	if messageType == messageStateReset {
+		h.reset(documentId)
+		return
+	}
+
+	r, ok := h.room(documentId)
+	if !ok {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.unloaded {
+		return
+	}
+
+	switch messageType {
+	case messageSync:
+		step, err := d.readVarUint()
+		if err != nil {
+			return
+		}
+		payload, err := d.readVarUint8Array()
+		if err != nil {
+			return
+		}
+		if step == syncStep1 {
+			return
+		}
+		update := append([]byte(nil), payload...)
+		r.updates = append(r.updates, update)
+		r.broadcast(encodeSyncMessage(syncUpdate, update), nil)
+	case messageStateRequest:
+		// another instance loaded the room, send it our log
+		instanceId, err := d.readVarString()
+		if err != nil || len(r.updates) == 0 {
+			return
+		}
+		h.publish(documentId, encodeStateReply(instanceId, r.updates))
+	case messageStateReply:
+		instanceId, err := d.readVarString()
+		if err != nil || instanceId != h.instanceId {
+			return
+		}
+		state, err := d.readVarUint8Array()
+		if err != nil {
+			return
+		}
+		updates, err := decodeUpdateLog(state)
+		if err != nil {
+			return
+		}
+		// the instances share the updates published since the room was loaded, they are only added once
+		known := make(map[string]struct{}, len(r.updates))
+		for _, update := range r.updates {
+			known[string(update)] = struct{}{}
+		}
+		for _, update := range updates {
+			if _, ok := known[string(update)]; ok {
+				continue
+			}
+			known[string(update)] = struct{}{}
+			r.updates = append(r.updates, update)
+			r.broadcast(encodeSyncMessage(syncUpdate, update), nil)
+		}
+	case messageAwareness:
+		r.broadcast(message, nil)
+	case messageSnapshot:
+		// the instance owning the writer persists the content
+		if content, err := d.readVarString(); err == nil {
+			r.content = content
+			r.hasContent = true
+		}
+	}
+}
+
+func (h *Hub) publish(documentId string, message []byte) {
+	if err := h.broker.Publish(documentId, message); err != nil {
+		h.logger.Error().Err(err).Str("document", documentId).Msg("failed to publish collaboration message")
+	}
+}
+
+// canEdit returns true if the user can edit the document, the users can't edit a locked document
+// unless they locked it or manage its space
+func (h *Hub) canEdit(documentId, userId string) bool {
+	_, access, err := h.accessService.GetDocumentAccess(userId, documentId)
+	if err != nil {
+		if !errors.Is(err, models.ErrAccessDenied) {
+			h.logger.Error().Err(err).Str("document", documentId).Str("user", userId).Msg("failed to check the collaboration access")
+		}
+		return false
+	}
+	return access.CanEdit()
+}
+
+// persist saves the update log and the content of a room, the room must be locked.
+// The updates of the clients that lost their edit access are rejected when they are received,
+// the editors who can no longer edit the document are only logged.
+// It returns false when the changes of the room are not persisted.
+func (h *Hub) persist(r *room) bool {
+	if !r.dirty {
+		return true
+	}
+
+	for userId := range r.editors {
+		if !h.canEdit(r.documentId, userId) {
+			h.logger.Warn().Str("document", r.documentId).Str("user", userId).Msg("collaboration changes persisted for an editor who can no longer edit the document")
+		}
+	}
+
+	if err := h.documentStateService.SaveState(r.documentId, encodeUpdateLog(r.updates)); err != nil {
+		h.logger.Error().Err(err).Str("document", r.documentId).Msg("failed to persist collaboration state")
+		return false
+	}
+
+	if r.hasContent {
+		if err := h.documentService.UpdateDocumentContent(r.documentId, r.content); err != nil {
+			h.logger.Error().Err(err).Str("document", r.documentId).Msg("failed to persist document content")
+			return false
+		}
+	}
+
+	r.dirty = false
+	r.editors = make(map[string]struct{})
+	h.logger.Debug().Str("document", r.documentId).Int("updates", len(r.updates)).Msg("collaboration state persisted")
+	return true
+}
+
+// persistAll persists the loaded rooms, the rooms are persisted without holding the lock of the hub.
+// The rooms left by their last client are unloaded once persisted.
+func (h *Hub) persistAll() {
+	h.mu.Lock()
+	rooms := make([]*room, 0, len(h.rooms))
+	for _, r := range h.rooms {
+		rooms = append(rooms, r)
+	}
+	h.mu.Unlock()
+
+	for _, r := range rooms {
+		r.mu.Lock()
+		unload := !r.unloaded && h.persist(r) && len(r.clients) == 0
+		if unload {
+			r.unloaded = true
+		}
+		r.mu.Unlock()
+
+		if unload {
+			h.unloadRoom(r)
+		}
+	}
+}
+
+func (h *Hub) persistLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			h.persistAll()
+		case <-h.stop:
+			return
+		}
+	}
+}
+
+// Close persists every room and closes the broker
+func (h *Hub) Close() error {
+	close(h.stop)
+	h.persistAll()
+	return h.broker.Close()
+}
@@ -0,0 +1,212 @@
+package collaboration
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/rs/zerolog"
+)
+
+// memoryStates stores the document states in memory, saving fails while err is set
+type memoryStates struct {
+	models.DocumentStateService
+	states map[string][]byte
+	err    error
+}
+
+func (s *memoryStates) GetState(documentId string) ([]byte, error) {
+	return s.states[documentId], nil
+}
+
+func (s *memoryStates) SaveState(documentId string, state []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.states[documentId] = state
+	return nil
+}
+
+// memoryContents stores the document contents in memory
+type memoryContents struct {
+	models.DocumentService
+	contents map[string]string
+}
+
+func (s *memoryContents) UpdateDocumentContent(documentId, content string) error {
+	s.contents[documentId] = content
+	return nil
+}
+
+// editorAccess gives the editor access to the users, except the read-only ones
+type editorAccess struct {
+	models.AccessService
+	readOnly map[string]bool
+}
+
+func (a editorAccess) GetDocumentAccess(userId, documentId string) (models.Document, models.AccessType, error) {
+	if a.readOnly[userId] {
+		return models.Document{Id: documentId}, models.AccessTypeViewer, nil
+	}
+	return models.Document{Id: documentId}, models.AccessTypeEditor, nil
+}
+
+func newTestHub(t *testing.T, states *memoryStates, contents *memoryContents, access editorAccess) *Hub {
+	t.Helper()
+	h := NewHub(zerolog.Nop(), NewLocalBroker(), contents, states, access, time.Hour)
+	t.Cleanup(func() { close(h.stop) })
+	return h
+}
+
+func TestHubLeaveKeepsUnpersistedRoom(t *testing.T) {
+	states := &memoryStates{states: make(map[string][]byte), err: errors.New("database is locked")}
+	h := newTestHub(t, states, &memoryContents{contents: make(map[string]string)}, editorAccess{})
+
+	client := NewClient("alice", false)
+	if err := h.Join("d1", client); err != nil {
+		t.Fatalf("Join() unexpected error: %v", err)
+	}
+	if err := h.HandleMessage("d1", client, encodeSyncMessage(syncUpdate, []byte{1, 2, 3})); err != nil {
+		t.Fatalf("HandleMessage() unexpected error: %v", err)
+	}
+	h.Leave("d1", client)
+
+	if _, ok := h.room("d1"); !ok {
+		t.Fatal("room should be kept while its changes are not persisted")
+	}
+
+	states.err = nil
+	h.persistAll()
+
+	if _, ok := h.room("d1"); ok {
+		t.Fatal("room should be unloaded once persisted")
+	}
+	updates, err := decodeUpdateLog(states.states["d1"])
+	if err != nil || len(updates) != 1 {
+		t.Fatalf("persisted updates = %v (%v), want 1 update", updates, err)
+	}
+}
+
+func TestHubPersistWithRevokedEditor(t *testing.T) {
+	states := &memoryStates{states: make(map[string][]byte)}
+	access := editorAccess{readOnly: make(map[string]bool)}
+	h := newTestHub(t, states, &memoryContents{contents: make(map[string]string)}, access)
+
+	alice := NewClient("alice", false)
+	bob := NewClient("bob", false)
+	for _, client := range []*Client{alice, bob} {
+		if err := h.Join("d1", client); err != nil {
+			t.Fatalf("Join() unexpected error: %v", err)
+		}
+		if err := h.HandleMessage("d1", client, encodeSyncMessage(syncUpdate, []byte(client.UserId))); err != nil {
+			t.Fatalf("HandleMessage() unexpected error: %v", err)
+		}
+	}
+
+	// bob loses the edit access before the room is persisted
+	access.readOnly["bob"] = true
+	h.persistAll()
+
+	updates, err := decodeUpdateLog(states.states["d1"])
+	if err != nil || len(updates) != 2 {
+		t.Fatalf("persisted updates = %v (%v), want the 2 updates", updates, err)
+	}
+}
+
+// recordingBroker keeps the published messages
+type recordingBroker struct {
+	localBroker
+	messages [][]byte
+}
+
+func (b *recordingBroker) Publish(documentId string, message []byte) error {
+	b.messages = append(b.messages, message)
+	return nil
+}
+
+func TestHubHandleRemoteState(t *testing.T) {
+	known := []byte{1}
+	unknown := []byte{2}
+
+	tests := []struct {
+		name         string
+		message      func(h *Hub) []byte
+		wantUpdates  [][]byte
+		wantMessages [][]byte
+	}{
+		{
+			name:         "request",
+			message:      func(h *Hub) []byte { return encodeStateRequest("other") },
+			wantUpdates:  [][]byte{known},
+			wantMessages: [][]byte{encodeStateReply("other", [][]byte{known})},
+		},
+		{
+			name:        "reply to the instance",
+			message:     func(h *Hub) []byte { return encodeStateReply(h.instanceId, [][]byte{known, unknown}) },
+			wantUpdates: [][]byte{known, unknown},
+		},
+		{
+			name:        "reply to another instance",
+			message:     func(h *Hub) []byte { return encodeStateReply("other", [][]byte{known, unknown}) },
+			wantUpdates: [][]byte{known},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := &recordingBroker{}
+			h := NewHub(zerolog.Nop(), broker, &memoryContents{}, &memoryStates{}, editorAccess{}, time.Hour)
+			t.Cleanup(func() { close(h.stop) })
+			h.rooms["d1"] = newRoom("d1", [][]byte{known})
+
+			h.handleRemote("d1", tt.message(h))
+
+			if got := encodeUpdateLog(h.rooms["d1"].updates); string(got) != string(encodeUpdateLog(tt.wantUpdates)) {
+				t.Errorf("updates = %v, want %v", h.rooms["d1"].updates, tt.wantUpdates)
+			}
+			if got := encodeUpdateLog(broker.messages); string(got) != string(encodeUpdateLog(tt.wantMessages)) {
+				t.Errorf("published = %v, want %v", broker.messages, tt.wantMessages)
+			}
+		})
+	}
+}
+
+func TestHubReset(t *testing.T) {
+	tests := []struct {
+		name  string
+		reset func(h *Hub)
+	}{
+		{"local", func(h *Hub) { h.Reset("d1") }},
+		{"remote", func(h *Hub) { h.handleRemote("d1", encodeStateReset()) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			states := &memoryStates{states: make(map[string][]byte)}
+			h := newTestHub(t, states, &memoryContents{contents: make(map[string]string)}, editorAccess{})
+
+			client := NewClient("alice", false)
+			if err := h.Join("d1", client); err != nil {
+				t.Fatalf("Join() unexpected error: %v", err)
+			}
+			if err := h.HandleMessage("d1", client, encodeSyncMessage(syncUpdate, []byte{1})); err != nil {
+				t.Fatalf("HandleMessage() unexpected error: %v", err)
+			}
+
+			tt.reset(h)
+			h.Leave("d1", client)
+			h.persistAll()
+
+			if _, ok := h.room("d1"); ok {
+				t.Error("room should be dropped")
+			}
+			if !client.closed {
+				t.Error("client should be disconnected")
+			}
+			if _, ok := states.states["d1"]; ok {
+				t.Error("changes of the dropped room should not be persisted")
+			}
+		})
+	}
+}
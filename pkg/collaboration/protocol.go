@@ -0,0 +1,251 @@
+package collaboration
+
+import (
+	"errors"
+)
+
+// Message types of the y-websocket protocol.
+// See https://github.com/yjs/y-protocols for the reference implementation.
+const (
+	messageSync           uint64 = 0
+	messageAwareness      uint64 = 1
+	messageAuth           uint64 = 2
+	messageQueryAwareness uint64 = 3
+
+	// messageSnapshot is a zotion extension used by the editor to push the
+	// serialized document (BlockNote JSON) that is stored in Document.Content.
+	messageSnapshot uint64 = 100
+
+	// messageStateRequest and messageStateReply are only exchanged between the instances,
+	// an instance loading a room asks the others for their update log and only handles
+	// the replies addressed to it.
+	messageStateRequest uint64 = 101
+	messageStateReply   uint64 = 102
+	// messageStateReset tells the other instances to drop the room of a document whose content was replaced
+	messageStateReset uint64 = 103
+)
+
+// Sub message types of messageSync
+const (
+	syncStep1  uint64 = 0
+	syncStep2  uint64 = 1
+	syncUpdate uint64 = 2
+)
+
+// Sub message types of messageAuth
+const (
+	authPermissionDenied uint64 = 0
+)
+
+var errUnexpectedEOF = errors.New("collaboration: unexpected end of message")
+
+// emptyStateVector is an encoded state vector without any client
+var emptyStateVector = []byte{0}
+
+// emptyUpdate is an encoded yjs update (v1) without structs and deletes
+var emptyUpdate = []byte{0, 0}
+
+// decoder reads lib0 encoded values from a message
+type decoder struct {
+	buf []byte
+	pos int
+}
+
+func newDecoder(buf []byte) *decoder {
+	return &decoder{buf: buf}
+}
+
+// remaining returns the number of bytes left to read
+func (d *decoder) remaining() int {
+	return len(d.buf) - d.pos
+}
+
+// readVarUint reads an unsigned integer encoded with 7 bits per byte
+func (d *decoder) readVarUint() (uint64, error) {
+	var num uint64
+	var shift uint
+	for {
+		if d.pos >= len(d.buf) {
+			return 0, errUnexpectedEOF
+		}
+		b := d.buf[d.pos]
+		d.pos++
+		num |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return num, nil
+		}
+		shift += 7
+		if shift > 63 {
+			return 0, errors.New("collaboration: varuint overflow")
+		}
+	}
+}
+
+// readVarUint8Array reads a length prefixed byte array
+func (d *decoder) readVarUint8Array() ([]byte, error) {
+	l, err := d.readVarUint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(d.buf)-d.pos) < l {
+		return nil, errUnexpectedEOF
+	}
+	b := d.buf[d.pos : d.pos+int(l)]
+	d.pos += int(l)
+	return b, nil
+}
+
+// readVarString reads a length prefixed utf-8 string
+func (d *decoder) readVarString() (string, error) {
+	b, err := d.readVarUint8Array()
+	return string(b), err
+}
+
+// encoder writes lib0 encoded values
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) writeVarUint(num uint64) {
+	for num >= 0x80 {
+		e.buf = append(e.buf, byte(num)|0x80)
+		num >>= 7
+	}
+	e.buf = append(e.buf, byte(num))
+}
+
+func (e *encoder) writeVarUint8Array(b []byte) {
+	e.writeVarUint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) writeVarString(s string) {
+	e.writeVarUint8Array([]byte(s))
+}
+
+// encodeSyncMessage builds a sync message with the given sub type and payload
+func encodeSyncMessage(step uint64, payload []byte) []byte {
+	e := encoder{}
+	e.writeVarUint(messageSync)
+	e.writeVarUint(step)
+	e.writeVarUint8Array(payload)
+	return e.buf
+}
+
+// encodePermissionDenied builds the auth message telling the client it can no longer edit the document
+func encodePermissionDenied(reason string) []byte {
+	e := encoder{}
+	e.writeVarUint(messageAuth)
+	e.writeVarUint(authPermissionDenied)
+	e.writeVarString(reason)
+	return e.buf
+}
+
+// encodeStateRequest builds the message asking the other instances for the update log of a room
+func encodeStateRequest(instanceId string) []byte {
+	e := encoder{}
+	e.writeVarUint(messageStateRequest)
+	e.writeVarString(instanceId)
+	return e.buf
+}
+
+// encodeStateReply builds the message sending the update log of a room to the instance that requested it
+func encodeStateReply(instanceId string, updates [][]byte) []byte {
+	e := encoder{}
+	e.writeVarUint(messageStateReply)
+	e.writeVarString(instanceId)
+	e.writeVarUint8Array(encodeUpdateLog(updates))
+	return e.buf
+}
+
+// encodeStateReset builds the message telling the other instances to drop the room of a document
+func encodeStateReset() []byte {
+	e := encoder{}
+	e.writeVarUint(messageStateReset)
+	return e.buf
+}
+
+// awarenessEntry is a single client state of an awareness update
+type awarenessEntry struct {
+	ClientId uint64
+	Clock    uint64
+	State    string
+}
+
+// decodeAwarenessUpdate decodes the payload of a messageAwareness
+func decodeAwarenessUpdate(update []byte) ([]awarenessEntry, error) {
+	d := newDecoder(update)
+	count, err := d.readVarUint()
+	if err != nil {
+		return nil, err
+	}
+	// an entry takes at least 3 bytes, the count is checked before the allocation
+	if count > uint64(d.remaining()/3) {
+		return nil, errUnexpectedEOF
+	}
+	entries := make([]awarenessEntry, 0, count)
+	for i := uint64(0); i < count; i++ {
+		var entry awarenessEntry
+		if entry.ClientId, err = d.readVarUint(); err != nil {
+			return nil, err
+		}
+		if entry.Clock, err = d.readVarUint(); err != nil {
+			return nil, err
+		}
+		if entry.State, err = d.readVarString(); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// encodeAwarenessMessage builds a messageAwareness from the given entries
+func encodeAwarenessMessage(entries []awarenessEntry) []byte {
+	u := encoder{}
+	u.writeVarUint(uint64(len(entries)))
+	for _, entry := range entries {
+		u.writeVarUint(entry.ClientId)
+		u.writeVarUint(entry.Clock)
+		u.writeVarString(entry.State)
+	}
+
+	e := encoder{}
+	e.writeVarUint(messageAwareness)
+	e.writeVarUint8Array(u.buf)
+	return e.buf
+}
+
+// encodeUpdateLog serializes a list of updates so it can be stored in the database
+func encodeUpdateLog(updates [][]byte) []byte {
+	e := encoder{}
+	e.writeVarUint(uint64(len(updates)))
+	for _, update := range updates {
+		e.writeVarUint8Array(update)
+	}
+	return e.buf
+}
+
+// decodeUpdateLog is the reverse operation of encodeUpdateLog
+func decodeUpdateLog(state []byte) ([][]byte, error) {
+	if len(state) == 0 {
+		return nil, nil
+	}
+	d := newDecoder(state)
+	count, err := d.readVarUint()
+	if err != nil {
+		return nil, err
+	}
+	if count > uint64(d.remaining()) {
+		return nil, errUnexpectedEOF
+	}
+	updates := make([][]byte, 0, count)
+	for i := uint64(0); i < count; i++ {
+		update, err := d.readVarUint8Array()
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, append([]byte(nil), update...))
+	}
+	return updates, nil
+}
@@ -0,0 +1,225 @@
+package collaboration
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestVarUint(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   uint64
+		encoded []byte
+	}{
+		{"zero", 0, []byte{0x00}},
+		{"one byte", 127, []byte{0x7f}},
+		{"two bytes", 128, []byte{0x80, 0x01}},
+		{"three bytes", 16384, []byte{0x80, 0x80, 0x01}},
+		{"max", ^uint64(0), []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := encoder{}
+			e.writeVarUint(tt.value)
+			if !bytes.Equal(e.buf, tt.encoded) {
+				t.Fatalf("encoded %v, want %v", e.buf, tt.encoded)
+			}
+
+			value, err := newDecoder(tt.encoded).readVarUint()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if value != tt.value {
+				t.Fatalf("decoded %d, want %d", value, tt.value)
+			}
+		})
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+		read func(d *decoder) error
+		err  error
+	}{
+		{"empty varuint", nil, func(d *decoder) error { _, err := d.readVarUint(); return err }, errUnexpectedEOF},
+		{"truncated varuint", []byte{0x80}, func(d *decoder) error { _, err := d.readVarUint(); return err }, errUnexpectedEOF},
+		{"overflowing varuint", bytes.Repeat([]byte{0xff}, 11), func(d *decoder) error { _, err := d.readVarUint(); return err }, nil},
+		{"truncated array", []byte{0x03, 0x01, 0x02}, func(d *decoder) error { _, err := d.readVarUint8Array(); return err }, errUnexpectedEOF},
+		{"truncated string", []byte{0x05, 'a'}, func(d *decoder) error { _, err := d.readVarString(); return err }, errUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.read(newDecoder(tt.buf))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestEncodeSyncMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		step    uint64
+		payload []byte
+		want    []byte
+	}{
+		{"step 1", syncStep1, emptyStateVector, []byte{0x00, 0x00, 0x01, 0x00}},
+		{"step 2", syncStep2, emptyUpdate, []byte{0x00, 0x01, 0x02, 0x00, 0x00}},
+		{"update", syncUpdate, []byte{0x01, 0x02, 0x03}, []byte{0x00, 0x02, 0x03, 0x01, 0x02, 0x03}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := encodeSyncMessage(tt.step, tt.payload)
+			if !bytes.Equal(message, tt.want) {
+				t.Fatalf("encoded %v, want %v", message, tt.want)
+			}
+
+			d := newDecoder(message)
+			messageType, _ := d.readVarUint()
+			step, _ := d.readVarUint()
+			payload, err := d.readVarUint8Array()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if messageType != messageSync || step != tt.step || !bytes.Equal(payload, tt.payload) {
+				t.Fatalf("decoded type %d step %d payload %v", messageType, step, payload)
+			}
+		})
+	}
+}
+
+func TestAwareness(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []awarenessEntry
+	}{
+		{"empty", []awarenessEntry{}},
+		{"single", []awarenessEntry{{ClientId: 1, Clock: 2, State: `{"user":{"name":"alice"}}`}}},
+		{"removed", []awarenessEntry{{ClientId: 300, Clock: 1, State: "null"}, {ClientId: 7, Clock: 128, State: "{}"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := encodeAwarenessMessage(tt.entries)
+
+			d := newDecoder(message)
+			messageType, _ := d.readVarUint()
+			if messageType != messageAwareness {
+				t.Fatalf("message type %d, want %d", messageType, messageAwareness)
+			}
+			payload, err := d.readVarUint8Array()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			entries, err := decodeAwarenessUpdate(payload)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entries) != len(tt.entries) {
+				t.Fatalf("decoded %d entries, want %d", len(entries), len(tt.entries))
+			}
+			for i := range entries {
+				if entries[i] != tt.entries[i] {
+					t.Fatalf("entry %d is %+v, want %+v", i, entries[i], tt.entries[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeAwarenessUpdateErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		update []byte
+	}{
+		{"empty", nil},
+		{"missing entry", []byte{0x01}},
+		{"missing state", []byte{0x01, 0x01, 0x01}},
+		{"huge count", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeAwarenessUpdate(tt.update); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestUpdateLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		updates [][]byte
+	}{
+		{"empty", [][]byte{}},
+		{"single", [][]byte{{0x01, 0x02}}},
+		{"several", [][]byte{{0x01}, {}, bytes.Repeat([]byte{0xaa}, 300)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updates, err := decodeUpdateLog(encodeUpdateLog(tt.updates))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(updates) != len(tt.updates) {
+				t.Fatalf("decoded %d updates, want %d", len(updates), len(tt.updates))
+			}
+			for i := range updates {
+				if !bytes.Equal(updates[i], tt.updates[i]) {
+					t.Fatalf("update %d is %v, want %v", i, updates[i], tt.updates[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeUpdateLogErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		state []byte
+	}{
+		{"truncated count", []byte{0x80}},
+		{"missing update", []byte{0x02, 0x01, 0x01}},
+		{"huge count", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeUpdateLog(tt.state); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+
+	updates, err := decodeUpdateLog(nil)
+	if err != nil || updates != nil {
+		t.Fatalf("empty state decoded as %v, %v", updates, err)
+	}
+}
+
+func TestEncodePermissionDenied(t *testing.T) {
+	message := encodePermissionDenied("locked")
+
+	d := newDecoder(message)
+	messageType, _ := d.readVarUint()
+	reasonType, _ := d.readVarUint()
+	reason, err := d.readVarString()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if messageType != messageAuth || reasonType != authPermissionDenied || reason != "locked" {
+		t.Fatalf("decoded type %d reason type %d reason %q", messageType, reasonType, reason)
+	}
+}
@@ -0,0 +1,103 @@
+package collaboration
+
+import "sync"
+
+// room holds the shared state of a document edited by several clients.
+// The server doesn't interpret the yjs updates, it keeps them in a log that is
+// replayed to new clients and compacted when a client sends its full state.
+type room struct {
+	mu sync.Mutex
+
+	documentId string
+	clients    map[*Client]struct{}
+
+	updates    [][]byte
+	generation int
+
+	content    string
+	hasContent bool
+
+	dirty bool
+	// editors are the users whose changes are not persisted yet
+	editors map[string]struct{}
+
+	// unloaded is set when the last client left, the room is no longer used by the hub
+	unloaded bool
+}
+
+func newRoom(documentId string, updates [][]byte) *room {
+	return &room{
+		documentId: documentId,
+		clients:    make(map[*Client]struct{}),
+		updates:    updates,
+		editors:    make(map[string]struct{}),
+	}
+}
+
+// broadcast sends a message to every client of the room except the sender,
+// the clients closed because they couldn't keep up are removed from the room
+func (r *room) broadcast(message []byte, sender *Client) {
+	for client := range r.clients {
+		if client != sender {
+			client.push(message)
+		}
+		if client.closed {
+			delete(r.clients, client)
+		}
+	}
+}
+
+// sendLog replays the update log to a client. The last update is sent as
+// sync step 2 so the client is marked as synced once it has the whole document.
+func (r *room) sendLog(client *Client) {
+	if len(r.updates) == 0 {
+		client.push(encodeSyncMessage(syncStep2, emptyUpdate))
+		return
+	}
+	for i, update := range r.updates {
+		step := syncUpdate
+		if i == len(r.updates)-1 {
+			step = syncStep2
+		}
+		client.push(encodeSyncMessage(step, update))
+	}
+}
+
+// requestState asks the client for its full state, used to compact the log
+func (r *room) requestState(client *Client) {
+	client.awaitingState = true
+	client.logMark = len(r.updates)
+	client.generation = r.generation
+	client.push(encodeSyncMessage(syncStep1, emptyStateVector))
+}
+
+// applyUpdate stores an update received from a client. When the update is the full
+// state requested by requestState, the part of the log already known by the client is replaced.
+func (r *room) applyUpdate(client *Client, step uint64, update []byte) {
+	if client != nil && step == syncStep2 && client.awaitingState && client.generation == r.generation {
+		updates := make([][]byte, 0, len(r.updates)-client.logMark+1)
+		updates = append(updates, update)
+		updates = append(updates, r.updates[client.logMark:]...)
+		r.updates = updates
+		r.generation++
+	} else {
+		r.updates = append(r.updates, update)
+	}
+	if client != nil {
+		client.awaitingState = false
+		r.editors[client.UserId] = struct{}{}
+	}
+	r.dirty = true
+}
+
+// removeAwareness returns the message announcing that the client states are removed
+func (r *room) removeAwareness(client *Client) []byte {
+	if len(client.awareness) == 0 {
+		return nil
+	}
+	entries := make([]awarenessEntry, 0, len(client.awareness))
+	for clientId, clock := range client.awareness {
+		entries = append(entries, awarenessEntry{ClientId: clientId, Clock: clock + 1, State: "null"})
+	}
+	return encodeAwarenessMessage(entries)
+}
@@ -0,0 +1,32 @@
+package collaboration
+
+import "testing"
+
+func TestRoomBroadcastDropsSlowClient(t *testing.T) {
+	r := newRoom("document", nil)
+	slow := NewClient("slow", false)
+	fast := NewClient("fast", false)
+	r.clients[slow] = struct{}{}
+	r.clients[fast] = struct{}{}
+
+	// the slow client never reads its messages, the messages are sent by the other client
+	for i := 0; i <= clientBufferSize+1; i++ {
+		r.broadcast([]byte{byte(i)}, fast)
+	}
+
+	if !slow.closed {
+		t.Fatal("slow client should be closed once its buffer is full")
+	}
+	if _, ok := r.clients[slow]; ok {
+		t.Fatal("slow client should be removed from the room")
+	}
+
+	// pushing to or closing the closed client again must not panic
+	slow.push([]byte{0})
+	slow.close()
+	r.broadcast([]byte{0}, fast)
+
+	if _, ok := r.clients[fast]; !ok {
+		t.Fatal("sender should stay in the room")
+	}
+}
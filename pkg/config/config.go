@@ -16,8 +16,10 @@ var (
 	}
 
 	Server struct {
-		Port     int
-		HttpLogs bool
+		Port      int
+		HttpLogs  bool
+		PublicUrl string // Public url of the instance used in the links of the public pages, the request host when empty and the pages are then kept out of the shared caches
+		SiteName  string // Name of the site shown in the public pages and the feeds
 	}
 
 	Session struct {
@@ -42,6 +44,66 @@ var (
 		Enable bool // Enable or disable caching
 	}
 
+	Collaboration struct {
+		Enabled         bool   // Enable or disable real-time collaborative editing
+		Broker          string // Broker used to share the updates between instances (memory, redis)
+		PersistInterval int    // Interval in seconds between two persistences of the documents
+	}
+
+	Presence struct {
+		HeartbeatInterval int // Interval in seconds between two presence updates sent to the clients
+		Timeout           int // Time in seconds without heartbeat before a presence is dropped
+	}
+
+	Attachment struct {
+		Storage      string          // Storage backend of the attachments (local, s3)
+		MaxSize      int             // Maximum size of an attachment in MB
+		AllowedTypes cli.StringSlice // List of allowed content types (e.g., 'image/*'), all types are allowed when empty
+		Local        struct {
+			Path string // Directory where the attachments are stored
+		}
+
+		Variants struct {
+			Widths  cli.IntSlice // Widths of the image variants
+			Workers int          // Number of workers generating the variants after the upload, 0 generates them on demand only
+		}
+
+		S3 struct {
+			Endpoint  string // S3 endpoint (e.g., 's3.amazonaws.com', 'localhost:9000' for minio)
+			Region    string // S3 region
+			Bucket    string // Bucket where the attachments are stored
+			AccessKey string // S3 access key
+			SecretKey string // S3 secret key
+			UseSSL    bool   // Use https to reach the endpoint
+		}
+	}
+
+	Events struct {
+		Broker            string // Broker used to share the events between instances (memory, redis)
+		HeartbeatInterval int    // Interval in seconds between two keep-alive comments of the event streams
+	}
+
+	Webhook struct {
+		Timeout          int // Timeout in seconds of a delivery attempt
+		MaxAttempts      int // Number of attempts of a delivery before it fails
+		FailureThreshold int // Number of consecutive failed attempts disabling a webhook
+		Interval         int // Interval in seconds between two checks of the pending deliveries
+		// AllowPrivateNetworks allows the deliveries to the loopback, private and link-local addresses
+		AllowPrivateNetworks bool
+	}
+
+	Mail struct {
+		Host     string // Smtp server host, the emails are disabled when empty
+		Port     int    // Smtp server port
+		Username string // Smtp username, no authentication when empty
+		Password string // Smtp password
+		From     string // Sender address of the emails
+	}
+
+	Notification struct {
+		Interval int // Interval in seconds between two checks of the due reminders and the email digests
+	}
+
 	Auth struct {
 		DisableAdminAccount bool
 	}
@@ -0,0 +1,107 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// Type is the type of an event
+type Type string
+
+// Type constants
+const (
+	DocumentCreated     Type = "document.created"
+	DocumentUpdated     Type = "document.updated"
+	DocumentRenamed     Type = "document.renamed"
+	DocumentMoved       Type = "document.moved"
+	DocumentDeleted     Type = "document.deleted"
+	DocumentRestored    Type = "document.restored"
+	FavoriteAdded       Type = "favorite.added"
+	FavoriteRemoved     Type = "favorite.removed"
+	NotificationCreated Type = "notification.created"
+	SpaceCreated        Type = "space.created"
+	UserCreated         Type = "user.created"
+)
+
+// Event is a change streamed to the clients. The events of a space are sent to its members,
+// the events with a user are only sent to this user.
+type Event struct {
+	Id         string    `json:"id"`
+	Type       Type      `json:"type"`
+	SpaceId    string    `json:"space_id,omitempty"`
+	DocumentId string    `json:"document_id,omitempty"`
+	UserId     string    `json:"user_id,omitempty"`
+	ActorId    string    `json:"actor_id,omitempty"`
+	Data       any       `json:"data,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// Bus dispatches the events to the subscribers of all the instances
+type Bus interface {
+	// Publish sends the event to the subscribers, the id and the time are set when empty
+	Publish(event Event) error
+	// Subscribe registers a handler called for each event and returns the function removing it.
+	// The handlers are called synchronously and must not block.
+	Subscribe(handler func(event Event)) func()
+	Close() error
+}
+
+// stamp sets the id and the time of a new event
+func stamp(event Event) Event {
+	if event.Id == "" {
+		event.Id = utils.UUIDv4()
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	return event
+}
+
+// memoryBus dispatches the events to the subscribers of the instance
+type memoryBus struct {
+	mutex    sync.RWMutex
+	next     int
+	handlers map[int]func(event Event)
+}
+
+// NewMemoryBus returns a bus for a single instance
+func NewMemoryBus() *memoryBus {
+	return &memoryBus{handlers: make(map[int]func(event Event))}
+}
+
+func (b *memoryBus) Publish(event Event) error {
+	b.dispatch(stamp(event))
+	return nil
+}
+
+func (b *memoryBus) dispatch(event Event) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	for _, handler := range b.handlers {
+		handler(event)
+	}
+}
+
+func (b *memoryBus) Subscribe(handler func(event Event)) func() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	id := b.next
+	b.next++
+	b.handlers[id] = handler
+
+	return func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		delete(b.handlers, id)
+	}
+}
+
+func (b *memoryBus) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	clear(b.handlers)
+	return nil
+}
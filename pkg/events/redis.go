@@ -0,0 +1,75 @@
+package events
+
+import (
+	"context"
+
+	"github.com/goccy/go-json"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+)
+
+const redisChannel = "zotion:events"
+
+// redisBus publishes the events on a redis channel, every instance including the publisher
+// receives them from redis and dispatches them to its own subscribers
+type redisBus struct {
+	local  *memoryBus
+	client *redis.Client
+	pubsub *redis.PubSub
+	logger zerolog.Logger
+	ctx    context.Context
+}
+
+// NewRedisBus subscribes to the events channel of the redis server
+func NewRedisBus(logger zerolog.Logger, addr, password string, db int) (*redisBus, error) {
+	b := &redisBus{
+		local: NewMemoryBus(),
+		client: redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: password,
+			DB:       db,
+		}),
+		logger: logger,
+		ctx:    context.Background(),
+	}
+
+	b.pubsub = b.client.Subscribe(b.ctx, redisChannel)
+	if _, err := b.pubsub.Receive(b.ctx); err != nil {
+		b.client.Close()
+		return nil, err
+	}
+
+	go func() {
+		for msg := range b.pubsub.Channel() {
+			var event Event
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				b.logger.Warn().Err(err).Msg("Invalid event received from redis")
+				continue
+			}
+			b.local.dispatch(event)
+		}
+		b.logger.Debug().Msg("Events redis subscription closed")
+	}()
+
+	return b, nil
+}
+
+func (b *redisBus) Publish(event Event) error {
+	payload, err := json.Marshal(stamp(event))
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(b.ctx, redisChannel, payload).Err()
+}
+
+func (b *redisBus) Subscribe(handler func(event Event)) func() {
+	return b.local.Subscribe(handler)
+}
+
+func (b *redisBus) Close() error {
+	if err := b.pubsub.Close(); err != nil {
+		return err
+	}
+	b.local.Close()
+	return b.client.Close()
+}
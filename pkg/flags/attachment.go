@@ -0,0 +1,103 @@
+package flags
+
+import (
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/urfave/cli/v2"
+	"github.com/urfave/cli/v2/altsrc"
+)
+
+// AttachmentFlags returns a slice of cli.Flag for the attachments configuration
+func AttachmentFlags() []cli.Flag {
+	return []cli.Flag{
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:        "attachment.storage",
+			Aliases:     []string{"as"},
+			EnvVars:     []string{"ATTACHMENT_STORAGE"},
+			Usage:       "Storage backend of the attachments (e.g., 'local', 's3')",
+			Value:       "local",
+			Destination: &config.Attachment.Storage,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:        "attachment.max-size",
+			Aliases:     []string{"ams"},
+			EnvVars:     []string{"ATTACHMENT_MAX_SIZE"},
+			Usage:       "Maximum size of an attachment in MB",
+			Value:       20,
+			Destination: &config.Attachment.MaxSize,
+		}),
+		altsrc.NewStringSliceFlag(&cli.StringSliceFlag{
+			Name:        "attachment.allowed-types",
+			Aliases:     []string{"aat"},
+			EnvVars:     []string{"ATTACHMENT_ALLOWED_TYPES"},
+			Usage:       "List of allowed content types (e.g., 'image/*', 'application/pdf'), all types are allowed when empty",
+			Destination: &config.Attachment.AllowedTypes,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:        "attachment.local.path",
+			Aliases:     []string{"alp"},
+			EnvVars:     []string{"ATTACHMENT_LOCAL_PATH"},
+			Usage:       "Directory where the attachments are stored with the local storage",
+			Value:       "./attachments",
+			Destination: &config.Attachment.Local.Path,
+		}),
+		altsrc.NewIntSliceFlag(&cli.IntSliceFlag{
+			Name:        "attachment.variants.widths",
+			Aliases:     []string{"avw"},
+			EnvVars:     []string{"ATTACHMENT_VARIANTS_WIDTHS"},
+			Usage:       "Widths of the image variants served with the w parameter",
+			Value:       cli.NewIntSlice(160, 320, 640, 1280),
+			Destination: &config.Attachment.Variants.Widths,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:        "attachment.variants.workers",
+			Aliases:     []string{"avwk"},
+			EnvVars:     []string{"ATTACHMENT_VARIANTS_WORKERS"},
+			Usage:       "Number of workers generating the image variants after the upload, 0 generates them on demand only",
+			Value:       2,
+			Destination: &config.Attachment.Variants.Workers,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:        "attachment.s3.endpoint",
+			Aliases:     []string{"ase"},
+			EnvVars:     []string{"ATTACHMENT_S3_ENDPOINT"},
+			Usage:       "S3 endpoint (e.g., 's3.amazonaws.com', 'localhost:9000')",
+			Destination: &config.Attachment.S3.Endpoint,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:        "attachment.s3.region",
+			Aliases:     []string{"asr"},
+			EnvVars:     []string{"ATTACHMENT_S3_REGION"},
+			Usage:       "S3 region",
+			Destination: &config.Attachment.S3.Region,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:        "attachment.s3.bucket",
+			Aliases:     []string{"asb"},
+			EnvVars:     []string{"ATTACHMENT_S3_BUCKET"},
+			Usage:       "Bucket where the attachments are stored, it's created if missing",
+			Value:       "zotion",
+			Destination: &config.Attachment.S3.Bucket,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:        "attachment.s3.access-key",
+			Aliases:     []string{"asak"},
+			EnvVars:     []string{"ATTACHMENT_S3_ACCESS_KEY"},
+			Usage:       "S3 access key",
+			Destination: &config.Attachment.S3.AccessKey,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:        "attachment.s3.secret-key",
+			Aliases:     []string{"assk"},
+			EnvVars:     []string{"ATTACHMENT_S3_SECRET_KEY"},
+			Usage:       "S3 secret key",
+			Destination: &config.Attachment.S3.SecretKey,
+		}),
+		altsrc.NewBoolFlag(&cli.BoolFlag{
+			Name:        "attachment.s3.use-ssl",
+			Aliases:     []string{"asssl"},
+			EnvVars:     []string{"ATTACHMENT_S3_USE_SSL"},
+			Usage:       "Use https to reach the S3 endpoint",
+			Destination: &config.Attachment.S3.UseSSL,
+		}),
+	}
+}
@@ -0,0 +1,38 @@
+package flags
+
+import (
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/urfave/cli/v2"
+	"github.com/urfave/cli/v2/altsrc"
+)
+
+// CollaborationFlags returns a slice of cli.Flag for the real-time collaboration configuration.
+// The redis broker uses the caching redis settings.
+func CollaborationFlags() []cli.Flag {
+	return []cli.Flag{
+		altsrc.NewBoolFlag(&cli.BoolFlag{
+			Name:        "collaboration.enabled",
+			Aliases:     []string{"cole"},
+			EnvVars:     []string{"COLLABORATION_ENABLED"},
+			Usage:       "Enable real-time collaborative editing",
+			Value:       true,
+			Destination: &config.Collaboration.Enabled,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:        "collaboration.broker",
+			Aliases:     []string{"colb"},
+			EnvVars:     []string{"COLLABORATION_BROKER"},
+			Usage:       "Broker used to share updates between instances (e.g., 'memory', 'redis')",
+			Value:       "memory",
+			Destination: &config.Collaboration.Broker,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:        "collaboration.persist-interval",
+			Aliases:     []string{"colpi"},
+			EnvVars:     []string{"COLLABORATION_PERSIST_INTERVAL"},
+			Usage:       "Interval in seconds between two persistences of the edited documents",
+			Value:       10,
+			Destination: &config.Collaboration.PersistInterval,
+		}),
+	}
+}
@@ -0,0 +1,30 @@
+package flags
+
+import (
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/urfave/cli/v2"
+	"github.com/urfave/cli/v2/altsrc"
+)
+
+// EventsFlags returns a slice of cli.Flag for the live events configuration.
+// The redis broker uses the caching redis settings.
+func EventsFlags() []cli.Flag {
+	return []cli.Flag{
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:        "events.broker",
+			Aliases:     []string{"evb"},
+			EnvVars:     []string{"EVENTS_BROKER"},
+			Usage:       "Broker used to share the live events between instances (e.g., 'memory', 'redis')",
+			Value:       "memory",
+			Destination: &config.Events.Broker,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:        "events.heartbeat-interval",
+			Aliases:     []string{"evhi"},
+			EnvVars:     []string{"EVENTS_HEARTBEAT_INTERVAL"},
+			Usage:       "Interval in seconds between two keep-alive comments of the event streams",
+			Value:       15,
+			Destination: &config.Events.HeartbeatInterval,
+		}),
+	}
+}
@@ -0,0 +1,64 @@
+package flags
+
+import (
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/urfave/cli/v2"
+	"github.com/urfave/cli/v2/altsrc"
+)
+
+// MailFlags returns a slice of cli.Flag for the mail configuration
+func MailFlags() []cli.Flag {
+	return []cli.Flag{
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:        "mail.host",
+			Aliases:     []string{"mh"},
+			EnvVars:     []string{"MAIL_HOST"},
+			Usage:       "Smtp server host, the emails are disabled when empty",
+			Destination: &config.Mail.Host,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:        "mail.port",
+			Aliases:     []string{"mp"},
+			EnvVars:     []string{"MAIL_PORT"},
+			Usage:       "Smtp server port",
+			Value:       587,
+			Destination: &config.Mail.Port,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:        "mail.username",
+			Aliases:     []string{"mu"},
+			EnvVars:     []string{"MAIL_USERNAME"},
+			Usage:       "Smtp username, no authentication when empty",
+			Destination: &config.Mail.Username,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:        "mail.password",
+			Aliases:     []string{"mpw"},
+			EnvVars:     []string{"MAIL_PASSWORD"},
+			Usage:       "Smtp password",
+			Destination: &config.Mail.Password,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:        "mail.from",
+			Aliases:     []string{"mf"},
+			EnvVars:     []string{"MAIL_FROM"},
+			Usage:       "Sender address of the emails (e.g., 'Zotion <noreply@example.com>')",
+			Value:       "Zotion <noreply@localhost>",
+			Destination: &config.Mail.From,
+		}),
+	}
+}
+
+// NotificationFlags returns a slice of cli.Flag for the notifications configuration
+func NotificationFlags() []cli.Flag {
+	return []cli.Flag{
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:        "notification.interval",
+			Aliases:     []string{"ni"},
+			EnvVars:     []string{"NOTIFICATION_INTERVAL"},
+			Usage:       "Interval in seconds between two checks of the due reminders and the email digests",
+			Value:       60,
+			Destination: &config.Notification.Interval,
+		}),
+	}
+}
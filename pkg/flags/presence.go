@@ -0,0 +1,29 @@
+package flags
+
+import (
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/urfave/cli/v2"
+	"github.com/urfave/cli/v2/altsrc"
+)
+
+// PresenceFlags returns a slice of cli.Flag for the presence configuration.
+func PresenceFlags() []cli.Flag {
+	return []cli.Flag{
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:        "presence.heartbeat-interval",
+			Aliases:     []string{"phi"},
+			EnvVars:     []string{"PRESENCE_HEARTBEAT_INTERVAL"},
+			Usage:       "Interval in seconds between two presence updates sent to the clients",
+			Value:       5,
+			Destination: &config.Presence.HeartbeatInterval,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:        "presence.timeout",
+			Aliases:     []string{"pt"},
+			EnvVars:     []string{"PRESENCE_TIMEOUT"},
+			Usage:       "Time in seconds without heartbeat before a presence is dropped",
+			Value:       30,
+			Destination: &config.Presence.Timeout,
+		}),
+	}
+}
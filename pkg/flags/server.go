@@ -24,5 +24,21 @@ func ServerFlags() []cli.Flag {
 			Value:       false,
 			Destination: &config.Server.HttpLogs,
 		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:        "http.public_url",
+			Aliases:     []string{"hpu"},
+			EnvVars:     []string{"HTTP_PUBLIC_URL"},
+			Usage:       "Public url of the instance used in the links of the public pages, the request host when empty and the pages are then kept out of the shared caches",
+			Value:       "",
+			Destination: &config.Server.PublicUrl,
+		}),
+		altsrc.NewStringFlag(&cli.StringFlag{
+			Name:        "http.site_name",
+			Aliases:     []string{"hsn"},
+			EnvVars:     []string{"HTTP_SITE_NAME"},
+			Usage:       "Name of the site shown in the public pages and the feeds",
+			Value:       "Zotion",
+			Destination: &config.Server.SiteName,
+		}),
 	}
 }
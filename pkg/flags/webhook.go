@@ -0,0 +1,52 @@
+package flags
+
+import (
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/urfave/cli/v2"
+	"github.com/urfave/cli/v2/altsrc"
+)
+
+// WebhookFlags returns a slice of cli.Flag for the webhooks configuration
+func WebhookFlags() []cli.Flag {
+	return []cli.Flag{
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:        "webhook.timeout",
+			Aliases:     []string{"wht"},
+			EnvVars:     []string{"WEBHOOK_TIMEOUT"},
+			Usage:       "Timeout in seconds of a delivery attempt",
+			Value:       10,
+			Destination: &config.Webhook.Timeout,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:        "webhook.max-attempts",
+			Aliases:     []string{"whma"},
+			EnvVars:     []string{"WEBHOOK_MAX_ATTEMPTS"},
+			Usage:       "Number of attempts of a delivery before it fails, retried with an exponential backoff",
+			Value:       8,
+			Destination: &config.Webhook.MaxAttempts,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:        "webhook.failure-threshold",
+			Aliases:     []string{"whft"},
+			EnvVars:     []string{"WEBHOOK_FAILURE_THRESHOLD"},
+			Usage:       "Number of consecutive failed attempts disabling a webhook",
+			Value:       20,
+			Destination: &config.Webhook.FailureThreshold,
+		}),
+		altsrc.NewIntFlag(&cli.IntFlag{
+			Name:        "webhook.interval",
+			Aliases:     []string{"whi"},
+			EnvVars:     []string{"WEBHOOK_INTERVAL"},
+			Usage:       "Interval in seconds between two checks of the pending deliveries",
+			Value:       5,
+			Destination: &config.Webhook.Interval,
+		}),
+		altsrc.NewBoolFlag(&cli.BoolFlag{
+			Name:        "webhook.allow-private-networks",
+			Aliases:     []string{"whapn"},
+			EnvVars:     []string{"WEBHOOK_ALLOW_PRIVATE_NETWORKS"},
+			Usage:       "Allow the deliveries to the loopback, private and link-local addresses",
+			Destination: &config.Webhook.AllowPrivateNetworks,
+		}),
+	}
+}
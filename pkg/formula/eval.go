@@ -0,0 +1,208 @@
+package formula
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// check sets the types of the nodes, the types of the properties are returned by the resolver
+func check(n *node, resolve Resolver) error {
+	for _, arg := range n.args {
+		if err := check(arg, resolve); err != nil {
+			return err
+		}
+	}
+
+	switch n.kind {
+	case nodeLiteral:
+		n.typ = n.value.Type
+	case nodeProperty:
+		typ, ok := resolve(n.name)
+		if !ok {
+			return errorf(n.position, "unknown property %q", n.name)
+		}
+		n.typ = typ
+	case nodeUnary:
+		operand := n.args[0].typ
+		switch {
+		case n.op == "-" && operand == TypeNumber:
+			n.typ = TypeNumber
+		case n.op == "!" && operand == TypeBoolean:
+			n.typ = TypeBoolean
+		default:
+			return errorf(n.position, "%s can't be applied to %s", n.op, operand)
+		}
+	case nodeBinary:
+		typ, err := binaryType(n.op, n.args[0].typ, n.args[1].typ)
+		if err != nil {
+			return errorf(n.position, "%s", err.Error())
+		}
+		n.typ = typ
+	case nodeCall:
+		f, ok := functions[n.name]
+		if !ok {
+			return errorf(n.position, "unknown function %s", n.name)
+		}
+		types := make([]Type, len(n.args))
+		for i, arg := range n.args {
+			types[i] = arg.typ
+		}
+		typ, err := f.check(types)
+		if err != nil {
+			return errorf(n.position, "%s: %s", n.name, err.Error())
+		}
+		n.typ = typ
+	}
+	return nil
+}
+
+// binaryType returns the type of the result of a binary operator, the text concatenation accepts any value
+func binaryType(op string, left, right Type) (Type, error) {
+	switch op {
+	case "+":
+		if left == TypeText || right == TypeText {
+			return TypeText, nil
+		}
+		if left == TypeNumber && right == TypeNumber {
+			return TypeNumber, nil
+		}
+	case "-", "*", "/", "%":
+		if left == TypeNumber && right == TypeNumber {
+			return TypeNumber, nil
+		}
+	case "&&", "||":
+		if left == TypeBoolean && right == TypeBoolean {
+			return TypeBoolean, nil
+		}
+	case "==", "!=":
+		if left == right {
+			return TypeBoolean, nil
+		}
+	case "<", "<=", ">", ">=":
+		if left == right && left != TypeBoolean {
+			return TypeBoolean, nil
+		}
+	}
+	return "", errors.New(op + " can't be applied to " + string(left) + " and " + string(right))
+}
+
+func (n *node) eval(env Env) (Value, error) {
+	switch n.kind {
+	case nodeLiteral:
+		return n.value, nil
+	case nodeProperty:
+		return env(n.name), nil
+	case nodeUnary:
+		operand, err := n.args[0].eval(env)
+		if err != nil {
+			return operand, err
+		}
+		if n.op == "-" {
+			return Number(-operand.Number), nil
+		}
+		return Boolean(!operand.Boolean), nil
+	case nodeBinary:
+		return n.binary(env)
+	}
+
+	// the conditions only evaluate the selected branch
+	if n.name == "if" {
+		condition, err := n.args[0].eval(env)
+		if err != nil {
+			return condition, err
+		}
+		if condition.Boolean {
+			return n.args[1].eval(env)
+		}
+		return n.args[2].eval(env)
+	}
+
+	args := make([]Value, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(env)
+		if err != nil {
+			return value, err
+		}
+		args[i] = value
+	}
+	value, err := functions[n.name].eval(args)
+	if err != nil {
+		return value, errorf(n.position, "%s: %s", n.name, err.Error())
+	}
+	return value, nil
+}
+
+func (n *node) binary(env Env) (Value, error) {
+	left, err := n.args[0].eval(env)
+	if err != nil {
+		return left, err
+	}
+	// the boolean operators short-circuit
+	if n.op == "&&" && !left.Boolean || n.op == "||" && left.Boolean {
+		return left, nil
+	}
+	right, err := n.args[1].eval(env)
+	if err != nil {
+		return right, err
+	}
+
+	switch n.op {
+	case "&&", "||":
+		return right, nil
+	case "+":
+		if n.typ == TypeText {
+			return Text(left.String() + right.String()), nil
+		}
+		return Number(left.Number + right.Number), nil
+	case "-":
+		return Number(left.Number - right.Number), nil
+	case "*":
+		return Number(left.Number * right.Number), nil
+	case "/", "%":
+		if right.Number == 0 {
+			return Value{}, errorf(n.position, "division by zero")
+		}
+		if n.op == "%" {
+			return Number(math.Mod(left.Number, right.Number)), nil
+		}
+		return Number(left.Number / right.Number), nil
+	}
+
+	c := compare(left, right)
+	switch n.op {
+	case "==":
+		return Boolean(c == 0), nil
+	case "!=":
+		return Boolean(c != 0), nil
+	case "<":
+		return Boolean(c < 0), nil
+	case "<=":
+		return Boolean(c <= 0), nil
+	case ">":
+		return Boolean(c > 0), nil
+	}
+	return Boolean(c >= 0), nil
+}
+
+// compare compares two values of the same type
+func compare(a, b Value) int {
+	switch a.Type {
+	case TypeNumber:
+		switch {
+		case a.Number < b.Number:
+			return -1
+		case a.Number > b.Number:
+			return 1
+		}
+		return 0
+	case TypeText:
+		return strings.Compare(a.Text, b.Text)
+	case TypeBoolean:
+		if a.Boolean == b.Boolean {
+			return 0
+		}
+		return 1
+	}
+	return a.Date.Compare(b.Date)
+}
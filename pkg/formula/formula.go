@@ -0,0 +1,182 @@
+// Package formula implements the expression language of the formula properties.
+//
+// A formula combines literals, the properties of the row with prop("Name"), operators and functions:
+//
+//	if(prop("Done"), 100, prop("Points") * 10 / prop("Estimate"))
+//	dateBetween(prop("End"), prop("Start"), "days") + " days"
+//
+// The expressions are type-checked when they are compiled and have no side effect,
+// their size and nesting are limited so they are safe to evaluate on the server.
+package formula
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Limits of the expressions
+const (
+	maxLength = 2000
+	maxDepth  = 50
+)
+
+// Type is the type of a formula value
+type Type string
+
+// Type constants
+const (
+	TypeNumber  Type = "number"
+	TypeText    Type = "text"
+	TypeBoolean Type = "boolean"
+	TypeDate    Type = "date"
+)
+
+// Value is a typed formula value, an empty date is the zero time
+type Value struct {
+	Type    Type
+	Number  float64
+	Text    string
+	Boolean bool
+	Date    time.Time
+}
+
+// Number returns a number value
+func Number(number float64) Value { return Value{Type: TypeNumber, Number: number} }
+
+// Text returns a text value
+func Text(text string) Value { return Value{Type: TypeText, Text: text} }
+
+// Boolean returns a boolean value
+func Boolean(boolean bool) Value { return Value{Type: TypeBoolean, Boolean: boolean} }
+
+// Date returns a date value
+func Date(date time.Time) Value { return Value{Type: TypeDate, Date: date} }
+
+// IsEmpty returns true for the zero values of the types
+func (v Value) IsEmpty() bool {
+	switch v.Type {
+	case TypeNumber:
+		return v.Number == 0
+	case TypeText:
+		return v.Text == ""
+	case TypeBoolean:
+		return !v.Boolean
+	}
+	return v.Date.IsZero()
+}
+
+// String formats the value, the dates without time are formatted as dates only
+func (v Value) String() string {
+	switch v.Type {
+	case TypeNumber:
+		return strconv.FormatFloat(v.Number, 'f', -1, 64)
+	case TypeText:
+		return v.Text
+	case TypeBoolean:
+		return strconv.FormatBool(v.Boolean)
+	}
+	if v.Date.IsZero() {
+		return ""
+	}
+	date := v.Date.UTC()
+	if date.Equal(date.Truncate(24 * time.Hour)) {
+		return date.Format(time.DateOnly)
+	}
+	return date.Format(time.RFC3339)
+}
+
+// Error is a compilation or evaluation error of an expression
+type Error struct {
+	Position int
+	Message  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Message, e.Position)
+}
+
+func errorf(position int, format string, args ...any) *Error {
+	return &Error{Position: position, Message: fmt.Sprintf(format, args...)}
+}
+
+// Resolver returns the type of a referenced property, ok is false when it doesn't exist
+type Resolver func(name string) (Type, bool)
+
+// Env returns the value of a referenced property when an expression is evaluated
+type Env func(name string) Value
+
+// Reference is a property referenced by an expression and its position in the source
+type Reference struct {
+	Name  string
+	Start int
+	End   int
+}
+
+// Expression is a compiled and type-checked expression
+type Expression struct {
+	root       *node
+	references []Reference
+}
+
+// Compile parses the source and checks the types of the expression,
+// the types of the referenced properties are returned by the resolver
+func Compile(source string, resolve Resolver) (*Expression, error) {
+	root, references, err := parse(source)
+	if err != nil {
+		return nil, err
+	}
+	if err := check(root, resolve); err != nil {
+		return nil, err
+	}
+	return &Expression{root: root, references: references}, nil
+}
+
+// References returns the properties referenced by the source without checking it
+func References(source string) ([]Reference, error) {
+	_, references, err := parse(source)
+	return references, err
+}
+
+// Rename returns the source with the references to the properties renamed
+func Rename(source string, names map[string]string) (string, error) {
+	references, err := References(source)
+	if err != nil {
+		return source, err
+	}
+	renamed := ""
+	last := 0
+	for _, reference := range references {
+		name, ok := names[reference.Name]
+		if !ok {
+			continue
+		}
+		renamed += source[last:reference.Start] + strconv.Quote(name)
+		last = reference.End
+	}
+	return renamed + source[last:], nil
+}
+
+// Type returns the type of the result of the expression
+func (e *Expression) Type() Type {
+	return e.root.typ
+}
+
+// References returns the properties referenced by the expression
+func (e *Expression) References() []Reference {
+	return e.references
+}
+
+// Eval evaluates the expression with the values of the properties
+func (e *Expression) Eval(env Env) (Value, error) {
+	value, err := e.root.eval(env)
+	if err != nil {
+		return value, err
+	}
+	if value.Type == TypeNumber && (math.IsNaN(value.Number) || math.IsInf(value.Number, 0)) {
+		return value, errors.New("the result is not a number")
+	}
+	return value, nil
+}
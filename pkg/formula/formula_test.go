@@ -0,0 +1,226 @@
+package formula
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// properties are the properties of the row of the tests
+var properties = map[string]Value{
+	"Points": Number(8),
+	"Name":   Text("Task"),
+	"Done":   Boolean(true),
+	"Start":  Date(time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)),
+	"Empty":  Date(time.Time{}),
+}
+
+func resolve(name string) (Type, bool) {
+	value, ok := properties[name]
+	return value.Type, ok
+}
+
+func env(name string) Value {
+	return properties[name]
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		want     string
+		wantType Type
+	}{
+		{"number", "42", "42", TypeNumber},
+		{"decimal", ".5", "0.5", TypeNumber},
+		{"multiplication before addition", "1 + 2 * 3", "7", TypeNumber},
+		{"parentheses", "(1 + 2) * 3", "9", TypeNumber},
+		{"left associative subtraction", "10 - 4 - 3", "3", TypeNumber},
+		{"left associative division", "24 / 4 / 2", "3", TypeNumber},
+		{"modulo with multiplication", "2 * 3 % 4", "2", TypeNumber},
+		{"unary minus", "-2 * -3", "6", TypeNumber},
+		{"double negation", "--1", "1", TypeNumber},
+		{"and before or", "true || false && false", "true", TypeBoolean},
+		{"comparison before and", "1 < 2 && 3 > 2", "true", TypeBoolean},
+		{"arithmetic before comparison", "1 + 1 == 2", "true", TypeBoolean},
+		{"not keyword", "not true or false", "false", TypeBoolean},
+		{"and keyword", "true and !false", "true", TypeBoolean},
+		{"text concatenation", `"a" + 1 + 2`, "a12", TypeText},
+		{"number then text", `1 + 2 + "a"`, "3a", TypeText},
+		{"escaped string", `"say \"hi\""`, `say "hi"`, TypeText},
+		{"text comparison", `"abc" < "abd"`, "true", TypeBoolean},
+		{"property", `prop("Points") * 2`, "16", TypeNumber},
+		{"boolean property", `if(prop("Done"), "done", "todo")`, "done", TypeText},
+		{"if evaluates only its branch", `if(true, 1, 1 / 0)`, "1", TypeNumber},
+		{"and short-circuits", `false && 1 / 0 == 1`, "false", TypeBoolean},
+		{"or short-circuits", `true || 1 / 0 == 1`, "true", TypeBoolean},
+		{"empty", `empty(prop("Empty"))`, "true", TypeBoolean},
+		{"format", `format(prop("Done"))`, "true", TypeText},
+		{"toNumber", `toNumber(" 1.5 ") + 1`, "2.5", TypeNumber},
+		{"length counts runes", `length("héllo")`, "5", TypeNumber},
+		{"upper", `upper(prop("Name"))`, "TASK", TypeText},
+		{"substring", `substring("formula", 1, 4)`, "orm", TypeText},
+		{"substring clamped", `substring("formula", -5, 50)`, "formula", TypeText},
+		{"replace", `replace("a-b-c", "-", "+")`, "a+b+c", TypeText},
+		{"round with digits", `round(3.14159, 2)`, "3.14", TypeNumber},
+		{"min and max", `min(3, 1, 2) + max(3, 1, 2)`, "4", TypeNumber},
+		{"date", `date("2026-10-19")`, "2026-10-19", TypeDate},
+		{"date time", `date("2026-10-19T10:30:00+02:00")`, "2026-10-19T08:30:00Z", TypeDate},
+		{"add months at the end of the month", `dateAdd(prop("Start"), 1, "months")`, "2026-02-28", TypeDate},
+		{"add years", `dateAdd(prop("Start"), 1, "years")`, "2027-01-31", TypeDate},
+		{"subtract weeks", `dateSubtract(prop("Start"), 2, "weeks")`, "2026-01-17", TypeDate},
+		{"days between", `dateBetween(date("2026-03-01"), prop("Start"), "days")`, "29", TypeNumber},
+		{"incomplete month not counted", `dateBetween(date("2026-02-28"), prop("Start"), "months")`, "0", TypeNumber},
+		{"negative months", `dateBetween(prop("Start"), date("2026-03-31"), "months")`, "-2", TypeNumber},
+		{"date parts", `year(prop("Start")) + month(prop("Start")) + day(prop("Start"))`, "2058", TypeNumber},
+		{"date comparison", `prop("Start") < date("2026-02-01")`, "true", TypeBoolean},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expression, err := Compile(tt.source, resolve)
+			if err != nil {
+				t.Fatalf("Compile(%s) unexpected error: %v", tt.source, err)
+			}
+			if expression.Type() != tt.wantType {
+				t.Errorf("Compile(%s) type = %s, want %s", tt.source, expression.Type(), tt.wantType)
+			}
+			value, err := expression.Eval(env)
+			if err != nil {
+				t.Fatalf("Eval(%s) unexpected error: %v", tt.source, err)
+			}
+			if value.String() != tt.want {
+				t.Errorf("Eval(%s) = %q, want %q", tt.source, value.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string
+	}{
+		{"empty", "  ", "empty expression at position 0"},
+		{"unterminated string", `"abc`, "unterminated string at position 0"},
+		{"unexpected character", "1 # 2", `unexpected character '#' at position 2`},
+		{"invalid number", "1.2.3", `invalid number "1.2.3"`},
+		{"missing operand", "1 +", "unexpected end of expression at position 3"},
+		{"missing parenthesis", "(1 + 2", `")" expected at position 6`},
+		{"trailing tokens", "1 2", `unexpected "2" at position 2`},
+		{"prop without string", "prop(Points)", "prop expects the name of a property"},
+		{"unknown property", `prop("Missing")`, `unknown property "Missing" at position 0`},
+		{"unknown function", "sum(1, 2)", "unknown function sum at position 0"},
+		{"number and boolean", "1 + true", "+ can't be applied to number and boolean"},
+		{"text subtraction", `"a" - 1`, "- can't be applied to text and number"},
+		{"boolean ordering", "true < false", "< can't be applied to boolean and boolean"},
+		{"equality of different types", `1 == "1"`, "== can't be applied to number and text"},
+		{"negated text", `-"a"`, "- can't be applied to text"},
+		{"not a number", "!1", "! can't be applied to number"},
+		{"if condition", `if(1, 2, 3)`, "if: the condition must be a boolean, not a number"},
+		{"if branches", `if(true, 1, "a")`, "if: both branches must have the same type, got number and text"},
+		{"if arguments", `if(true, 1)`, "if: 3 arguments expected, got 2"},
+		{"function argument type", `upper(1)`, "upper:"},
+		{"too many arguments", `abs(1, 2)`, "abs:"},
+		{"date of a number", `date(1)`, "date:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.source, resolve)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Compile(%s) error = %v, want %q", tt.source, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string
+	}{
+		{"division by zero", "1 / (2 - 2)", "division by zero at position 2"},
+		{"modulo by zero", "1 % 0", "division by zero"},
+		{"not a number", "sqrt(-1)", "the result is not a number"},
+		{"invalid number", `toNumber("one")`, `toNumber: invalid number "one"`},
+		{"invalid date", `date("19/10/2026")`, `date: invalid date "19/10/2026"`},
+		{"empty date", `dateAdd(prop("Empty"), 1, "days")`, "dateAdd: empty date at position 0"},
+		{"unknown unit", `dateAdd(prop("Start"), 1, "fortnights")`, `dateAdd: unknown unit "fortnights"`},
+		{"empty date part", `year(prop("Empty"))`, "year: empty date"},
+		{"error in a branch", `if(prop("Done"), 1 / 0, 1)`, "division by zero"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expression, err := Compile(tt.source, resolve)
+			if err != nil {
+				t.Fatalf("Compile(%s) unexpected error: %v", tt.source, err)
+			}
+			_, err = expression.Eval(env)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Eval(%s) error = %v, want %q", tt.source, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string
+	}{
+		{"maximum length", "1" + strings.Repeat(" ", maxLength-1), ""},
+		{"too long", "1" + strings.Repeat(" ", maxLength), "expression longer than 2000 characters"},
+		{"maximum nesting", strings.Repeat("(", maxDepth-1) + "1" + strings.Repeat(")", maxDepth-1), ""},
+		{"nested parentheses", strings.Repeat("(", maxDepth) + "1" + strings.Repeat(")", maxDepth), "expression nested more than 50 times"},
+		{"nested unary operators", strings.Repeat("-", maxDepth) + "1", "expression nested more than 50 times"},
+		{"nested calls", strings.Repeat("abs(", maxDepth) + "1" + strings.Repeat(")", maxDepth), "expression nested more than 50 times"},
+		{"long flat expression", "1" + strings.Repeat("+1", 900), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.source, resolve)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Compile() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Compile() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRename(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		names  map[string]string
+		want   string
+	}{
+		{"renamed reference", `prop("Points") * 2`, map[string]string{"Points": "Score"}, `prop("Score") * 2`},
+		{"every reference", `prop("A") + prop("B") + prop("A")`, map[string]string{"A": "C"}, `prop("C") + prop("B") + prop("C")`},
+		{"quoted name", `prop("A")`, map[string]string{"A": `Say "hi"`}, `prop("Say \"hi\"")`},
+		{"text literal unchanged", `"A" + prop("B")`, map[string]string{"A": "C"}, `"A" + prop("B")`},
+		{"nothing renamed", `prop("A")`, map[string]string{}, `prop("A")`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Rename(tt.source, tt.names)
+			if err != nil {
+				t.Fatalf("Rename() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Rename() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package formula
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// function is a function of the language, check returns the type of its result for the types of the arguments
+type function struct {
+	check func(args []Type) (Type, error)
+	eval  func(args []Value) (Value, error)
+}
+
+// signature returns the check of a function with fixed arguments, the optional arguments are last
+func signature(result Type, optional int, params ...Type) func([]Type) (Type, error) {
+	return func(args []Type) (Type, error) {
+		if len(args) < len(params)-optional || len(args) > len(params) {
+			return "", fmt.Errorf("%d arguments expected, got %d", len(params), len(args))
+		}
+		for i, arg := range args {
+			if arg != params[i] {
+				return "", fmt.Errorf("argument %d must be a %s, not a %s", i+1, params[i], arg)
+			}
+		}
+		return result, nil
+	}
+}
+
+// numbers returns the check of a function of one or more numbers
+func numbers(args []Type) (Type, error) {
+	if len(args) == 0 {
+		return "", errors.New("at least one argument expected")
+	}
+	for i, arg := range args {
+		if arg != TypeNumber {
+			return "", fmt.Errorf("argument %d must be a number, not a %s", i+1, arg)
+		}
+	}
+	return TypeNumber, nil
+}
+
+// number returns a function of a number
+func number(f func(float64) float64) function {
+	return function{
+		check: signature(TypeNumber, 0, TypeNumber),
+		eval:  func(args []Value) (Value, error) { return Number(f(args[0].Number)), nil },
+	}
+}
+
+// text returns a function of a text
+func text(f func(string) string) function {
+	return function{
+		check: signature(TypeText, 0, TypeText),
+		eval:  func(args []Value) (Value, error) { return Text(f(args[0].Text)), nil },
+	}
+}
+
+// dateUnits are the units of the date math, the months and the years follow the calendar
+var dateUnits = map[string]time.Duration{
+	"years":   0,
+	"months":  0,
+	"weeks":   7 * 24 * time.Hour,
+	"days":    24 * time.Hour,
+	"hours":   time.Hour,
+	"minutes": time.Minute,
+	"seconds": time.Second,
+}
+
+var functions map[string]function
+
+func init() {
+	functions = map[string]function{
+		"if": {
+			check: func(args []Type) (Type, error) {
+				if len(args) != 3 {
+					return "", fmt.Errorf("3 arguments expected, got %d", len(args))
+				}
+				if args[0] != TypeBoolean {
+					return "", fmt.Errorf("the condition must be a boolean, not a %s", args[0])
+				}
+				if args[1] != args[2] {
+					return "", fmt.Errorf("both branches must have the same type, got %s and %s", args[1], args[2])
+				}
+				return args[1], nil
+			},
+		},
+		"empty": {
+			check: func(args []Type) (Type, error) {
+				if len(args) != 1 {
+					return "", fmt.Errorf("1 argument expected, got %d", len(args))
+				}
+				return TypeBoolean, nil
+			},
+			eval: func(args []Value) (Value, error) { return Boolean(args[0].IsEmpty()), nil },
+		},
+		"format": {
+			check: func(args []Type) (Type, error) {
+				if len(args) != 1 {
+					return "", fmt.Errorf("1 argument expected, got %d", len(args))
+				}
+				return TypeText, nil
+			},
+			eval: func(args []Value) (Value, error) { return Text(args[0].String()), nil },
+		},
+		"toNumber": {
+			check: signature(TypeNumber, 0, TypeText),
+			eval: func(args []Value) (Value, error) {
+				number, err := strconv.ParseFloat(strings.TrimSpace(args[0].Text), 64)
+				if err != nil {
+					return Value{}, fmt.Errorf("invalid number %q", args[0].Text)
+				}
+				return Number(number), nil
+			},
+		},
+
+		"length": {
+			check: signature(TypeNumber, 0, TypeText),
+			eval:  func(args []Value) (Value, error) { return Number(float64(len([]rune(args[0].Text)))), nil },
+		},
+		"lower": text(strings.ToLower),
+		"upper": text(strings.ToUpper),
+		"trim":  text(strings.TrimSpace),
+		"contains": {
+			check: signature(TypeBoolean, 0, TypeText, TypeText),
+			eval: func(args []Value) (Value, error) {
+				return Boolean(strings.Contains(args[0].Text, args[1].Text)), nil
+			},
+		},
+		"replace": {
+			check: signature(TypeText, 0, TypeText, TypeText, TypeText),
+			eval: func(args []Value) (Value, error) {
+				return Text(strings.ReplaceAll(args[0].Text, args[1].Text, args[2].Text)), nil
+			},
+		},
+		"substring": {
+			check: signature(TypeText, 1, TypeText, TypeNumber, TypeNumber),
+			eval: func(args []Value) (Value, error) {
+				runes := []rune(args[0].Text)
+				start, end := clamp(args[1].Number, len(runes)), len(runes)
+				if len(args) > 2 {
+					end = clamp(args[2].Number, len(runes))
+				}
+				if start >= end {
+					return Text(""), nil
+				}
+				return Text(string(runes[start:end])), nil
+			},
+		},
+
+		"abs":   number(math.Abs),
+		"floor": number(math.Floor),
+		"ceil":  number(math.Ceil),
+		"sqrt":  number(math.Sqrt),
+		"round": {
+			check: signature(TypeNumber, 1, TypeNumber, TypeNumber),
+			eval: func(args []Value) (Value, error) {
+				scale := 1.0
+				if len(args) > 1 {
+					scale = math.Pow(10, math.Trunc(args[1].Number))
+				}
+				return Number(math.Round(args[0].Number*scale) / scale), nil
+			},
+		},
+		"min": {
+			check: numbers,
+			eval: func(args []Value) (Value, error) {
+				result := args[0].Number
+				for _, arg := range args[1:] {
+					result = math.Min(result, arg.Number)
+				}
+				return Number(result), nil
+			},
+		},
+		"max": {
+			check: numbers,
+			eval: func(args []Value) (Value, error) {
+				result := args[0].Number
+				for _, arg := range args[1:] {
+					result = math.Max(result, arg.Number)
+				}
+				return Number(result), nil
+			},
+		},
+
+		"date": {
+			check: signature(TypeDate, 0, TypeText),
+			eval: func(args []Value) (Value, error) {
+				for _, layout := range []string{time.DateOnly, time.RFC3339} {
+					if date, err := time.Parse(layout, strings.TrimSpace(args[0].Text)); err == nil {
+						return Date(date.UTC()), nil
+					}
+				}
+				return Value{}, fmt.Errorf("invalid date %q", args[0].Text)
+			},
+		},
+		"dateAdd": {
+			check: signature(TypeDate, 0, TypeDate, TypeNumber, TypeText),
+			eval: func(args []Value) (Value, error) {
+				return dateAdd(args[0], args[1].Number, args[2].Text)
+			},
+		},
+		"dateSubtract": {
+			check: signature(TypeDate, 0, TypeDate, TypeNumber, TypeText),
+			eval: func(args []Value) (Value, error) {
+				return dateAdd(args[0], -args[1].Number, args[2].Text)
+			},
+		},
+		"dateBetween": {
+			check: signature(TypeNumber, 0, TypeDate, TypeDate, TypeText),
+			eval: func(args []Value) (Value, error) {
+				return dateBetween(args[0], args[1], args[2].Text)
+			},
+		},
+		"year": {
+			check: signature(TypeNumber, 0, TypeDate),
+			eval: func(args []Value) (Value, error) {
+				if args[0].Date.IsZero() {
+					return Value{}, errors.New("empty date")
+				}
+				return Number(float64(args[0].Date.Year())), nil
+			},
+		},
+		"month": {
+			check: signature(TypeNumber, 0, TypeDate),
+			eval: func(args []Value) (Value, error) {
+				if args[0].Date.IsZero() {
+					return Value{}, errors.New("empty date")
+				}
+				return Number(float64(args[0].Date.Month())), nil
+			},
+		},
+		"day": {
+			check: signature(TypeNumber, 0, TypeDate),
+			eval: func(args []Value) (Value, error) {
+				if args[0].Date.IsZero() {
+					return Value{}, errors.New("empty date")
+				}
+				return Number(float64(args[0].Date.Day())), nil
+			},
+		},
+	}
+}
+
+// clamp converts a position in a text to an index
+func clamp(position float64, length int) int {
+	return int(math.Max(0, math.Min(float64(length), math.Trunc(position))))
+}
+
+func dateAdd(date Value, amount float64, unit string) (Value, error) {
+	if date.Date.IsZero() {
+		return Value{}, errors.New("empty date")
+	}
+	duration, ok := dateUnits[unit]
+	if !ok {
+		return Value{}, fmt.Errorf("unknown unit %q", unit)
+	}
+	switch unit {
+	case "years":
+		return Date(addMonths(date.Date, int(amount)*12)), nil
+	case "months":
+		return Date(addMonths(date.Date, int(amount))), nil
+	}
+	return Date(date.Date.Add(time.Duration(amount * float64(duration)))), nil
+}
+
+// addMonths adds months to a date, the day is the last day of the month when it doesn't exist
+func addMonths(date time.Time, months int) time.Time {
+	first := time.Date(date.Year(), date.Month(), 1, date.Hour(), date.Minute(), date.Second(), date.Nanosecond(), date.Location()).AddDate(0, months, 0)
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(date.Day(), last)-1)
+}
+
+// dateBetween returns the number of whole units from the second date to the first one
+func dateBetween(end Value, start Value, unit string) (Value, error) {
+	if end.Date.IsZero() || start.Date.IsZero() {
+		return Value{}, errors.New("empty date")
+	}
+	duration, ok := dateUnits[unit]
+	if !ok {
+		return Value{}, fmt.Errorf("unknown unit %q", unit)
+	}
+	if unit != "years" && unit != "months" {
+		return Number(math.Trunc(float64(end.Date.Sub(start.Date)) / float64(duration))), nil
+	}
+
+	months := (end.Date.Year()-start.Date.Year())*12 + int(end.Date.Month()-start.Date.Month())
+	// an incomplete month is not counted
+	if months > 0 && start.Date.AddDate(0, months, 0).After(end.Date) {
+		months--
+	} else if months < 0 && start.Date.AddDate(0, months, 0).Before(end.Date) {
+		months++
+	}
+	if unit == "years" {
+		return Number(float64(months / 12)), nil
+	}
+	return Number(float64(months)), nil
+}
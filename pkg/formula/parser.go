@@ -0,0 +1,311 @@
+package formula
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// tokenKind is the kind of a lexical token
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOperator
+)
+
+type token struct {
+	kind     tokenKind
+	text     string
+	position int
+	end      int
+}
+
+// operators are sorted by length to match the longest one first
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "+", "-", "*", "/", "%", "<", ">", "!", "(", ")", ","}
+
+// tokenize splits the source in tokens
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(source) {
+		c := rune(source[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			start := i
+			for i < len(source) && (source[i] >= '0' && source[i] <= '9' || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: source[start:i], position: start, end: i})
+		case c == '"':
+			start := i
+			i++
+			for i < len(source) && source[i] != '"' {
+				if source[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(source) {
+				return nil, errorf(start, "unterminated string")
+			}
+			i++
+			text, err := strconv.Unquote(source[start:i])
+			if err != nil {
+				return nil, errorf(start, "invalid string")
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text, position: start, end: i})
+		case isIdent(source[i]):
+			start := i
+			for i < len(source) && (isIdent(source[i]) || source[i] >= '0' && source[i] <= '9') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: source[start:i], position: start, end: i})
+		default:
+			matched := false
+			for _, operator := range operators {
+				if strings.HasPrefix(source[i:], operator) {
+					tokens = append(tokens, token{kind: tokenOperator, text: operator, position: i, end: i + len(operator)})
+					i += len(operator)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, errorf(i, "unexpected character %q", c)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, position: len(source), end: len(source)}), nil
+}
+
+func isIdent(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// nodeKind is the kind of a node of the syntax tree
+type nodeKind int
+
+const (
+	nodeLiteral nodeKind = iota
+	nodeProperty
+	nodeUnary
+	nodeBinary
+	nodeCall
+)
+
+// node is a node of the syntax tree, its type is set when the tree is checked
+type node struct {
+	kind     nodeKind
+	position int
+	op       string
+	name     string
+	value    Value
+	args     []*node
+	typ      Type
+}
+
+type parser struct {
+	tokens     []token
+	current    int
+	depth      int
+	references []Reference
+}
+
+// parse builds the syntax tree of the source and lists the referenced properties
+func parse(source string) (*node, []Reference, error) {
+	if strings.TrimSpace(source) == "" {
+		return nil, nil, errorf(0, "empty expression")
+	}
+	if len(source) > maxLength {
+		return nil, nil, errorf(maxLength, "expression longer than %d characters", maxLength)
+	}
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.expression()
+	if err != nil {
+		return nil, nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, nil, errorf(t.position, "unexpected %q", t.text)
+	}
+	return root, p.references, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.current]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.current]
+	if t.kind != tokenEOF {
+		p.current++
+	}
+	return t
+}
+
+// accept consumes the next token if it's one of the operators or keywords
+func (p *parser) accept(texts ...string) (token, bool) {
+	t := p.peek()
+	if t.kind != tokenOperator && t.kind != tokenIdent {
+		return t, false
+	}
+	for _, text := range texts {
+		if t.text == text {
+			return p.next(), true
+		}
+	}
+	return t, false
+}
+
+func (p *parser) expect(text string) error {
+	if t, ok := p.accept(text); !ok {
+		return errorf(t.position, "%q expected", text)
+	}
+	return nil
+}
+
+func (p *parser) expression() (*node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return nil, errorf(p.peek().position, "expression nested more than %d times", maxDepth)
+	}
+	return p.binary(0)
+}
+
+// precedences lists the binary operators from the lowest precedence
+var precedences = [][]string{
+	{"||", "or"},
+	{"&&", "and"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) binary(level int) (*node, error) {
+	if level == len(precedences) {
+		return p.unary()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, ok := p.accept(precedences[level]...)
+		if !ok {
+			return left, nil
+		}
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		op := t.text
+		switch op {
+		case "or":
+			op = "||"
+		case "and":
+			op = "&&"
+		}
+		left = &node{kind: nodeBinary, position: t.position, op: op, args: []*node{left, right}}
+	}
+}
+
+func (p *parser) unary() (*node, error) {
+	if t, ok := p.accept("-", "!", "not"); ok {
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > maxDepth {
+			return nil, errorf(t.position, "expression nested more than %d times", maxDepth)
+		}
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		op := t.text
+		if op == "not" {
+			op = "!"
+		}
+		return &node{kind: nodeUnary, position: t.position, op: op, args: []*node{operand}}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (*node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		number, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, errorf(t.position, "invalid number %q", t.text)
+		}
+		return &node{kind: nodeLiteral, position: t.position, value: Number(number)}, nil
+	case tokenString:
+		return &node{kind: nodeLiteral, position: t.position, value: Text(t.text)}, nil
+	case tokenOperator:
+		if t.text != "(" {
+			break
+		}
+		inner, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	case tokenIdent:
+		switch t.text {
+		case "true", "false":
+			return &node{kind: nodeLiteral, position: t.position, value: Boolean(t.text == "true")}, nil
+		case "prop":
+			return p.property(t)
+		}
+		return p.call(t)
+	case tokenEOF:
+		return nil, errorf(t.position, "unexpected end of expression")
+	}
+	return nil, errorf(t.position, "unexpected %q", t.text)
+}
+
+// property parses a reference to a property, its name must be a string literal
+func (p *parser) property(t token) (*node, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	name := p.next()
+	if name.kind != tokenString {
+		return nil, errorf(name.position, "prop expects the name of a property")
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	p.references = append(p.references, Reference{Name: name.text, Start: name.position, End: name.end})
+	return &node{kind: nodeProperty, position: t.position, name: name.text}, nil
+}
+
+func (p *parser) call(t token) (*node, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	n := &node{kind: nodeCall, position: t.position, name: t.text}
+	if _, ok := p.accept(")"); ok {
+		return n, nil
+	}
+	for {
+		arg, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		n.args = append(n.args, arg)
+		if _, ok := p.accept(","); ok {
+			continue
+		}
+		return n, p.expect(")")
+	}
+}
@@ -1,6 +1,7 @@
 package httpserver
 
 import (
+	"bytes"
 	"os"
 	"strconv"
 
@@ -8,7 +9,10 @@ import (
 	"github.com/labbs/zotion/internal/logger/zerolog"
 	apiRouter "github.com/labbs/zotion/pkg/api/router"
 	appRouter "github.com/labbs/zotion/pkg/app/router"
+	"github.com/labbs/zotion/pkg/mailer"
+	"github.com/labbs/zotion/pkg/storage"
 	z "github.com/rs/zerolog"
+	"github.com/valyala/fasthttp"
 	"gorm.io/gorm"
 
 	"github.com/gofiber/fiber/v2"
@@ -25,6 +29,13 @@ type Config struct {
 	Logger   z.Logger
 	Stop     chan os.Signal
 	Db       *gorm.DB
+	Storage  storage.Storage
+	Mailer   mailer.Mailer
+	Api      apiRouter.Config
+
+	// UploadBodyLimit is the maximum size of the body of the attachment uploads,
+	// the other routes keep the fiber default limit
+	UploadBodyLimit int
 }
 
 func (s *Config) Configure() {
@@ -36,6 +47,16 @@ func (s *Config) Configure() {
 
 	r := fiber.New(fconfig)
 
+	// the limit of the body is selected from the headers, before the body is read
+	if s.UploadBodyLimit > 0 {
+		r.Server().HeaderReceived = func(header *fasthttp.RequestHeader) fasthttp.RequestConfig {
+			if header.IsPost() && bytes.HasPrefix(header.RequestURI(), []byte(apiRouter.AttachmentUploadPath)) {
+				return fasthttp.RequestConfig{MaxRequestBodySize: s.UploadBodyLimit}
+			}
+			return fasthttp.RequestConfig{}
+		}
+	}
+
 	if s.HttpLogs {
 		r.Use(zerolog.HTTPLogger(s.Logger))
 	}
@@ -43,7 +64,9 @@ func (s *Config) Configure() {
 	// gofiber recover => https://docs.gofiber.io/api/middleware/recover
 	r.Use(recover.New())
 
-	r.Use(cors.New())
+	r.Use(cors.New(cors.Config{
+		ExposeHeaders: fiber.HeaderETag,
+	}))
 	r.Use(compress.New())
 	r.Use(requestid.New())
 
@@ -57,10 +80,12 @@ func (s *Config) Configure() {
 func (s *Config) NewServer() error {
 	s.Configure()
 
-	apirc := apiRouter.Config{
-		Fiber:  s.Fiber,
-		Logger: s.Logger,
-		Db:     s.Db,
+	s.Api = apiRouter.Config{
+		Fiber:   s.Fiber,
+		Logger:  s.Logger,
+		Db:      s.Db,
+		Storage: s.Storage,
+		Mailer:  s.Mailer,
 	}
 
 	apprc := appRouter.Config{
@@ -68,7 +93,7 @@ func (s *Config) NewServer() error {
 		Logger: s.Logger,
 	}
 
-	apirc.Setup()
+	s.Api.Setup()
 	apprc.Setup()
 
 	go func() {
@@ -90,5 +115,8 @@ func (s *Config) NewServer() error {
 
 func (s *Config) Shutdown() error {
 	s.Logger.Info().Msg("Shutting down server")
-	return s.Fiber.Shutdown()
+	if err := s.Fiber.Shutdown(); err != nil {
+		return err
+	}
+	return s.Api.Shutdown()
 }
@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/rs/zerolog"
+)
+
+// Mailer sends the emails of the application, the body is plain text
+type Mailer interface {
+	Send(to string, subject string, body string) error
+	// Enabled returns false when the emails are discarded
+	Enabled() bool
+}
+
+// New returns the configured mailer, the emails are discarded when no smtp host is set
+func New(logger zerolog.Logger) Mailer {
+	if config.Mail.Host == "" {
+		logger.Info().Msg("No smtp host configured, the emails are disabled")
+		return noopMailer{}
+	}
+	logger.Info().Msgf("Sending the emails with the smtp server %s:%d", config.Mail.Host, config.Mail.Port)
+	return NewSMTPMailer(config.Mail.Host, config.Mail.Port, config.Mail.Username, config.Mail.Password, config.Mail.From)
+}
+
+// noopMailer discards the emails
+type noopMailer struct{}
+
+func (noopMailer) Send(to string, subject string, body string) error {
+	return nil
+}
+
+func (noopMailer) Enabled() bool {
+	return false
+}
@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPMailer sends the emails through a smtp server, the authentication is only used with a username
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer returns a mailer sending the emails through the smtp server
+func NewSMTPMailer(host string, port int, username string, password string, from string) *SMTPMailer {
+	m := &SMTPMailer{
+		addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		from: from,
+	}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+func (m *SMTPMailer) Send(to string, subject string, body string) error {
+	from, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+	recipient, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	var message strings.Builder
+	message.WriteString("From: " + from.String() + "\r\n")
+	message.WriteString("To: " + recipient.String() + "\r\n")
+	message.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	message.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	message.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return smtp.SendMail(m.addr, m.auth, from.Address, []string{recipient.Address}, []byte(message.String()))
+}
+
+func (m *SMTPMailer) Enabled() bool {
+	return true
+}
@@ -0,0 +1,121 @@
+package models
+
+import "errors"
+
+// ErrAccessDenied is returned when the user is not a member of the resource
+var ErrAccessDenied = errors.New("access denied")
+
+// AccessService resolves the access of a user on spaces and documents
+type AccessService interface {
+	GetSpaceAccess(userId string, spaceId string) (Space, AccessType, error)
+	GetDocumentAccess(userId string, documentId string) (Document, AccessType, error)
+	GetDocumentAccessUnscoped(userId string, documentId string) (Document, AccessType, error)
+	GetDocumentAccessChain(documentId string) (AccessChain, error)
+	ExplainDocumentAccess(userId string, documentId string) (AccessExplanation, error)
+}
+
+// AccessChain is the chain of the resources a document inherits its access from: the document, its ancestors
+// up to the first one breaking the inheritance, and its space when no document of the chain breaks it.
+type AccessChain struct {
+	// Documents are the document and its ancestors, the nearest first
+	Documents []Document
+	Space     Space
+
+	// SpaceInherited is false when a document of the chain breaks the inheritance or the chain is incomplete
+	SpaceInherited bool
+}
+
+// GetAccess returns the highest access granted to the user along the chain, directly or through one of his groups.
+// The boolean is false when the user isn't a member of any resource of the chain.
+// The guests don't inherit the access of the space members.
+func (c AccessChain) GetAccess(userId string, groups []Group) (AccessType, bool) {
+	var access AccessType
+	found := false
+	for _, step := range c.Explain(userId, groups) {
+		if step.Ignored {
+			continue
+		}
+		for _, grant := range step.Grants {
+			if !found || grant.Access.Includes(access) {
+				access = grant.Access
+				found = true
+			}
+		}
+	}
+	return access, found
+}
+
+// Explain returns the steps of the chain with the members granting an access to the user,
+// from the document up to the space
+func (c AccessChain) Explain(userId string, groups []Group) []AccessStep {
+	steps := make([]AccessStep, 0, len(c.Documents)+1)
+	for _, document := range c.Documents {
+		steps = append(steps, AccessStep{
+			Type:              "document",
+			Id:                document.Id,
+			Name:              document.Name,
+			InheritanceBroken: document.InheritanceBroken,
+			Grants:            document.Members.Grants(userId, groups),
+		})
+	}
+	if c.SpaceInherited {
+		steps = append(steps, AccessStep{
+			Type:    "space",
+			Id:      c.Space.Id,
+			Name:    c.Space.Name,
+			Ignored: IsGuest(groups),
+			Grants:  c.Space.Members.Grants(userId, groups),
+		})
+	}
+	return steps
+}
+
+// AccessExplanation explains the access of a user on a document step by step along its access chain
+type AccessExplanation struct {
+	UserId     string `json:"user_id"`
+	DocumentId string `json:"document_id"`
+
+	// Access is the effective access of the user, it's empty when the user can't access the document
+	Access AccessType `json:"access"`
+	Guest  bool       `json:"guest"`
+
+	// Locked is true when the access is reduced to comment by the lock of the document
+	Locked bool `json:"locked"`
+
+	Steps []AccessStep `json:"steps"`
+}
+
+// AccessStep is a resource of the access chain with the members granting an access to the user
+type AccessStep struct {
+	// Type is document or space
+	Type string `json:"type"`
+	Id   string `json:"id"`
+	Name string `json:"name"`
+
+	// InheritanceBroken is set on the document breaking the inheritance, the chain stops there
+	InheritanceBroken bool `json:"inheritance_broken,omitempty"`
+
+	// Ignored is set on the space of the guests, the guests don't inherit the access of the space members
+	Ignored bool `json:"ignored,omitempty"`
+
+	Grants []AccessGrant `json:"grants"`
+}
+
+// AccessGrant is an access granted by a member to the user, directly or through one of his groups
+type AccessGrant struct {
+	Access AccessType `json:"access"`
+	Type   MemberType `json:"type"`
+
+	// GroupId and GroupName are set when the access is granted through a group
+	GroupId   string `json:"group_id,omitempty"`
+	GroupName string `json:"group_name,omitempty"`
+}
+
+// InheritanceRequest breaks or restores the inheritance of the access of a document
+type InheritanceRequest struct {
+	InheritanceBroken bool `json:"inheritance_broken"`
+
+	// CopyMembers copies the inherited members to the document when the inheritance is broken,
+	// so the members keep their access
+	CopyMembers bool `json:"copy_members"`
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+	"gorm.io/gorm"
+)
+
+// ActivityType is the type of an activity on a document
+type ActivityType string
+
+// ActivityType constants
+const (
+	ActivityTypeCreated   ActivityType = "created"
+	ActivityTypeEdited    ActivityType = "edited"
+	ActivityTypeMoved     ActivityType = "moved"
+	ActivityTypeDeleted   ActivityType = "deleted"
+	ActivityTypeRestored  ActivityType = "restored"
+	ActivityTypeShared    ActivityType = "shared"
+	ActivityTypeCommented ActivityType = "commented"
+)
+
+// Activity is an entry of the activity log of a space, the log is append only.
+// DocumentName is the name of the document when the activity happened.
+type Activity struct {
+	Id           string       `json:"id"`
+	Type         ActivityType `json:"type"`
+	UserId       string       `json:"user_id"`
+	SpaceId      string       `json:"space_id"`
+	DocumentId   string       `json:"document_id"`
+	DocumentName string       `json:"document_name"`
+	Data         JSONB        `json:"data,omitempty"`
+
+	// UserName and UserAvatarUrl are loaded with the activities of a feed
+	UserName      string `json:"user_name,omitempty" gorm:"->"`
+	UserAvatarUrl string `json:"user_avatar_url,omitempty" gorm:"->"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName returns the name of the table
+func (a Activity) TableName() string {
+	return "activity"
+}
+
+// BeforeCreate is a hook that runs before creating an activity
+func (a *Activity) BeforeCreate(tx *gorm.DB) error {
+	a.Id = utils.UUIDv4()
+	return nil
+}
+
+// NewDocumentActivity returns an activity of the user on the document
+func NewDocumentActivity(activityType ActivityType, userId string, document Document) Activity {
+	return Activity{
+		Type:         activityType,
+		UserId:       userId,
+		SpaceId:      document.SpaceId,
+		DocumentId:   document.Id,
+		DocumentName: document.Name,
+	}
+}
+
+// ActivityPage is a page of activities, the most recent first
+type ActivityPage struct {
+	Results []Activity `json:"results"`
+	Total   int64      `json:"total"`
+	HasMore bool       `json:"has_more"`
+}
+
+// RecentDocument records the last time a user viewed and edited a document
+type RecentDocument struct {
+	UserId     string     `json:"-" gorm:"primaryKey"`
+	DocumentId string     `json:"document_id" gorm:"primaryKey"`
+	ViewedAt   *time.Time `json:"viewed_at"`
+	EditedAt   *time.Time `json:"edited_at"`
+
+	Document Document `json:"document" gorm:"foreignKey:DocumentId;references:Id"`
+}
+
+// TableName returns the name of the table
+func (rd RecentDocument) TableName() string {
+	return "recent_document"
+}
+
+// RecentKind selects the recent documents viewed or edited by a user
+type RecentKind string
+
+// RecentKind constants
+const (
+	RecentKindViewed RecentKind = "viewed"
+	RecentKindEdited RecentKind = "edited"
+)
+
+// ActivityRepository is the repository for activities and recent documents
+type ActivityRepository interface {
+	CreateActivity(activity Activity) (Activity, error)
+	GetLastDocumentActivity(documentId string) (Activity, error)
+	GetSpaceActivities(spaceId string, excludedDocumentIds []string, limit int, offset int) ([]Activity, int64, error)
+	TouchRecentDocument(userId string, documentId string, kind RecentKind, at time.Time) error
+	GetRecentDocuments(userId string, kind RecentKind, limit int) ([]RecentDocument, error)
+}
+
+// ActivityService is the service for activities and recent documents
+type ActivityService interface {
+	Record(activity Activity) error
+	RecordView(userId string, documentId string) error
+	GetSpaceActivities(userId string, spaceId string, limit int, offset int) (ActivityPage, error)
+	GetRecentDocuments(userId string, kind RecentKind, limit int) ([]RecentDocument, error)
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+	"gorm.io/gorm"
+)
+
+// Attachment is a file uploaded in a document, the file itself is in the storage
+type Attachment struct {
+	Id          string `json:"id"`
+	DocumentId  string `json:"document_id"`
+	SpaceId     string `json:"space_id"`
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Checksum    string `json:"checksum"`
+	// Width and Height are the dimensions of the images, zero for the other files
+	Width      int    `json:"width,omitempty"`
+	Height     int    `json:"height,omitempty"`
+	StorageKey string `json:"-"`
+	CreatedBy  string `json:"created_by"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName returns the name of the table
+func (a Attachment) TableName() string {
+	return "attachment"
+}
+
+// BeforeCreate is a hook that runs before creating an attachment, the id is kept when already set
+// because the storage key is built from it
+func (a *Attachment) BeforeCreate(tx *gorm.DB) error {
+	if a.Id == "" {
+		a.Id = utils.UUIDv4()
+	}
+	return nil
+}
+
+// ErrAttachmentTooLarge is returned when the file exceeds the maximum size of the attachments
+var ErrAttachmentTooLarge = errors.New("attachment too large")
+
+// ErrAttachmentTypeNotAllowed is returned when the content type of the file is not allowed
+var ErrAttachmentTypeNotAllowed = errors.New("attachment type not allowed")
+
+// AttachmentRepository is the repository for attachments
+type AttachmentRepository interface {
+	CreateAttachment(attachment Attachment) (Attachment, error)
+	GetAttachmentById(id string) (Attachment, error)
+	GetAttachmentsByDocumentId(documentId string) ([]Attachment, error)
+	DeleteAttachment(id string) error
+}
+
+// AttachmentService is the service for attachments
+type AttachmentService interface {
+	CreateAttachment(attachment Attachment, reader io.Reader) (Attachment, error)
+	GetAttachmentById(id string) (Attachment, error)
+	GetAttachmentsByDocumentId(documentId string) ([]Attachment, error)
+	OpenAttachment(attachment Attachment) (io.ReadCloser, error)
+	OpenAttachmentVariant(attachment Attachment, width int) (io.ReadCloser, string, error)
+	DeleteAttachment(attachment Attachment) error
+	DeleteDocumentAttachments(documentId string) error
+}
@@ -19,7 +19,9 @@ type RegisterRequest struct {
 	Password string `json:"password"`
 }
 
-type RegisterResponse struct{}
+type RegisterResponse struct {
+	UserId string `json:"user_id"`
+}
 
 type JwtCustomClaims struct {
 	SessionId string `json:"session_id"`
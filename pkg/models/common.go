@@ -29,20 +29,78 @@ type AccessType string
 type Members []Member
 type MembersWithUsersOrGroups []MemberWithUsersOrGroups
 
-func (m Members) Value() (driver.Value, error) {
-	valueString, err := json.Marshal(m)
-	return string(valueString), err
-}
-
-func (m *Members) Scan(value any) error {
-	return json.Unmarshal([]byte(value.(string)), m)
-}
-
-// Member is a model for a member
+// Member is a model for a member, the members of the spaces and the documents are stored
+// in the space_member and document_member tables
 type Member struct {
 	Id     string     `json:"id"`
 	Type   MemberType `json:"type"`
 	Access AccessType `json:"access"`
+
+	// Guest marks the guest users and the groups with the guest role in the member lists
+	Guest bool `json:"guest,omitempty"`
+}
+
+// GetAccess returns the highest access granted to the user, directly or through one of his groups.
+// The boolean is false when the user isn't a member.
+func (m Members) GetAccess(userId string, groups []Group) (AccessType, bool) {
+	var access AccessType
+	found := false
+	for _, member := range m {
+		match := member.Type == MemberTypeUser && member.Id == userId
+		if member.Type == MemberTypeGroup {
+			for _, group := range groups {
+				if group.Id == member.Id {
+					match = true
+					break
+				}
+			}
+		}
+		if match && (!found || member.Access.Includes(access)) {
+			access = member.Access
+			found = true
+		}
+	}
+	return access, found
+}
+
+// Grants returns the accesses granted to the user by the members, directly or through one of his groups
+func (m Members) Grants(userId string, groups []Group) []AccessGrant {
+	grants := []AccessGrant{}
+	for _, member := range m {
+		switch member.Type {
+		case MemberTypeUser:
+			if member.Id == userId {
+				grants = append(grants, AccessGrant{Access: member.Access, Type: member.Type})
+			}
+		case MemberTypeGroup:
+			for _, group := range groups {
+				if group.Id == member.Id {
+					grants = append(grants, AccessGrant{Access: member.Access, Type: member.Type, GroupId: group.Id, GroupName: group.Name})
+					break
+				}
+			}
+		}
+	}
+	return grants
+}
+
+// Merge returns the members with the other members added, a member of both keeps the highest access
+func (m Members) Merge(other Members) Members {
+	merged := make(Members, 0, len(m)+len(other))
+	index := map[string]int{}
+	for _, member := range append(append(Members{}, m...), other...) {
+		member.Guest = false
+		key := string(member.Type) + ":" + member.Id
+		if i, ok := index[key]; ok {
+			if member.Access.Includes(merged[i].Access) {
+				merged[i].Access = member.Access
+			}
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, member)
+	}
+	return merged
 }
 
 // MemberWithUser is a model for a member with user information
@@ -60,6 +118,30 @@ const (
 	AccessTypeFull    AccessType = "full"
 )
 
+// accessLevels orders the access types from the lowest to the highest
+var accessLevels = map[AccessType]int{
+	AccessTypeViewer:  1,
+	AccessTypeComment: 2,
+	AccessTypeEditor:  3,
+	AccessTypeFull:    4,
+}
+
+// Includes returns true if the access grants at least the other access
+func (a AccessType) Includes(other AccessType) bool {
+	return accessLevels[a] >= accessLevels[other]
+}
+
+// Valid returns true if the access is one of the access types
+func (a AccessType) Valid() bool {
+	_, ok := accessLevels[a]
+	return ok
+}
+
+// CanEdit returns true if the access allows to modify the content
+func (a AccessType) CanEdit() bool {
+	return a.Includes(AccessTypeEditor)
+}
+
 // MemberType is the type of member
 const (
 	MemberTypeUser  MemberType = "user"
@@ -0,0 +1,233 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2/utils"
+	"gorm.io/gorm"
+)
+
+// ErrNotDatabase is returned when a database operation targets a document which is not a database
+var ErrNotDatabase = errors.New("document is not a database")
+
+// Built-in columns of the rows usable in the filters and the sorts like the properties
+const (
+	RowColumnName      = "name"
+	RowColumnCreatedAt = "created_at"
+	RowColumnUpdatedAt = "updated_at"
+)
+
+// FilterOperator is the comparison of a database filter
+type FilterOperator string
+
+// FilterOperator constants
+const (
+	FilterOperatorEquals         FilterOperator = "equals"
+	FilterOperatorNotEquals      FilterOperator = "not_equals"
+	FilterOperatorContains       FilterOperator = "contains"
+	FilterOperatorNotContains    FilterOperator = "not_contains"
+	FilterOperatorStartsWith     FilterOperator = "starts_with"
+	FilterOperatorEndsWith       FilterOperator = "ends_with"
+	FilterOperatorGreaterThan    FilterOperator = "greater_than"
+	FilterOperatorGreaterOrEqual FilterOperator = "greater_than_or_equal"
+	FilterOperatorLessThan       FilterOperator = "less_than"
+	FilterOperatorLessOrEqual    FilterOperator = "less_than_or_equal"
+	FilterOperatorIsEmpty        FilterOperator = "is_empty"
+	FilterOperatorIsNotEmpty     FilterOperator = "is_not_empty"
+)
+
+// DatabaseFilter filters the rows of a database, it's either a compound filter with And or Or
+// or a condition on a property (id or name) or a built-in column.
+type DatabaseFilter struct {
+	And []DatabaseFilter `json:"and,omitempty"`
+	Or  []DatabaseFilter `json:"or,omitempty"`
+
+	Property string         `json:"property,omitempty"`
+	Operator FilterOperator `json:"operator,omitempty"`
+	Value    string         `json:"value,omitempty"`
+}
+
+// SortDirection is the direction of a database sort
+type SortDirection string
+
+// SortDirection constants
+const (
+	SortDirectionAscending  SortDirection = "asc"
+	SortDirectionDescending SortDirection = "desc"
+)
+
+// DatabaseSort sorts the rows of a database on a property (id or name) or a built-in column,
+// the empty values are always last
+type DatabaseSort struct {
+	Property  string        `json:"property"`
+	Direction SortDirection `json:"direction"`
+}
+
+// DatabaseQuery queries the rows of a database.
+// With a view, the filter is combined with the one of the view and the sorts and the grouping
+// of the view are used when they are not set.
+type DatabaseQuery struct {
+	ViewId  string          `json:"view_id,omitempty"`
+	Filter  *DatabaseFilter `json:"filter,omitempty"`
+	Sorts   []DatabaseSort  `json:"sorts,omitempty"`
+	GroupBy string          `json:"group_by,omitempty"`
+	Offset  int             `json:"offset"`
+	Limit   int             `json:"limit"`
+
+	// ExcludedIds are the rows the user can't access, they are set by the service
+	ExcludedIds []string `json:"-"`
+}
+
+// DatabaseGroup is the number of rows having a value of the grouping property,
+// the rows without value are in the group with an empty value
+type DatabaseGroup struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// DatabaseQueryResult is a page of the rows of a database, the content of the rows is not loaded
+type DatabaseQueryResult struct {
+	Results []Document      `json:"results"`
+	Total   int64           `json:"total"`
+	Offset  int             `json:"offset"`
+	Limit   int             `json:"limit"`
+	HasMore bool            `json:"has_more"`
+	Groups  []DatabaseGroup `json:"groups,omitempty"`
+}
+
+// DatabaseViewType is the layout of a database view
+type DatabaseViewType string
+
+// DatabaseViewType constants
+const (
+	DatabaseViewTypeTable    DatabaseViewType = "table"
+	DatabaseViewTypeBoard    DatabaseViewType = "board"
+	DatabaseViewTypeCalendar DatabaseViewType = "calendar"
+	DatabaseViewTypeList     DatabaseViewType = "list"
+)
+
+// DatabaseView is a saved view of a database
+type DatabaseView struct {
+	Id         string             `json:"id"`
+	DatabaseId string             `json:"database_id"`
+	Name       string             `json:"name"`
+	Type       DatabaseViewType   `json:"type"`
+	Config     DatabaseViewConfig `json:"config"`
+	Position   int                `json:"position"`
+	CreatedBy  string             `json:"created_by"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName returns the name of the table
+func (dv DatabaseView) TableName() string {
+	return "database_view"
+}
+
+// BeforeCreate is a hook that runs before creating a database view
+func (dv *DatabaseView) BeforeCreate(tx *gorm.DB) error {
+	dv.Id = utils.UUIDv4()
+	return nil
+}
+
+// DatabaseViewConfig is the configuration of a database view.
+// GroupBy is required by the boards and DateProperty by the calendars.
+type DatabaseViewConfig struct {
+	Filter            *DatabaseFilter `json:"filter,omitempty"`
+	Sorts             []DatabaseSort  `json:"sorts,omitempty"`
+	GroupBy           string          `json:"group_by,omitempty"`
+	DateProperty      string          `json:"date_property,omitempty"`
+	VisibleProperties []string        `json:"visible_properties,omitempty"`
+}
+
+// Value implements the driver.Valuer interface
+func (dvc DatabaseViewConfig) Value() (driver.Value, error) {
+	return json.Marshal(dvc)
+}
+
+// Scan implements the sql.Scanner interface
+func (dvc *DatabaseViewConfig) Scan(value any) error {
+	switch v := value.(type) {
+	case []byte:
+		// PostgreSQL usually returns []byte
+		return json.Unmarshal(v, dvc)
+	case string:
+		// SQLite often returns string
+		return json.Unmarshal([]byte(v), dvc)
+	case nil:
+		// Handle null case
+		*dvc = DatabaseViewConfig{}
+		return nil
+	default:
+		// Fall back to string conversion
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, dvc)
+	}
+}
+
+// IsValid returns true if the view type is known
+func (dvt DatabaseViewType) IsValid() bool {
+	switch dvt {
+	case DatabaseViewTypeTable, DatabaseViewTypeBoard, DatabaseViewTypeCalendar, DatabaseViewTypeList:
+		return true
+	}
+	return false
+}
+
+// CSVImportOptions are the options of a csv import into a database.
+// Mapping maps the columns to the properties (id or name), to the name of the rows with "name"
+// or skips them with an empty value. The columns which are not mapped are matched by name,
+// otherwise a property is created with the type inferred from the values.
+// UserId is the author of the imported rows.
+type CSVImportOptions struct {
+	Mapping   map[string]string `json:"mapping,omitempty"`
+	Delimiter rune              `json:"-"`
+	UserId    string            `json:"-"`
+}
+
+// CSVImport is the report of a csv import, the rows which fail the validation are not imported
+type CSVImport struct {
+	Imported          int              `json:"imported"`
+	Failed            int              `json:"failed"`
+	CreatedProperties []string         `json:"created_properties"`
+	Errors            []CSVImportError `json:"errors"`
+}
+
+// CSVImportError are the validation errors of a row of a csv import, Line is the line in the file
+type CSVImportError struct {
+	Line   int             `json:"line"`
+	Errors []PropertyError `json:"errors"`
+}
+
+// DatabaseViewRepository is the repository for database views
+type DatabaseViewRepository interface {
+	CreateView(view DatabaseView) (DatabaseView, error)
+	GetViewById(id string) (DatabaseView, error)
+	GetViewsByDatabaseId(databaseId string) ([]DatabaseView, error)
+	UpdateView(view DatabaseView) (DatabaseView, error)
+	DeleteView(id string) error
+}
+
+// DatabaseService is the service for database documents
+type DatabaseService interface {
+	GetDatabase(databaseId string) (Document, error)
+	UpdateSchema(databaseId string, schema PropertySchema) (Document, error)
+	ValidateRow(row Document) (Properties, error)
+	SyncRow(row Document, previous Properties) error
+	QueryDatabase(userId string, databaseId string, query DatabaseQuery) (DatabaseQueryResult, error)
+	GetViews(databaseId string) ([]DatabaseView, error)
+	GetView(databaseId string, viewId string) (DatabaseView, error)
+	CreateView(view DatabaseView) (DatabaseView, error)
+	UpdateView(view DatabaseView) (DatabaseView, error)
+	DeleteView(databaseId string, viewId string) error
+	ImportCSV(databaseId string, reader io.Reader, options CSVImportOptions) (CSVImport, error)
+	ExportCSV(userId string, databaseId string, viewId string, writer io.Writer) error
+}
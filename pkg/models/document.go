@@ -2,6 +2,8 @@ package models
 
 import (
 	"database/sql/driver"
+	"errors"
+	"strconv"
 	"time"
 
 	"github.com/goccy/go-json"
@@ -26,7 +28,14 @@ type Document struct {
 	ParentId   string         `json:"parent_id"`
 	Properties Properties     `json:"properties"`
 
-	Members Members `json:"members"`
+	// Schema is the property schema shared by the rows of a database document
+	Schema PropertySchema `json:"schema,omitempty"`
+
+	Members Members `json:"members" gorm:"-"`
+
+	// InheritanceBroken stops the inheritance of the members of the parent documents and the space,
+	// only the members of the document and of its descendants can access them
+	InheritanceBroken bool `json:"inheritance_broken"`
 
 	Public bool `json:"public"`
 
@@ -34,6 +43,13 @@ type Document struct {
 
 	Content string `json:"content"`
 
+	// Version is incremented on each update, it's used for the optimistic concurrency control
+	Version int `json:"version"`
+
+	// CreatedBy and UpdatedBy are the users who created and last updated the document
+	CreatedBy string `json:"created_by"`
+	UpdatedBy string `json:"updated_by"`
+
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"deleted_at"`
@@ -48,6 +64,23 @@ func (d Document) TableName() string {
 func (d *Document) BeforeCreate(tx *gorm.DB) error {
 	d.Id = utils.UUIDv4()
 	d.Slug = slug.Make(d.Name + "-" + shortuuid.GenerateShortUUID())
+	d.Version = 1
+	return nil
+}
+
+// ETag returns the entity tag of the current version of the document
+func (d Document) ETag() string {
+	return `"` + strconv.Itoa(d.Version) + `"`
+}
+
+// ErrVersionConflict is returned when the document was updated since the version sent by the client
+var ErrVersionConflict = errors.New("document version conflict")
+
+// AfterFind is a hook that runs after loading a document, the expired check-out locks are released
+func (d *Document) AfterFind(tx *gorm.DB) error {
+	if d.Config.Lock && !d.Config.IsLocked() {
+		d.Config.ClearLock()
+	}
 	return nil
 }
 
@@ -75,12 +108,24 @@ func (d *Document) AfterDelete(tx *gorm.DB) error {
 	return nil
 }
 
+// DocumentPatch is the list of fields that can be modified with a JSON merge patch
+type DocumentPatch struct {
+	Name       string         `json:"name"`
+	Content    string         `json:"content"`
+	Public     bool           `json:"public"`
+	Config     DocumentConfig `json:"config"`
+	Properties Properties     `json:"properties"`
+	Metadata   JSONB          `json:"metadata"`
+}
+
 // DocumentType is the type of document
 type DocumentType string
 
 // DocumentType constants
 const (
 	DocumentTypeDocument DocumentType = "document"
+	// DocumentTypeDatabase is a database, its child documents are rows sharing its property schema
+	DocumentTypeDatabase DocumentType = "database"
 )
 
 // Properties is a list of properties for a document
@@ -88,10 +133,14 @@ type Properties []Propertie
 
 // Propertie is a property for a document
 type Propertie struct {
+	// Id references the property of the database schema for the rows
+	Id    string `json:"id,omitempty"`
 	Name  string `json:"name"`
 	Type  string `json:"type"`
 	Value string `json:"value"`
 	Order int    `json:"order"`
+	// Error is the evaluation error of a formula of a row
+	Error string `json:"error,omitempty"`
 }
 
 // Properties implements the driver.Valuer interface
@@ -128,6 +177,53 @@ type DocumentConfig struct {
 	Icon             string `json:"icon"`
 	Lock             bool   `json:"lock"`
 	HeaderBackground string `json:"header_background"`
+
+	// PublishSubpages publishes the descendants of a public document, down to the ones breaking the inheritance
+	PublishSubpages bool `json:"publish_subpages"`
+
+	// LockedBy and LockedAt record who locked the document, they are managed by the server.
+	// LockExpiresAt is set for a check-out lock, the lock is released once expired.
+	LockedBy      string     `json:"locked_by,omitempty"`
+	LockedAt      *time.Time `json:"locked_at,omitempty"`
+	LockExpiresAt *time.Time `json:"lock_expires_at,omitempty"`
+}
+
+// IsLocked returns true if the document has an active lock
+func (dc DocumentConfig) IsLocked() bool {
+	return dc.Lock && (dc.LockExpiresAt == nil || dc.LockExpiresAt.After(time.Now()))
+}
+
+// CanBypassLock returns true if the user can edit or unlock the locked document,
+// only the locker and the members with a full access on the space can.
+func (dc DocumentConfig) CanBypassLock(userId string, spaceAccess AccessType) bool {
+	return (dc.LockedBy != "" && dc.LockedBy == userId) || spaceAccess == AccessTypeFull
+}
+
+// SetLock locks the document for the user, a zero duration locks it until it's unlocked
+func (dc *DocumentConfig) SetLock(userId string, duration time.Duration) {
+	now := time.Now()
+	dc.Lock = true
+	dc.LockedBy = userId
+	dc.LockedAt = &now
+	dc.LockExpiresAt = nil
+	if duration > 0 {
+		expiresAt := now.Add(duration)
+		dc.LockExpiresAt = &expiresAt
+	}
+}
+
+// ClearLock removes the lock of the document
+func (dc *DocumentConfig) ClearLock() {
+	dc.Lock = false
+	dc.LockedBy = ""
+	dc.LockedAt = nil
+	dc.LockExpiresAt = nil
+}
+
+// LockRequest is the request to lock a document, Duration is in seconds
+// and a zero duration locks the document until it's unlocked
+type LockRequest struct {
+	Duration int `json:"duration"`
 }
 
 // Value implements the driver.Valuer interface
@@ -165,12 +261,31 @@ type DocumentRepository interface {
 	GetDocumentsFirstLevelByDocumentId(documentId string) ([]Document, error)
 	GetDocumentBySlug(slug string) (Document, error)
 	GetDocumentById(id string) (Document, error)
+	GetDocumentByIdUnscoped(id string) (Document, error)
 	UpdateDocument(document Document) (Document, error)
+	UpdateDocumentContent(id string, content string) error
+	DeleteDocumentState(id string) error
 	DeleteDocument(id string) error
 	GetAllDocuments() ([]Document, error)
 	GetAllDeletedDocument() ([]Document, error)
 	RestoreDocument(id string) error
 	GetDocumentsBySpaceId(spaceId string) ([]Document, error)
+	GetSpaceDocumentsUnscoped(spaceId string) ([]Document, error)
+	GetChildDocumentIds(documentId string) ([]string, error)
+	GetPublicDocuments(spaceId string) ([]Document, error)
+	GetDocumentsForMember(userId string, groups []Group) ([]Document, error)
+	UpdateDocumentMembers(id string, members Members) error
+	UpdateDocumentInheritance(id string, inheritanceBroken bool, members Members) error
+	PurgeDocument(id string) (Document, error)
+	UpdateDocumentSchema(id string, schema PropertySchema) error
+	UpdateDocumentProperties(id string, properties Properties) error
+	QueryDatabaseRows(databaseId string, schema PropertySchema, query DatabaseQuery) ([]Document, int64, error)
+	GroupDatabaseRows(databaseId string, schema PropertySchema, query DatabaseQuery) ([]DatabaseGroup, error)
+	GetDatabaseRows(databaseId string, ids []string) ([]Document, error)
+	GetRestrictedDatabaseRows(databaseId string) ([]Document, error)
+	GetRestrictedDocuments(spaceIds []string) ([]Document, error)
+	GetRelatedDatabases(databaseId string) ([]Document, error)
+	Transaction(fn func(documentRepository DocumentRepository) error) error
 }
 
 // DocumentService is the service for documents
@@ -181,5 +296,11 @@ type DocumentService interface {
 	GetDocumentBySlug(slug string) (Document, error)
 	GetDocumentById(id string) (Document, error)
 	UpdateDocument(document Document) (Document, error)
+	UpdateDocumentContent(id string, content string) error
 	DeleteDocument(id string) error
+	PurgeDocument(id string) ([]string, error)
+	RestoreDocument(id string) error
+	GetDocumentsForMember(userId string, groups []Group) ([]Document, error)
+	UpdateDocumentMembers(id string, members Members) error
+	UpdateDocumentInheritance(id string, inheritanceBroken bool, members Members) error
 }
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// DocumentState stores the collaborative (yjs) state of a document
+type DocumentState struct {
+	DocumentId string `json:"document_id" gorm:"primaryKey"`
+	State      []byte `json:"-"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName returns the name of the table
+func (ds DocumentState) TableName() string {
+	return "document_state"
+}
+
+// DocumentStateRepository is the repository for document states
+type DocumentStateRepository interface {
+	GetState(documentId string) (DocumentState, error)
+	SaveState(state DocumentState) error
+	DeleteState(documentId string) error
+}
+
+// DocumentStateService is the service for document states
+type DocumentStateService interface {
+	GetState(documentId string) ([]byte, error)
+	SaveState(documentId string, state []byte) error
+	DeleteState(documentId string) error
+}
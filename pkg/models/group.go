@@ -40,6 +40,21 @@ const (
 	RoleGuest RoleType = "guest"
 )
 
+// IsGuest returns true when one of the groups has the guest role, the administrators are never guests.
+// The guests only access the documents they are invited to, without their space.
+func IsGuest(groups []Group) bool {
+	guest := false
+	for _, group := range groups {
+		switch group.Role {
+		case RoleAdmin:
+			return false
+		case RoleGuest:
+			guest = true
+		}
+	}
+	return guest
+}
+
 // GroupRepository is the repository for groups
 type GroupRepository interface {
 	Create(group Group) (Group, error)
@@ -0,0 +1,31 @@
+package models
+
+// GuestReport is a guest user with the documents the guest is invited to
+type GuestReport struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Active bool   `json:"active"`
+
+	// Groups are the names of the groups of the guest
+	Groups    []string        `json:"groups"`
+	Documents []GuestDocument `json:"documents"`
+}
+
+// GuestDocument is a document a guest can access with the access granted by its members.
+// InheritedFrom is the document the guest is invited to when the access is inherited from it.
+type GuestDocument struct {
+	Id            string     `json:"id"`
+	Name          string     `json:"name"`
+	SpaceId       string     `json:"space_id"`
+	SpaceName     string     `json:"space_name"`
+	Access        AccessType `json:"access"`
+	InheritedFrom string     `json:"inherited_from,omitempty"`
+}
+
+// GuestService is the service for the guest users
+type GuestService interface {
+	MarkGuests(members Members) (Members, error)
+	MarkGuestUsers(users []User) ([]User, error)
+	GetGuestReport() ([]GuestReport, error)
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// SpaceMember is a member of a space as stored in the space_member table, the members of a space
+// are exposed as Members in the API
+type SpaceMember struct {
+	SpaceId    string     `gorm:"primaryKey"`
+	MemberType MemberType `gorm:"primaryKey"`
+	MemberId   string     `gorm:"primaryKey"`
+	Access     AccessType
+
+	// Position keeps the order of the members
+	Position  int
+	CreatedAt time.Time
+}
+
+// TableName returns the name of the table
+func (m SpaceMember) TableName() string {
+	return "space_member"
+}
+
+// DocumentMember is a member of a document as stored in the document_member table, the members of a document
+// are exposed as Members in the API
+type DocumentMember struct {
+	DocumentId string     `gorm:"primaryKey"`
+	MemberType MemberType `gorm:"primaryKey"`
+	MemberId   string     `gorm:"primaryKey"`
+	Access     AccessType
+
+	// Position keeps the order of the members
+	Position  int
+	CreatedAt time.Time
+}
+
+// TableName returns the name of the table
+func (m DocumentMember) TableName() string {
+	return "document_member"
+}
+
+// NewSpaceMembers returns the rows of the members of the space
+func NewSpaceMembers(spaceId string, members Members) []SpaceMember {
+	rows := make([]SpaceMember, 0, len(members))
+	for i, member := range members {
+		rows = append(rows, SpaceMember{SpaceId: spaceId, MemberType: member.Type, MemberId: member.Id, Access: member.Access, Position: i})
+	}
+	return rows
+}
+
+// NewDocumentMembers returns the rows of the members of the document
+func NewDocumentMembers(documentId string, members Members) []DocumentMember {
+	rows := make([]DocumentMember, 0, len(members))
+	for i, member := range members {
+		rows = append(rows, DocumentMember{DocumentId: documentId, MemberType: member.Type, MemberId: member.Id, Access: member.Access, Position: i})
+	}
+	return rows
+}
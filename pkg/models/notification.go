@@ -0,0 +1,218 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+	"gorm.io/gorm"
+)
+
+// NotificationType is the event which notified a user
+type NotificationType string
+
+// NotificationType constants
+const (
+	NotificationTypeMention    NotificationType = "mention"
+	NotificationTypePageUpdate NotificationType = "page_update"
+	NotificationTypeShare      NotificationType = "share"
+	NotificationTypeInvite     NotificationType = "invite"
+	NotificationTypeReminder   NotificationType = "reminder"
+)
+
+// Notification is an entry of the inbox of a user. The unread updates of a followed page
+// are coalesced in a single notification, Count is the number of updates.
+type Notification struct {
+	Id           string           `json:"id"`
+	UserId       string           `json:"-"`
+	Type         NotificationType `json:"type"`
+	ActorId      string           `json:"actor_id"`
+	SpaceId      string           `json:"space_id"`
+	DocumentId   string           `json:"document_id"`
+	DocumentName string           `json:"document_name"`
+	Data         JSONB            `json:"data,omitempty"`
+	Count        int              `json:"count"`
+	ReadAt       *time.Time       `json:"read_at"`
+	EmailedAt    *time.Time       `json:"-"`
+
+	// ActorName and ActorAvatarUrl are loaded with the notifications of an inbox
+	ActorName      string `json:"actor_name,omitempty" gorm:"->"`
+	ActorAvatarUrl string `json:"actor_avatar_url,omitempty" gorm:"->"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName returns the name of the table
+func (n Notification) TableName() string {
+	return "notification"
+}
+
+// BeforeCreate is a hook that runs before creating a notification
+func (n *Notification) BeforeCreate(tx *gorm.DB) error {
+	n.Id = utils.UUIDv4()
+	return nil
+}
+
+// NotificationPage is a page of the inbox of a user, the most recent first
+type NotificationPage struct {
+	Results []Notification `json:"results"`
+	Total   int64          `json:"total"`
+	Unread  int64          `json:"unread"`
+	HasMore bool           `json:"has_more"`
+}
+
+// NotificationReadRequest is the request to mark notifications as read or unread, all the notifications without ids
+type NotificationReadRequest struct {
+	Ids []string `json:"ids"`
+}
+
+// DigestFrequency is the frequency of the email digests of the unread notifications
+type DigestFrequency string
+
+// DigestFrequency constants
+const (
+	DigestFrequencyOff    DigestFrequency = "off"
+	DigestFrequencyHourly DigestFrequency = "hourly"
+	DigestFrequencyDaily  DigestFrequency = "daily"
+)
+
+// Interval returns the minimum time between two digests, zero when the digests are disabled
+func (f DigestFrequency) Interval() time.Duration {
+	switch f {
+	case DigestFrequencyHourly:
+		return time.Hour
+	case DigestFrequencyDaily:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// NotificationPreferencesKey is the key of the notification preferences in the preferences of a user
+const NotificationPreferencesKey = "notifications"
+
+// NotificationPreferences selects the events notifying a user, they are stored in the preferences of the user
+type NotificationPreferences struct {
+	Mention     bool            `json:"mention"`
+	PageUpdate  bool            `json:"page_update"`
+	Share       bool            `json:"share"`
+	Invite      bool            `json:"invite"`
+	Reminder    bool            `json:"reminder"`
+	EmailDigest DigestFrequency `json:"email_digest"`
+}
+
+// DefaultNotificationPreferences returns the preferences of the users who never changed them
+func DefaultNotificationPreferences() NotificationPreferences {
+	return NotificationPreferences{
+		Mention:     true,
+		PageUpdate:  true,
+		Share:       true,
+		Invite:      true,
+		Reminder:    true,
+		EmailDigest: DigestFrequencyOff,
+	}
+}
+
+// Allows returns true when the events of the type notify the user
+func (p NotificationPreferences) Allows(notificationType NotificationType) bool {
+	switch notificationType {
+	case NotificationTypeMention:
+		return p.Mention
+	case NotificationTypePageUpdate:
+		return p.PageUpdate
+	case NotificationTypeShare:
+		return p.Share
+	case NotificationTypeInvite:
+		return p.Invite
+	case NotificationTypeReminder:
+		return p.Reminder
+	}
+	return false
+}
+
+// Subscription is a page followed by a user, the changes of the page notify the user
+type Subscription struct {
+	UserId     string    `json:"-" gorm:"primaryKey"`
+	DocumentId string    `json:"document_id" gorm:"primaryKey"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	Document Document `json:"document" gorm:"foreignKey:DocumentId;references:Id"`
+}
+
+// TableName returns the name of the table
+func (s Subscription) TableName() string {
+	return "subscription"
+}
+
+// Reminder notifies a user about a document at a given time
+type Reminder struct {
+	Id         string     `json:"id"`
+	UserId     string     `json:"-"`
+	DocumentId string     `json:"document_id"`
+	Note       string     `json:"note"`
+	RemindAt   time.Time  `json:"remind_at"`
+	FiredAt    *time.Time `json:"fired_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// TableName returns the name of the table
+func (r Reminder) TableName() string {
+	return "reminder"
+}
+
+// BeforeCreate is a hook that runs before creating a reminder
+func (r *Reminder) BeforeCreate(tx *gorm.DB) error {
+	r.Id = utils.UUIDv4()
+	return nil
+}
+
+// ReminderRequest is the request to create a reminder
+type ReminderRequest struct {
+	DocumentId string    `json:"document_id"`
+	Note       string    `json:"note"`
+	RemindAt   time.Time `json:"remind_at"`
+}
+
+// NotificationRepository is the repository for notifications, subscriptions and reminders
+type NotificationRepository interface {
+	CreateNotification(notification Notification) (Notification, error)
+	GetUnreadNotification(userId string, documentId string, notificationType NotificationType) (Notification, error)
+	UpdateNotification(notification Notification) (Notification, error)
+	GetNotifications(userId string, unreadOnly bool, limit int, offset int) ([]Notification, int64, int64, error)
+	SetNotificationsRead(userId string, ids []string, readAt *time.Time) error
+	GetUnmailedNotifications(frequencies []DigestFrequency) ([]Notification, error)
+	GetLastEmailedAt(userId string) (*time.Time, error)
+	ClaimNotificationEmail(id string, at time.Time) (bool, error)
+	ReleaseNotificationEmails(ids []string) error
+	Subscribe(subscription Subscription) error
+	Unsubscribe(userId string, documentId string) error
+	IsSubscribed(userId string, documentId string) (bool, error)
+	GetSubscribers(documentId string) ([]string, error)
+	GetSubscriptions(userId string) ([]Subscription, error)
+	CreateReminder(reminder Reminder) (Reminder, error)
+	GetReminders(userId string) ([]Reminder, error)
+	DeleteReminder(userId string, id string) error
+	GetDueReminders(now time.Time) ([]Reminder, error)
+	ClaimReminder(id string, at time.Time) (bool, error)
+	ReleaseReminder(id string) error
+}
+
+// NotificationService is the service for notifications, subscriptions and reminders
+type NotificationService interface {
+	Notify(notification Notification) error
+	NotifyMentions(actorId string, document Document, previousContent string) error
+	NotifySubscribers(activity Activity, document Document) error
+	GetNotifications(userId string, unreadOnly bool, limit int, offset int) (NotificationPage, error)
+	MarkRead(userId string, ids []string) error
+	MarkUnread(userId string, ids []string) error
+	GetPreferences(userId string) (NotificationPreferences, error)
+	UpdatePreferences(userId string, preferences NotificationPreferences) error
+	Subscribe(userId string, documentId string) error
+	Unsubscribe(userId string, documentId string) error
+	IsSubscribed(userId string, documentId string) (bool, error)
+	GetSubscriptions(userId string) ([]Subscription, error)
+	CreateReminder(userId string, request ReminderRequest) (Reminder, error)
+	GetReminders(userId string) ([]Reminder, error)
+	DeleteReminder(userId string, id string) error
+	SendDueReminders(now time.Time) error
+	SendDigests(now time.Time) error
+}
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// PresenceMode is what the user is doing on the document
+type PresenceMode string
+
+const (
+	PresenceModeViewing PresenceMode = "viewing"
+	PresenceModeEditing PresenceMode = "editing"
+)
+
+// PresenceCursor is the position of the user in the document
+type PresenceCursor struct {
+	BlockId string `json:"block_id"`
+	Anchor  int    `json:"anchor"`
+	Head    int    `json:"head"`
+}
+
+// Presence is a connection of a user on a document
+type Presence struct {
+	ConnectionId string `json:"connection_id"`
+
+	UserId    string `json:"user_id"`
+	UserName  string `json:"user_name"`
+	AvatarUrl string `json:"avatar_url"`
+
+	DocumentId string `json:"document_id"`
+	SpaceId    string `json:"space_id"`
+
+	Mode   PresenceMode    `json:"mode"`
+	Cursor *PresenceCursor `json:"cursor,omitempty"`
+
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// PresenceMessage is a message exchanged on the presence websocket
+type PresenceMessage struct {
+	Type string `json:"type"`
+
+	// sent by the client with the "heartbeat" type
+	Mode   PresenceMode    `json:"mode,omitempty"`
+	Cursor *PresenceCursor `json:"cursor,omitempty"`
+
+	// sent by the server with the "presence" type
+	DocumentId string     `json:"document_id,omitempty"`
+	Users      []Presence `json:"users,omitempty"`
+}
+
+// PresenceService is the service for presences, they are stored in the cache
+type PresenceService interface {
+	Touch(presence Presence) error
+	Remove(presence Presence) error
+	GetDocumentPresence(documentId string) ([]Presence, error)
+	GetSpacePresence(spaceId string) ([]Presence, error)
+}
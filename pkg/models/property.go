@@ -0,0 +1,445 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// PropertyType is the type of a database property
+type PropertyType string
+
+// PropertyType constants
+const (
+	PropertyTypeText        PropertyType = "text"
+	PropertyTypeNumber      PropertyType = "number"
+	PropertyTypeSelect      PropertyType = "select"
+	PropertyTypeMultiSelect PropertyType = "multi_select"
+	PropertyTypeDate        PropertyType = "date"
+	PropertyTypeCheckbox    PropertyType = "checkbox"
+	PropertyTypePerson      PropertyType = "person"
+	PropertyTypeURL         PropertyType = "url"
+	PropertyTypeRelation    PropertyType = "relation"
+	PropertyTypeRollup      PropertyType = "rollup"
+	PropertyTypeFormula     PropertyType = "formula"
+)
+
+// propertyTypes lists the valid property types
+var propertyTypes = []PropertyType{
+	PropertyTypeText,
+	PropertyTypeNumber,
+	PropertyTypeSelect,
+	PropertyTypeMultiSelect,
+	PropertyTypeDate,
+	PropertyTypeCheckbox,
+	PropertyTypePerson,
+	PropertyTypeURL,
+	PropertyTypeRelation,
+	PropertyTypeRollup,
+	PropertyTypeFormula,
+}
+
+// IsValid returns true if the property type is known
+func (pt PropertyType) IsValid() bool {
+	return slices.Contains(propertyTypes, pt)
+}
+
+// IsList returns true if the values of the type are a json array of strings
+func (pt PropertyType) IsList() bool {
+	return pt == PropertyTypeMultiSelect || pt == PropertyTypePerson || pt == PropertyTypeRelation
+}
+
+// ValueType returns the type of the values of the property, the rollups and the formulas have the type of their result
+func (pd PropertyDefinition) ValueType() PropertyType {
+	switch {
+	case pd.Type == PropertyTypeRollup && pd.Rollup != nil && pd.Rollup.Type != "":
+		return pd.Rollup.Type
+	case pd.Type == PropertyTypeRollup:
+		return PropertyTypeNumber
+	case pd.Type == PropertyTypeFormula && pd.Formula != nil && pd.Formula.Type != "":
+		return pd.Formula.Type
+	case pd.Type == PropertyTypeFormula:
+		return PropertyTypeText
+	}
+	return pd.Type
+}
+
+// IsComputed returns true if the values of the property are computed by the server
+func (pd PropertyDefinition) IsComputed() bool {
+	return pd.Type == PropertyTypeRollup || pd.Type == PropertyTypeFormula
+}
+
+// RollupFunction is the aggregation of a rollup property
+type RollupFunction string
+
+// RollupFunction constants
+const (
+	RollupFunctionCount          RollupFunction = "count"
+	RollupFunctionSum            RollupFunction = "sum"
+	RollupFunctionMin            RollupFunction = "min"
+	RollupFunctionMax            RollupFunction = "max"
+	RollupFunctionPercentChecked RollupFunction = "percent_checked"
+)
+
+// IsValid returns true if the rollup function is known
+func (rf RollupFunction) IsValid() bool {
+	switch rf {
+	case RollupFunctionCount, RollupFunctionSum, RollupFunctionMin, RollupFunctionMax, RollupFunctionPercentChecked:
+		return true
+	}
+	return false
+}
+
+// PropertySchema is the list of the properties shared by the rows of a database
+type PropertySchema []PropertyDefinition
+
+// PropertyDefinition is a property of a database schema, the rows reference it by id
+// so it can be renamed without updating them.
+type PropertyDefinition struct {
+	Id       string           `json:"id"`
+	Name     string           `json:"name"`
+	Type     PropertyType     `json:"type"`
+	Options  []PropertyOption `json:"options,omitempty"`
+	Relation *RelationConfig  `json:"relation,omitempty"`
+	Rollup   *RollupConfig    `json:"rollup,omitempty"`
+	Formula  *FormulaConfig   `json:"formula,omitempty"`
+}
+
+// RelationConfig links the rows of a relation property to the rows of a database.
+// A bidirectional relation is synced with a relation property of the other database, created when it's enabled.
+type RelationConfig struct {
+	DatabaseId       string `json:"database_id"`
+	Bidirectional    bool   `json:"bidirectional"`
+	SyncedPropertyId string `json:"synced_property_id,omitempty"`
+}
+
+// RollupConfig aggregates a property of the rows linked by a relation property of the same database.
+// The values are computed when the rows are written, Type is the type of the result.
+type RollupConfig struct {
+	RelationPropertyId string         `json:"relation_property_id"`
+	PropertyId         string         `json:"property_id,omitempty"`
+	Function           RollupFunction `json:"function"`
+	Type               PropertyType   `json:"type,omitempty"`
+}
+
+// FormulaConfig is the expression of a formula property, it references the properties of the row by name.
+// The values are computed when the rows are written, Type is the type of the result.
+type FormulaConfig struct {
+	Expression string       `json:"expression"`
+	Type       PropertyType `json:"type,omitempty"`
+}
+
+// PropertyOption is an option of a select or multi-select property, the rows store its name
+type PropertyOption struct {
+	Id    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+}
+
+// dateLayouts are the accepted layouts of the date values
+var dateLayouts = []string{time.DateOnly, time.RFC3339}
+
+// Find returns the property with the id, or the name when the id is empty
+func (ps PropertySchema) Find(id string, name string) (*PropertyDefinition, bool) {
+	for i := range ps {
+		if (id != "" && ps[i].Id == id) || (id == "" && ps[i].Name == name) {
+			return &ps[i], true
+		}
+	}
+	return nil, false
+}
+
+// Normalize checks the schema, the missing ids of the properties and options are generated
+func (ps PropertySchema) Normalize() error {
+	names := map[string]bool{}
+	for i := range ps {
+		definition := &ps[i]
+		definition.Name = strings.TrimSpace(definition.Name)
+		if definition.Name == "" {
+			return fmt.Errorf("property %d: name is required", i)
+		}
+		if names[definition.Name] {
+			return fmt.Errorf("property %s: name is already used", definition.Name)
+		}
+		names[definition.Name] = true
+
+		if !definition.Type.IsValid() {
+			return fmt.Errorf("property %s: unknown type %q", definition.Name, definition.Type)
+		}
+		if definition.Id == "" {
+			definition.Id = utils.UUIDv4()
+		}
+
+		if definition.Type != PropertyTypeRelation {
+			definition.Relation = nil
+		} else if definition.Relation == nil || definition.Relation.DatabaseId == "" {
+			return fmt.Errorf("property %s: a relation requires a database", definition.Name)
+		}
+		if definition.Type != PropertyTypeRollup {
+			definition.Rollup = nil
+		} else if definition.Rollup == nil || !definition.Rollup.Function.IsValid() {
+			return fmt.Errorf("property %s: a rollup requires a function", definition.Name)
+		}
+		if definition.Type != PropertyTypeFormula {
+			definition.Formula = nil
+		} else if definition.Formula == nil || strings.TrimSpace(definition.Formula.Expression) == "" {
+			return fmt.Errorf("property %s: a formula requires an expression", definition.Name)
+		}
+
+		if definition.Type != PropertyTypeSelect && definition.Type != PropertyTypeMultiSelect {
+			definition.Options = nil
+			continue
+		}
+		options := map[string]bool{}
+		for j := range definition.Options {
+			option := &definition.Options[j]
+			option.Name = strings.TrimSpace(option.Name)
+			if option.Name == "" || strings.Contains(option.Name, ",") {
+				return fmt.Errorf("property %s: invalid option name %q", definition.Name, option.Name)
+			}
+			if options[option.Name] {
+				return fmt.Errorf("property %s: option %s is already used", definition.Name, option.Name)
+			}
+			options[option.Name] = true
+			if option.Id == "" {
+				option.Id = utils.UUIDv4()
+			}
+		}
+	}
+
+	// the rollups reference a relation of the schema, by id or name
+	for i := range ps {
+		rollup := ps[i].Rollup
+		if rollup == nil {
+			continue
+		}
+		relation, ok := ps.Find(rollup.RelationPropertyId, "")
+		if !ok {
+			relation, ok = ps.Find("", rollup.RelationPropertyId)
+		}
+		if !ok || relation.Type != PropertyTypeRelation {
+			return fmt.Errorf("property %s: %q is not a relation property", ps[i].Name, rollup.RelationPropertyId)
+		}
+		rollup.RelationPropertyId = relation.Id
+	}
+	return nil
+}
+
+// Option returns the option with the name or the id
+func (pd PropertyDefinition) Option(value string) (PropertyOption, bool) {
+	for _, option := range pd.Options {
+		if option.Name == value || option.Id == value {
+			return option, true
+		}
+	}
+	return PropertyOption{}, false
+}
+
+// Normalize checks a value sent for the property and returns its canonical form, an empty value is always valid.
+// The lists are json arrays of strings, an empty list is an empty value, and the select options are stored by name.
+func (pd PropertyDefinition) Normalize(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	switch pd.Type {
+	case PropertyTypeText, PropertyTypeRollup, PropertyTypeFormula:
+		return value, nil
+	case PropertyTypeNumber:
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid number %q", value)
+		}
+		return strconv.FormatFloat(number, 'f', -1, 64), nil
+	case PropertyTypeCheckbox:
+		checked, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid checkbox %q", value)
+		}
+		return strconv.FormatBool(checked), nil
+	case PropertyTypeDate:
+		return normalizeDate(value)
+	case PropertyTypeURL:
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "mailto") {
+			return "", fmt.Errorf("invalid url %q", value)
+		}
+		return value, nil
+	case PropertyTypeSelect:
+		option, ok := pd.Option(value)
+		if !ok {
+			return "", fmt.Errorf("unknown option %q", value)
+		}
+		return option.Name, nil
+	}
+
+	var values []string
+	if err := json.Unmarshal([]byte(value), &values); err != nil {
+		return "", fmt.Errorf("invalid list %q, a json array of strings is expected", value)
+	}
+	if len(values) == 0 {
+		return "", nil
+	}
+	if pd.Type == PropertyTypeMultiSelect {
+		for i, v := range values {
+			option, ok := pd.Option(v)
+			if !ok {
+				return "", fmt.Errorf("unknown option %q", v)
+			}
+			values[i] = option.Name
+		}
+	}
+	return EncodeList(values), nil
+}
+
+// Text returns the value as text, the lists are joined by commas
+func (pd PropertyDefinition) Text(value string) string {
+	if !pd.Type.IsList() || value == "" {
+		return value
+	}
+	var values []string
+	if err := json.Unmarshal([]byte(value), &values); err != nil {
+		return value
+	}
+	return strings.Join(values, ", ")
+}
+
+// FromText converts a text to a value of the property, it's used when the type of a property changes
+// or to import values. The missing select options are added to the definition.
+// An empty value is returned when the text can't be converted.
+func (pd *PropertyDefinition) FromText(text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ""
+	}
+
+	switch pd.Type {
+	case PropertyTypeRollup, PropertyTypeFormula:
+		// the values are computed
+		return ""
+	case PropertyTypeSelect:
+		return pd.ensureOption(text)
+	case PropertyTypeMultiSelect, PropertyTypePerson, PropertyTypeRelation:
+		var values []string
+		for _, v := range strings.Split(text, ",") {
+			if v = strings.TrimSpace(v); v == "" {
+				continue
+			}
+			if pd.Type == PropertyTypeMultiSelect {
+				v = pd.ensureOption(v)
+			}
+			values = append(values, v)
+		}
+		if len(values) == 0 {
+			return ""
+		}
+		return EncodeList(values)
+	case PropertyTypeCheckbox:
+		switch strings.ToLower(text) {
+		case "yes", "y", "x", "on", "checked":
+			return "true"
+		case "no", "n", "off", "unchecked":
+			return "false"
+		}
+	}
+
+	value, err := pd.Normalize(text)
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
+// ensureOption returns the name of the option, it's created if it doesn't exist
+func (pd *PropertyDefinition) ensureOption(name string) string {
+	if option, ok := pd.Option(name); ok {
+		return option.Name
+	}
+	pd.Options = append(pd.Options, PropertyOption{Id: utils.UUIDv4(), Name: name})
+	return name
+}
+
+// normalizeDate keeps the date only values and converts the date times to utc
+func normalizeDate(value string) (string, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			if layout == time.DateOnly {
+				return value, nil
+			}
+			return t.UTC().Format(time.RFC3339), nil
+		}
+	}
+	return "", fmt.Errorf("invalid date %q", value)
+}
+
+// EncodeList returns the value of a list property
+func EncodeList(values []string) string {
+	if values == nil {
+		values = []string{}
+	}
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+// DecodeList returns the elements of a value of a list property, nil is returned for an invalid value
+func DecodeList(value string) []string {
+	var values []string
+	if value == "" || json.Unmarshal([]byte(value), &values) != nil {
+		return nil
+	}
+	return values
+}
+
+// PropertyError is the validation error of a property value
+type PropertyError struct {
+	Property string `json:"property"`
+	Error    string `json:"error"`
+}
+
+// ValidationError is returned when the properties of a row don't match the schema of its database
+type ValidationError struct {
+	Errors []PropertyError `json:"errors"`
+}
+
+func (ve *ValidationError) Error() string {
+	messages := make([]string, len(ve.Errors))
+	for i, e := range ve.Errors {
+		messages[i] = e.Property + ": " + e.Error
+	}
+	return "invalid properties: " + strings.Join(messages, ", ")
+}
+
+// Value implements the driver.Valuer interface
+func (ps PropertySchema) Value() (driver.Value, error) {
+	return json.Marshal(ps)
+}
+
+// Scan implements the sql.Scanner interface
+func (ps *PropertySchema) Scan(value any) error {
+	switch v := value.(type) {
+	case []byte:
+		// PostgreSQL usually returns []byte
+		return json.Unmarshal(v, ps)
+	case string:
+		// SQLite often returns string
+		return json.Unmarshal([]byte(v), ps)
+	case nil:
+		// Handle null case
+		*ps = PropertySchema{}
+		return nil
+	default:
+		// Fall back to string conversion
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, ps)
+	}
+}
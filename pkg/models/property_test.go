@@ -0,0 +1,131 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPropertyDefinitionNormalize(t *testing.T) {
+	options := []PropertyOption{{Id: "o1", Name: "Todo"}, {Id: "o2", Name: "Done"}}
+	tests := []struct {
+		name       string
+		definition PropertyDefinition
+		value      string
+		want       string
+		wantErr    string
+	}{
+		{"empty value", PropertyDefinition{Type: PropertyTypeNumber}, "", "", ""},
+		{"text", PropertyDefinition{Type: PropertyTypeText}, " some text ", " some text ", ""},
+		{"number", PropertyDefinition{Type: PropertyTypeNumber}, " 1.50 ", "1.5", ""},
+		{"number exponent", PropertyDefinition{Type: PropertyTypeNumber}, "1e3", "1000", ""},
+		{"invalid number", PropertyDefinition{Type: PropertyTypeNumber}, "one", "", `invalid number "one"`},
+		{"checkbox", PropertyDefinition{Type: PropertyTypeCheckbox}, "1", "true", ""},
+		{"invalid checkbox", PropertyDefinition{Type: PropertyTypeCheckbox}, "yes", "", `invalid checkbox "yes"`},
+		{"date only", PropertyDefinition{Type: PropertyTypeDate}, "2026-10-19", "2026-10-19", ""},
+		{"date time to utc", PropertyDefinition{Type: PropertyTypeDate}, "2026-10-19T10:00:00+02:00", "2026-10-19T08:00:00Z", ""},
+		{"invalid date", PropertyDefinition{Type: PropertyTypeDate}, "19/10/2026", "", `invalid date "19/10/2026"`},
+		{"url", PropertyDefinition{Type: PropertyTypeURL}, "https://example.com/a", "https://example.com/a", ""},
+		{"mailto url", PropertyDefinition{Type: PropertyTypeURL}, "mailto:a@example.com", "mailto:a@example.com", ""},
+		{"invalid url scheme", PropertyDefinition{Type: PropertyTypeURL}, "javascript:alert(1)", "", `invalid url "javascript:alert(1)"`},
+		{"select by name", PropertyDefinition{Type: PropertyTypeSelect, Options: options}, "Done", "Done", ""},
+		{"select by id", PropertyDefinition{Type: PropertyTypeSelect, Options: options}, "o1", "Todo", ""},
+		{"unknown select option", PropertyDefinition{Type: PropertyTypeSelect, Options: options}, "Later", "", `unknown option "Later"`},
+		{"multi select by id and name", PropertyDefinition{Type: PropertyTypeMultiSelect, Options: options}, `["o2","Todo"]`, `["Done","Todo"]`, ""},
+		{"unknown multi select option", PropertyDefinition{Type: PropertyTypeMultiSelect, Options: options}, `["Later"]`, "", `unknown option "Later"`},
+		{"empty list", PropertyDefinition{Type: PropertyTypePerson}, `[]`, "", ""},
+		{"person list", PropertyDefinition{Type: PropertyTypePerson}, `["u1","u2"]`, `["u1","u2"]`, ""},
+		{"invalid list", PropertyDefinition{Type: PropertyTypeRelation}, "r1,r2", "", "invalid list"},
+		{"computed value unchanged", PropertyDefinition{Type: PropertyTypeRollup}, "3", "3", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.definition.Normalize(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Normalize(%q) error = %v, want %q", tt.value, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Normalize(%q) unexpected error: %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPropertySchemaNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  PropertySchema
+		wantErr string
+	}{
+		{"valid schema", PropertySchema{
+			{Name: " Status ", Type: PropertyTypeSelect, Options: []PropertyOption{{Name: "Todo"}}},
+			{Name: "Tasks", Type: PropertyTypeRelation, Relation: &RelationConfig{DatabaseId: "db"}},
+			{Name: "Count", Type: PropertyTypeRollup, Rollup: &RollupConfig{RelationPropertyId: "Tasks", Function: RollupFunctionCount}},
+			{Name: "Total", Type: PropertyTypeFormula, Formula: &FormulaConfig{Expression: "1 + 1"}},
+		}, ""},
+		{"missing name", PropertySchema{{Name: " ", Type: PropertyTypeText}}, "property 0: name is required"},
+		{"duplicated name", PropertySchema{{Name: "A", Type: PropertyTypeText}, {Name: "A ", Type: PropertyTypeNumber}}, "property A: name is already used"},
+		{"unknown type", PropertySchema{{Name: "A", Type: "color"}}, `property A: unknown type "color"`},
+		{"relation without database", PropertySchema{{Name: "A", Type: PropertyTypeRelation, Relation: &RelationConfig{}}}, "a relation requires a database"},
+		{"rollup without function", PropertySchema{{Name: "A", Type: PropertyTypeRollup, Rollup: &RollupConfig{Function: "median"}}}, "a rollup requires a function"},
+		{"formula without expression", PropertySchema{{Name: "A", Type: PropertyTypeFormula, Formula: &FormulaConfig{Expression: " "}}}, "a formula requires an expression"},
+		{"invalid option name", PropertySchema{{Name: "A", Type: PropertyTypeSelect, Options: []PropertyOption{{Name: "a,b"}}}}, `invalid option name "a,b"`},
+		{"duplicated option", PropertySchema{{Name: "A", Type: PropertyTypeMultiSelect, Options: []PropertyOption{{Name: "x"}, {Name: " x"}}}}, "option x is already used"},
+		{"rollup of unknown relation", PropertySchema{
+			{Name: "A", Type: PropertyTypeText},
+			{Name: "B", Type: PropertyTypeRollup, Rollup: &RollupConfig{RelationPropertyId: "A", Function: RollupFunctionCount}},
+		}, `property B: "A" is not a relation property`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schema.Normalize()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Normalize() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Normalize() unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestPropertySchemaNormalizeFillsDefinitions(t *testing.T) {
+	schema := PropertySchema{
+		{Name: " Status ", Type: PropertyTypeSelect, Options: []PropertyOption{{Name: " Todo "}}, Formula: &FormulaConfig{Expression: "1"}},
+		{Name: "Tasks", Type: PropertyTypeRelation, Relation: &RelationConfig{DatabaseId: "db"}},
+		{Name: "Count", Type: PropertyTypeRollup, Rollup: &RollupConfig{RelationPropertyId: "Tasks", Function: RollupFunctionCount}},
+		{Name: "Notes", Type: PropertyTypeText, Options: []PropertyOption{{Name: "x"}}},
+	}
+	if err := schema.Normalize(); err != nil {
+		t.Fatalf("Normalize() unexpected error: %v", err)
+	}
+
+	for _, definition := range schema {
+		if definition.Id == "" {
+			t.Errorf("property %s: the id is not generated", definition.Name)
+		}
+	}
+	status := schema[0]
+	if status.Name != "Status" || status.Formula != nil {
+		t.Errorf("status = %+v, want the name trimmed and the formula dropped", status)
+	}
+	if option := status.Options[0]; option.Name != "Todo" || option.Id == "" {
+		t.Errorf("option = %+v, want the name trimmed and an id", option)
+	}
+	if schema[2].Rollup.RelationPropertyId != schema[1].Id {
+		t.Errorf("rollup relation = %q, want the id of the relation %q", schema[2].Rollup.RelationPropertyId, schema[1].Id)
+	}
+	if schema[3].Options != nil {
+		t.Errorf("text options = %+v, want nil", schema[3].Options)
+	}
+}
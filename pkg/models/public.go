@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+// PublicDocument is a document as seen without an account, the members and the authors are not exposed
+type PublicDocument struct {
+	Id         string         `json:"id"`
+	Name       string         `json:"name"`
+	Slug       string         `json:"slug"`
+	Type       DocumentType   `json:"type"`
+	ParentId   string         `json:"parent_id"`
+	Icon       string         `json:"icon"`
+	FullWidth  bool           `json:"full_width"`
+	Header     string         `json:"header_background"`
+	Properties Properties     `json:"properties"`
+	Schema     PropertySchema `json:"schema,omitempty"`
+	Content    string         `json:"content"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// NewPublicDocument returns the public fields of the document
+func NewPublicDocument(document Document) PublicDocument {
+	return PublicDocument{
+		Id:         document.Id,
+		Name:       document.Name,
+		Slug:       document.Slug,
+		Type:       document.Type,
+		ParentId:   document.ParentId,
+		Icon:       document.Config.Icon,
+		FullWidth:  document.Config.FullWidth,
+		Header:     document.Config.HeaderBackground,
+		Properties: document.Properties,
+		Schema:     document.Schema,
+		Content:    document.Content,
+		UpdatedAt:  document.UpdatedAt,
+	}
+}
+
+// PublicPage is an entry of the navigation of public pages, without the content
+type PublicPage struct {
+	Id       string       `json:"id"`
+	Name     string       `json:"name"`
+	Slug     string       `json:"slug"`
+	Type     DocumentType `json:"type"`
+	ParentId string       `json:"parent_id"`
+	Icon     string       `json:"icon"`
+}
+
+// NewPublicPage returns the navigation entry of the document
+func NewPublicPage(document Document) PublicPage {
+	return PublicPage{
+		Id:       document.Id,
+		Name:     document.Name,
+		Slug:     document.Slug,
+		Type:     document.Type,
+		ParentId: document.ParentId,
+		Icon:     document.Config.Icon,
+	}
+}
+
+// PublicNavNode is a published page and its published children
+type PublicNavNode struct {
+	PublicPage
+	Children []PublicNavNode `json:"children"`
+}
+
+// PublicService serves the published documents. A document is published when it's public or when one of its
+// ancestors is public with its subpages published, the other documents are reported as not found.
+// The subpages breaking the inheritance aren't published with their ancestors.
+type PublicService interface {
+	GetPublishedDocumentById(id string) (Document, error)
+	GetPublishedDocumentBySlug(slug string) (Document, error)
+	GetPublishedTree(documentId string) (PublicNavNode, error)
+	GetPublishedDocuments(spaceId string, limit int) ([]Document, error)
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+	"gorm.io/gorm"
+)
+
+// ShareLink gives access to a document to anyone knowing its token, without an account.
+// The subpages of the document are shared too when IncludeSubpages is set, down to the ones breaking the inheritance.
+type ShareLink struct {
+	Id         string     `json:"id"`
+	Token      string     `json:"token"`
+	DocumentId string     `json:"document_id"`
+	SpaceId    string     `json:"space_id"`
+	Access     AccessType `json:"access"`
+
+	IncludeSubpages bool `json:"include_subpages"`
+
+	// PasswordHash is the bcrypt hash of the password, the link isn't protected when empty
+	PasswordHash string `json:"-"`
+	// HasPassword is true when a password is required to open the link
+	HasPassword bool `json:"has_password" gorm:"-"`
+
+	ExpiresAt    *time.Time `json:"expires_at"`
+	ViewCount    int64      `json:"view_count"`
+	LastViewedAt *time.Time `json:"last_viewed_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName returns the name of the table
+func (sl ShareLink) TableName() string {
+	return "share_link"
+}
+
+// BeforeCreate is a hook that runs before creating a share link
+func (sl *ShareLink) BeforeCreate(tx *gorm.DB) error {
+	sl.Id = utils.UUIDv4()
+	return nil
+}
+
+// AfterFind is a hook that runs after loading a share link
+func (sl *ShareLink) AfterFind(tx *gorm.DB) error {
+	sl.HasPassword = sl.PasswordHash != ""
+	return nil
+}
+
+// IsExpired returns true when the link is revoked or its expiry date is passed
+func (sl ShareLink) IsExpired(now time.Time) bool {
+	return sl.RevokedAt != nil || (sl.ExpiresAt != nil && !sl.ExpiresAt.After(now))
+}
+
+// ShareLinkRequest is the request to create or update a share link. The access is viewer or comment.
+// A nil password keeps the current one and an empty password removes it.
+type ShareLinkRequest struct {
+	Access          AccessType `json:"access"`
+	IncludeSubpages bool       `json:"include_subpages"`
+	Password        *string    `json:"password"`
+	ExpiresAt       *time.Time `json:"expires_at"`
+}
+
+// SharedDocument is a document opened with a share link
+type SharedDocument struct {
+	Access          AccessType     `json:"access"`
+	IncludeSubpages bool           `json:"include_subpages"`
+	RootId          string         `json:"root_id"`
+	ExpiresAt       *time.Time     `json:"expires_at"`
+	Document        PublicDocument `json:"document"`
+}
+
+// Share link errors
+var (
+	// ErrShareLinkExpired is returned when a share link is revoked or expired
+	ErrShareLinkExpired = errors.New("share link expired")
+	// ErrShareLinkPassword is returned when the password of a share link is missing or wrong
+	ErrShareLinkPassword = errors.New("share link password required")
+	// ErrShareLinkAccess is returned when the access of a share link is not viewer or comment
+	ErrShareLinkAccess = errors.New("the access of a share link must be viewer or comment")
+)
+
+// ShareLinkRepository is the repository for share links
+type ShareLinkRepository interface {
+	CreateShareLink(link ShareLink) (ShareLink, error)
+	GetShareLinkById(id string) (ShareLink, error)
+	GetShareLinkByToken(token string) (ShareLink, error)
+	GetShareLinksByDocumentId(documentId string) ([]ShareLink, error)
+	UpdateShareLink(link ShareLink) (ShareLink, error)
+	RecordView(id string, at time.Time) error
+}
+
+// ShareLinkService is the service for share links
+type ShareLinkService interface {
+	CreateShareLink(userId string, document Document, request ShareLinkRequest) (ShareLink, error)
+	GetShareLinkById(id string) (ShareLink, error)
+	GetShareLinks(documentId string) ([]ShareLink, error)
+	UpdateShareLink(link ShareLink, request ShareLinkRequest) (ShareLink, error)
+	RevokeShareLink(link ShareLink) (ShareLink, error)
+	OpenShareLink(token string, password string) (ShareLink, error)
+	GetSharedDocument(link ShareLink, documentId string) (Document, error)
+	GetSharedPages(link ShareLink, parentId string) ([]Document, error)
+	RecordView(link ShareLink) error
+}
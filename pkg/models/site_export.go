@@ -0,0 +1,78 @@
+package models
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+	"gorm.io/gorm"
+)
+
+type SiteExportStatus string
+
+const (
+	SiteExportPending   SiteExportStatus = "pending"
+	SiteExportRunning   SiteExportStatus = "running"
+	SiteExportCompleted SiteExportStatus = "completed"
+	SiteExportFailed    SiteExportStatus = "failed"
+)
+
+// SiteExport is a job rendering the published documents of a space as a static site, the zip file
+// of the site is kept in the storage of the attachments until the export is deleted
+type SiteExport struct {
+	Id      string           `json:"id"`
+	SpaceId string           `json:"space_id"`
+	Status  SiteExportStatus `json:"status"`
+	Error   string           `json:"error,omitempty"`
+
+	// Pages and Attachments are the number of exported pages and copied attachments, Size is the size of the zip file
+	Pages       int    `json:"pages"`
+	Attachments int    `json:"attachments"`
+	Size        int64  `json:"size"`
+	StorageKey  string `json:"-"`
+
+	CreatedBy   string     `json:"created_by"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName returns the name of the table
+func (e SiteExport) TableName() string {
+	return "site_export"
+}
+
+// BeforeCreate is a hook that runs before creating a site export
+func (e *SiteExport) BeforeCreate(tx *gorm.DB) error {
+	e.Id = utils.UUIDv4()
+	return nil
+}
+
+// SiteExportRepository is the repository for site exports
+type SiteExportRepository interface {
+	CreateExport(export SiteExport) (SiteExport, error)
+	GetExportById(id string) (SiteExport, error)
+	GetExportsBySpaceId(spaceId string) ([]SiteExport, error)
+	ClaimExport(id string, at time.Time, staleBefore time.Time) (bool, error)
+	GetPendingExports(staleBefore time.Time) ([]SiteExport, error)
+	UpdateExport(export SiteExport) error
+	DeleteExport(id string) error
+}
+
+// SiteExportService is the service for site exports
+type SiteExportService interface {
+	CreateExport(spaceId string, userId string) (SiteExport, error)
+	GetExportById(id string) (SiteExport, error)
+	GetExportsBySpaceId(spaceId string) ([]SiteExport, error)
+	OpenExportArchive(export SiteExport) (io.ReadCloser, error)
+	DeleteExport(export SiteExport) error
+	RunPending() error
+}
+
+// ErrSiteExportNotReady is returned when the zip file of an export which isn't completed is requested
+var ErrSiteExportNotReady = errors.New("the site export is not completed")
+
+// ErrSiteExportRunning is returned when a running export is deleted
+var ErrSiteExportRunning = errors.New("the site export is running")
@@ -22,7 +22,7 @@ type Space struct {
 
 	Documents []Document `json:"documents"`
 
-	Members Members `json:"members"`
+	Members Members `json:"members" gorm:"-"`
 
 	// MembersWithUsers is used to return the members with user information
 	MembersWithUsersOrGroups MembersWithUsersOrGroups `json:"members_with_users_or_groups" gorm:"-"`
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+	"gorm.io/gorm"
+)
+
+// Tag is a label shared by documents across the tree. A tag without space is available in all the spaces,
+// otherwise it can only be added to the documents of its space.
+type Tag struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Color     string `json:"color"`
+	SpaceId   string `json:"space_id"`
+	CreatedBy string `json:"created_by"`
+
+	// Count is the number of documents with the tag readable by the user
+	Count int64 `json:"count" gorm:"-"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName returns the name of the table
+func (t Tag) TableName() string {
+	return "tag"
+}
+
+// BeforeCreate is a hook that runs before creating a tag
+func (t *Tag) BeforeCreate(tx *gorm.DB) error {
+	t.Id = utils.UUIDv4()
+	return nil
+}
+
+// DocumentTag is the tag of a document
+type DocumentTag struct {
+	DocumentId string    `json:"document_id"`
+	TagId      string    `json:"tag_id"`
+	CreatedBy  string    `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName returns the name of the table
+func (dt DocumentTag) TableName() string {
+	return "document_tag"
+}
+
+// TagRequest is the request to create or update a tag, the space can't be changed
+type TagRequest struct {
+	Name    string `json:"name"`
+	Color   string `json:"color"`
+	SpaceId string `json:"space_id"`
+}
+
+// TagMergeRequest is the request to merge a tag into another one
+type TagMergeRequest struct {
+	TargetId string `json:"target_id"`
+}
+
+// TaggedDocuments is a page of the documents with a tag
+type TaggedDocuments struct {
+	Results []Document `json:"results"`
+	Total   int64      `json:"total"`
+	HasMore bool       `json:"has_more"`
+}
+
+// DocumentScope is the set of documents a user can access: the documents of the spaces except the excluded ones,
+// and the included documents of the other spaces
+type DocumentScope struct {
+	SpaceIds    []string
+	ExcludedIds []string
+	IncludedIds []string
+}
+
+var (
+	// ErrTagExists is returned when a tag with the same name already exists in the scope
+	ErrTagExists = errors.New("tag already exists")
+	// ErrTagScope is returned when a tag of a space is used outside of it
+	ErrTagScope = errors.New("tag is not available in this space")
+)
+
+// TagRepository is the repository for tags
+type TagRepository interface {
+	CreateTag(tag Tag) (Tag, error)
+	GetTagById(id string) (Tag, error)
+	GetTagByName(spaceId string, name string) (Tag, error)
+	GetTags(spaceIds []string) ([]Tag, error)
+	UpdateTag(tag Tag) (Tag, error)
+	DeleteTag(id string) error
+	MergeTag(sourceId string, targetId string) error
+	CountDocuments(spaceIds []string) (map[string]int64, error)
+	GetDocumentTags(documentId string) ([]Tag, error)
+	AddDocumentTag(documentTag DocumentTag) error
+	RemoveDocumentTag(documentId string, tagId string) error
+	GetTaggedDocuments(tagId string, scope DocumentScope, limit int, offset int) ([]Document, int64, error)
+}
+
+// TagService is the service for tags
+type TagService interface {
+	CreateTag(userId string, request TagRequest) (Tag, error)
+	GetTagById(id string) (Tag, error)
+	GetTagsForUser(userId string, spaceId string) ([]Tag, error)
+	UpdateTag(tag Tag, request TagRequest) (Tag, error)
+	DeleteTag(id string) error
+	MergeTag(source Tag, target Tag) error
+	GetDocumentTags(documentId string) ([]Tag, error)
+	AddDocumentTag(userId string, document Document, tag Tag) error
+	RemoveDocumentTag(documentId string, tagId string) error
+	GetTaggedDocuments(userId string, tagId string, limit int, offset int) (TaggedDocuments, error)
+}
@@ -22,6 +22,7 @@ type User struct {
 	Groups []Group `json:"groups" gorm:"many2many:user_group;"`
 
 	IsAdmin bool `json:"is_admin,omitempty" gorm:"-"`
+	IsGuest bool `json:"is_guest,omitempty" gorm:"-"`
 
 	Favorites []Favorite `json:"favorites"`
 
@@ -0,0 +1,197 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2/utils"
+	"gorm.io/gorm"
+)
+
+// Webhook sends the events of the instance or of a space to an url. A webhook without space
+// receives the events of all the spaces and the events of the users.
+type Webhook struct {
+	Id      string        `json:"id"`
+	Name    string        `json:"name"`
+	Url     string        `json:"url"`
+	SpaceId string        `json:"space_id"`
+	Events  WebhookEvents `json:"events"`
+	Active  bool          `json:"active"`
+
+	// Secret signs the payloads, it's only returned when the webhook is created or the secret rotated
+	Secret string `json:"secret,omitempty"`
+
+	// FailureCount is the number of consecutive failed attempts, the webhook is disabled past the threshold
+	FailureCount int        `json:"failure_count"`
+	DisabledAt   *time.Time `json:"disabled_at"`
+
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName returns the name of the table
+func (w Webhook) TableName() string {
+	return "webhook"
+}
+
+// BeforeCreate is a hook that runs before creating a webhook
+func (w *Webhook) BeforeCreate(tx *gorm.DB) error {
+	w.Id = utils.UUIDv4()
+	return nil
+}
+
+// WebhookEvents are the patterns of the event types sent to a webhook, "document.*" selects all the
+// document events. All the events are sent when empty.
+type WebhookEvents []string
+
+// Matches returns true when the event type is selected
+func (we WebhookEvents) Matches(eventType string) bool {
+	if len(we) == 0 {
+		return true
+	}
+	for _, pattern := range we {
+		if pattern == "*" || pattern == eventType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(eventType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Value implements the driver.Valuer interface
+func (we WebhookEvents) Value() (driver.Value, error) {
+	if we == nil {
+		we = WebhookEvents{}
+	}
+	data, err := json.Marshal(we)
+	return string(data), err
+}
+
+// Scan implements the sql.Scanner interface
+func (we *WebhookEvents) Scan(value any) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, we)
+	case string:
+		return json.Unmarshal([]byte(v), we)
+	case nil:
+		*we = WebhookEvents{}
+		return nil
+	default:
+		return fmt.Errorf("unsupported type for webhook events: %T", value)
+	}
+}
+
+// WebhookRequest is the request to create or update a webhook, the space can't be changed
+type WebhookRequest struct {
+	Name    string        `json:"name"`
+	Url     string        `json:"url"`
+	SpaceId string        `json:"space_id"`
+	Events  WebhookEvents `json:"events"`
+	Active  *bool         `json:"active"`
+}
+
+// WebhookDeliveryStatus is the status of a delivery
+type WebhookDeliveryStatus string
+
+// WebhookDeliveryStatus constants
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is the delivery of an event to a webhook, it's retried until it succeeds or the attempts are exhausted.
+// The response of the last attempt is kept.
+type WebhookDelivery struct {
+	Id        string                `json:"id"`
+	WebhookId string                `json:"webhook_id"`
+	EventId   string                `json:"event_id"`
+	EventType string                `json:"event_type"`
+	Payload   string                `json:"payload"`
+	Status    WebhookDeliveryStatus `json:"status"`
+	Attempts  int                   `json:"attempts"`
+
+	// DedupKey identifies the delivery of an event to a webhook, an event is only queued once across the instances
+	DedupKey string `json:"-"`
+	// RedeliveryOf is the delivery copied by a manual redelivery
+	RedeliveryOf string `json:"redelivery_of,omitempty"`
+
+	ResponseCode int    `json:"response_code"`
+	ResponseBody string `json:"response_body"`
+	Error        string `json:"error"`
+	Duration     int64  `json:"duration_ms" gorm:"column:duration_ms"`
+
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// TableName returns the name of the table
+func (wd WebhookDelivery) TableName() string {
+	return "webhook_delivery"
+}
+
+// BeforeCreate is a hook that runs before creating a delivery
+func (wd *WebhookDelivery) BeforeCreate(tx *gorm.DB) error {
+	wd.Id = utils.UUIDv4()
+	return nil
+}
+
+// WebhookDeliveryPage is a page of the deliveries of a webhook, the most recent first
+type WebhookDeliveryPage struct {
+	Results []WebhookDelivery `json:"results"`
+	Total   int64             `json:"total"`
+	HasMore bool              `json:"has_more"`
+}
+
+// Webhook errors
+var (
+	// ErrWebhookUrl is returned when the url of a webhook is not an absolute http(s) url
+	ErrWebhookUrl = errors.New("the url must be an absolute http or https url")
+	// ErrWebhookEvents is returned when an event pattern doesn't select document, space or user events
+	ErrWebhookEvents = errors.New("the events must be document, space or user event types, \"document.*\" or \"*\"")
+	// ErrWebhookAddress is returned when a delivery would connect to a loopback, private or link-local address
+	ErrWebhookAddress = errors.New("the address of the webhook is not allowed")
+)
+
+// WebhookRepository is the repository for webhooks and their deliveries
+type WebhookRepository interface {
+	CreateWebhook(webhook Webhook) (Webhook, error)
+	GetWebhookById(id string) (Webhook, error)
+	GetWebhooks(spaceId string) ([]Webhook, error)
+	GetActiveWebhooks() ([]Webhook, error)
+	UpdateWebhook(webhook Webhook) (Webhook, error)
+	UpdateWebhookSecret(id string, secret string) error
+	DeleteWebhook(id string) error
+	RecordWebhookResult(id string, success bool, failureThreshold int, at time.Time) error
+	CreateDelivery(delivery WebhookDelivery) (WebhookDelivery, error)
+	GetDeliveryById(id string) (WebhookDelivery, error)
+	GetDeliveries(webhookId string, limit int, offset int) ([]WebhookDelivery, int64, error)
+	GetDueDeliveries(now time.Time, limit int) ([]WebhookDelivery, error)
+	ClaimDelivery(delivery WebhookDelivery, leaseUntil time.Time) (bool, error)
+	UpdateDelivery(delivery WebhookDelivery) error
+}
+
+// WebhookService is the service for webhooks and their deliveries
+type WebhookService interface {
+	CreateWebhook(userId string, request WebhookRequest) (Webhook, error)
+	GetWebhookById(id string) (Webhook, error)
+	GetWebhooks(spaceId string) ([]Webhook, error)
+	UpdateWebhook(webhook Webhook, request WebhookRequest) (Webhook, error)
+	DeleteWebhook(id string) error
+	RotateSecret(webhook Webhook) (Webhook, error)
+	GetDeliveries(webhookId string, limit int, offset int) (WebhookDeliveryPage, error)
+	GetDeliveryById(id string) (WebhookDelivery, error)
+	Redeliver(delivery WebhookDelivery) (WebhookDelivery, error)
+	Enqueue(eventId string, eventType string, spaceId string, payload []byte) error
+	DeliverDue(now time.Time) error
+}
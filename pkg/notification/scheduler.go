@@ -0,0 +1,63 @@
+package notification
+
+import (
+	"sync"
+	"time"
+
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/rs/zerolog"
+)
+
+// Scheduler periodically fires the due reminders and sends the email digests in background
+type Scheduler struct {
+	logger  zerolog.Logger
+	service models.NotificationService
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewScheduler starts the scheduler, the reminders and the digests are checked at each interval
+func NewScheduler(logger zerolog.Logger, service models.NotificationService, interval time.Duration) *Scheduler {
+	s := &Scheduler{
+		logger:  logger.With().Str("component", "notification").Logger(),
+		service: service,
+		stop:    make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run(interval)
+	return s
+}
+
+func (s *Scheduler) run(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.tick(now)
+		}
+	}
+}
+
+// tick fires the due reminders and sends the digests, the errors are only logged
+func (s *Scheduler) tick(now time.Time) {
+	if err := s.service.SendDueReminders(now); err != nil {
+		s.logger.Error().Err(err).Msg("failed to send the due reminders")
+	}
+	if err := s.service.SendDigests(now); err != nil {
+		s.logger.Error().Err(err).Msg("failed to send the email digests")
+	}
+}
+
+// Close stops the scheduler and waits for the running check
+func (s *Scheduler) Close() {
+	close(s.stop)
+	s.wg.Wait()
+}
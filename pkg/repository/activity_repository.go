@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type activityRepository struct {
+	db *gorm.DB
+}
+
+func NewActivityRepository(db *gorm.DB) *activityRepository {
+	return &activityRepository{db: db}
+}
+
+func (r *activityRepository) CreateActivity(activity models.Activity) (models.Activity, error) {
+	err := r.db.Debug().Table("activity").Create(&activity).Error
+	return activity, err
+}
+
+// GetLastDocumentActivity returns the most recent activity on the document
+func (r *activityRepository) GetLastDocumentActivity(documentId string) (models.Activity, error) {
+	var activity models.Activity
+	err := r.db.Debug().Table("activity").Where("document_id = ?", documentId).Order("created_at DESC").First(&activity).Error
+	return activity, err
+}
+
+// GetSpaceActivities returns a page of the activities of the space with the name and avatar of their user, the most recent first.
+// The activities of the excluded documents are left out.
+func (r *activityRepository) GetSpaceActivities(spaceId string, excludedDocumentIds []string, limit int, offset int) ([]models.Activity, int64, error) {
+	query := r.db.Debug().Table("activity").Where("activity.space_id = ?", spaceId)
+	if len(excludedDocumentIds) > 0 {
+		query = query.Where("activity.document_id NOT IN ?", excludedDocumentIds)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var activities []models.Activity
+	err := query.
+		Select(`activity.*, "user".name AS user_name, "user".avatar_url AS user_avatar_url`).
+		Joins(`LEFT JOIN "user" ON "user".id = activity.user_id`).
+		Order("activity.created_at DESC, activity.id").
+		Limit(limit).Offset(offset).
+		Find(&activities).Error
+	return activities, total, err
+}
+
+// TouchRecentDocument sets the last time the user viewed or edited the document
+func (r *activityRepository) TouchRecentDocument(userId string, documentId string, kind models.RecentKind, at time.Time) error {
+	recent := models.RecentDocument{UserId: userId, DocumentId: documentId}
+	column := "viewed_at"
+	if kind == models.RecentKindEdited {
+		column = "edited_at"
+		recent.EditedAt = &at
+	} else {
+		recent.ViewedAt = &at
+	}
+
+	return r.db.Debug().Table("recent_document").Omit("Document").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "document_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{column}),
+	}).Create(&recent).Error
+}
+
+// GetRecentDocuments returns the documents last viewed or edited by the user with their members,
+// the deleted documents are skipped. The content of the documents is not loaded.
+func (r *activityRepository) GetRecentDocuments(userId string, kind models.RecentKind, limit int) ([]models.RecentDocument, error) {
+	column := "recent_document.viewed_at"
+	if kind == models.RecentKindEdited {
+		column = "recent_document.edited_at"
+	}
+
+	var recents []models.RecentDocument
+	err := r.db.Debug().Table("recent_document").
+		Joins("JOIN document ON document.id = recent_document.document_id AND document.deleted_at IS NULL").
+		Where("recent_document.user_id = ? AND "+column+" IS NOT NULL", userId).
+		Preload("Document", func(db *gorm.DB) *gorm.DB {
+			return db.Omit("content")
+		}).
+		Order(column + " DESC").
+		Limit(limit).
+		Find(&recents).Error
+	if err != nil {
+		return recents, err
+	}
+
+	documents := make([]models.Document, len(recents))
+	for i, recent := range recents {
+		documents[i] = recent.Document
+	}
+	if err := loadDocumentMembers(r.db, documents); err != nil {
+		return recents, err
+	}
+	for i := range recents {
+		recents[i].Document.Members = documents[i].Members
+	}
+	return recents, nil
+}
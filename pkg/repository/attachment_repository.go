@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+type attachmentRepository struct {
+	db *gorm.DB
+}
+
+func NewAttachmentRepository(db *gorm.DB) *attachmentRepository {
+	return &attachmentRepository{db: db}
+}
+
+func (r *attachmentRepository) CreateAttachment(attachment models.Attachment) (models.Attachment, error) {
+	err := r.db.Debug().Table("attachment").Create(&attachment).Error
+	return attachment, err
+}
+
+func (r *attachmentRepository) GetAttachmentById(id string) (models.Attachment, error) {
+	var attachment models.Attachment
+	err := r.db.Debug().Table("attachment").First(&attachment, "id = ?", id).Error
+	return attachment, err
+}
+
+func (r *attachmentRepository) GetAttachmentsByDocumentId(documentId string) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	err := r.db.Debug().Table("attachment").Where("document_id = ?", documentId).Order("created_at").Find(&attachments).Error
+	return attachments, err
+}
+
+func (r *attachmentRepository) DeleteAttachment(id string) error {
+	return r.db.Debug().Table("attachment").Where("id = ?", id).Delete(&models.Attachment{}).Error
+}
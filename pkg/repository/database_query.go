@@ -0,0 +1,337 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// databaseQuery builds the sql of the database queries, the values of the properties are read
+// from the json properties column of the rows with json_each on sqlite, jsonb_array_elements on postgres
+// and json_table on mysql.
+// The filters, sorts and grouping must reference the properties by id.
+type databaseQuery struct {
+	dialect string
+	schema  models.PropertySchema
+}
+
+// column returns the expression of a property or a built-in column and the type of its values
+func (q databaseQuery) column(property string) (string, []any, models.PropertyType, error) {
+	switch property {
+	case models.RowColumnName:
+		return "document.name", nil, models.PropertyTypeText, nil
+	case models.RowColumnCreatedAt, models.RowColumnUpdatedAt:
+		return "document." + property, nil, models.PropertyTypeDate, nil
+	}
+
+	definition, ok := q.schema.Find(property, "")
+	if !ok {
+		return "", nil, "", fmt.Errorf("unknown property %s", property)
+	}
+	expr := "(SELECT p->>'value' FROM jsonb_array_elements(CASE WHEN jsonb_typeof(document.properties) = 'array' THEN document.properties ELSE '[]'::jsonb END) p WHERE p->>'id' = ?)"
+	switch q.dialect {
+	case "sqlite":
+		expr = "(SELECT json_extract(p.value, '$.value') FROM json_each(document.properties) p WHERE json_extract(p.value, '$.id') = ?)"
+	case "mysql":
+		// the lists are kept as json text and the json null is a null like on the other dialects
+		expr = "(SELECT CASE JSON_TYPE(p.value) WHEN 'NULL' THEN NULL WHEN 'ARRAY' THEN CAST(p.value AS CHAR) WHEN 'OBJECT' THEN CAST(p.value AS CHAR) ELSE JSON_UNQUOTE(p.value) END" +
+			" FROM JSON_TABLE(document.properties, '$[*]' COLUMNS (id VARCHAR(255) PATH '$.id', value JSON PATH '$.value')) p WHERE p.id = ?)"
+	}
+	if definition.Type.IsList() {
+		// an empty list is an empty value
+		expr = "NULLIF(" + expr + ", '[]')"
+	}
+	return expr, []any{definition.Id}, definition.ValueType(), nil
+}
+
+// typed casts the expression to compare the numbers as numbers
+func (q databaseQuery) typed(expr string, propertyType models.PropertyType) string {
+	if propertyType != models.PropertyTypeNumber {
+		return expr
+	}
+	switch q.dialect {
+	case "sqlite":
+		return "CAST(" + expr + " AS REAL)"
+	case "mysql":
+		return "CAST(" + expr + " AS DOUBLE)"
+	}
+	return "CAST(" + expr + " AS double precision)"
+}
+
+// listContains returns the condition checking that the json array contains the value
+func (q databaseQuery) listContains(expr string) string {
+	switch q.dialect {
+	case "sqlite":
+		return "EXISTS (SELECT 1 FROM json_each(" + expr + ") e WHERE e.value = ?)"
+	case "mysql":
+		return "JSON_CONTAINS(" + expr + ", JSON_QUOTE(?))"
+	}
+	return "EXISTS (SELECT 1 FROM jsonb_array_elements_text((" + expr + ")::jsonb) e WHERE e = ?)"
+}
+
+// like returns the case insensitive like condition of the expression with the pattern, the special characters
+// of the pattern are escaped with a backslash. The values read from json have a binary collation on mysql.
+func (q databaseQuery) like(expr string) string {
+	switch q.dialect {
+	case "sqlite":
+		return expr + " LIKE ? ESCAPE '\\'"
+	case "mysql":
+		// the backslash is the default escape character and can't be written in a literal without escaping it
+		return "LOWER(" + expr + ") LIKE LOWER(?)"
+	}
+	return expr + " ILIKE ? ESCAPE '\\'"
+}
+
+// filter returns the condition of the filter
+func (q databaseQuery) filter(filter models.DatabaseFilter) (string, []any, error) {
+	if len(filter.And) > 0 || len(filter.Or) > 0 {
+		filters, separator := filter.And, " AND "
+		if len(filter.Or) > 0 {
+			filters, separator = filter.Or, " OR "
+		}
+		var conditions []string
+		var args []any
+		for _, f := range filters {
+			condition, conditionArgs, err := q.filter(f)
+			if err != nil {
+				return "", nil, err
+			}
+			conditions = append(conditions, "("+condition+")")
+			args = append(args, conditionArgs...)
+		}
+		return strings.Join(conditions, separator), args, nil
+	}
+
+	expr, args, propertyType, err := q.column(filter.Property)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var value any = filter.Value
+	if propertyType == models.PropertyTypeDate && (filter.Property == models.RowColumnCreatedAt || filter.Property == models.RowColumnUpdatedAt) {
+		// the timestamps are compared as times because their text format depends on the driver
+		if t, err := parseFilterTime(filter.Value); err == nil {
+			value = t
+		}
+	}
+
+	// the values of the lists are json arrays, the equality and the contains check an element
+	if propertyType.IsList() {
+		switch filter.Operator {
+		case models.FilterOperatorEquals, models.FilterOperatorContains:
+			return q.listContains(expr), append(args, value), nil
+		case models.FilterOperatorNotEquals, models.FilterOperatorNotContains:
+			return expr + " IS NULL OR NOT " + q.listContains(expr), append(append(args, args...), value), nil
+		}
+	}
+
+	typed := q.typed(expr, propertyType)
+	switch filter.Operator {
+	case models.FilterOperatorEquals:
+		return typed + " = " + q.typed("?", propertyType), append(args, value), nil
+	case models.FilterOperatorNotEquals:
+		return expr + " IS NULL OR " + typed + " <> " + q.typed("?", propertyType), append(append(args, args...), value), nil
+	case models.FilterOperatorGreaterThan:
+		return typed + " > " + q.typed("?", propertyType), append(args, value), nil
+	case models.FilterOperatorGreaterOrEqual:
+		return typed + " >= " + q.typed("?", propertyType), append(args, value), nil
+	case models.FilterOperatorLessThan:
+		return typed + " < " + q.typed("?", propertyType), append(args, value), nil
+	case models.FilterOperatorLessOrEqual:
+		return typed + " <= " + q.typed("?", propertyType), append(args, value), nil
+	case models.FilterOperatorContains:
+		return q.like(expr), append(args, "%"+escapeLike(filter.Value)+"%"), nil
+	case models.FilterOperatorNotContains:
+		return expr + " IS NULL OR NOT (" + q.like(expr) + ")", append(append(args, args...), "%"+escapeLike(filter.Value)+"%"), nil
+	case models.FilterOperatorStartsWith:
+		return q.like(expr), append(args, escapeLike(filter.Value)+"%"), nil
+	case models.FilterOperatorEndsWith:
+		return q.like(expr), append(args, "%"+escapeLike(filter.Value)), nil
+	case models.FilterOperatorIsEmpty:
+		return expr + " IS NULL", args, nil
+	case models.FilterOperatorIsNotEmpty:
+		return expr + " IS NOT NULL", args, nil
+	}
+	return "", nil, fmt.Errorf("unknown operator %s", filter.Operator)
+}
+
+// order returns the order of the sorts, the empty values are last in both directions.
+// The rows are finally sorted by creation to keep the pages stable.
+func (q databaseQuery) order(sorts []models.DatabaseSort) (clause.OrderBy, error) {
+	var columns []string
+	var vars []any
+	for _, sort := range sorts {
+		expr, args, propertyType, err := q.column(sort.Property)
+		if err != nil {
+			return clause.OrderBy{}, err
+		}
+		direction := "ASC"
+		if sort.Direction == models.SortDirectionDescending {
+			direction = "DESC"
+		}
+		columns = append(columns, "("+expr+") IS NULL", q.typed(expr, propertyType)+" "+direction)
+		vars = append(append(vars, args...), args...)
+	}
+	columns = append(columns, "document.created_at", "document.id")
+
+	// a single expression is used because gorm drops an expression merged with other orders
+	return clause.OrderBy{Expression: clause.Expr{
+		SQL:                strings.Join(columns, ", "),
+		Vars:               vars,
+		WithoutParentheses: true,
+	}}, nil
+}
+
+// rows returns the query of the rows of the database matching the filter
+func (q databaseQuery) rows(db *gorm.DB, databaseId string, filter *models.DatabaseFilter, excludedIds []string) (*gorm.DB, error) {
+	query := db.Model(&models.Document{}).Where("document.parent_id = ?", databaseId)
+	if len(excludedIds) > 0 {
+		query = query.Where("document.id NOT IN ?", excludedIds)
+	}
+	if filter == nil {
+		return query, nil
+	}
+	condition, args, err := q.filter(*filter)
+	if err != nil {
+		return nil, err
+	}
+	if condition == "" {
+		return query, nil
+	}
+	return query.Where("("+condition+")", args...), nil
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(value)
+}
+
+func parseFilterTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// QueryDatabaseRows returns a page of the rows of the database and the total number of rows matching the filter,
+// the content of the rows is not loaded
+func (r *documentRepository) QueryDatabaseRows(databaseId string, schema models.PropertySchema, query models.DatabaseQuery) ([]models.Document, int64, error) {
+	q := databaseQuery{dialect: r.db.Dialector.Name(), schema: schema}
+
+	rows, err := q.rows(r.db.Debug(), databaseId, query.Filter, query.ExcludedIds)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := rows.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order, err := q.order(query.Sorts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	documents := []models.Document{}
+	err = rows.Order(order).Omit("content").Offset(query.Offset).Limit(query.Limit).Find(&documents).Error
+	return documents, total, err
+}
+
+// GroupDatabaseRows counts the rows of the database matching the query by value of its grouping property,
+// the rows are counted in each value of the lists. The group of the empty values is last like in the sorts.
+func (r *documentRepository) GroupDatabaseRows(databaseId string, schema models.PropertySchema, query models.DatabaseQuery) ([]models.DatabaseGroup, error) {
+	q := databaseQuery{dialect: r.db.Dialector.Name(), schema: schema}
+
+	expr, args, propertyType, err := q.column(query.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.rows(r.db.Debug(), databaseId, query.Filter, query.ExcludedIds)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []models.DatabaseGroup{}
+	if !propertyType.IsList() {
+		order := clause.OrderBy{Expression: clause.Expr{SQL: "MIN(" + q.typed(expr, propertyType) + ")", Vars: args, WithoutParentheses: true}}
+		err := rows.Select("COALESCE("+expr+", '') AS value, COUNT(*) AS count", args...).Group("value").Order(order).Scan(&groups).Error
+		for i, group := range groups {
+			if group.Value == "" {
+				groups = append(append(groups[:i:i], groups[i+1:]...), group)
+				break
+			}
+		}
+		return groups, err
+	}
+
+	var empty int64
+	if err := rows.Session(&gorm.Session{}).Where(expr+" IS NULL", args...).Count(&empty).Error; err != nil {
+		return nil, err
+	}
+
+	switch q.dialect {
+	case "sqlite":
+		rows = rows.Joins("CROSS JOIN json_each(COALESCE("+expr+", '[]')) e", args...)
+	case "mysql":
+		// json_table can't read a subquery, the property is joined first then the elements of its list
+		rows = rows.Joins("CROSS JOIN JSON_TABLE(document.properties, '$[*]' COLUMNS (id VARCHAR(255) PATH '$.id', list LONGTEXT PATH '$.value')) p").
+			Joins("CROSS JOIN JSON_TABLE(IF(JSON_VALID(p.list), p.list, '[]'), '$[*]' COLUMNS (value VARCHAR(1024) PATH '$')) e").
+			Where("p.id = ?", args...)
+	default:
+		rows = rows.Joins("CROSS JOIN LATERAL jsonb_array_elements_text(COALESCE(("+expr+")::jsonb, '[]'::jsonb)) e(value)", args...)
+	}
+	err = rows.Select("e.value AS value, COUNT(*) AS count").Group("e.value").Order("e.value").Scan(&groups).Error
+	if err == nil && empty > 0 {
+		groups = append(groups, models.DatabaseGroup{Value: "", Count: empty})
+	}
+	return groups, err
+}
+
+// GetDatabaseRows returns the rows of the database with the ids, including the deleted ones,
+// the content of the rows is not loaded
+func (r *documentRepository) GetDatabaseRows(databaseId string, ids []string) ([]models.Document, error) {
+	documents := []models.Document{}
+	if len(ids) == 0 {
+		return documents, nil
+	}
+	err := r.db.Debug().Unscoped().Table("document").Omit("content").Where("parent_id = ? AND id IN ?", databaseId, ids).Find(&documents).Error
+	return documents, err
+}
+
+// GetRestrictedDatabaseRows returns the rows of the database breaking the inheritance with their members,
+// the content of the rows is not loaded
+func (r *documentRepository) GetRestrictedDatabaseRows(databaseId string) ([]models.Document, error) {
+	documents := []models.Document{}
+	if err := r.db.Debug().Table("document").Omit("content").Where("parent_id = ? AND inheritance_broken = ?", databaseId, true).Find(&documents).Error; err != nil {
+		return documents, err
+	}
+	err := loadDocumentMembers(r.db, documents)
+	return documents, err
+}
+
+// GetRelatedDatabases returns the databases having a relation property to the database
+func (r *documentRepository) GetRelatedDatabases(databaseId string) ([]models.Document, error) {
+	var databases []models.Document
+	query := r.db.Debug().Table("document").Omit("content").Where("type = ?", models.DocumentTypeDatabase)
+	if r.db.Dialector.Name() == "sqlite" {
+		query = query.Where("EXISTS (SELECT 1 FROM json_each(document.schema) p WHERE json_extract(p.value, '$.relation.database_id') = ?)", databaseId)
+	} else {
+		relation, err := json.Marshal([]map[string]any{{"relation": map[string]string{"database_id": databaseId}}})
+		if err != nil {
+			return nil, err
+		}
+		if r.db.Dialector.Name() == "mysql" {
+			query = query.Where("JSON_CONTAINS(document.schema, ?)", string(relation))
+		} else {
+			query = query.Where("schema @> ?", string(relation))
+		}
+	}
+	err := query.Find(&databases).Error
+	return databases, err
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+type databaseViewRepository struct {
+	db *gorm.DB
+}
+
+func NewDatabaseViewRepository(db *gorm.DB) *databaseViewRepository {
+	return &databaseViewRepository{db: db}
+}
+
+func (r *databaseViewRepository) CreateView(view models.DatabaseView) (models.DatabaseView, error) {
+	err := r.db.Debug().Table("database_view").Create(&view).Error
+	return view, err
+}
+
+func (r *databaseViewRepository) GetViewById(id string) (models.DatabaseView, error) {
+	var view models.DatabaseView
+	err := r.db.Debug().Table("database_view").First(&view, "id = ?", id).Error
+	return view, err
+}
+
+func (r *databaseViewRepository) GetViewsByDatabaseId(databaseId string) ([]models.DatabaseView, error) {
+	var views []models.DatabaseView
+	err := r.db.Debug().Table("database_view").Where("database_id = ?", databaseId).Order("position, created_at").Find(&views).Error
+	return views, err
+}
+
+func (r *databaseViewRepository) UpdateView(view models.DatabaseView) (models.DatabaseView, error) {
+	err := r.db.Debug().Table("database_view").Where("id = ?", view.Id).Select("name", "type", "config", "position", "updated_at").Updates(&view).Error
+	return view, err
+}
+
+func (r *databaseViewRepository) DeleteView(id string) error {
+	return r.db.Debug().Table("database_view").Where("id = ?", id).Delete(&models.DatabaseView{}).Error
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/labbs/zotion/pkg/models"
 	"gorm.io/gorm"
 )
@@ -13,14 +15,71 @@ func NewDocumentRepository(db *gorm.DB) *documentRepository {
 	return &documentRepository{db: db}
 }
 
+// CreateDocument creates the document with its members
 func (r *documentRepository) CreateDocument(document models.Document) (models.Document, error) {
-	err := r.db.Debug().Table("document").Create(&document).Error
+	err := r.db.Debug().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("document").Create(&document).Error; err != nil {
+			return err
+		}
+		return replaceDocumentMembers(tx, document.Id, document.Members)
+	})
 	return document, err
 }
 
+// UpdateDocument saves the document if its version didn't change in the database, the members aren't saved.
+// The version is incremented, models.ErrVersionConflict is returned when the document was modified meanwhile.
 func (r *documentRepository) UpdateDocument(document models.Document) (models.Document, error) {
-	err := r.db.Debug().Table("document").Save(&document).Error
-	return document, err
+	version := document.Version
+	document.Version++
+	result := r.db.Debug().Model(&document).Where("version = ?", version).Select("*").Updates(&document)
+	if result.Error != nil {
+		return document, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return document, models.ErrVersionConflict
+	}
+	return document, nil
+}
+
+// UpdateDocumentContent only updates the content of a document, the hooks are skipped on purpose
+// because the members are not loaded.
+func (r *documentRepository) UpdateDocumentContent(id string, content string) error {
+	return r.db.Table("document").Where("id = ?", id).Updates(map[string]any{
+		"content":    content,
+		"version":    gorm.Expr("version + 1"),
+		"updated_at": time.Now(),
+	}).Error
+}
+
+// DeleteDocumentState removes the collaborative state of a document, the editors rebuild it from the content
+func (r *documentRepository) DeleteDocumentState(id string) error {
+	return r.db.Debug().Table("document_state").Where("document_id = ?", id).Delete(&models.DocumentState{}).Error
+}
+
+// UpdateDocumentSchema only updates the property schema of a database document
+func (r *documentRepository) UpdateDocumentSchema(id string, schema models.PropertySchema) error {
+	return r.db.Debug().Table("document").Where("id = ?", id).Updates(map[string]any{
+		"schema":     schema,
+		"version":    gorm.Expr("version + 1"),
+		"updated_at": time.Now(),
+	}).Error
+}
+
+// UpdateDocumentProperties only updates the properties of a document, it's used to migrate the rows of a database
+func (r *documentRepository) UpdateDocumentProperties(id string, properties models.Properties) error {
+	return r.db.Debug().Table("document").Where("id = ?", id).Updates(map[string]any{
+		"properties": properties,
+		"version":    gorm.Expr("version + 1"),
+		"updated_at": time.Now(),
+	}).Error
+}
+
+// Transaction runs the function with a repository whose writes are committed together,
+// they are rolled back when the function returns an error
+func (r *documentRepository) Transaction(fn func(documentRepository models.DocumentRepository) error) error {
+	return r.db.Debug().Transaction(func(tx *gorm.DB) error {
+		return fn(&documentRepository{db: tx})
+	})
 }
 
 func (r *documentRepository) DeleteDocument(id string) error {
@@ -29,26 +88,39 @@ func (r *documentRepository) DeleteDocument(id string) error {
 
 func (r *documentRepository) GetDocumentsFirstLevelForSpace(spaceId string) ([]models.Document, error) {
 	var documents []models.Document
-	err := r.db.Debug().Table("document").Where("space_id = ? AND (parent_id IS NULL OR parent_id = '')", spaceId).Find(&documents).Error
+	if err := r.db.Debug().Table("document").Where("space_id = ? AND (parent_id IS NULL OR parent_id = '')", spaceId).Find(&documents).Error; err != nil {
+		return documents, err
+	}
+	err := loadDocumentMembers(r.db, documents)
 	return documents, err
 }
 
 func (r *documentRepository) GetDocumentsFirstLevelByDocumentId(documentId string) ([]models.Document, error) {
 	var documents []models.Document
-	err := r.db.Debug().Table("document").Where("parent_id = ?", documentId).Find(&documents).Error
+	if err := r.db.Debug().Table("document").Where("parent_id = ?", documentId).Find(&documents).Error; err != nil {
+		return documents, err
+	}
+	err := loadDocumentMembers(r.db, documents)
 	return documents, err
 }
 
 func (r *documentRepository) GetDocumentById(id string) (models.Document, error) {
 	var document models.Document
 	err := r.db.Debug().Table("document").First(&document, "id = ?", id).Error
-	return document, err
+	return r.withMembers(document, err)
+}
+
+// GetDocumentByIdUnscoped returns the document even when it's deleted
+func (r *documentRepository) GetDocumentByIdUnscoped(id string) (models.Document, error) {
+	var document models.Document
+	err := r.db.Debug().Unscoped().Table("document").First(&document, "id = ?", id).Error
+	return r.withMembers(document, err)
 }
 
 func (r *documentRepository) GetDocumentBySlug(slug string) (models.Document, error) {
 	var document models.Document
 	err := r.db.Debug().Table("document").First(&document, "slug = ?", slug).Error
-	return document, err
+	return r.withMembers(document, err)
 }
 
 func (r *documentRepository) GetAllDocuments() ([]models.Document, error) {
@@ -59,7 +131,10 @@ func (r *documentRepository) GetAllDocuments() ([]models.Document, error) {
 
 func (r *documentRepository) GetAllDeletedDocument() ([]models.Document, error) {
 	var documents []models.Document
-	err := r.db.Debug().Table("document").Where("deleted_at IS NOT NULL").Find(&documents).Error
+	if err := r.db.Debug().Table("document").Where("deleted_at IS NOT NULL").Find(&documents).Error; err != nil {
+		return documents, err
+	}
+	err := loadDocumentMembers(r.db, documents)
 	return documents, err
 }
 
@@ -69,6 +144,114 @@ func (r *documentRepository) RestoreDocument(id string) error {
 
 func (r *documentRepository) GetDocumentsBySpaceId(spaceId string) ([]models.Document, error) {
 	var documents []models.Document
-	err := r.db.Debug().Table("document").Where("space_id = ?", spaceId).Find(&documents).Error
+	if err := r.db.Debug().Table("document").Where("space_id = ?", spaceId).Find(&documents).Error; err != nil {
+		return documents, err
+	}
+	err := loadDocumentMembers(r.db, documents)
 	return documents, err
 }
+
+// GetSpaceDocumentsUnscoped returns the documents of the space without their content, including the deleted ones
+func (r *documentRepository) GetSpaceDocumentsUnscoped(spaceId string) ([]models.Document, error) {
+	var documents []models.Document
+	if err := r.db.Debug().Unscoped().Table("document").Omit("content").Where("space_id = ?", spaceId).Find(&documents).Error; err != nil {
+		return documents, err
+	}
+	err := loadDocumentMembers(r.db, documents)
+	return documents, err
+}
+
+// GetChildDocumentIds returns the ids of the child documents, including the deleted ones
+func (r *documentRepository) GetChildDocumentIds(documentId string) ([]string, error) {
+	var ids []string
+	err := r.db.Debug().Unscoped().Table("document").Where("parent_id = ?", documentId).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// GetPublicDocuments returns the public documents of the space, of all the spaces when the space is empty
+func (r *documentRepository) GetPublicDocuments(spaceId string) ([]models.Document, error) {
+	var documents []models.Document
+	query := r.db.Debug().Table("document").Where("public = ?", true)
+	if spaceId != "" {
+		query = query.Where("space_id = ?", spaceId)
+	}
+	if err := query.Find(&documents).Error; err != nil {
+		return documents, err
+	}
+	err := loadDocumentMembers(r.db, documents)
+	return documents, err
+}
+
+// GetDocumentsForMember returns the documents the user or one of the groups is directly a member of
+func (r *documentRepository) GetDocumentsForMember(userId string, groups []models.Group) ([]models.Document, error) {
+	var documents []models.Document
+	members := r.db.Table("document_member").Select("document_member.document_id").Where(memberOf(r.db, "document_member", userId, groups))
+	if err := r.db.Debug().Table("document").Where("id IN (?)", members).Order("name").Find(&documents).Error; err != nil {
+		return documents, err
+	}
+	err := loadDocumentMembers(r.db, documents)
+	return documents, err
+}
+
+// GetRestrictedDocuments returns the documents of the spaces breaking the inheritance with their members,
+// the content of the documents is not loaded
+func (r *documentRepository) GetRestrictedDocuments(spaceIds []string) ([]models.Document, error) {
+	documents := []models.Document{}
+	if len(spaceIds) == 0 {
+		return documents, nil
+	}
+	if err := r.db.Debug().Table("document").Omit("content").Where("space_id IN ? AND inheritance_broken = ?", spaceIds, true).Find(&documents).Error; err != nil {
+		return documents, err
+	}
+	err := loadDocumentMembers(r.db, documents)
+	return documents, err
+}
+
+// UpdateDocumentMembers replaces the members of the document, the version isn't changed because the content is the same
+func (r *documentRepository) UpdateDocumentMembers(id string, members models.Members) error {
+	return r.db.Debug().Transaction(func(tx *gorm.DB) error {
+		if err := replaceDocumentMembers(tx, id, members); err != nil {
+			return err
+		}
+		return tx.Table("document").Where("id = ?", id).Update("updated_at", time.Now()).Error
+	})
+}
+
+// UpdateDocumentInheritance breaks or restores the inheritance of the access of the document with its new members
+func (r *documentRepository) UpdateDocumentInheritance(id string, inheritanceBroken bool, members models.Members) error {
+	return r.db.Debug().Transaction(func(tx *gorm.DB) error {
+		if err := replaceDocumentMembers(tx, id, members); err != nil {
+			return err
+		}
+		return tx.Table("document").Where("id = ?", id).Updates(map[string]any{"inheritance_broken": inheritanceBroken, "updated_at": time.Now()}).Error
+	})
+}
+
+// withMembers loads the members of the document found
+func (r *documentRepository) withMembers(document models.Document, err error) (models.Document, error) {
+	if err != nil {
+		return document, err
+	}
+	members, err := getMembers(r.db, "document_member", "document_id", []string{document.Id})
+	document.Members = members[document.Id]
+	return document, err
+}
+
+// PurgeDocument permanently deletes the document with its tags and its members, even if it was already deleted.
+// The purged document is returned.
+func (r *documentRepository) PurgeDocument(id string) (models.Document, error) {
+	var document models.Document
+	err := r.db.Debug().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Table("document").Omit("content").Where("id = ?", id).Limit(1).Find(&document).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("document_tag").Where("document_id = ?", id).Delete(&models.DocumentTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("document_id = ?", id).Delete(&models.DocumentMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Table("document").Where("id = ?", id).Delete(&models.Document{}).Error
+	})
+	return document, err
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+type documentStateRepository struct {
+	db *gorm.DB
+}
+
+func NewDocumentStateRepository(db *gorm.DB) *documentStateRepository {
+	return &documentStateRepository{db: db}
+}
+
+// GetState returns the collaborative state of a document
+func (r *documentStateRepository) GetState(documentId string) (models.DocumentState, error) {
+	var state models.DocumentState
+	err := r.db.Table("document_state").First(&state, "document_id = ?", documentId).Error
+	return state, err
+}
+
+// SaveState creates or replaces the collaborative state of a document
+func (r *documentStateRepository) SaveState(state models.DocumentState) error {
+	return r.db.Table("document_state").Save(&state).Error
+}
+
+// DeleteState removes the collaborative state of a document
+func (r *documentStateRepository) DeleteState(documentId string) error {
+	return r.db.Table("document_state").Where("document_id = ?", documentId).Delete(&models.DocumentState{}).Error
+}
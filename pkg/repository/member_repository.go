@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// memberBatchSize is the maximum number of owners whose members are loaded by a query
+const memberBatchSize = 500
+
+// memberRow is a row of the space_member or document_member table with the id of its owner
+type memberRow struct {
+	OwnerId    string
+	MemberType models.MemberType
+	MemberId   string
+	Access     models.AccessType
+}
+
+// getMembers returns the members of the owners by owner id, read from the member table of the owners.
+// The owners without member are not in the map.
+func getMembers(db *gorm.DB, table string, ownerColumn string, ids []string) (map[string]models.Members, error) {
+	members := map[string]models.Members{}
+	for start := 0; start < len(ids); start += memberBatchSize {
+		end := min(start+memberBatchSize, len(ids))
+
+		var rows []memberRow
+		err := db.Table(table).
+			Select(ownerColumn+" AS owner_id", "member_type", "member_id", "access").
+			Where(ownerColumn+" IN ?", ids[start:end]).
+			Order(ownerColumn).Order("position").
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			members[row.OwnerId] = append(members[row.OwnerId], models.Member{Id: row.MemberId, Type: row.MemberType, Access: row.Access})
+		}
+	}
+	return members, nil
+}
+
+// memberOf returns the condition matching the rows of the member table of the user or of one of the groups
+func memberOf(db *gorm.DB, table string, userId string, groups []models.Group) *gorm.DB {
+	condition := db.Where(table+".member_type = ? AND "+table+".member_id = ?", models.MemberTypeUser, userId)
+	if len(groups) > 0 {
+		groupIds := make([]string, 0, len(groups))
+		for _, group := range groups {
+			groupIds = append(groupIds, group.Id)
+		}
+		condition = condition.Or(table+".member_type = ? AND "+table+".member_id IN ?", models.MemberTypeGroup, groupIds)
+	}
+	return condition
+}
+
+// loadSpaceMembers sets the members of the spaces
+func loadSpaceMembers(db *gorm.DB, spaces []models.Space) error {
+	ids := make([]string, 0, len(spaces))
+	for _, space := range spaces {
+		ids = append(ids, space.Id)
+	}
+	members, err := getMembers(db, "space_member", "space_id", ids)
+	if err != nil {
+		return err
+	}
+	for i := range spaces {
+		spaces[i].Members = members[spaces[i].Id]
+	}
+	return nil
+}
+
+// loadDocumentMembers sets the members of the documents
+func loadDocumentMembers(db *gorm.DB, documents []models.Document) error {
+	ids := make([]string, 0, len(documents))
+	for _, document := range documents {
+		ids = append(ids, document.Id)
+	}
+	members, err := getMembers(db, "document_member", "document_id", ids)
+	if err != nil {
+		return err
+	}
+	for i := range documents {
+		documents[i].Members = members[documents[i].Id]
+	}
+	return nil
+}
+
+// replaceSpaceMembers replaces the members of the space, it should run in a transaction
+func replaceSpaceMembers(tx *gorm.DB, spaceId string, members models.Members) error {
+	if err := tx.Where("space_id = ?", spaceId).Delete(&models.SpaceMember{}).Error; err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(models.NewSpaceMembers(spaceId, members)).Error
+}
+
+// replaceDocumentMembers replaces the members of the document, it should run in a transaction
+func replaceDocumentMembers(tx *gorm.DB, documentId string, members models.Members) error {
+	if err := tx.Where("document_id = ?", documentId).Delete(&models.DocumentMember{}).Error; err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(models.NewDocumentMembers(documentId, members)).Error
+}
@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) *notificationRepository {
+	return &notificationRepository{db: db}
+}
+
+func (r *notificationRepository) CreateNotification(notification models.Notification) (models.Notification, error) {
+	err := r.db.Debug().Table("notification").Create(&notification).Error
+	return notification, err
+}
+
+// GetUnreadNotification returns the unread notification of the user with the type about the document
+func (r *notificationRepository) GetUnreadNotification(userId string, documentId string, notificationType models.NotificationType) (models.Notification, error) {
+	var notification models.Notification
+	err := r.db.Debug().Table("notification").
+		Where("user_id = ? AND document_id = ? AND type = ? AND read_at IS NULL", userId, documentId, notificationType).
+		Order("created_at DESC").
+		First(&notification).Error
+	return notification, err
+}
+
+func (r *notificationRepository) UpdateNotification(notification models.Notification) (models.Notification, error) {
+	err := r.db.Debug().Table("notification").Where("id = ?", notification.Id).Save(&notification).Error
+	return notification, err
+}
+
+// GetNotifications returns a page of the notifications of the user with the name and avatar of their actor,
+// the most recent first, with the total number of notifications and the number of unread ones
+func (r *notificationRepository) GetNotifications(userId string, unreadOnly bool, limit int, offset int) ([]models.Notification, int64, int64, error) {
+	var unread int64
+	if err := r.db.Debug().Table("notification").Where("user_id = ? AND read_at IS NULL", userId).Count(&unread).Error; err != nil {
+		return nil, 0, 0, err
+	}
+
+	query := r.db.Debug().Table("notification").Where("notification.user_id = ?", userId)
+	if unreadOnly {
+		query = query.Where("notification.read_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, 0, err
+	}
+
+	var notifications []models.Notification
+	err := query.
+		Select(`notification.*, "user".name AS actor_name, "user".avatar_url AS actor_avatar_url`).
+		Joins(`LEFT JOIN "user" ON "user".id = notification.actor_id`).
+		Order("notification.updated_at DESC, notification.id").
+		Limit(limit).Offset(offset).
+		Find(&notifications).Error
+	return notifications, total, unread, err
+}
+
+// SetNotificationsRead sets the read time of the notifications of the user, all of them without ids.
+// A nil time marks the notifications as unread.
+func (r *notificationRepository) SetNotificationsRead(userId string, ids []string, readAt *time.Time) error {
+	query := r.db.Debug().Table("notification").Where("user_id = ?", userId)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	if readAt != nil {
+		query = query.Where("read_at IS NULL")
+	}
+	return query.Update("read_at", readAt).Error
+}
+
+// GetUnmailedNotifications returns the unread notifications not sent by email yet, grouped by user.
+// Only the notifications of the active users with an email and a digest frequency of the list are returned.
+func (r *notificationRepository) GetUnmailedNotifications(frequencies []models.DigestFrequency) ([]models.Notification, error) {
+	// the frequency is read from the notification preferences of the recipient
+	frequency := "recipient.preferences->'notifications'->>'email_digest'"
+	switch r.db.Dialector.Name() {
+	case "sqlite":
+		frequency = "json_extract(recipient.preferences, '$.notifications.email_digest')"
+	case "mysql":
+		frequency = "JSON_UNQUOTE(JSON_EXTRACT(recipient.preferences, '$.notifications.email_digest'))"
+	}
+
+	var notifications []models.Notification
+	err := r.db.Debug().Table("notification").
+		Select(`notification.*, "user".name AS actor_name`).
+		Joins(`LEFT JOIN "user" ON "user".id = notification.actor_id`).
+		Joins(`JOIN "user" recipient ON recipient.id = notification.user_id`).
+		Where("notification.read_at IS NULL AND notification.emailed_at IS NULL").
+		Where("recipient.active = ? AND recipient.email <> ''", true).
+		Where(frequency+" IN ?", frequencies).
+		Order("notification.user_id, notification.updated_at").
+		Find(&notifications).Error
+	return notifications, err
+}
+
+// GetLastEmailedAt returns the last time a notification was sent by email to the user, nil if never
+func (r *notificationRepository) GetLastEmailedAt(userId string) (*time.Time, error) {
+	var notification models.Notification
+	err := r.db.Debug().Table("notification").
+		Where("user_id = ? AND emailed_at IS NOT NULL", userId).
+		Order("emailed_at DESC").
+		First(&notification).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return notification.EmailedAt, nil
+}
+
+// ClaimNotificationEmail sets the time the notification is sent by email,
+// false is returned when another instance claimed it first
+func (r *notificationRepository) ClaimNotificationEmail(id string, at time.Time) (bool, error) {
+	result := r.db.Debug().Table("notification").Where("id = ? AND emailed_at IS NULL", id).Update("emailed_at", at)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ReleaseNotificationEmails clears the claims of the notifications which couldn't be sent by email
+func (r *notificationRepository) ReleaseNotificationEmails(ids []string) error {
+	return r.db.Debug().Table("notification").Where("id IN ?", ids).Update("emailed_at", nil).Error
+}
+
+// Subscribe adds the document to the pages followed by the user, nothing is done if it's already followed
+func (r *notificationRepository) Subscribe(subscription models.Subscription) error {
+	return r.db.Debug().Table("subscription").Omit("Document").Clauses(clause.OnConflict{DoNothing: true}).Create(&subscription).Error
+}
+
+func (r *notificationRepository) Unsubscribe(userId string, documentId string) error {
+	return r.db.Debug().Table("subscription").Where("user_id = ? AND document_id = ?", userId, documentId).Delete(&models.Subscription{}).Error
+}
+
+func (r *notificationRepository) IsSubscribed(userId string, documentId string) (bool, error) {
+	var count int64
+	err := r.db.Debug().Table("subscription").Where("user_id = ? AND document_id = ?", userId, documentId).Count(&count).Error
+	return count > 0, err
+}
+
+// GetSubscribers returns the ids of the users following the document
+func (r *notificationRepository) GetSubscribers(documentId string) ([]string, error) {
+	var userIds []string
+	err := r.db.Debug().Table("subscription").Where("document_id = ?", documentId).Pluck("user_id", &userIds).Error
+	return userIds, err
+}
+
+// GetSubscriptions returns the pages followed by the user, the deleted documents are skipped.
+// The content of the documents is not loaded.
+func (r *notificationRepository) GetSubscriptions(userId string) ([]models.Subscription, error) {
+	var subscriptions []models.Subscription
+	err := r.db.Debug().Table("subscription").
+		Joins("JOIN document ON document.id = subscription.document_id AND document.deleted_at IS NULL").
+		Where("subscription.user_id = ?", userId).
+		Preload("Document", func(db *gorm.DB) *gorm.DB {
+			return db.Omit("content")
+		}).
+		Order("subscription.created_at DESC").
+		Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+func (r *notificationRepository) CreateReminder(reminder models.Reminder) (models.Reminder, error) {
+	err := r.db.Debug().Table("reminder").Create(&reminder).Error
+	return reminder, err
+}
+
+// GetReminders returns the reminders of the user, the next ones first
+func (r *notificationRepository) GetReminders(userId string) ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	err := r.db.Debug().Table("reminder").Where("user_id = ?", userId).Order("remind_at").Find(&reminders).Error
+	return reminders, err
+}
+
+// DeleteReminder deletes the reminder of the user, gorm.ErrRecordNotFound is returned when the user has no such reminder
+func (r *notificationRepository) DeleteReminder(userId string, id string) error {
+	result := r.db.Debug().Table("reminder").Where("id = ? AND user_id = ?", id, userId).Delete(&models.Reminder{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetDueReminders returns the reminders not fired yet whose time is passed
+func (r *notificationRepository) GetDueReminders(now time.Time) ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	err := r.db.Debug().Table("reminder").Where("fired_at IS NULL AND remind_at <= ?", now).Order("remind_at").Find(&reminders).Error
+	return reminders, err
+}
+
+// ClaimReminder sets the time the reminder is fired, false is returned when another instance claimed it first
+func (r *notificationRepository) ClaimReminder(id string, at time.Time) (bool, error) {
+	result := r.db.Debug().Table("reminder").Where("id = ? AND fired_at IS NULL", id).Update("fired_at", at)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ReleaseReminder clears the claim of a reminder which couldn't be fired
+func (r *notificationRepository) ReleaseReminder(id string) error {
+	return r.db.Debug().Table("reminder").Where("id = ?", id).Update("fired_at", nil).Error
+}
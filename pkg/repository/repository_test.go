@@ -0,0 +1,581 @@
+package repository_test
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/labbs/zotion/internal/database"
+	"github.com/labbs/zotion/internal/migration"
+	"github.com/labbs/zotion/pkg/caching"
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/labbs/zotion/pkg/logger"
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/labbs/zotion/pkg/repository"
+	"gorm.io/gorm"
+)
+
+// The tests of the repositories run against the database of TEST_DATABASE_DIALECT (sqlite, postgres or mysql)
+// and TEST_DATABASE_DSN once the migrations are applied, they are skipped without database. The data written
+// by the tests is deleted at the end, they should be run against a scratch database:
+//
+//	TEST_DATABASE_DIALECT=sqlite TEST_DATABASE_DSN=/tmp/zotion.db go test ./pkg/repository
+
+// testDb is the database of the tests, nil when no database is configured
+var testDb *gorm.DB
+
+func TestMain(m *testing.M) {
+	dialect, dsn := os.Getenv("TEST_DATABASE_DIALECT"), os.Getenv("TEST_DATABASE_DSN")
+	if dsn != "" {
+		// the migrations and the json queries follow the dialect of the configuration
+		config.Database.Dialect = dialect
+		config.Database.DSN = dsn
+
+		l := logger.NewLogger("warn", true, "test")
+		testDb = database.NewGorm(l, dialect, dsn)
+
+		// the models keep the members in the cache, a local cache keeps the tests away from a shared one
+		caching.Cache = caching.NewMemoryCache(1000, time.Minute)
+
+		if err := migration.RunMigration(l, testDb); err != nil {
+			fmt.Fprintf(os.Stderr, "migration of the test database failed: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	os.Exit(m.Run())
+}
+
+// fixture is the data of a test: a user in a group, a space with both as members and a document of the user
+type fixture struct {
+	t  *testing.T
+	db *gorm.DB
+
+	userRepository         models.UserRepository
+	groupRepository        models.GroupRepository
+	spaceRepository        models.SpaceRepository
+	documentRepository     models.DocumentRepository
+	tagRepository          models.TagRepository
+	notificationRepository models.NotificationRepository
+	activityRepository     models.ActivityRepository
+
+	// suffix makes the unique names of the data of a test
+	suffix string
+
+	user     models.User
+	group    models.Group
+	space    models.Space
+	document models.Document
+}
+
+// newFixture writes the data of the fixture, the test is skipped without database
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	if testDb == nil {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	f := &fixture{
+		t:                      t,
+		db:                     testDb,
+		userRepository:         repository.NewUserRepository(testDb),
+		groupRepository:        repository.NewGroupRepository(testDb),
+		spaceRepository:        repository.NewSpaceRepository(testDb),
+		documentRepository:     repository.NewDocumentRepository(testDb),
+		tagRepository:          repository.NewTagRepository(testDb),
+		notificationRepository: repository.NewNotificationRepository(testDb),
+		activityRepository:     repository.NewActivityRepository(testDb),
+		suffix:                 utils.UUIDv4()[:8],
+	}
+
+	f.user = models.User{
+		Name:     "test-" + f.suffix,
+		Email:    "test-" + f.suffix + "@zotion.local",
+		Password: "test",
+		Active:   true,
+	}
+	err := f.userRepository.Create(&f.user)
+	if f.user.Id != "" {
+		f.deleted(`DELETE FROM "user" WHERE id = ?`, f.user.Id)
+	}
+	f.check(err)
+
+	f.group, err = f.groupRepository.Create(models.Group{Name: "test-" + f.suffix, Role: models.RoleUser})
+	if f.group.Id != "" {
+		f.deleted(`DELETE FROM "group" WHERE id = ?`, f.group.Id)
+	}
+	f.check(err)
+	f.deleted(`DELETE FROM user_group WHERE user_id = ?`, f.user.Id)
+	f.check(testDb.Create(&models.UserGroup{UserId: f.user.Id, GroupId: f.group.Id, CreatedAt: time.Now()}).Error)
+
+	f.space, err = f.spaceRepository.CreateSpace(models.Space{
+		Name: "test-" + f.suffix,
+		Type: models.SpaceTypePrivate,
+		Members: models.Members{
+			{Id: f.user.Id, Type: models.MemberTypeUser, Access: models.AccessTypeEditor},
+			{Id: f.group.Id, Type: models.MemberTypeGroup, Access: models.AccessTypeViewer},
+		},
+	})
+	if f.space.Id != "" {
+		f.deleted(`DELETE FROM space_member WHERE space_id = ?`, f.space.Id)
+		f.deleted(`DELETE FROM space WHERE id = ?`, f.space.Id)
+	}
+	f.check(err)
+
+	f.document = f.createDocument(models.Document{
+		Name:    "Test",
+		Members: models.Members{{Id: f.user.Id, Type: models.MemberTypeUser, Access: models.AccessTypeFull}},
+	})
+	return f
+}
+
+// check stops the test on the errors writing the data of the fixture
+func (f *fixture) check(err error) {
+	f.t.Helper()
+	if err != nil {
+		f.t.Fatalf("writing the test data failed: %v", err)
+	}
+}
+
+// deleted registers a statement deleting data written by the test, the statements run in reverse order
+func (f *fixture) deleted(query string, args ...any) {
+	f.t.Cleanup(func() {
+		if err := f.db.Exec(query, args...).Error; err != nil {
+			f.t.Errorf("deleting the test data failed: %v", err)
+		}
+	})
+}
+
+// createDocument creates a document in the space of the fixture
+func (f *fixture) createDocument(document models.Document) models.Document {
+	f.t.Helper()
+	document.SpaceId = f.space.Id
+	if document.Type == "" {
+		document.Type = models.DocumentTypeDocument
+	}
+	document, err := f.documentRepository.CreateDocument(document)
+	if document.Id != "" {
+		f.deleted(`DELETE FROM document_member WHERE document_id = ?`, document.Id)
+		f.deleted(`DELETE FROM document WHERE id = ?`, document.Id)
+	}
+	f.check(err)
+	return document
+}
+
+func TestUsers(t *testing.T) {
+	f := newFixture(t)
+
+	user, err := f.userRepository.GetByEmail(f.user.Email)
+	if err != nil {
+		t.Fatalf("GetByEmail() unexpected error: %v", err)
+	}
+	if user.Id != f.user.Id {
+		t.Errorf("GetByEmail() = %s, want %s", user.Id, f.user.Id)
+	}
+
+	groups, err := f.userRepository.GetGroupsByUserId(f.user.Id)
+	if err != nil {
+		t.Fatalf("GetGroupsByUserId() unexpected error: %v", err)
+	}
+	if len(groups) != 1 || groups[0].Id != f.group.Id {
+		t.Errorf("GetGroupsByUserId() = %v, want %s", groups, f.group.Id)
+	}
+}
+
+func TestSpaces(t *testing.T) {
+	f := newFixture(t)
+
+	stored, err := f.spaceRepository.GetSpaceById(f.space.Id)
+	if err != nil {
+		t.Fatalf("GetSpaceById() unexpected error: %v", err)
+	}
+	if len(stored.Members) != 2 || stored.Members[0].Id != f.user.Id || stored.Members[1].Id != f.group.Id {
+		t.Errorf("members of the space not kept in order: %v", stored.Members)
+	}
+
+	// the space is found through the group only
+	spaces, err := f.spaceRepository.GetSpacesForUser(utils.UUIDv4(), []models.Group{f.group})
+	if err != nil {
+		t.Fatalf("GetSpacesForUser() unexpected error: %v", err)
+	}
+	if !slices.ContainsFunc(spaces, func(s models.Space) bool { return s.Id == f.space.Id }) {
+		t.Errorf("GetSpacesForUser() = %v, want the space %s of the group", spaces, f.space.Id)
+	}
+
+	member, err := f.spaceRepository.IsMember(f.space.Id, f.user.Id)
+	if err != nil {
+		t.Fatalf("IsMember() unexpected error: %v", err)
+	}
+	if !member {
+		t.Errorf("IsMember() = false, want true")
+	}
+}
+
+func TestDocuments(t *testing.T) {
+	f := newFixture(t)
+
+	// the content is larger than the text types of mysql
+	content := strings.Repeat("zotion ", 16*1024)
+	document := f.createDocument(models.Document{
+		Name:    "Large",
+		Content: content,
+		Members: models.Members{{Id: f.user.Id, Type: models.MemberTypeUser, Access: models.AccessTypeFull}},
+	})
+
+	stored, err := f.documentRepository.GetDocumentById(document.Id)
+	if err != nil {
+		t.Fatalf("GetDocumentById() unexpected error: %v", err)
+	}
+	if stored.Content != content {
+		t.Errorf("content of the document truncated to %d bytes", len(stored.Content))
+	}
+	if len(stored.Members) != 1 || stored.Members[0].Access != models.AccessTypeFull {
+		t.Errorf("members of the document not kept: %v", stored.Members)
+	}
+
+	// the members are replaced, the document is then found through the group
+	err = f.documentRepository.UpdateDocumentMembers(document.Id, models.Members{{Id: f.group.Id, Type: models.MemberTypeGroup, Access: models.AccessTypeViewer}})
+	if err != nil {
+		t.Fatalf("UpdateDocumentMembers() unexpected error: %v", err)
+	}
+	documents, err := f.documentRepository.GetDocumentsForMember(utils.UUIDv4(), []models.Group{f.group})
+	if err != nil {
+		t.Fatalf("GetDocumentsForMember() unexpected error: %v", err)
+	}
+	if len(documents) != 1 || documents[0].Id != document.Id {
+		t.Fatalf("GetDocumentsForMember() = %d documents, want %s", len(documents), document.Id)
+	}
+	if len(documents[0].Members) != 1 || documents[0].Members[0].Id != f.group.Id {
+		t.Errorf("members of the document not replaced: %v", documents[0].Members)
+	}
+
+	if err := f.documentRepository.UpdateDocumentContent(document.Id, "updated"); err != nil {
+		t.Fatalf("UpdateDocumentContent() unexpected error: %v", err)
+	}
+	stored, err = f.documentRepository.GetDocumentById(document.Id)
+	if err != nil {
+		t.Fatalf("GetDocumentById() unexpected error: %v", err)
+	}
+	if stored.Content != "updated" || stored.Version != document.Version+1 {
+		t.Errorf("document not updated: version %d", stored.Version)
+	}
+
+	// the collaborative state is removed with the document repository
+	stateRepository := repository.NewDocumentStateRepository(f.db)
+	f.deleted(`DELETE FROM document_state WHERE document_id = ?`, document.Id)
+	if err := stateRepository.SaveState(models.DocumentState{DocumentId: document.Id, State: []byte{0}}); err != nil {
+		t.Fatalf("SaveState() unexpected error: %v", err)
+	}
+	if err := f.documentRepository.DeleteDocumentState(document.Id); err != nil {
+		t.Fatalf("DeleteDocumentState() unexpected error: %v", err)
+	}
+	if _, err := stateRepository.GetState(document.Id); err != gorm.ErrRecordNotFound {
+		t.Errorf("GetState() error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+
+	// the deleted documents are only found unscoped
+	if err := f.documentRepository.DeleteDocument(document.Id); err != nil {
+		t.Fatalf("DeleteDocument() unexpected error: %v", err)
+	}
+	if _, err := f.documentRepository.GetDocumentById(document.Id); err != gorm.ErrRecordNotFound {
+		t.Errorf("GetDocumentById() error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+	if _, err := f.documentRepository.GetDocumentByIdUnscoped(document.Id); err != nil {
+		t.Errorf("GetDocumentByIdUnscoped() unexpected error: %v", err)
+	}
+}
+
+func TestDatabaseQueries(t *testing.T) {
+	f := newFixture(t)
+
+	schema := models.PropertySchema{
+		{Id: "text", Name: "Text", Type: models.PropertyTypeText},
+		{Id: "number", Name: "Number", Type: models.PropertyTypeNumber},
+		{Id: "tags", Name: "Tags", Type: models.PropertyTypeMultiSelect},
+	}
+	database := f.createDocument(models.Document{Name: "Test database", Type: models.DocumentTypeDatabase, Schema: schema})
+
+	rows := []struct {
+		name       string
+		properties models.Properties
+		restricted bool
+	}{
+		{"Alpha", models.Properties{{Id: "text", Value: "Alpha"}, {Id: "number", Value: "10"}, {Id: "tags", Value: `["red","blue"]`}}, false},
+		{"Beta", models.Properties{{Id: "text", Value: "beta 100%"}, {Id: "number", Value: "2"}, {Id: "tags", Value: `["blue"]`}}, true},
+		// the unset values are not in the properties and an empty list is empty
+		{"Gamma", models.Properties{{Id: "tags", Value: `[]`}}, false},
+	}
+	names := map[string]string{}
+	ids := map[string]string{}
+	for _, row := range rows {
+		document := f.createDocument(models.Document{Name: row.name, ParentId: database.Id, Properties: row.properties, InheritanceBroken: row.restricted})
+		names[document.Id] = row.name
+		ids[row.name] = document.Id
+	}
+
+	tests := []struct {
+		name     string
+		filter   *models.DatabaseFilter
+		sorts    []models.DatabaseSort
+		excluded []string
+		want     []string
+	}{
+		// the numbers are compared as numbers, 10 is greater than 5
+		{"number greater than", &models.DatabaseFilter{Property: "number", Operator: models.FilterOperatorGreaterThan, Value: "5"}, nil, nil, []string{"Alpha"}},
+		{"text contains any case", &models.DatabaseFilter{Property: "text", Operator: models.FilterOperatorContains, Value: "ALP"}, nil, nil, []string{"Alpha"}},
+		{"text starts with", &models.DatabaseFilter{Property: "text", Operator: models.FilterOperatorStartsWith, Value: "Be"}, nil, nil, []string{"Beta"}},
+		{"text ends with a percent", &models.DatabaseFilter{Property: "text", Operator: models.FilterOperatorEndsWith, Value: "0%"}, nil, nil, []string{"Beta"}},
+		{"underscore not a wildcard", &models.DatabaseFilter{Property: "text", Operator: models.FilterOperatorContains, Value: "a_p"}, nil, nil, []string{}},
+		{"text is empty", &models.DatabaseFilter{Property: "text", Operator: models.FilterOperatorIsEmpty}, nil, nil, []string{"Gamma"}},
+		{"list contains", &models.DatabaseFilter{Property: "tags", Operator: models.FilterOperatorContains, Value: "blue"}, nil, nil, []string{"Alpha", "Beta"}},
+		{"list doesn't contain", &models.DatabaseFilter{Property: "tags", Operator: models.FilterOperatorNotContains, Value: "red"}, nil, nil, []string{"Beta", "Gamma"}},
+		{"or", &models.DatabaseFilter{Or: []models.DatabaseFilter{
+			{Property: "number", Operator: models.FilterOperatorLessOrEqual, Value: "2"},
+			{Property: "name", Operator: models.FilterOperatorEquals, Value: "Gamma"},
+		}}, nil, nil, []string{"Beta", "Gamma"}},
+		// the empty values are last in both directions
+		{"descending with empty values", nil, []models.DatabaseSort{{Property: "number", Direction: models.SortDirectionDescending}}, nil, []string{"Alpha", "Beta", "Gamma"}},
+		{"ascending with empty values", nil, []models.DatabaseSort{{Property: "number", Direction: models.SortDirectionAscending}}, nil, []string{"Beta", "Alpha", "Gamma"}},
+		{"excluded rows", nil, []models.DatabaseSort{{Property: "number", Direction: models.SortDirectionAscending}}, []string{ids["Beta"]}, []string{"Alpha", "Gamma"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			documents, total, err := f.documentRepository.QueryDatabaseRows(database.Id, schema, models.DatabaseQuery{Filter: tt.filter, Sorts: tt.sorts, Limit: 10, ExcludedIds: tt.excluded})
+			if err != nil {
+				t.Fatalf("QueryDatabaseRows() unexpected error: %v", err)
+			}
+			got := []string{}
+			for _, document := range documents {
+				got = append(got, names[document.Id])
+			}
+			if tt.sorts == nil {
+				slices.Sort(got)
+			}
+			if !slices.Equal(got, tt.want) || total != int64(len(tt.want)) {
+				t.Errorf("QueryDatabaseRows() = %v (total %d), want %v", got, total, tt.want)
+			}
+		})
+	}
+
+	groups := []struct {
+		name     string
+		property string
+		excluded []string
+		want     []models.DatabaseGroup
+	}{
+		{"group by list", "tags", nil, []models.DatabaseGroup{{Value: "blue", Count: 2}, {Value: "red", Count: 1}, {Value: "", Count: 1}}},
+		{"group by text", "text", nil, []models.DatabaseGroup{{Value: "Alpha", Count: 1}, {Value: "beta 100%", Count: 1}, {Value: "", Count: 1}}},
+		{"group without the excluded rows", "tags", []string{ids["Beta"]}, []models.DatabaseGroup{{Value: "blue", Count: 1}, {Value: "red", Count: 1}, {Value: "", Count: 1}}},
+	}
+	for _, tt := range groups {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := f.documentRepository.GroupDatabaseRows(database.Id, schema, models.DatabaseQuery{GroupBy: tt.property, ExcludedIds: tt.excluded})
+			if err != nil {
+				t.Fatalf("GroupDatabaseRows() unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("GroupDatabaseRows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	restricted, err := f.documentRepository.GetRestrictedDatabaseRows(database.Id)
+	if err != nil {
+		t.Fatalf("GetRestrictedDatabaseRows() unexpected error: %v", err)
+	}
+	if len(restricted) != 1 || restricted[0].Id != ids["Beta"] {
+		t.Errorf("GetRestrictedDatabaseRows() = %d rows, want %s", len(restricted), ids["Beta"])
+	}
+
+	related := f.createDocument(models.Document{
+		Name:   "Test related",
+		Type:   models.DocumentTypeDatabase,
+		Schema: models.PropertySchema{{Id: "relation", Name: "Relation", Type: models.PropertyTypeRelation, Relation: &models.RelationConfig{DatabaseId: database.Id}}},
+	})
+	databases, err := f.documentRepository.GetRelatedDatabases(database.Id)
+	if err != nil {
+		t.Fatalf("GetRelatedDatabases() unexpected error: %v", err)
+	}
+	if len(databases) != 1 || databases[0].Id != related.Id {
+		t.Errorf("GetRelatedDatabases() = %d databases, want %s", len(databases), related.Id)
+	}
+}
+
+func TestTags(t *testing.T) {
+	f := newFixture(t)
+
+	var tags []models.Tag
+	for _, name := range []string{"Test", "Test merged"} {
+		tag, err := f.tagRepository.CreateTag(models.Tag{Id: utils.UUIDv4(), Name: name + " " + f.suffix, SpaceId: f.space.Id, CreatedBy: f.user.Id})
+		f.deleted(`DELETE FROM tag WHERE id = ?`, tag.Id)
+		f.deleted(`DELETE FROM document_tag WHERE tag_id = ?`, tag.Id)
+		f.check(err)
+		// the tags are added twice, the second time is ignored
+		for range 2 {
+			if err := f.tagRepository.AddDocumentTag(models.DocumentTag{DocumentId: f.document.Id, TagId: tag.Id, CreatedBy: f.user.Id, CreatedAt: time.Now()}); err != nil {
+				t.Fatalf("AddDocumentTag() unexpected error: %v", err)
+			}
+		}
+		tags = append(tags, tag)
+	}
+
+	// the names are unique in a space whatever their case
+	tag, err := f.tagRepository.GetTagByName(f.space.Id, strings.ToUpper(tags[0].Name))
+	if err != nil {
+		t.Fatalf("GetTagByName() unexpected error: %v", err)
+	}
+	if tag.Id != tags[0].Id {
+		t.Errorf("GetTagByName() = %s, want %s", tag.Id, tags[0].Id)
+	}
+	if _, err := f.tagRepository.CreateTag(models.Tag{Id: utils.UUIDv4(), Name: strings.ToLower(tags[0].Name), SpaceId: f.space.Id}); err == nil {
+		t.Errorf("CreateTag() created a tag with the name of another tag in another case")
+	}
+
+	documents, total, err := f.tagRepository.GetTaggedDocuments(tags[0].Id, models.DocumentScope{SpaceIds: []string{f.space.Id}}, -1, 0)
+	if err != nil {
+		t.Fatalf("GetTaggedDocuments() unexpected error: %v", err)
+	}
+	if total != 1 || len(documents) != 1 || len(documents[0].Members) != 1 {
+		t.Errorf("GetTaggedDocuments() = %d documents (total %d), want the document with its members", len(documents), total)
+	}
+
+	// the excluded documents are left out of the spaces, the included ones are added outside of them
+	tests := []struct {
+		name      string
+		scope     models.DocumentScope
+		wantTotal int64
+	}{
+		{"excluded", models.DocumentScope{SpaceIds: []string{f.space.Id}, ExcludedIds: []string{f.document.Id}}, 0},
+		{"included", models.DocumentScope{IncludedIds: []string{f.document.Id}}, 1},
+		{"empty", models.DocumentScope{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, total, err := f.tagRepository.GetTaggedDocuments(tags[0].Id, tt.scope, 10, 0)
+			if err != nil {
+				t.Fatalf("GetTaggedDocuments() unexpected error: %v", err)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("GetTaggedDocuments() total = %d, want %d", total, tt.wantTotal)
+			}
+		})
+	}
+
+	if err := f.tagRepository.MergeTag(tags[1].Id, tags[0].Id); err != nil {
+		t.Fatalf("MergeTag() unexpected error: %v", err)
+	}
+	documentTags, err := f.tagRepository.GetDocumentTags(f.document.Id)
+	if err != nil {
+		t.Fatalf("GetDocumentTags() unexpected error: %v", err)
+	}
+	if len(documentTags) != 1 || documentTags[0].Id != tags[0].Id {
+		t.Errorf("GetDocumentTags() = %v after the merge, want %s", documentTags, tags[0].Id)
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	f := newFixture(t)
+
+	notification, err := f.notificationRepository.CreateNotification(models.Notification{
+		UserId:       f.user.Id,
+		Type:         models.NotificationTypeMention,
+		ActorId:      f.user.Id,
+		SpaceId:      f.space.Id,
+		DocumentId:   f.document.Id,
+		DocumentName: f.document.Name,
+		Count:        1,
+	})
+	f.deleted(`DELETE FROM notification WHERE id = ?`, notification.Id)
+	f.check(err)
+
+	notifications, total, unread, err := f.notificationRepository.GetNotifications(f.user.Id, true, 10, 0)
+	if err != nil {
+		t.Fatalf("GetNotifications() unexpected error: %v", err)
+	}
+	if total != 1 || unread != 1 || len(notifications) != 1 || notifications[0].ActorName != f.user.Name {
+		t.Errorf("GetNotifications() = %d notifications, want one with the name of its actor", len(notifications))
+	}
+
+	// the notifications are mailed to the users with the digest frequency, once
+	preferences := models.JSONB{models.NotificationPreferencesKey: map[string]any{"email_digest": string(models.DigestFrequencyDaily)}}
+	if err := f.userRepository.UpdatePreferences(f.user.Id, preferences); err != nil {
+		t.Fatalf("UpdatePreferences() unexpected error: %v", err)
+	}
+	digests := []struct {
+		frequency models.DigestFrequency
+		want      bool
+	}{
+		{models.DigestFrequencyDaily, true},
+		{models.DigestFrequencyHourly, false},
+	}
+	for _, tt := range digests {
+		t.Run("digest "+string(tt.frequency), func(t *testing.T) {
+			unmailed, err := f.notificationRepository.GetUnmailedNotifications([]models.DigestFrequency{tt.frequency})
+			if err != nil {
+				t.Fatalf("GetUnmailedNotifications() unexpected error: %v", err)
+			}
+			found := slices.ContainsFunc(unmailed, func(n models.Notification) bool { return n.Id == notification.Id })
+			if found != tt.want {
+				t.Errorf("GetUnmailedNotifications(%s) found the notification %v, want %v", tt.frequency, found, tt.want)
+			}
+		})
+	}
+	for i, want := range []bool{true, false} {
+		claimed, err := f.notificationRepository.ClaimNotificationEmail(notification.Id, time.Now())
+		if err != nil {
+			t.Fatalf("ClaimNotificationEmail() unexpected error: %v", err)
+		}
+		if claimed != want {
+			t.Errorf("ClaimNotificationEmail() #%d = %v, want %v", i+1, claimed, want)
+		}
+	}
+
+	f.deleted(`DELETE FROM subscription WHERE user_id = ?`, f.user.Id)
+	for range 2 {
+		if err := f.notificationRepository.Subscribe(models.Subscription{UserId: f.user.Id, DocumentId: f.document.Id, CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("Subscribe() unexpected error: %v", err)
+		}
+	}
+
+	activity, err := f.activityRepository.CreateActivity(models.Activity{
+		Type:         models.ActivityTypeCreated,
+		UserId:       f.user.Id,
+		SpaceId:      f.space.Id,
+		DocumentId:   f.document.Id,
+		DocumentName: f.document.Name,
+	})
+	f.deleted(`DELETE FROM activity WHERE id = ?`, activity.Id)
+	f.check(err)
+	activities, _, err := f.activityRepository.GetSpaceActivities(f.space.Id, nil, 10, 0)
+	if err != nil {
+		t.Fatalf("GetSpaceActivities() unexpected error: %v", err)
+	}
+	if len(activities) != 1 || activities[0].UserName != f.user.Name {
+		t.Errorf("GetSpaceActivities() = %d activities, want one with the name of its user", len(activities))
+	}
+	activities, total, err = f.activityRepository.GetSpaceActivities(f.space.Id, []string{f.document.Id}, 10, 0)
+	if err != nil {
+		t.Fatalf("GetSpaceActivities() unexpected error: %v", err)
+	}
+	if len(activities) != 0 || total != 0 {
+		t.Errorf("GetSpaceActivities() = %d activities of %d, want the activities of the excluded document left out", len(activities), total)
+	}
+
+	// the recent documents are upserted
+	f.deleted(`DELETE FROM recent_document WHERE user_id = ?`, f.user.Id)
+	for _, kind := range []models.RecentKind{models.RecentKindViewed, models.RecentKindEdited, models.RecentKindViewed} {
+		if err := f.activityRepository.TouchRecentDocument(f.user.Id, f.document.Id, kind, time.Now()); err != nil {
+			t.Fatalf("TouchRecentDocument() unexpected error: %v", err)
+		}
+	}
+	recent, err := f.activityRepository.GetRecentDocuments(f.user.Id, models.RecentKindEdited, 10)
+	if err != nil {
+		t.Fatalf("GetRecentDocuments() unexpected error: %v", err)
+	}
+	if len(recent) != 1 || recent[0].ViewedAt == nil || recent[0].EditedAt == nil {
+		t.Errorf("GetRecentDocuments() = %d documents, want one viewed and edited", len(recent))
+	}
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+type shareLinkRepository struct {
+	db *gorm.DB
+}
+
+func NewShareLinkRepository(db *gorm.DB) *shareLinkRepository {
+	return &shareLinkRepository{db: db}
+}
+
+func (r *shareLinkRepository) CreateShareLink(link models.ShareLink) (models.ShareLink, error) {
+	err := r.db.Debug().Table("share_link").Create(&link).Error
+	link.HasPassword = link.PasswordHash != ""
+	return link, err
+}
+
+func (r *shareLinkRepository) GetShareLinkById(id string) (models.ShareLink, error) {
+	var link models.ShareLink
+	err := r.db.Debug().Table("share_link").Where("id = ?", id).First(&link).Error
+	return link, err
+}
+
+func (r *shareLinkRepository) GetShareLinkByToken(token string) (models.ShareLink, error) {
+	var link models.ShareLink
+	err := r.db.Debug().Table("share_link").Where("token = ?", token).First(&link).Error
+	return link, err
+}
+
+// GetShareLinksByDocumentId returns the share links of the document, the revoked ones included
+func (r *shareLinkRepository) GetShareLinksByDocumentId(documentId string) ([]models.ShareLink, error) {
+	var links []models.ShareLink
+	err := r.db.Debug().Table("share_link").Where("document_id = ?", documentId).Order("created_at").Find(&links).Error
+	return links, err
+}
+
+// UpdateShareLink saves the settings and the revocation of the share link, the views are not updated
+func (r *shareLinkRepository) UpdateShareLink(link models.ShareLink) (models.ShareLink, error) {
+	err := r.db.Debug().Table("share_link").Where("id = ?", link.Id).
+		Select("access", "include_subpages", "password_hash", "expires_at", "revoked_at", "updated_at").
+		Updates(&link).Error
+	link.HasPassword = link.PasswordHash != ""
+	return link, err
+}
+
+// RecordView counts a view of the share link
+func (r *shareLinkRepository) RecordView(id string, at time.Time) error {
+	return r.db.Debug().Table("share_link").Where("id = ?", id).
+		Updates(map[string]any{"view_count": gorm.Expr("view_count + 1"), "last_viewed_at": at}).Error
+}
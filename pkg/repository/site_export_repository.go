@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+type siteExportRepository struct {
+	db *gorm.DB
+}
+
+func NewSiteExportRepository(db *gorm.DB) *siteExportRepository {
+	return &siteExportRepository{db: db}
+}
+
+func (r *siteExportRepository) CreateExport(export models.SiteExport) (models.SiteExport, error) {
+	err := r.db.Debug().Table("site_export").Create(&export).Error
+	return export, err
+}
+
+func (r *siteExportRepository) GetExportById(id string) (models.SiteExport, error) {
+	var export models.SiteExport
+	err := r.db.Debug().Table("site_export").Where("id = ?", id).First(&export).Error
+	return export, err
+}
+
+// GetExportsBySpaceId returns the exports of the space, the most recent first
+func (r *siteExportRepository) GetExportsBySpaceId(spaceId string) ([]models.SiteExport, error) {
+	var exports []models.SiteExport
+	err := r.db.Debug().Table("site_export").Where("space_id = ?", spaceId).Order("created_at DESC").Find(&exports).Error
+	return exports, err
+}
+
+// GetPendingExports returns the exports waiting to run, the oldest first. The running exports started before
+// staleBefore are returned too, their instance stopped before the end.
+func (r *siteExportRepository) GetPendingExports(staleBefore time.Time) ([]models.SiteExport, error) {
+	var exports []models.SiteExport
+	err := r.db.Debug().Table("site_export").
+		Where("status = ? OR (status = ? AND started_at < ?)", models.SiteExportPending, models.SiteExportRunning, staleBefore).
+		Order("created_at").Find(&exports).Error
+	return exports, err
+}
+
+// ClaimExport marks the pending or stale export as running, false is returned when another instance claimed it first
+func (r *siteExportRepository) ClaimExport(id string, at time.Time, staleBefore time.Time) (bool, error) {
+	result := r.db.Debug().Table("site_export").
+		Where("id = ? AND (status = ? OR (status = ? AND started_at < ?))", id, models.SiteExportPending, models.SiteExportRunning, staleBefore).
+		Updates(map[string]any{"status": models.SiteExportRunning, "started_at": at, "updated_at": at})
+	return result.RowsAffected > 0, result.Error
+}
+
+// UpdateExport saves the result of the export
+func (r *siteExportRepository) UpdateExport(export models.SiteExport) error {
+	return r.db.Debug().Table("site_export").Where("id = ?", export.Id).
+		Select("status", "error", "pages", "attachments", "size", "storage_key", "completed_at", "updated_at").
+		Updates(&export).Error
+}
+
+func (r *siteExportRepository) DeleteExport(id string) error {
+	return r.db.Debug().Table("site_export").Where("id = ?", id).Delete(&models.SiteExport{}).Error
+}
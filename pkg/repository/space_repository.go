@@ -1,8 +1,6 @@
 package repository
 
 import (
-	"fmt"
-
 	"github.com/labbs/zotion/pkg/models"
 	"gorm.io/gorm"
 )
@@ -15,70 +13,58 @@ func NewSpaceRepository(db *gorm.DB) *spaceRepository {
 	return &spaceRepository{db: db}
 }
 
-// Get Space for a user
+// GetSpacesForUser returns the spaces the user or one of the groups is a member of
 func (r *spaceRepository) GetSpacesForUser(userId string, groups []models.Group) ([]models.Space, error) {
 	var spaces []models.Space
-	var query *gorm.DB
-	if r.db.Dialector.Name() == "sqlite" {
-		query = r.db.Table("space").Where("JSON_EXTRACT(members, '$') LIKE ?", fmt.Sprintf("%%\"id\":\"%s\"%%", userId))
-		if len(groups) > 0 {
-			for _, group := range groups {
-				query = query.Or("JSON_EXTRACT(members, '$') LIKE ?", fmt.Sprintf("%%\"id\":\"%s\"%%", group.Id))
-			}
-		}
-	} else {
-		query = r.db.Table("space").Where("members @> ?", fmt.Sprintf(`[{"id": "%s", "type": "user"}]`, userId))
-		if len(groups) > 0 {
-			for _, group := range groups {
-				query = query.Or("members @> ?", fmt.Sprintf(`[{"id": "%s", "type": "group"}]`, group.Id))
-			}
-		}
+	members := r.db.Table("space_member").Select("space_member.space_id").Where(memberOf(r.db, "space_member", userId, groups))
+	if err := r.db.Table("space").Where("id IN (?)", members).Find(&spaces).Error; err != nil {
+		return spaces, err
 	}
-
-	err := query.Find(&spaces).Error
+	err := loadSpaceMembers(r.db, spaces)
 	return spaces, err
 }
 
 // GetSpaceById returns a space by its id
 func (r *spaceRepository) GetSpaceById(spaceId string) (models.Space, error) {
 	var space models.Space
-	err := r.db.Table("space").Preload("Documents").First(&space, "id = ?", spaceId).Error
+	if err := r.db.Table("space").Preload("Documents").First(&space, "id = ?", spaceId).Error; err != nil {
+		return space, err
+	}
+	spaces := []models.Space{space}
+	if err := loadSpaceMembers(r.db, spaces); err != nil {
+		return space, err
+	}
+	space = spaces[0]
+	err := loadDocumentMembers(r.db, space.Documents)
 	return space, err
 }
 
-// CreateSpace creates a new space
+// CreateSpace creates a new space with its members
 func (sr *spaceRepository) CreateSpace(space models.Space) (models.Space, error) {
-	err := sr.db.Table("space").Create(&space).Error
+	err := sr.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("space").Create(&space).Error; err != nil {
+			return err
+		}
+		return replaceSpaceMembers(tx, space.Id, space.Members)
+	})
 	return space, err
 }
 
 // IsMember checks if a user is a member of a space
 func (sr *spaceRepository) IsMember(spaceId, userId string) (bool, error) {
 	var count int64
-
-	// Get database dialect
-	dialect := sr.db.Dialector.Name()
-
-	if dialect == "sqlite" {
-		// For SQLite: Use JSON_EXTRACT and LIKE for checking members
-		err := sr.db.Table("space").
-			Where("id = ? AND (JSON_EXTRACT(members, '$') LIKE ?)",
-				spaceId, userId, "%\"id\":\""+userId+"\"%").
-			Count(&count).Error
-		return count > 0, err
-	} else {
-		// For PostgreSQL: Use the @> containment operator
-		err := sr.db.Table("space").
-			Where("id = ? AND members @> ?",
-				spaceId, userId, fmt.Sprintf(`[{"id": "%s"}]`, userId)).
-			Count(&count).Error
-		return count > 0, err
-	}
+	err := sr.db.Table("space_member").
+		Where("space_id = ? AND member_type = ? AND member_id = ?", spaceId, models.MemberTypeUser, userId).
+		Count(&count).Error
+	return count > 0, err
 }
 
 // GetAllSpaces returns all spaces
 func (sr *spaceRepository) GetAllSpaces() ([]models.Space, error) {
 	var spaces []models.Space
-	err := sr.db.Table("space").Find(&spaces).Error
+	if err := sr.db.Table("space").Find(&spaces).Error; err != nil {
+		return spaces, err
+	}
+	err := loadSpaceMembers(sr.db, spaces)
 	return spaces, err
 }
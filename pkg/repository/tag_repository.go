@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type tagRepository struct {
+	db *gorm.DB
+}
+
+func NewTagRepository(db *gorm.DB) *tagRepository {
+	return &tagRepository{db: db}
+}
+
+func (r *tagRepository) CreateTag(tag models.Tag) (models.Tag, error) {
+	err := r.db.Debug().Table("tag").Create(&tag).Error
+	return tag, err
+}
+
+func (r *tagRepository) GetTagById(id string) (models.Tag, error) {
+	var tag models.Tag
+	err := r.db.Debug().Table("tag").First(&tag, "id = ?", id).Error
+	return tag, err
+}
+
+// GetTagByName returns the tag of the scope with the name, the case is ignored
+func (r *tagRepository) GetTagByName(spaceId string, name string) (models.Tag, error) {
+	var tag models.Tag
+	err := r.db.Debug().Table("tag").Where("space_id = ? AND LOWER(name) = LOWER(?)", spaceId, name).First(&tag).Error
+	return tag, err
+}
+
+// GetTags returns the instance tags and the tags of the spaces
+func (r *tagRepository) GetTags(spaceIds []string) ([]models.Tag, error) {
+	var tags []models.Tag
+	err := r.db.Debug().Table("tag").Where("space_id = '' OR space_id IN ?", spaceIds).Order("LOWER(name), space_id").Find(&tags).Error
+	return tags, err
+}
+
+func (r *tagRepository) UpdateTag(tag models.Tag) (models.Tag, error) {
+	err := r.db.Debug().Table("tag").Where("id = ?", tag.Id).Select("name", "color", "updated_at").Updates(&tag).Error
+	return tag, err
+}
+
+// DeleteTag deletes the tag and removes it from the documents
+func (r *tagRepository) DeleteTag(id string) error {
+	return r.db.Debug().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("document_tag").Where("tag_id = ?", id).Delete(&models.DocumentTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Table("tag").Where("id = ?", id).Delete(&models.Tag{}).Error
+	})
+}
+
+// MergeTag moves the documents of the source tag to the target tag and deletes the source tag
+func (r *tagRepository) MergeTag(sourceId string, targetId string) error {
+	return r.db.Debug().Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`INSERT INTO document_tag (document_id, tag_id, created_by, created_at)
+			SELECT document_id, ?, created_by, created_at FROM document_tag
+			WHERE tag_id = ? AND document_id NOT IN (SELECT document_id FROM document_tag WHERE tag_id = ?)`,
+			targetId, sourceId, targetId).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Table("document_tag").Where("tag_id = ?", sourceId).Delete(&models.DocumentTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Table("tag").Where("id = ?", sourceId).Delete(&models.Tag{}).Error
+	})
+}
+
+// CountDocuments returns the number of documents of the spaces by tag id, the deleted documents are not counted
+func (r *tagRepository) CountDocuments(spaceIds []string) (map[string]int64, error) {
+	var rows []struct {
+		TagId string
+		Count int64
+	}
+	err := r.db.Debug().Table("document_tag").
+		Select("document_tag.tag_id, COUNT(*) AS count").
+		Joins("JOIN document ON document.id = document_tag.document_id AND document.deleted_at IS NULL").
+		Where("document.space_id IN ?", spaceIds).
+		Group("document_tag.tag_id").
+		Scan(&rows).Error
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.TagId] = row.Count
+	}
+	return counts, err
+}
+
+// GetDocumentTags returns the tags of a document ordered by name
+func (r *tagRepository) GetDocumentTags(documentId string) ([]models.Tag, error) {
+	var tags []models.Tag
+	err := r.db.Debug().Table("tag").
+		Joins("JOIN document_tag ON document_tag.tag_id = tag.id").
+		Where("document_tag.document_id = ?", documentId).
+		Order("LOWER(tag.name)").
+		Find(&tags).Error
+	return tags, err
+}
+
+// AddDocumentTag tags a document, adding a tag twice does nothing
+func (r *tagRepository) AddDocumentTag(documentTag models.DocumentTag) error {
+	return r.db.Debug().Table("document_tag").Clauses(clause.OnConflict{DoNothing: true}).Create(&documentTag).Error
+}
+
+func (r *tagRepository) RemoveDocumentTag(documentId string, tagId string) error {
+	return r.db.Debug().Table("document_tag").Where("document_id = ? AND tag_id = ?", documentId, tagId).Delete(&models.DocumentTag{}).Error
+}
+
+// GetTaggedDocuments returns a page of the documents of the scope with the tag and their members, the last updated
+// first, all the documents when limit is negative. The content of the documents is not loaded.
+func (r *tagRepository) GetTaggedDocuments(tagId string, scope models.DocumentScope, limit int, offset int) ([]models.Document, int64, error) {
+	query := r.db.Debug().Model(&models.Document{}).
+		Joins("JOIN document_tag ON document_tag.document_id = document.id AND document_tag.tag_id = ?", tagId).
+		Where(documentScope(r.db, scope))
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var documents []models.Document
+	if err := query.Omit("content").Order("document.updated_at DESC, document.id").Limit(limit).Offset(offset).Find(&documents).Error; err != nil {
+		return nil, 0, err
+	}
+	err := loadDocumentMembers(r.db, documents)
+	return documents, total, err
+}
+
+// documentScope returns the condition selecting the documents of the scope, none when the scope is empty
+func documentScope(db *gorm.DB, scope models.DocumentScope) *gorm.DB {
+	condition := db.Where("1 = 0")
+	if len(scope.SpaceIds) > 0 {
+		spaces := db.Where("document.space_id IN ?", scope.SpaceIds)
+		if len(scope.ExcludedIds) > 0 {
+			spaces = spaces.Where("document.id NOT IN ?", scope.ExcludedIds)
+		}
+		condition = condition.Or(spaces)
+	}
+	if len(scope.IncludedIds) > 0 {
+		condition = condition.Or("document.id IN ?", scope.IncludedIds)
+	}
+	return condition
+}
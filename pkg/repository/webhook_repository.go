@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) *webhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) CreateWebhook(webhook models.Webhook) (models.Webhook, error) {
+	err := r.db.Debug().Table("webhook").Create(&webhook).Error
+	return webhook, err
+}
+
+func (r *webhookRepository) GetWebhookById(id string) (models.Webhook, error) {
+	var webhook models.Webhook
+	err := r.db.Debug().Table("webhook").Where("id = ?", id).First(&webhook).Error
+	return webhook, err
+}
+
+// GetWebhooks returns the webhooks of the space, the instance webhooks without space
+func (r *webhookRepository) GetWebhooks(spaceId string) ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	err := r.db.Debug().Table("webhook").Where("space_id = ?", spaceId).Order("created_at").Find(&webhooks).Error
+	return webhooks, err
+}
+
+// GetActiveWebhooks returns the enabled webhooks of the instance and of all the spaces
+func (r *webhookRepository) GetActiveWebhooks() ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	err := r.db.Debug().Table("webhook").Where("active = ?", true).Find(&webhooks).Error
+	return webhooks, err
+}
+
+// UpdateWebhook saves the settings and the state of the webhook, the secret is not updated
+func (r *webhookRepository) UpdateWebhook(webhook models.Webhook) (models.Webhook, error) {
+	err := r.db.Debug().Table("webhook").Where("id = ?", webhook.Id).
+		Select("name", "url", "events", "active", "failure_count", "disabled_at", "updated_at").
+		Updates(&webhook).Error
+	return webhook, err
+}
+
+func (r *webhookRepository) UpdateWebhookSecret(id string, secret string) error {
+	return r.db.Debug().Table("webhook").Where("id = ?", id).Updates(map[string]any{"secret": secret, "updated_at": time.Now()}).Error
+}
+
+// DeleteWebhook deletes the webhook and its deliveries
+func (r *webhookRepository) DeleteWebhook(id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Debug().Table("webhook_delivery").Where("webhook_id = ?", id).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Debug().Table("webhook").Where("id = ?", id).Delete(&models.Webhook{}).Error
+	})
+}
+
+// RecordWebhookResult resets the consecutive failures of the webhook after a success, or counts the failure
+// and disables the webhook when the failures reach the threshold
+func (r *webhookRepository) RecordWebhookResult(id string, success bool, failureThreshold int, at time.Time) error {
+	if success {
+		return r.db.Debug().Table("webhook").Where("id = ? AND failure_count > 0", id).Update("failure_count", 0).Error
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Debug().Table("webhook").Where("id = ?", id).Update("failure_count", gorm.Expr("failure_count + 1")).Error; err != nil {
+			return err
+		}
+		return tx.Debug().Table("webhook").
+			Where("id = ? AND active = ? AND failure_count >= ?", id, true, failureThreshold).
+			Updates(map[string]any{"active": false, "disabled_at": at}).Error
+	})
+}
+
+// CreateDelivery queues the delivery, nothing is done if a delivery with the same key already exists
+func (r *webhookRepository) CreateDelivery(delivery models.WebhookDelivery) (models.WebhookDelivery, error) {
+	err := r.db.Debug().Table("webhook_delivery").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "dedup_key"}},
+		DoNothing: true,
+	}).Create(&delivery).Error
+	return delivery, err
+}
+
+func (r *webhookRepository) GetDeliveryById(id string) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := r.db.Debug().Table("webhook_delivery").Where("id = ?", id).First(&delivery).Error
+	return delivery, err
+}
+
+// GetDeliveries returns a page of the deliveries of the webhook, the most recent first
+func (r *webhookRepository) GetDeliveries(webhookId string, limit int, offset int) ([]models.WebhookDelivery, int64, error) {
+	query := r.db.Debug().Table("webhook_delivery").Where("webhook_id = ?", webhookId)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var deliveries []models.WebhookDelivery
+	err := query.Order("created_at DESC, id").Limit(limit).Offset(offset).Find(&deliveries).Error
+	return deliveries, total, err
+}
+
+// GetDueDeliveries returns the pending deliveries of the enabled webhooks whose next attempt is passed
+func (r *webhookRepository) GetDueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := r.db.Debug().Table("webhook_delivery").
+		Select("webhook_delivery.*").
+		Joins("JOIN webhook ON webhook.id = webhook_delivery.webhook_id AND webhook.active = ?", true).
+		Where("webhook_delivery.status = ? AND webhook_delivery.next_attempt_at <= ?", models.WebhookDeliveryPending, now).
+		Order("webhook_delivery.next_attempt_at").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+// ClaimDelivery counts a new attempt of the delivery and postpones the next one until the lease ends,
+// false is returned when another instance claimed the attempt first
+func (r *webhookRepository) ClaimDelivery(delivery models.WebhookDelivery, leaseUntil time.Time) (bool, error) {
+	result := r.db.Debug().Table("webhook_delivery").
+		Where("id = ? AND status = ? AND attempts = ?", delivery.Id, models.WebhookDeliveryPending, delivery.Attempts).
+		Updates(map[string]any{"attempts": delivery.Attempts + 1, "next_attempt_at": leaseUntil})
+	return result.RowsAffected > 0, result.Error
+}
+
+// UpdateDelivery saves the result of an attempt
+func (r *webhookRepository) UpdateDelivery(delivery models.WebhookDelivery) error {
+	return r.db.Debug().Table("webhook_delivery").Where("id = ?", delivery.Id).
+		Select("status", "attempts", "response_code", "response_body", "error", "duration_ms", "next_attempt_at", "delivered_at", "updated_at").
+		Updates(&delivery).Error
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+type accessService struct {
+	userRepository     models.UserRepository
+	spaceRepository    models.SpaceRepository
+	documentRepository models.DocumentRepository
+}
+
+// NewAccessService creates a new access service
+func NewAccessService(ur models.UserRepository, sr models.SpaceRepository, dr models.DocumentRepository) *accessService {
+	return &accessService{
+		userRepository:     ur,
+		spaceRepository:    sr,
+		documentRepository: dr,
+	}
+}
+
+// GetSpaceAccess returns the space and the access of the user on it.
+// models.ErrAccessDenied is returned when the user isn't a member of the space, the guests never access the spaces.
+func (s *accessService) GetSpaceAccess(userId string, spaceId string) (models.Space, models.AccessType, error) {
+	space, err := s.spaceRepository.GetSpaceById(spaceId)
+	if err != nil {
+		return models.Space{}, "", err
+	}
+
+	groups, err := s.userRepository.GetGroupsByUserId(userId)
+	if err != nil {
+		return models.Space{}, "", err
+	}
+
+	if models.IsGuest(groups) {
+		return space, "", models.ErrAccessDenied
+	}
+
+	access, ok := space.Members.GetAccess(userId, groups)
+	if !ok {
+		return space, "", models.ErrAccessDenied
+	}
+
+	return space, access, nil
+}
+
+// GetDocumentAccess returns the document and the access of the user on it, the access is inherited
+// from the members of the parent documents and the space unless a document breaks the inheritance.
+// models.ErrAccessDenied is returned when the user isn't a member of the document or its access chain.
+// The guests only get the access granted by the members of the documents, not the one of the space.
+// When the document is locked, the access is reduced to comment for the users who can't bypass the lock.
+func (s *accessService) GetDocumentAccess(userId string, documentId string) (models.Document, models.AccessType, error) {
+	document, err := s.documentRepository.GetDocumentById(documentId)
+	if err != nil {
+		return models.Document{}, "", err
+	}
+	return s.documentAccess(userId, document)
+}
+
+// GetDocumentAccessUnscoped returns the document and the access of the user on it like GetDocumentAccess,
+// even when the document is deleted
+func (s *accessService) GetDocumentAccessUnscoped(userId string, documentId string) (models.Document, models.AccessType, error) {
+	document, err := s.documentRepository.GetDocumentByIdUnscoped(documentId)
+	if err != nil {
+		return models.Document{}, "", err
+	}
+	return s.documentAccess(userId, document)
+}
+
+// documentAccess returns the access of the user on the document along its access chain
+func (s *accessService) documentAccess(userId string, document models.Document) (models.Document, models.AccessType, error) {
+	space, err := s.spaceRepository.GetSpaceById(document.SpaceId)
+	if err != nil {
+		return models.Document{}, "", err
+	}
+	chain, err := accessChain(s.documentRepository, document, space)
+	if err != nil {
+		return models.Document{}, "", err
+	}
+
+	groups, err := s.userRepository.GetGroupsByUserId(userId)
+	if err != nil {
+		return models.Document{}, "", err
+	}
+
+	access, ok := chain.GetAccess(userId, groups)
+	if !ok {
+		return document, "", models.ErrAccessDenied
+	}
+	if document.Config.IsLocked() && !document.Config.CanBypassLock(userId, s.spaceAccess(chain, userId, groups)) && access.CanEdit() {
+		access = models.AccessTypeComment
+	}
+
+	return document, access, nil
+}
+
+// GetDocumentAccessChain returns the access chain of the document
+func (s *accessService) GetDocumentAccessChain(documentId string) (models.AccessChain, error) {
+	document, err := s.documentRepository.GetDocumentById(documentId)
+	if err != nil {
+		return models.AccessChain{}, err
+	}
+
+	space, err := s.spaceRepository.GetSpaceById(document.SpaceId)
+	if err != nil {
+		return models.AccessChain{}, err
+	}
+
+	return accessChain(s.documentRepository, document, space)
+}
+
+// ExplainDocumentAccess returns the effective access of the user on the document with the members
+// granting it along the access chain
+func (s *accessService) ExplainDocumentAccess(userId string, documentId string) (models.AccessExplanation, error) {
+	if _, err := s.userRepository.GetById(userId); err != nil {
+		return models.AccessExplanation{}, err
+	}
+
+	chain, err := s.GetDocumentAccessChain(documentId)
+	if err != nil {
+		return models.AccessExplanation{}, err
+	}
+	document := chain.Documents[0]
+
+	groups, err := s.userRepository.GetGroupsByUserId(userId)
+	if err != nil {
+		return models.AccessExplanation{}, err
+	}
+
+	explanation := models.AccessExplanation{
+		UserId:     userId,
+		DocumentId: document.Id,
+		Guest:      models.IsGuest(groups),
+		Steps:      chain.Explain(userId, groups),
+	}
+	if access, ok := chain.GetAccess(userId, groups); ok {
+		explanation.Access = access
+		if document.Config.IsLocked() && !document.Config.CanBypassLock(userId, s.spaceAccess(chain, userId, groups)) && access.CanEdit() {
+			explanation.Access = models.AccessTypeComment
+			explanation.Locked = true
+		}
+	}
+	return explanation, nil
+}
+
+// spaceAccess returns the access inherited from the members of the space, the guests don't inherit it
+func (s *accessService) spaceAccess(chain models.AccessChain, userId string, groups []models.Group) models.AccessType {
+	if !chain.SpaceInherited || models.IsGuest(groups) {
+		return ""
+	}
+	access, _ := chain.Space.Members.GetAccess(userId, groups)
+	return access
+}
+
+// maxAccessDepth is the maximum number of ancestors walked to resolve the inherited access of a document
+const maxAccessDepth = 64
+
+// ancestorCache reads each ancestor once when the access chains of several documents are built
+type ancestorCache struct {
+	models.DocumentRepository
+	documents map[string]models.Document
+}
+
+// newAncestorCache creates a cache holding the documents already loaded
+func newAncestorCache(documentRepository models.DocumentRepository, documents []models.Document) *ancestorCache {
+	cache := &ancestorCache{DocumentRepository: documentRepository, documents: make(map[string]models.Document, len(documents))}
+	for _, document := range documents {
+		cache.documents[document.Id] = document
+	}
+	return cache
+}
+
+func (c *ancestorCache) GetDocumentByIdUnscoped(id string) (models.Document, error) {
+	if document, ok := c.documents[id]; ok {
+		return document, nil
+	}
+	document, err := c.DocumentRepository.GetDocumentByIdUnscoped(id)
+	if err != nil {
+		return document, err
+	}
+	c.documents[id] = document
+	return document, nil
+}
+
+// accessChain returns the access chain of the document in the space, from the document up to its highest
+// ancestor or the first document breaking the inheritance. The deleted ancestors are walked like the others,
+// the space isn't inherited when an ancestor is missing or the ancestors are nested deeper than maxAccessDepth.
+func accessChain(documentRepository models.DocumentRepository, document models.Document, space models.Space) (models.AccessChain, error) {
+	chain := models.AccessChain{Documents: []models.Document{document}, Space: space, SpaceInherited: true}
+	for depth := 0; ; depth++ {
+		if document.InheritanceBroken {
+			chain.SpaceInherited = false
+			return chain, nil
+		}
+		if document.ParentId == "" {
+			return chain, nil
+		}
+		if depth >= maxAccessDepth {
+			chain.SpaceInherited = false
+			return chain, nil
+		}
+
+		parent, err := documentRepository.GetDocumentByIdUnscoped(document.ParentId)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			chain.SpaceInherited = false
+			return chain, nil
+		}
+		if err != nil {
+			return chain, err
+		}
+		chain.Documents = append(chain.Documents, parent)
+		document = parent
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+// treeRepository returns the documents of the tree, the deleted ones included
+type treeRepository struct {
+	models.DocumentRepository
+	documents map[string]models.Document
+}
+
+func (r treeRepository) GetDocumentByIdUnscoped(id string) (models.Document, error) {
+	document, ok := r.documents[id]
+	if !ok {
+		return models.Document{}, gorm.ErrRecordNotFound
+	}
+	return document, nil
+}
+
+func (r treeRepository) GetDocumentById(id string) (models.Document, error) {
+	document, err := r.GetDocumentByIdUnscoped(id)
+	if err == nil && document.DeletedAt.Valid {
+		return models.Document{}, gorm.ErrRecordNotFound
+	}
+	return document, err
+}
+
+func (r treeRepository) GetSpaceDocumentsUnscoped(spaceId string) ([]models.Document, error) {
+	var documents []models.Document
+	for _, document := range r.documents {
+		if document.SpaceId == spaceId {
+			documents = append(documents, document)
+		}
+	}
+	slices.SortFunc(documents, func(a, b models.Document) int { return strings.Compare(a.Id, b.Id) })
+	return documents, nil
+}
+
+func (r treeRepository) GetRestrictedDocuments(spaceIds []string) ([]models.Document, error) {
+	var documents []models.Document
+	for _, document := range r.documents {
+		if document.InheritanceBroken && slices.Contains(spaceIds, document.SpaceId) && !document.DeletedAt.Valid {
+			documents = append(documents, document)
+		}
+	}
+	slices.SortFunc(documents, func(a, b models.Document) int { return strings.Compare(a.Id, b.Id) })
+	return documents, nil
+}
+
+func (r treeRepository) GetDocumentsFirstLevelByDocumentId(documentId string) ([]models.Document, error) {
+	var children []models.Document
+	for _, document := range r.documents {
+		if document.ParentId == documentId && !document.DeletedAt.Valid {
+			children = append(children, document)
+		}
+	}
+	slices.SortFunc(children, func(a, b models.Document) int { return strings.Compare(a.Id, b.Id) })
+	return children, nil
+}
+
+func (r treeRepository) GetPublicDocuments(spaceId string) ([]models.Document, error) {
+	var documents []models.Document
+	for _, document := range r.documents {
+		if document.Public && !document.DeletedAt.Valid {
+			documents = append(documents, document)
+		}
+	}
+	return documents, nil
+}
+
+// ancestors returns a chain of count documents, d0 being the highest one
+func ancestors(count int) map[string]models.Document {
+	documents := map[string]models.Document{}
+	for i := 0; i < count; i++ {
+		document := models.Document{Id: fmt.Sprintf("d%d", i)}
+		if i > 0 {
+			document.ParentId = fmt.Sprintf("d%d", i-1)
+		}
+		documents[document.Id] = document
+	}
+	return documents
+}
+
+func TestAccessChain(t *testing.T) {
+	trashed := ancestors(3)
+	parent := trashed["d1"]
+	parent.DeletedAt = gorm.DeletedAt{Valid: true}
+	trashed["d1"] = parent
+
+	broken := ancestors(3)
+	parent = broken["d1"]
+	parent.InheritanceBroken = true
+	broken["d1"] = parent
+
+	tests := []struct {
+		name               string
+		documents          map[string]models.Document
+		document           string
+		wantLength         int
+		wantSpaceInherited bool
+	}{
+		{"root document", ancestors(1), "d0", 1, true},
+		{"nested document", ancestors(3), "d2", 3, true},
+		{"broken inheritance", broken, "d2", 2, false},
+		{"trashed ancestor", trashed, "d2", 3, true},
+		{"missing ancestor", map[string]models.Document{"d1": {Id: "d1", ParentId: "d0"}}, "d1", 1, false},
+		{"maximum depth", ancestors(maxAccessDepth + 1), fmt.Sprintf("d%d", maxAccessDepth), maxAccessDepth + 1, true},
+		{"deeper than the maximum depth", ancestors(maxAccessDepth + 2), fmt.Sprintf("d%d", maxAccessDepth+1), maxAccessDepth + 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := treeRepository{documents: tt.documents}
+			chain, err := accessChain(repository, tt.documents[tt.document], models.Space{Id: "s"})
+			if err != nil {
+				t.Fatalf("accessChain() unexpected error: %v", err)
+			}
+			if len(chain.Documents) != tt.wantLength || chain.SpaceInherited != tt.wantSpaceInherited {
+				t.Errorf("accessChain() = %d documents and space inherited %v, want %d and %v", len(chain.Documents), chain.SpaceInherited, tt.wantLength, tt.wantSpaceInherited)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+// editCoalescingWindow is the period during which the successive edits of a document by the same user are a single activity
+const editCoalescingWindow = 10 * time.Minute
+
+type activityService struct {
+	activityRepository models.ActivityRepository
+	spaceRepository    models.SpaceRepository
+	userRepository     models.UserRepository
+	documentRepository models.DocumentRepository
+}
+
+// NewActivityService creates a new activity service, the access chains of the documents and the groups of the users
+// filter the recent documents
+func NewActivityService(ar models.ActivityRepository, sr models.SpaceRepository, ur models.UserRepository, dr models.DocumentRepository) *activityService {
+	return &activityService{
+		activityRepository: ar,
+		spaceRepository:    sr,
+		userRepository:     ur,
+		documentRepository: dr,
+	}
+}
+
+// Record appends the activity to the log of its space, an edit following an edit of the same user
+// is only recorded once the coalescing window is over. The edits also update the recent documents of the user.
+func (s *activityService) Record(activity models.Activity) error {
+	now := time.Now()
+	if activity.Type == models.ActivityTypeEdited {
+		if err := s.activityRepository.TouchRecentDocument(activity.UserId, activity.DocumentId, models.RecentKindEdited, now); err != nil {
+			return err
+		}
+
+		last, err := s.activityRepository.GetLastDocumentActivity(activity.DocumentId)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil && last.Type == models.ActivityTypeEdited && last.UserId == activity.UserId && now.Sub(last.CreatedAt) < editCoalescingWindow {
+			return nil
+		}
+	}
+
+	activity.CreatedAt = now
+	_, err := s.activityRepository.CreateActivity(activity)
+	return err
+}
+
+// RecordView updates the recent documents viewed by the user
+func (s *activityService) RecordView(userId string, documentId string) error {
+	return s.activityRepository.TouchRecentDocument(userId, documentId, models.RecentKindViewed, time.Now())
+}
+
+// GetSpaceActivities returns a page of the activities of the space, the most recent first.
+// The activities of the documents the user can't access are left out.
+func (s *activityService) GetSpaceActivities(userId string, spaceId string, limit int, offset int) (models.ActivityPage, error) {
+	page := models.ActivityPage{Results: []models.Activity{}}
+
+	excludedIds, err := s.restrictedDocumentIds(userId, spaceId)
+	if err != nil {
+		return page, err
+	}
+
+	activities, total, err := s.activityRepository.GetSpaceActivities(spaceId, excludedIds, limit, offset)
+	if err != nil {
+		return page, err
+	}
+	if activities != nil {
+		page.Results = activities
+	}
+	page.Total = total
+	page.HasMore = int64(offset+len(activities)) < total
+	return page, nil
+}
+
+// GetRecentDocuments returns the documents last viewed or edited by the user, the documents
+// the user can't access anymore are skipped
+func (s *activityService) GetRecentDocuments(userId string, kind models.RecentKind, limit int) ([]models.RecentDocument, error) {
+	recents, err := s.activityRepository.GetRecentDocuments(userId, kind, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := s.userRepository.GetGroupsByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	spaces := map[string]models.Space{}
+	accessible := make([]models.RecentDocument, 0, len(recents))
+	for _, recent := range recents {
+		space, ok := spaces[recent.Document.SpaceId]
+		if !ok {
+			space, err = s.spaceRepository.GetSpaceById(recent.Document.SpaceId)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			spaces[space.Id] = space
+		}
+		chain, err := accessChain(s.documentRepository, recent.Document, space)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := chain.GetAccess(userId, groups); ok {
+			accessible = append(accessible, recent)
+		}
+	}
+	return accessible, nil
+}
+
+// restrictedDocumentIds returns the ids of the documents of the space the user can't access,
+// the deleted documents included since their activities stay in the feed
+func (s *activityService) restrictedDocumentIds(userId string, spaceId string) ([]string, error) {
+	space, err := s.spaceRepository.GetSpaceById(spaceId)
+	if err != nil {
+		return nil, err
+	}
+	documents, err := s.documentRepository.GetSpaceDocumentsUnscoped(spaceId)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.userRepository.GetGroupsByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	ancestors := newAncestorCache(s.documentRepository, documents)
+	var restricted []string
+	for _, document := range documents {
+		chain, err := accessChain(ancestors, document, space)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := chain.GetAccess(userId, groups); !ok {
+			restricted = append(restricted, document.Id)
+		}
+	}
+	return restricted, nil
+}
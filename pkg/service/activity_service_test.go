@@ -0,0 +1,43 @@
+package service
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/labbs/zotion/pkg/models"
+)
+
+// excludingActivities records the documents excluded from the activities
+type excludingActivities struct {
+	models.ActivityRepository
+	excludedIds *[]string
+}
+
+func (r excludingActivities) GetSpaceActivities(spaceId string, excludedDocumentIds []string, limit int, offset int) ([]models.Activity, int64, error) {
+	*r.excludedIds = excludedDocumentIds
+	return nil, 0, nil
+}
+
+func TestGetSpaceActivities(t *testing.T) {
+	tests := []struct {
+		name         string
+		userId       string
+		wantExcluded []string
+	}{
+		{"member of the restricted document", "alice", nil},
+		{"restricted documents excluded", "bob", []string{"r", "r1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var excludedIds []string
+			s := NewActivityService(excludingActivities{excludedIds: &excludedIds}, memberSpaces{}, noGroups{}, treeRepository{documents: restrictedTree()})
+			if _, err := s.GetSpaceActivities(tt.userId, "s", 10, 0); err != nil {
+				t.Fatalf("GetSpaceActivities() unexpected error: %v", err)
+			}
+			if !slices.Equal(excludedIds, tt.wantExcluded) {
+				t.Errorf("excluded documents = %v, want %v", excludedIds, tt.wantExcluded)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/labbs/zotion/pkg/storage"
+	"github.com/labbs/zotion/pkg/thumbnail"
+)
+
+type attachmentService struct {
+	attachmentRepository models.AttachmentRepository
+	storage              storage.Storage
+	thumbnails           *thumbnail.Generator
+	maxSize              int64
+	allowedTypes         []string
+}
+
+// NewAttachmentService returns the attachment service, maxSize is in bytes and
+// all the content types are allowed when allowedTypes is empty
+func NewAttachmentService(attachmentRepository models.AttachmentRepository, storage storage.Storage, thumbnails *thumbnail.Generator, maxSize int64, allowedTypes []string) *attachmentService {
+	return &attachmentService{
+		attachmentRepository: attachmentRepository,
+		storage:              storage,
+		thumbnails:           thumbnails,
+		maxSize:              maxSize,
+		allowedTypes:         allowedTypes,
+	}
+}
+
+// CreateAttachment stores the file and saves the attachment.
+// The content type is sniffed from the file, the one sent by the client is ignored.
+func (s *attachmentService) CreateAttachment(attachment models.Attachment, reader io.Reader) (models.Attachment, error) {
+	if attachment.Size > s.maxSize {
+		return attachment, models.ErrAttachmentTooLarge
+	}
+
+	// the header is large enough to read the dimensions of the images after their exif metadata
+	buffered := bufio.NewReaderSize(reader, 64*1024)
+	head, err := buffered.Peek(64 * 1024)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return attachment, err
+	}
+	attachment.ContentType = detectContentType(head, attachment.Name)
+	if !s.isAllowedType(attachment.ContentType) {
+		return attachment, models.ErrAttachmentTypeNotAllowed
+	}
+	if thumbnail.Supported(attachment.ContentType) {
+		if width, height, err := thumbnail.Dimensions(head); err == nil {
+			attachment.Width, attachment.Height = width, height
+		}
+	}
+
+	attachment.Id = utils.UUIDv4()
+	attachment.StorageKey = attachment.SpaceId + "/" + attachment.DocumentId + "/" + attachment.Id
+
+	// the metadata of the images, like the location of the photos, are removed before they are stored
+	var file io.Reader = buffered
+	if thumbnail.Supported(attachment.ContentType) {
+		data, err := io.ReadAll(io.LimitReader(buffered, s.maxSize+1))
+		if err != nil {
+			return attachment, err
+		}
+		if int64(len(data)) > s.maxSize {
+			return attachment, models.ErrAttachmentTooLarge
+		}
+		file = bytes.NewReader(thumbnail.StripMetadata(data))
+	}
+
+	// the size sent by the client is checked again while reading the file
+	hash := sha256.New()
+	counter := &countingReader{reader: io.LimitReader(file, s.maxSize+1)}
+	if err := s.storage.Put(context.Background(), attachment.StorageKey, io.TeeReader(counter, hash), -1, attachment.ContentType); err != nil {
+		return attachment, err
+	}
+	if counter.count > s.maxSize {
+		s.storage.Delete(context.Background(), attachment.StorageKey)
+		return attachment, models.ErrAttachmentTooLarge
+	}
+	attachment.Size = counter.count
+	attachment.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	created, err := s.attachmentRepository.CreateAttachment(attachment)
+	if err != nil {
+		s.storage.Delete(context.Background(), attachment.StorageKey)
+		return attachment, err
+	}
+
+	// the variants are generated in background to keep the upload fast
+	if thumbnail.Supported(created.ContentType) {
+		s.thumbnails.Enqueue(created.StorageKey, created.Width)
+	}
+	return created, nil
+}
+
+func (s *attachmentService) GetAttachmentById(id string) (models.Attachment, error) {
+	return s.attachmentRepository.GetAttachmentById(id)
+}
+
+func (s *attachmentService) GetAttachmentsByDocumentId(documentId string) ([]models.Attachment, error) {
+	return s.attachmentRepository.GetAttachmentsByDocumentId(documentId)
+}
+
+// OpenAttachment returns the file of the attachment, the caller must close it
+func (s *attachmentService) OpenAttachment(attachment models.Attachment) (io.ReadCloser, error) {
+	return s.storage.Get(context.Background(), attachment.StorageKey)
+}
+
+// OpenAttachmentVariant returns a variant of an image narrower than the width with its content type,
+// the original is returned when it's not an image. When the width is larger than the image, the variant
+// has the size of the image without its metadata. The caller must close it.
+func (s *attachmentService) OpenAttachmentVariant(attachment models.Attachment, width int) (io.ReadCloser, string, error) {
+	if !thumbnail.Supported(attachment.ContentType) {
+		reader, err := s.OpenAttachment(attachment)
+		return reader, attachment.ContentType, err
+	}
+
+	variantWidth := s.thumbnails.Width(width, attachment.Width)
+	if variantWidth == 0 {
+		reader, err := s.OpenAttachment(attachment)
+		return reader, attachment.ContentType, err
+	}
+
+	reader, err := s.thumbnails.Open(context.Background(), attachment.StorageKey, variantWidth)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// the variants are encoded in jpeg or png depending on the transparency, the type is sniffed
+	buffered := bufio.NewReader(reader)
+	head, _ := buffered.Peek(512)
+	return struct {
+		io.Reader
+		io.Closer
+	}{buffered, reader}, http.DetectContentType(head), nil
+}
+
+// DeleteAttachment removes the file, its variants and the attachment
+func (s *attachmentService) DeleteAttachment(attachment models.Attachment) error {
+	if err := s.storage.Delete(context.Background(), attachment.StorageKey); err != nil {
+		return err
+	}
+	if thumbnail.Supported(attachment.ContentType) {
+		if err := s.thumbnails.Delete(context.Background(), attachment.StorageKey, attachment.Width); err != nil {
+			return err
+		}
+	}
+	return s.attachmentRepository.DeleteAttachment(attachment.Id)
+}
+
+// DeleteDocumentAttachments removes all the attachments of a document, it's used when the document is purged
+func (s *attachmentService) DeleteDocumentAttachments(documentId string) error {
+	attachments, err := s.attachmentRepository.GetAttachmentsByDocumentId(documentId)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, attachment := range attachments {
+		errs = append(errs, s.DeleteAttachment(attachment))
+	}
+	return errors.Join(errs...)
+}
+
+// isAllowedType checks the content type against the allowed types, a type like 'image/*' allows all the subtypes
+func (s *attachmentService) isAllowedType(contentType string) bool {
+	if len(s.allowedTypes) == 0 {
+		return true
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, allowed := range s.allowedTypes {
+		if allowed == mediaType || (strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectContentType sniffs the content type of the file, the extension is only used
+// when the content is not recognized
+func detectContentType(head []byte, name string) string {
+	contentType := http.DetectContentType(head)
+	if contentType == "application/octet-stream" {
+		if byExtension := mime.TypeByExtension(filepath.Ext(name)); byExtension != "" && !strings.HasPrefix(byExtension, "text/") {
+			return byExtension
+		}
+	}
+	return contentType
+}
+
+// countingReader counts the bytes read
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
@@ -114,5 +114,5 @@ func (s *authService) Register(request models.RegisterRequest) (models.RegisterR
 		return models.RegisterResponse{}, err
 	}
 
-	return models.RegisterResponse{}, nil
+	return models.RegisterResponse{UserId: newUser.Id}, nil
 }
@@ -0,0 +1,377 @@
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labbs/zotion/pkg/models"
+)
+
+// maxInferredOptions is the maximum number of distinct values of a column inferred as a select
+const maxInferredOptions = 20
+
+// csvColumn is the target of a column of an imported file, a property of the schema by index
+type csvColumn struct {
+	name     bool
+	property int
+}
+
+// ImportCSV creates a row of the database for each line of the csv file, the first line is the header.
+// The columns are mapped to the properties and the missing properties are created, their type is inferred
+// from the values. The lines which fail the validation are reported and not imported.
+func (s *databaseService) ImportCSV(databaseId string, reader io.Reader, options models.CSVImportOptions) (models.CSVImport, error) {
+	report := models.CSVImport{CreatedProperties: []string{}, Errors: []models.CSVImportError{}}
+
+	database, err := s.GetDatabase(databaseId)
+	if err != nil {
+		return report, err
+	}
+
+	header, records, lines, err := readCSV(reader, options.Delimiter)
+	if err != nil {
+		return report, err
+	}
+
+	// the options are copied because the conversions can add some to the schema
+	schema := slices.Clone(database.Schema)
+	for i := range schema {
+		schema[i].Options = slices.Clone(schema[i].Options)
+	}
+
+	columns, err := mapColumns(header, records, &schema, options.Mapping)
+	if err != nil {
+		return report, err
+	}
+	for _, definition := range schema[len(database.Schema):] {
+		report.CreatedProperties = append(report.CreatedProperties, definition.Name)
+	}
+	if err := schema.Normalize(); err != nil {
+		return report, validationError("schema", err.Error())
+	}
+
+	// the lines are converted first to save the options added to the schema once
+	rows := make([]models.Document, len(records))
+	failures := make([][]models.PropertyError, len(records))
+	for i, record := range records {
+		rows[i] = models.Document{
+			Name:      "Untitled",
+			SpaceId:   database.SpaceId,
+			ParentId:  database.Id,
+			Type:      models.DocumentTypeDocument,
+			CreatedBy: options.UserId,
+			UpdatedBy: options.UserId,
+		}
+		for j, column := range columns {
+			if column == nil || j >= len(record) {
+				continue
+			}
+			text := strings.TrimSpace(record[j])
+			if column.name {
+				if text != "" {
+					rows[i].Name = text
+				}
+				continue
+			}
+			definition := &schema[column.property]
+			value := definition.FromText(text)
+			if text != "" && value == "" {
+				failures[i] = append(failures[i], models.PropertyError{Property: definition.Name, Error: fmt.Sprintf("can't convert %q to %s", text, definition.Type)})
+				continue
+			}
+			if value != "" {
+				rows[i].Properties = append(rows[i].Properties, models.Propertie{Id: definition.Id, Name: definition.Name, Value: value})
+			}
+		}
+	}
+
+	if !slices.EqualFunc(schema, database.Schema, func(a, b models.PropertyDefinition) bool {
+		return a.Id == b.Id && len(a.Options) == len(b.Options)
+	}) {
+		if _, err := s.UpdateSchema(database.Id, schema); err != nil {
+			return report, err
+		}
+	}
+
+	for i, row := range rows {
+		var validationErr *models.ValidationError
+		if len(failures[i]) == 0 {
+			row.Properties, err = s.ValidateRow(row)
+			if errors.As(err, &validationErr) {
+				failures[i] = validationErr.Errors
+			} else if err != nil {
+				return report, err
+			}
+		}
+		if len(failures[i]) > 0 {
+			report.Failed++
+			report.Errors = append(report.Errors, models.CSVImportError{Line: lines[i], Errors: failures[i]})
+			continue
+		}
+
+		row, err = s.documentRepository.CreateDocument(row)
+		if err != nil {
+			return report, err
+		}
+		if err := s.SyncRow(row, nil); err != nil {
+			return report, err
+		}
+		report.Imported++
+	}
+	return report, nil
+}
+
+// readCSV reads the header and the records of a csv file with their line numbers
+func readCSV(reader io.Reader, delimiter rune) ([]string, [][]string, []int, error) {
+	r := csv.NewReader(reader)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	if delimiter != 0 {
+		r.Comma = delimiter
+	}
+
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, nil, validationError("file", "the file is empty")
+	}
+	if err != nil {
+		return nil, nil, nil, validationError("file", err.Error())
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+	var records [][]string
+	var lines []int
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, nil, validationError("file", err.Error())
+		}
+		line, _ := r.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+	return header, records, lines, nil
+}
+
+// mapColumns returns the target of each column, nil for the skipped ones. The properties missing
+// from the schema are added to it. Without an explicit mapping, the column named "name" or else
+// the first column is the name of the rows.
+func mapColumns(header []string, records [][]string, schema *models.PropertySchema, mapping map[string]string) ([]*csvColumn, error) {
+	columns := make([]*csvColumn, len(header))
+	// mapped are the indexes of the properties already targeted by a column
+	mapped := map[int]bool{}
+
+	nameColumn := -1
+	for i, title := range header {
+		title = strings.TrimSpace(title)
+		target, explicit := mapping[title]
+		switch {
+		case explicit && target == "":
+			continue
+		case explicit && strings.EqualFold(target, models.RowColumnName):
+			columns[i] = &csvColumn{name: true}
+			nameColumn = i
+		case explicit:
+			definition, ok := findProperty(*schema, target)
+			if !ok {
+				return nil, validationError("mapping", fmt.Sprintf("unknown property %q", target))
+			}
+			if definition.IsComputed() {
+				return nil, validationError("mapping", fmt.Sprintf("%s is computed", definition.Name))
+			}
+			index := slices.IndexFunc(*schema, func(d models.PropertyDefinition) bool { return d.Id == definition.Id })
+			if mapped[index] {
+				return nil, validationError("mapping", fmt.Sprintf("%s is mapped by several columns", definition.Name))
+			}
+			columns[i] = &csvColumn{property: index}
+			mapped[index] = true
+		}
+	}
+	if nameColumn < 0 {
+		nameColumn = slices.IndexFunc(header, func(title string) bool {
+			_, explicit := mapping[strings.TrimSpace(title)]
+			return !explicit && strings.EqualFold(strings.TrimSpace(title), models.RowColumnName)
+		})
+	}
+	if _, explicit := mapping[strings.TrimSpace(header[0])]; nameColumn < 0 && !explicit {
+		nameColumn = 0
+	}
+
+	for i, title := range header {
+		title = strings.TrimSpace(title)
+		if _, explicit := mapping[title]; explicit {
+			continue
+		}
+		if i == nameColumn {
+			columns[i] = &csvColumn{name: true}
+			continue
+		}
+		if title == "" {
+			continue
+		}
+
+		if index := slices.IndexFunc(*schema, func(d models.PropertyDefinition) bool { return strings.EqualFold(d.Name, title) }); index >= 0 {
+			if (*schema)[index].IsComputed() || mapped[index] {
+				continue
+			}
+			columns[i] = &csvColumn{property: index}
+			mapped[index] = true
+			continue
+		}
+
+		values := make([]string, 0, len(records))
+		for _, record := range records {
+			if i < len(record) {
+				values = append(values, record[i])
+			}
+		}
+		*schema = append(*schema, models.PropertyDefinition{Name: title, Type: inferPropertyType(values)})
+		columns[i] = &csvColumn{property: len(*schema) - 1}
+		mapped[len(*schema)-1] = true
+	}
+	return columns, nil
+}
+
+// inferPropertyType returns the most specific type accepting all the values,
+// the columns with few distinct values are selects
+func inferPropertyType(values []string) models.PropertyType {
+	candidates := []models.PropertyType{models.PropertyTypeNumber, models.PropertyTypeCheckbox, models.PropertyTypeDate, models.PropertyTypeURL}
+	distinct := map[string]bool{}
+	count := 0
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		count++
+		distinct[value] = true
+		candidates = slices.DeleteFunc(candidates, func(candidate models.PropertyType) bool {
+			if candidate == models.PropertyTypeCheckbox {
+				switch strings.ToLower(value) {
+				case "true", "false", "yes", "no":
+					return false
+				}
+				return true
+			}
+			_, err := models.PropertyDefinition{Type: candidate}.Normalize(value)
+			return err != nil
+		})
+	}
+
+	switch {
+	case count == 0:
+		return models.PropertyTypeText
+	case len(candidates) > 0:
+		return candidates[0]
+	case len(distinct) <= maxInferredOptions && len(distinct)*2 <= count:
+		return models.PropertyTypeSelect
+	}
+	return models.PropertyTypeText
+}
+
+// ExportCSV writes the rows of the database the user can access as csv, with a view the rows are filtered and sorted
+// like in the view and the columns are its visible properties. The rows are written page by page.
+func (s *databaseService) ExportCSV(userId string, databaseId string, viewId string, writer io.Writer) error {
+	database, err := s.GetDatabase(databaseId)
+	if err != nil {
+		return err
+	}
+
+	var columns []string
+	if viewId != "" {
+		view, err := s.GetView(databaseId, viewId)
+		if err != nil {
+			return err
+		}
+		columns = view.Config.VisibleProperties
+	}
+	if len(columns) == 0 {
+		for _, definition := range database.Schema {
+			columns = append(columns, definition.Id)
+		}
+	}
+	if !slices.Contains(columns, models.RowColumnName) {
+		columns = append([]string{models.RowColumnName}, columns...)
+	}
+
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		switch column {
+		case models.RowColumnName:
+			header[i] = "Name"
+		case models.RowColumnCreatedAt:
+			header[i] = "Created at"
+		case models.RowColumnUpdatedAt:
+			header[i] = "Updated at"
+		default:
+			definition, _ := database.Schema.Find(column, "")
+			header[i] = definition.Name
+		}
+	}
+
+	w := csv.NewWriter(writer)
+	if err := w.Write(header); err != nil {
+		return err
+	}
+
+	query := models.DatabaseQuery{ViewId: viewId, Limit: maxQueryLimit}
+	for {
+		result, err := s.QueryDatabase(userId, databaseId, query)
+		if err != nil {
+			return err
+		}
+		for _, row := range result.Results {
+			values := propertyValues(row.Properties)
+			record := make([]string, len(columns))
+			for i, column := range columns {
+				switch column {
+				case models.RowColumnName:
+					record[i] = csvText(row.Name)
+				case models.RowColumnCreatedAt:
+					record[i] = row.CreatedAt.UTC().Format(time.RFC3339)
+				case models.RowColumnUpdatedAt:
+					record[i] = row.UpdatedAt.UTC().Format(time.RFC3339)
+				default:
+					definition, _ := database.Schema.Find(column, "")
+					record[i] = definition.Text(values[column])
+					if definition.ValueType() != models.PropertyTypeNumber {
+						record[i] = csvText(record[i])
+					}
+				}
+			}
+			if err := w.Write(record); err != nil {
+				return err
+			}
+		}
+
+		// each page is sent to the client
+		w.Flush()
+		if flusher, ok := writer.(interface{ Flush() error }); ok {
+			if err := flusher.Flush(); err != nil {
+				return err
+			}
+		}
+		if !result.HasMore {
+			return w.Error()
+		}
+		query.Offset += len(result.Results)
+	}
+}
+
+// csvText escapes the texts interpreted as formulas by the spreadsheets
+func csvText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return "'" + text
+		}
+	}
+	return text
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/labbs/zotion/pkg/formula"
+	"github.com/labbs/zotion/pkg/models"
+)
+
+// resolveFormulas type-checks the formulas of the new schema and sets the type of their result.
+// The references of the unchanged formulas follow the renaming of the properties.
+func resolveFormulas(previous models.PropertySchema, schema models.PropertySchema) error {
+	renamed := map[string]string{}
+	for _, definition := range previous {
+		if current, ok := schema.Find(definition.Id, ""); ok && current.Name != definition.Name {
+			renamed[definition.Name] = current.Name
+		}
+	}
+
+	for i := range schema {
+		definition := &schema[i]
+		if definition.Formula == nil || len(renamed) == 0 {
+			continue
+		}
+		if old, ok := previous.Find(definition.Id, ""); ok && old.Formula != nil && old.Formula.Expression == definition.Formula.Expression {
+			if expression, err := formula.Rename(definition.Formula.Expression, renamed); err == nil {
+				definition.Formula.Expression = expression
+			}
+		}
+	}
+
+	for i := range schema {
+		if schema[i].Formula == nil {
+			continue
+		}
+		if _, err := compileFormula(schema, &schema[i], map[string]bool{}); err != nil {
+			return validationError(schema[i].Name, err.Error())
+		}
+	}
+	return nil
+}
+
+// compileFormula compiles the formula of the property, the formulas it references are compiled first
+// to know their type. The properties being compiled are tracked to detect the circular references.
+func compileFormula(schema models.PropertySchema, definition *models.PropertyDefinition, compiling map[string]bool) (*formula.Expression, error) {
+	if compiling[definition.Id] {
+		return nil, fmt.Errorf("circular reference to %s", definition.Name)
+	}
+	compiling[definition.Id] = true
+	defer delete(compiling, definition.Id)
+
+	var resolveErr error
+	expression, err := formula.Compile(definition.Formula.Expression, func(name string) (formula.Type, bool) {
+		referenced, ok := schema.Find("", name)
+		if !ok {
+			return "", false
+		}
+		if referenced.Formula != nil {
+			if _, err := compileFormula(schema, referenced, compiling); err != nil && resolveErr == nil {
+				resolveErr = err
+			}
+		}
+		return formulaType(*referenced), true
+	})
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	definition.Formula.Type = propertyType(expression.Type())
+	return expression, nil
+}
+
+// evaluateFormulas computes the formulas of the values of a row, the formulas referencing other formulas
+// are computed after them. The evaluation errors are returned by property id.
+func evaluateFormulas(schema models.PropertySchema, values map[string]string) map[string]string {
+	errs := map[string]string{}
+	evaluated := map[string]bool{}
+
+	var evaluate func(definition *models.PropertyDefinition)
+	evaluate = func(definition *models.PropertyDefinition) {
+		if evaluated[definition.Id] {
+			return
+		}
+		evaluated[definition.Id] = true
+		values[definition.Id] = ""
+
+		expression, err := formula.Compile(definition.Formula.Expression, func(name string) (formula.Type, bool) {
+			referenced, ok := schema.Find("", name)
+			if !ok {
+				return "", false
+			}
+			return formulaType(*referenced), true
+		})
+		if err != nil {
+			errs[definition.Id] = err.Error()
+			return
+		}
+
+		value, err := expression.Eval(func(name string) formula.Value {
+			referenced, _ := schema.Find("", name)
+			if referenced.Formula != nil {
+				evaluate(referenced)
+			}
+			return formulaValue(*referenced, values[referenced.Id])
+		})
+		if err != nil {
+			errs[definition.Id] = err.Error()
+			return
+		}
+		values[definition.Id] = value.String()
+	}
+
+	for i := range schema {
+		if schema[i].Formula != nil {
+			evaluate(&schema[i])
+		}
+	}
+	return errs
+}
+
+// formulaType returns the type of the values of a property in the formulas, the lists are texts
+func formulaType(definition models.PropertyDefinition) formula.Type {
+	switch definition.ValueType() {
+	case models.PropertyTypeNumber:
+		return formula.TypeNumber
+	case models.PropertyTypeCheckbox:
+		return formula.TypeBoolean
+	case models.PropertyTypeDate:
+		return formula.TypeDate
+	}
+	return formula.TypeText
+}
+
+// propertyType returns the type of the values of a formula result
+func propertyType(typ formula.Type) models.PropertyType {
+	switch typ {
+	case formula.TypeNumber:
+		return models.PropertyTypeNumber
+	case formula.TypeBoolean:
+		return models.PropertyTypeCheckbox
+	case formula.TypeDate:
+		return models.PropertyTypeDate
+	}
+	return models.PropertyTypeText
+}
+
+// formulaValue converts the value of a property to a formula value, an empty value is the zero value of the type
+func formulaValue(definition models.PropertyDefinition, value string) formula.Value {
+	switch formulaType(definition) {
+	case formula.TypeNumber:
+		number, _ := strconv.ParseFloat(value, 64)
+		return formula.Number(number)
+	case formula.TypeBoolean:
+		return formula.Boolean(value == "true")
+	case formula.TypeDate:
+		for _, layout := range []string{time.DateOnly, time.RFC3339} {
+			if date, err := time.Parse(layout, value); err == nil {
+				return formula.Date(date.UTC())
+			}
+		}
+		return formula.Date(time.Time{})
+	}
+	return formula.Text(definition.Text(value))
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/labbs/zotion/pkg/models"
+)
+
+func formulaProperty(id string, name string, expression string) models.PropertyDefinition {
+	return models.PropertyDefinition{Id: id, Name: name, Type: models.PropertyTypeFormula, Formula: &models.FormulaConfig{Expression: expression}}
+}
+
+func TestResolveFormulas(t *testing.T) {
+	points := models.PropertyDefinition{Id: "p", Name: "Points", Type: models.PropertyTypeNumber}
+	tests := []struct {
+		name      string
+		schema    models.PropertySchema
+		wantTypes map[string]models.PropertyType
+		wantErr   string
+	}{
+		{"types of the results", models.PropertySchema{
+			points,
+			formulaProperty("a", "Double", `prop("Points") * 2`),
+			formulaProperty("b", "Label", `"x" + prop("Double")`),
+			formulaProperty("c", "High", `prop("Double") > 10`),
+		}, map[string]models.PropertyType{"a": models.PropertyTypeNumber, "b": models.PropertyTypeText, "c": models.PropertyTypeCheckbox}, ""},
+		{"formula referencing a later formula", models.PropertySchema{
+			formulaProperty("a", "Late", `dateAdd(prop("Due"), 1, "days")`),
+			formulaProperty("b", "Due", `date("2026-10-19")`),
+		}, map[string]models.PropertyType{"a": models.PropertyTypeDate, "b": models.PropertyTypeDate}, ""},
+		{"self reference", models.PropertySchema{
+			formulaProperty("a", "Loop", `prop("Loop") + 1`),
+		}, nil, "Loop: circular reference to Loop"},
+		{"indirect cycle", models.PropertySchema{
+			points,
+			formulaProperty("a", "A", `prop("B") + prop("Points")`),
+			formulaProperty("b", "B", `prop("C") * 2`),
+			formulaProperty("c", "C", `prop("A") - 1`),
+		}, nil, "circular reference to A"},
+		{"unknown property", models.PropertySchema{
+			formulaProperty("a", "A", `prop("Missing")`),
+		}, nil, `A: unknown property "Missing"`},
+		{"type error", models.PropertySchema{
+			points,
+			formulaProperty("a", "A", `prop("Points") && true`),
+		}, nil, "A: && can't be applied to number and boolean"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := resolveFormulas(nil, tt.schema)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveFormulas() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveFormulas() unexpected error: %v", err)
+			}
+			for id, want := range tt.wantTypes {
+				definition, _ := tt.schema.Find(id, "")
+				if definition.Formula.Type != want {
+					t.Errorf("type of %s = %s, want %s", definition.Name, definition.Formula.Type, want)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveFormulasRenamesReferences(t *testing.T) {
+	previous := models.PropertySchema{
+		{Id: "p", Name: "Points", Type: models.PropertyTypeNumber},
+		formulaProperty("a", "Double", `prop("Points") * 2`),
+	}
+	schema := models.PropertySchema{
+		{Id: "p", Name: "Score", Type: models.PropertyTypeNumber},
+		formulaProperty("a", "Double", `prop("Points") * 2`),
+	}
+	if err := resolveFormulas(previous, schema); err != nil {
+		t.Fatalf("resolveFormulas() unexpected error: %v", err)
+	}
+	if expression := schema[1].Formula.Expression; expression != `prop("Score") * 2` {
+		t.Errorf("expression = %s, want the reference renamed", expression)
+	}
+}
+
+func TestEvaluateFormulas(t *testing.T) {
+	schema := models.PropertySchema{
+		{Id: "p", Name: "Points", Type: models.PropertyTypeNumber},
+		formulaProperty("a", "Total", `prop("Double") + 1`),
+		formulaProperty("b", "Double", `prop("Points") * 2`),
+		formulaProperty("c", "Ratio", `prop("Points") / prop("Zero")`),
+		formulaProperty("z", "Zero", `0`),
+	}
+	if err := resolveFormulas(nil, schema); err != nil {
+		t.Fatalf("resolveFormulas() unexpected error: %v", err)
+	}
+
+	values := map[string]string{"p": "4"}
+	errs := evaluateFormulas(schema, values)
+	if values["a"] != "9" || values["b"] != "8" {
+		t.Errorf("values = %v, want Total 9 and Double 8", values)
+	}
+	if values["c"] != "" || !strings.Contains(errs["c"], "division by zero") {
+		t.Errorf("Ratio = %q with error %q, want an empty value and a division by zero", values["c"], errs["c"])
+	}
+}
@@ -0,0 +1,474 @@
+package service
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+// relations keeps the relation and rollup properties of the databases consistent.
+// It's shared by the database service, which writes the rows and the schemas,
+// and the document service, which deletes the rows.
+type relations struct {
+	documentRepository models.DocumentRepository
+}
+
+// linkedSchema is the schema of a related database updated to sync a bidirectional relation
+type linkedSchema struct {
+	database models.Document
+	schema   models.PropertySchema
+	// created lists the relation properties of the database synced with a new property of the related database
+	created []string
+}
+
+// resolveSchema checks the relations and the rollups of the new schema of the database.
+// The synced properties of the bidirectional relations are created in the related databases,
+// or unlinked when the relation is removed, the related schemas to save are returned.
+func (r relations) resolveSchema(database models.Document, schema models.PropertySchema) (map[string]*linkedSchema, error) {
+	linked := map[string]*linkedSchema{}
+	target := func(databaseId string) (*linkedSchema, error) {
+		if l, ok := linked[databaseId]; ok {
+			return l, nil
+		}
+		related, err := r.documentRepository.GetDocumentById(databaseId)
+		if err != nil || related.Type != models.DocumentTypeDatabase {
+			return nil, fmt.Errorf("database %s not found", databaseId)
+		}
+		// the relations are copied to compare the schemas once updated
+		schema := slices.Clone(related.Schema)
+		for i := range schema {
+			if schema[i].Relation != nil {
+				relation := *schema[i].Relation
+				schema[i].Relation = &relation
+			}
+		}
+		linked[databaseId] = &linkedSchema{database: related, schema: schema}
+		return linked[databaseId], nil
+	}
+
+	for i := range schema {
+		definition := &schema[i]
+		if definition.Relation == nil {
+			continue
+		}
+		relation := definition.Relation
+
+		// the synced property is managed by the server
+		relation.SyncedPropertyId = ""
+		if previous, ok := database.Schema.Find(definition.Id, ""); ok && previous.Relation != nil && previous.Relation.DatabaseId == relation.DatabaseId {
+			relation.SyncedPropertyId = previous.Relation.SyncedPropertyId
+		}
+
+		if relation.DatabaseId == database.Id {
+			if relation.Bidirectional {
+				return nil, validationError(definition.Name, "a relation to the same database can't be bidirectional")
+			}
+			continue
+		}
+
+		related, err := target(relation.DatabaseId)
+		if err != nil {
+			return nil, validationError(definition.Name, err.Error())
+		}
+
+		if !relation.Bidirectional {
+			relation.SyncedPropertyId = ""
+			continue
+		}
+		if synced, ok := related.schema.Find(relation.SyncedPropertyId, ""); ok && synced.Relation != nil && synced.Relation.DatabaseId == database.Id {
+			synced.Relation.Bidirectional = true
+			synced.Relation.SyncedPropertyId = definition.Id
+			continue
+		}
+
+		// the synced property is named after the database, it must be unique in the related schema
+		name := database.Name
+		for n := 2; ; n++ {
+			if _, exists := related.schema.Find("", name); !exists {
+				break
+			}
+			name = fmt.Sprintf("%s (%d)", database.Name, n)
+		}
+		synced := models.PropertySchema{{
+			Name:     name,
+			Type:     models.PropertyTypeRelation,
+			Relation: &models.RelationConfig{DatabaseId: database.Id, Bidirectional: true, SyncedPropertyId: definition.Id},
+		}}
+		if err := synced.Normalize(); err != nil {
+			return nil, validationError(definition.Name, err.Error())
+		}
+		related.schema = append(related.schema, synced[0])
+		related.created = append(related.created, definition.Id)
+		relation.SyncedPropertyId = synced[0].Id
+	}
+
+	// the properties synced with a removed or unidirectional relation become unidirectional
+	for _, previous := range database.Schema {
+		if previous.Relation == nil || previous.Relation.SyncedPropertyId == "" {
+			continue
+		}
+		if definition, ok := schema.Find(previous.Id, ""); ok && definition.Relation != nil && definition.Relation.SyncedPropertyId == previous.Relation.SyncedPropertyId {
+			continue
+		}
+		related, err := target(previous.Relation.DatabaseId)
+		if err != nil {
+			continue
+		}
+		if synced, ok := related.schema.Find(previous.Relation.SyncedPropertyId, ""); ok && synced.Relation != nil {
+			synced.Relation.Bidirectional = false
+			synced.Relation.SyncedPropertyId = ""
+		}
+	}
+
+	for i := range schema {
+		definition := &schema[i]
+		if definition.Rollup == nil {
+			continue
+		}
+		relation, _ := schema.Find(definition.Rollup.RelationPropertyId, "")
+
+		relatedSchema := schema
+		if relation.Relation.DatabaseId != database.Id {
+			related, err := target(relation.Relation.DatabaseId)
+			if err != nil {
+				return nil, validationError(definition.Name, err.Error())
+			}
+			relatedSchema = related.schema
+		}
+		if err := resolveRollup(definition, relatedSchema); err != nil {
+			return nil, err
+		}
+	}
+
+	for id, l := range linked {
+		if slices.EqualFunc(l.schema, l.database.Schema, equalDefinitions) {
+			delete(linked, id)
+		}
+	}
+	return linked, nil
+}
+
+// resolveRollup checks the aggregated property of the rollup and sets the type of its result
+func resolveRollup(definition *models.PropertyDefinition, relatedSchema models.PropertySchema) error {
+	rollup := definition.Rollup
+	rollup.Type = models.PropertyTypeNumber
+	if rollup.Function == models.RollupFunctionCount {
+		rollup.PropertyId = ""
+		return nil
+	}
+
+	property, ok := relatedSchema.Find(rollup.PropertyId, "")
+	if !ok {
+		property, ok = relatedSchema.Find("", rollup.PropertyId)
+	}
+	if !ok {
+		return validationError(definition.Name, fmt.Sprintf("unknown related property %q", rollup.PropertyId))
+	}
+	rollup.PropertyId = property.Id
+
+	valid := false
+	switch rollup.Function {
+	case models.RollupFunctionSum:
+		valid = property.ValueType() == models.PropertyTypeNumber
+	case models.RollupFunctionMin, models.RollupFunctionMax:
+		valid = property.ValueType() == models.PropertyTypeNumber || property.ValueType() == models.PropertyTypeDate
+		rollup.Type = property.ValueType()
+	case models.RollupFunctionPercentChecked:
+		valid = property.Type == models.PropertyTypeCheckbox
+	}
+	if !valid || property.Type == models.PropertyTypeRollup {
+		return validationError(definition.Name, fmt.Sprintf("%s can't be applied to %s", rollup.Function, property.Name))
+	}
+	return nil
+}
+
+// saveLinkedSchemas saves the related schemas updated by resolveSchema,
+// the synced properties created are filled from the relations of the rows of the database
+func (r relations) saveLinkedSchemas(database models.Document, schema models.PropertySchema, linked map[string]*linkedSchema) error {
+	for _, l := range linked {
+		if err := r.documentRepository.UpdateDocumentSchema(l.database.Id, l.schema); err != nil {
+			return err
+		}
+		l.database.Schema = l.schema
+		if len(l.created) == 0 {
+			continue
+		}
+
+		rows, err := r.documentRepository.GetDocumentsFirstLevelByDocumentId(database.Id)
+		if err != nil {
+			return err
+		}
+		for _, propertyId := range l.created {
+			definition, _ := schema.Find(propertyId, "")
+			for _, row := range rows {
+				related := models.DecodeList(propertyValues(row.Properties)[propertyId])
+				if err := r.linkRows(l.database, definition.Relation.SyncedPropertyId, row.Id, related, nil); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resolve drops the related rows which don't exist anymore and computes the rollups of the values of a row.
+// The deleted rows are kept in the relations until they are purged, they are not aggregated by the rollups.
+// With strict, an error is returned for each row which is not in the related database instead.
+func (r relations) resolve(schema models.PropertySchema, values map[string]string, strict bool) ([]models.PropertyError, error) {
+	var errs []models.PropertyError
+	related := map[string][]models.Document{}
+	for _, definition := range schema {
+		if definition.Relation == nil {
+			continue
+		}
+		ids := models.DecodeList(values[definition.Id])
+		if len(ids) == 0 {
+			continue
+		}
+
+		rows, err := r.documentRepository.GetDatabaseRows(definition.Relation.DatabaseId, ids)
+		if err != nil {
+			return nil, err
+		}
+		byId := map[string]models.Document{}
+		for _, row := range rows {
+			byId[row.Id] = row
+		}
+
+		var kept []string
+		for _, id := range ids {
+			if row, ok := byId[id]; ok && !slices.Contains(kept, id) {
+				kept = append(kept, id)
+				if !row.DeletedAt.Valid {
+					related[definition.Id] = append(related[definition.Id], row)
+				}
+			} else if strict && !ok {
+				errs = append(errs, models.PropertyError{Property: definition.Name, Error: fmt.Sprintf("unknown related row %q", id)})
+			}
+		}
+		values[definition.Id] = ""
+		if len(kept) > 0 {
+			values[definition.Id] = models.EncodeList(kept)
+		}
+	}
+
+	for _, definition := range schema {
+		if definition.Rollup != nil {
+			values[definition.Id] = rollupValue(definition.Rollup, related[definition.Rollup.RelationPropertyId])
+		}
+	}
+	return errs, nil
+}
+
+// rollupValue aggregates the property of the related rows, an empty value is returned
+// when there is nothing to aggregate except for the count and the sum
+func rollupValue(rollup *models.RollupConfig, rows []models.Document) string {
+	if rollup.Function == models.RollupFunctionCount {
+		return strconv.Itoa(len(rows))
+	}
+
+	var values []string
+	for _, row := range rows {
+		if value := propertyValues(row.Properties)[rollup.PropertyId]; value != "" {
+			values = append(values, value)
+		}
+	}
+
+	switch rollup.Function {
+	case models.RollupFunctionPercentChecked:
+		if len(rows) == 0 {
+			return ""
+		}
+		checked := 0
+		for _, value := range values {
+			if value == "true" {
+				checked++
+			}
+		}
+		return formatNumber(math.Round(float64(checked)*10000/float64(len(rows))) / 100)
+	case models.RollupFunctionSum:
+		sum := 0.0
+		for _, value := range values {
+			number, _ := strconv.ParseFloat(value, 64)
+			sum += number
+		}
+		return formatNumber(sum)
+	}
+
+	if len(values) == 0 {
+		return ""
+	}
+	// the dates are in iso format so they are compared as strings
+	compare := func(a, b string) int {
+		if rollup.Type != models.PropertyTypeNumber {
+			return cmp.Compare(a, b)
+		}
+		x, _ := strconv.ParseFloat(a, 64)
+		y, _ := strconv.ParseFloat(b, 64)
+		return cmp.Compare(x, y)
+	}
+	if rollup.Function == models.RollupFunctionMin {
+		return slices.MinFunc(values, compare)
+	}
+	return slices.MaxFunc(values, compare)
+}
+
+// syncRow updates the rows related to a row of a database after it was saved:
+// the synced properties of the bidirectional relations and the rollups of the rows relating to it
+func (r relations) syncRow(row models.Document, previous models.Properties) error {
+	if row.ParentId == "" {
+		return nil
+	}
+	database, err := r.documentRepository.GetDocumentById(row.ParentId)
+	if err != nil || database.Type != models.DocumentTypeDatabase {
+		return err
+	}
+
+	before, after := propertyValues(previous), propertyValues(row.Properties)
+	for _, definition := range database.Schema {
+		if definition.Relation == nil || definition.Relation.SyncedPropertyId == "" {
+			continue
+		}
+		previousIds, ids := models.DecodeList(before[definition.Id]), models.DecodeList(after[definition.Id])
+		added := slices.DeleteFunc(slices.Clone(ids), func(id string) bool { return slices.Contains(previousIds, id) })
+		removed := slices.DeleteFunc(slices.Clone(previousIds), func(id string) bool { return slices.Contains(ids, id) })
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+
+		related, err := r.documentRepository.GetDocumentById(definition.Relation.DatabaseId)
+		if err != nil {
+			return err
+		}
+		if err := r.linkRows(related, definition.Relation.SyncedPropertyId, row.Id, added, removed); err != nil {
+			return err
+		}
+	}
+
+	return r.refreshReferences(row)
+}
+
+// linkRows adds and removes the row in the synced property of the rows of the related database
+func (r relations) linkRows(related models.Document, syncedPropertyId string, rowId string, added []string, removed []string) error {
+	if _, ok := related.Schema.Find(syncedPropertyId, ""); !ok {
+		return nil
+	}
+	rows, err := r.documentRepository.GetDatabaseRows(related.Id, append(slices.Clone(added), removed...))
+	if err != nil {
+		return err
+	}
+	for _, relatedRow := range rows {
+		values := propertyValues(relatedRow.Properties)
+		ids := models.DecodeList(values[syncedPropertyId])
+		if slices.Contains(added, relatedRow.Id) && !slices.Contains(ids, rowId) {
+			ids = append(ids, rowId)
+		}
+		if slices.Contains(removed, relatedRow.Id) {
+			ids = slices.DeleteFunc(ids, func(id string) bool { return id == rowId })
+		}
+		values[syncedPropertyId] = ""
+		if len(ids) > 0 {
+			values[syncedPropertyId] = models.EncodeList(ids)
+		}
+		if err := r.saveRow(related.Schema, relatedRow, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshReferences resolves again the rows relating to a row, it's called when the row changed, was deleted,
+// restored or purged so the rollups are up to date and the purged rows are removed from the relations
+func (r relations) refreshReferences(row models.Document) error {
+	if row.ParentId == "" {
+		return nil
+	}
+	databases, err := r.documentRepository.GetRelatedDatabases(row.ParentId)
+	if err != nil {
+		return err
+	}
+	for _, database := range databases {
+		for _, definition := range database.Schema {
+			if definition.Relation == nil || definition.Relation.DatabaseId != row.ParentId {
+				continue
+			}
+			// a negative limit loads all the rows
+			filter := &models.DatabaseFilter{Property: definition.Id, Operator: models.FilterOperatorContains, Value: row.Id}
+			rows, _, err := r.documentRepository.QueryDatabaseRows(database.Id, database.Schema, models.DatabaseQuery{Filter: filter, Limit: -1})
+			if err != nil {
+				return err
+			}
+			for _, relatedRow := range rows {
+				if err := r.saveRow(database.Schema, relatedRow, propertyValues(relatedRow.Properties)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// restoreRow computes again the values of a restored row and the rollups of the rows relating to it,
+// the row stayed in their relations while it was deleted
+func (r relations) restoreRow(row models.Document) error {
+	if row.ParentId == "" {
+		return nil
+	}
+	database, err := r.documentRepository.GetDocumentById(row.ParentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// the database is still deleted, the row is resolved when the database is restored
+		return nil
+	}
+	if err != nil || database.Type != models.DocumentTypeDatabase {
+		return err
+	}
+	if err := r.saveRow(database.Schema, row, propertyValues(row.Properties)); err != nil {
+		return err
+	}
+	return r.refreshReferences(row)
+}
+
+// saveRow resolves the values of a row, computes its formulas and saves them when they changed
+func (r relations) saveRow(schema models.PropertySchema, row models.Document, values map[string]string) error {
+	if _, err := r.resolve(schema, values, false); err != nil {
+		return err
+	}
+	properties := schemaProperties(schema, values, evaluateFormulas(schema, values))
+	if slices.Equal(properties, row.Properties) {
+		return nil
+	}
+	return r.documentRepository.UpdateDocumentProperties(row.Id, properties)
+}
+
+// propertyValues returns the values of the properties by id
+func propertyValues(properties models.Properties) map[string]string {
+	values := map[string]string{}
+	for _, property := range properties {
+		values[property.Id] = property.Value
+	}
+	return values
+}
+
+// hasComputedProperties returns true if the rows of the schema must be resolved when they are written
+func hasComputedProperties(schema models.PropertySchema) bool {
+	return slices.ContainsFunc(schema, func(definition models.PropertyDefinition) bool {
+		return definition.Relation != nil || definition.IsComputed()
+	})
+}
+
+func equalDefinitions(a, b models.PropertyDefinition) bool {
+	if a.Id != b.Id || a.Name != b.Name || a.Type != b.Type || (a.Relation == nil) != (b.Relation == nil) {
+		return false
+	}
+	return a.Relation == nil || *a.Relation == *b.Relation
+}
+
+func formatNumber(number float64) string {
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
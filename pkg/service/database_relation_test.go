@@ -0,0 +1,118 @@
+package service
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+func row(id string, values ...string) models.Document {
+	document := models.Document{Id: id}
+	for i := 0; i+1 < len(values); i += 2 {
+		document.Properties = append(document.Properties, models.Propertie{Id: values[i], Value: values[i+1]})
+	}
+	return document
+}
+
+func TestRollupValue(t *testing.T) {
+	rows := []models.Document{
+		row("r1", "pts", "3", "done", "true", "due", "2026-12-01"),
+		row("r2", "pts", "10", "done", "false", "due", "2026-01-15"),
+		row("r3", "pts", "2.5", "done", "true"),
+		row("r4"),
+	}
+	tests := []struct {
+		name   string
+		rollup models.RollupConfig
+		rows   []models.Document
+		want   string
+	}{
+		{"count", models.RollupConfig{Function: models.RollupFunctionCount}, rows, "4"},
+		{"count without rows", models.RollupConfig{Function: models.RollupFunctionCount}, nil, "0"},
+		{"sum", models.RollupConfig{Function: models.RollupFunctionSum, PropertyId: "pts"}, rows, "15.5"},
+		{"sum without rows", models.RollupConfig{Function: models.RollupFunctionSum, PropertyId: "pts"}, nil, "0"},
+		{"min number", models.RollupConfig{Function: models.RollupFunctionMin, PropertyId: "pts", Type: models.PropertyTypeNumber}, rows, "2.5"},
+		{"max number is not compared as text", models.RollupConfig{Function: models.RollupFunctionMax, PropertyId: "pts", Type: models.PropertyTypeNumber}, rows, "10"},
+		{"min date", models.RollupConfig{Function: models.RollupFunctionMin, PropertyId: "due", Type: models.PropertyTypeDate}, rows, "2026-01-15"},
+		{"max date", models.RollupConfig{Function: models.RollupFunctionMax, PropertyId: "due", Type: models.PropertyTypeDate}, rows, "2026-12-01"},
+		{"max without values", models.RollupConfig{Function: models.RollupFunctionMax, PropertyId: "due", Type: models.PropertyTypeDate}, rows[3:], ""},
+		{"percent checked", models.RollupConfig{Function: models.RollupFunctionPercentChecked, PropertyId: "done"}, rows, "50"},
+		{"percent checked rounded", models.RollupConfig{Function: models.RollupFunctionPercentChecked, PropertyId: "done"}, rows[:3], "66.67"},
+		{"percent checked without rows", models.RollupConfig{Function: models.RollupFunctionPercentChecked, PropertyId: "done"}, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rollupValue(&tt.rollup, tt.rows); got != tt.want {
+				t.Errorf("rollupValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// rowsRepository returns the rows of a single related database
+type rowsRepository struct {
+	models.DocumentRepository
+	rows []models.Document
+}
+
+func (r rowsRepository) GetDatabaseRows(databaseId string, ids []string) ([]models.Document, error) {
+	var rows []models.Document
+	for _, row := range r.rows {
+		if slices.Contains(ids, row.Id) {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func TestResolve(t *testing.T) {
+	deleted := row("t3", "pts", "100")
+	deleted.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	r := relations{documentRepository: rowsRepository{rows: []models.Document{
+		row("t1", "pts", "3"),
+		row("t2", "pts", "4"),
+		deleted,
+	}}}
+	schema := models.PropertySchema{
+		{Id: "tasks", Name: "Tasks", Type: models.PropertyTypeRelation, Relation: &models.RelationConfig{DatabaseId: "db"}},
+		{Id: "total", Name: "Total", Type: models.PropertyTypeRollup, Rollup: &models.RollupConfig{RelationPropertyId: "tasks", PropertyId: "pts", Function: models.RollupFunctionSum}},
+		{Id: "count", Name: "Count", Type: models.PropertyTypeRollup, Rollup: &models.RollupConfig{RelationPropertyId: "tasks", Function: models.RollupFunctionCount}},
+	}
+
+	tests := []struct {
+		name      string
+		tasks     string
+		strict    bool
+		wantTasks string
+		wantTotal string
+		wantCount string
+		wantErrs  int
+	}{
+		{"related rows", `["t1","t2"]`, false, `["t1","t2"]`, "7", "2", 0},
+		{"duplicates are dropped", `["t1","t1"]`, false, `["t1"]`, "3", "1", 0},
+		{"unknown rows are dropped", `["t1","t9"]`, false, `["t1"]`, "3", "1", 0},
+		{"unknown rows are errors when strict", `["t1","t9"]`, true, `["t1"]`, "3", "1", 1},
+		{"deleted rows are kept but not aggregated", `["t2","t3"]`, true, `["t2","t3"]`, "4", "1", 0},
+		{"no related rows", ``, false, ``, "0", "0", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := map[string]string{"tasks": tt.tasks}
+			errs, err := r.resolve(schema, values, tt.strict)
+			if err != nil {
+				t.Fatalf("resolve() unexpected error: %v", err)
+			}
+			if len(errs) != tt.wantErrs {
+				t.Errorf("resolve() errors = %v, want %d", errs, tt.wantErrs)
+			}
+			if values["tasks"] != tt.wantTasks || values["total"] != tt.wantTotal || values["count"] != tt.wantCount {
+				t.Errorf("resolve() = %v, want tasks %s, total %s and count %s", values, tt.wantTasks, tt.wantTotal, tt.wantCount)
+			}
+		})
+	}
+}
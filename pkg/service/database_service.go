@@ -0,0 +1,671 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+type databaseService struct {
+	documentRepository     models.DocumentRepository
+	databaseViewRepository models.DatabaseViewRepository
+	userRepository         models.UserRepository
+}
+
+func NewDatabaseService(documentRepository models.DocumentRepository, databaseViewRepository models.DatabaseViewRepository, userRepository models.UserRepository) *databaseService {
+	return &databaseService{documentRepository: documentRepository, databaseViewRepository: databaseViewRepository, userRepository: userRepository}
+}
+
+// Limits of the pages of the database queries
+const (
+	defaultQueryLimit = 50
+	maxQueryLimit     = 200
+)
+
+// GetDatabase returns the database document, models.ErrNotDatabase is returned for the other documents
+func (s *databaseService) GetDatabase(databaseId string) (models.Document, error) {
+	database, err := s.documentRepository.GetDocumentById(databaseId)
+	if err != nil {
+		return database, err
+	}
+	if database.Type != models.DocumentTypeDatabase {
+		return database, models.ErrNotDatabase
+	}
+	return database, nil
+}
+
+// UpdateSchema replaces the property schema of the database and migrates its rows.
+// The values of the properties whose type changed are converted, they are emptied when it's not possible,
+// and the values of the removed properties and options are dropped.
+func (s *databaseService) UpdateSchema(databaseId string, schema models.PropertySchema) (models.Document, error) {
+	database, err := s.GetDatabase(databaseId)
+	if err != nil {
+		return database, err
+	}
+
+	if schema == nil {
+		schema = models.PropertySchema{}
+	}
+	if err := schema.Normalize(); err != nil {
+		return database, validationError("schema", err.Error())
+	}
+	// the schema, the migrated rows and the related schemas are saved together
+	err = s.documentRepository.Transaction(func(documentRepository models.DocumentRepository) error {
+		relations := relations{documentRepository: documentRepository}
+		linked, err := relations.resolveSchema(database, schema)
+		if err != nil {
+			return err
+		}
+		if err := resolveFormulas(database.Schema, schema); err != nil {
+			return err
+		}
+
+		rows, err := documentRepository.GetDocumentsFirstLevelByDocumentId(databaseId)
+		if err != nil {
+			return err
+		}
+
+		// the rows are migrated first because the conversions can add select options to the schema
+		migrated := map[string]models.Properties{}
+		for _, row := range rows {
+			properties, changed := migrateProperties(row.Properties, database.Schema, schema)
+			if changed {
+				migrated[row.Id] = properties
+			}
+		}
+
+		if err := documentRepository.UpdateDocumentSchema(databaseId, schema); err != nil {
+			return err
+		}
+		for id, properties := range migrated {
+			if err := documentRepository.UpdateDocumentProperties(id, properties); err != nil {
+				return err
+			}
+		}
+		if err := relations.saveLinkedSchemas(database, schema, linked); err != nil {
+			return err
+		}
+
+		// the relations and the rollups of the rows are resolved with the new schema
+		if !hasComputedProperties(schema) {
+			return nil
+		}
+		rows, err = documentRepository.GetDocumentsFirstLevelByDocumentId(databaseId)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := relations.saveRow(schema, row, propertyValues(row.Properties)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return database, err
+	}
+
+	// the views must not reference the removed properties
+	views, err := s.databaseViewRepository.GetViewsByDatabaseId(databaseId)
+	if err != nil {
+		return database, err
+	}
+	for _, view := range views {
+		if config, changed := pruneViewConfig(view.Config, schema); changed {
+			view.Config = config
+			view.UpdatedAt = time.Now()
+			if _, err := s.databaseViewRepository.UpdateView(view); err != nil {
+				return database, err
+			}
+		}
+	}
+
+	return s.documentRepository.GetDocumentById(databaseId)
+}
+
+// ValidateRow checks the properties of a document against the schema of its parent database
+// and returns them in their canonical form ordered like the schema.
+// The properties of the documents outside a database are returned unchanged.
+func (s *databaseService) ValidateRow(row models.Document) (models.Properties, error) {
+	if row.ParentId == "" {
+		return row.Properties, nil
+	}
+	database, err := s.documentRepository.GetDocumentById(row.ParentId)
+	if err != nil {
+		return row.Properties, err
+	}
+	if database.Type != models.DocumentTypeDatabase {
+		return row.Properties, nil
+	}
+
+	var errs []models.PropertyError
+	values := map[string]string{}
+	for _, property := range row.Properties {
+		definition, ok := database.Schema.Find(property.Id, property.Name)
+		if !ok {
+			errs = append(errs, models.PropertyError{Property: property.Name, Error: "unknown property"})
+			continue
+		}
+		// the rollups and the formulas are computed, the values sent are ignored
+		if definition.IsComputed() {
+			continue
+		}
+		if _, exists := values[definition.Id]; exists {
+			errs = append(errs, models.PropertyError{Property: definition.Name, Error: "duplicated property"})
+			continue
+		}
+		value, err := definition.Normalize(property.Value)
+		if err != nil {
+			errs = append(errs, models.PropertyError{Property: definition.Name, Error: err.Error()})
+			continue
+		}
+		values[definition.Id] = value
+	}
+
+	relationErrs, err := s.relations().resolve(database.Schema, values, true)
+	if err != nil {
+		return row.Properties, err
+	}
+	errs = append(errs, relationErrs...)
+	if len(errs) > 0 {
+		return row.Properties, &models.ValidationError{Errors: errs}
+	}
+
+	return schemaProperties(database.Schema, values, evaluateFormulas(database.Schema, values)), nil
+}
+
+// SyncRow updates the rows related to a saved row: the bidirectional relations of the rows added or removed
+// from its relations and the rollups of the rows relating to it. The previous properties are nil for a new row.
+func (s *databaseService) SyncRow(row models.Document, previous models.Properties) error {
+	return s.documentRepository.Transaction(func(documentRepository models.DocumentRepository) error {
+		return relations{documentRepository: documentRepository}.syncRow(row, previous)
+	})
+}
+
+func (s *databaseService) relations() relations {
+	return relations{documentRepository: s.documentRepository}
+}
+
+// QueryDatabase returns a page of the rows of the database matching the query, the rows breaking the inheritance
+// are left out for the users who aren't their members. The properties can be referenced by id or name,
+// they are resolved before running the query in sql.
+func (s *databaseService) QueryDatabase(userId string, databaseId string, query models.DatabaseQuery) (models.DatabaseQueryResult, error) {
+	result := models.DatabaseQueryResult{Results: []models.Document{}}
+
+	database, err := s.GetDatabase(databaseId)
+	if err != nil {
+		return result, err
+	}
+
+	if query.ViewId != "" {
+		view, err := s.GetView(databaseId, query.ViewId)
+		if err != nil {
+			return result, err
+		}
+		query.Filter = combineFilters(view.Config.Filter, query.Filter)
+		if len(query.Sorts) == 0 {
+			query.Sorts = view.Config.Sorts
+		}
+		if query.GroupBy == "" {
+			query.GroupBy = view.Config.GroupBy
+		}
+	}
+
+	if err := resolveQuery(&query, database.Schema); err != nil {
+		return result, err
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = defaultQueryLimit
+	}
+	query.Limit = min(query.Limit, maxQueryLimit)
+	query.Offset = max(query.Offset, 0)
+
+	query.ExcludedIds, err = s.restrictedRowIds(userId, databaseId)
+	if err != nil {
+		return result, err
+	}
+
+	// the rows are sorted by group first to page them group by group
+	if query.GroupBy != "" {
+		query.Sorts = append([]models.DatabaseSort{{Property: query.GroupBy, Direction: models.SortDirectionAscending}}, query.Sorts...)
+		result.Groups, err = s.documentRepository.GroupDatabaseRows(databaseId, database.Schema, query)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	result.Results, result.Total, err = s.documentRepository.QueryDatabaseRows(databaseId, database.Schema, query)
+	if err != nil {
+		return result, err
+	}
+	result.Offset = query.Offset
+	result.Limit = query.Limit
+	result.HasMore = int64(query.Offset+len(result.Results)) < result.Total
+	return result, nil
+}
+
+// restrictedRowIds returns the ids of the rows breaking the inheritance the user isn't a member of.
+// The other rows inherit the access of the database.
+func (s *databaseService) restrictedRowIds(userId string, databaseId string) ([]string, error) {
+	rows, err := s.documentRepository.GetRestrictedDatabaseRows(databaseId)
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	groups, err := s.userRepository.GetGroupsByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, row := range rows {
+		chain := models.AccessChain{Documents: []models.Document{row}}
+		if _, ok := chain.GetAccess(userId, groups); !ok {
+			ids = append(ids, row.Id)
+		}
+	}
+	return ids, nil
+}
+
+// GetViews returns the saved views of the database
+func (s *databaseService) GetViews(databaseId string) ([]models.DatabaseView, error) {
+	if _, err := s.GetDatabase(databaseId); err != nil {
+		return nil, err
+	}
+	return s.databaseViewRepository.GetViewsByDatabaseId(databaseId)
+}
+
+// GetView returns a saved view of the database, gorm.ErrRecordNotFound is returned when it belongs to another database
+func (s *databaseService) GetView(databaseId string, viewId string) (models.DatabaseView, error) {
+	view, err := s.databaseViewRepository.GetViewById(viewId)
+	if err != nil {
+		return view, err
+	}
+	if view.DatabaseId != databaseId {
+		return view, gorm.ErrRecordNotFound
+	}
+	return view, nil
+}
+
+// CreateView checks and saves a new view of the database
+func (s *databaseService) CreateView(view models.DatabaseView) (models.DatabaseView, error) {
+	database, err := s.GetDatabase(view.DatabaseId)
+	if err != nil {
+		return view, err
+	}
+	if err := resolveView(&view, database.Schema); err != nil {
+		return view, err
+	}
+	return s.databaseViewRepository.CreateView(view)
+}
+
+// UpdateView checks and saves the view
+func (s *databaseService) UpdateView(view models.DatabaseView) (models.DatabaseView, error) {
+	database, err := s.GetDatabase(view.DatabaseId)
+	if err != nil {
+		return view, err
+	}
+	if _, err := s.GetView(view.DatabaseId, view.Id); err != nil {
+		return view, err
+	}
+	if err := resolveView(&view, database.Schema); err != nil {
+		return view, err
+	}
+	view.UpdatedAt = time.Now()
+	if _, err := s.databaseViewRepository.UpdateView(view); err != nil {
+		return view, err
+	}
+	return s.databaseViewRepository.GetViewById(view.Id)
+}
+
+// DeleteView removes a view of the database
+func (s *databaseService) DeleteView(databaseId string, viewId string) error {
+	if _, err := s.GetView(databaseId, viewId); err != nil {
+		return err
+	}
+	return s.databaseViewRepository.DeleteView(viewId)
+}
+
+// resolveView checks the view, the properties of its configuration are resolved to their id
+func resolveView(view *models.DatabaseView, schema models.PropertySchema) error {
+	view.Name = strings.TrimSpace(view.Name)
+	if view.Name == "" {
+		return validationError("name", "name is required")
+	}
+	if !view.Type.IsValid() {
+		return validationError("type", fmt.Sprintf("unknown view type %q", view.Type))
+	}
+
+	config := &view.Config
+	query := models.DatabaseQuery{Filter: config.Filter, Sorts: config.Sorts, GroupBy: config.GroupBy}
+	if err := resolveQuery(&query, schema); err != nil {
+		return err
+	}
+	config.Filter, config.Sorts, config.GroupBy = query.Filter, query.Sorts, query.GroupBy
+
+	if view.Type == models.DatabaseViewTypeBoard && config.GroupBy == "" {
+		return validationError("group_by", "a board view must be grouped by a property")
+	}
+
+	if config.DateProperty != "" {
+		definition, ok := findProperty(schema, config.DateProperty)
+		if !ok || definition.Type != models.PropertyTypeDate {
+			return validationError("date_property", fmt.Sprintf("%q is not a date property", config.DateProperty))
+		}
+		config.DateProperty = definition.Id
+	}
+	if view.Type == models.DatabaseViewTypeCalendar && config.DateProperty == "" {
+		return validationError("date_property", "a calendar view requires a date property")
+	}
+
+	for i, property := range config.VisibleProperties {
+		id, _, err := resolveColumn(schema, property)
+		if err != nil {
+			return validationError("visible_properties", err.Error())
+		}
+		config.VisibleProperties[i] = id
+	}
+	return nil
+}
+
+// resolveQuery resolves the properties of the query to their id and normalizes the values of the filters
+func resolveQuery(query *models.DatabaseQuery, schema models.PropertySchema) error {
+	if query.Filter != nil {
+		if err := resolveFilter(query.Filter, schema); err != nil {
+			return err
+		}
+	}
+
+	for i := range query.Sorts {
+		id, _, err := resolveColumn(schema, query.Sorts[i].Property)
+		if err != nil {
+			return validationError("sorts", err.Error())
+		}
+		query.Sorts[i].Property = id
+		switch query.Sorts[i].Direction {
+		case "":
+			query.Sorts[i].Direction = models.SortDirectionAscending
+		case models.SortDirectionAscending, models.SortDirectionDescending:
+		default:
+			return validationError("sorts", fmt.Sprintf("unknown direction %q", query.Sorts[i].Direction))
+		}
+	}
+
+	if query.GroupBy != "" {
+		definition, ok := findProperty(schema, query.GroupBy)
+		if !ok {
+			return validationError("group_by", fmt.Sprintf("unknown property %q", query.GroupBy))
+		}
+		query.GroupBy = definition.Id
+	}
+	return nil
+}
+
+// resolveFilter resolves the properties of the filter to their id and normalizes the values
+func resolveFilter(filter *models.DatabaseFilter, schema models.PropertySchema) error {
+	if len(filter.And) > 0 && len(filter.Or) > 0 {
+		return validationError("filter", "a filter can't combine and and or")
+	}
+	for i := range filter.And {
+		if err := resolveFilter(&filter.And[i], schema); err != nil {
+			return err
+		}
+	}
+	for i := range filter.Or {
+		if err := resolveFilter(&filter.Or[i], schema); err != nil {
+			return err
+		}
+	}
+	if len(filter.And) > 0 || len(filter.Or) > 0 {
+		return nil
+	}
+
+	id, definition, err := resolveColumn(schema, filter.Property)
+	if err != nil {
+		return validationError("filter", err.Error())
+	}
+	filter.Property = id
+
+	switch filter.Operator {
+	case models.FilterOperatorIsEmpty, models.FilterOperatorIsNotEmpty:
+		filter.Value = ""
+		return nil
+	case models.FilterOperatorContains, models.FilterOperatorNotContains, models.FilterOperatorStartsWith, models.FilterOperatorEndsWith:
+		if definition != nil && definition.Type.IsList() && (filter.Operator == models.FilterOperatorStartsWith || filter.Operator == models.FilterOperatorEndsWith) {
+			return validationError("filter", fmt.Sprintf("operator %s can't be used on %s", filter.Operator, definition.Name))
+		}
+	case models.FilterOperatorEquals, models.FilterOperatorNotEquals,
+		models.FilterOperatorGreaterThan, models.FilterOperatorGreaterOrEqual,
+		models.FilterOperatorLessThan, models.FilterOperatorLessOrEqual:
+	default:
+		return validationError("filter", fmt.Sprintf("unknown operator %q", filter.Operator))
+	}
+
+	if definition == nil || filter.Value == "" {
+		return nil
+	}
+
+	// the values are compared in their canonical form, a list is compared on one of its elements
+	if definition.Type.IsList() {
+		if option, ok := definition.Option(filter.Value); ok {
+			filter.Value = option.Name
+		}
+		return nil
+	}
+	switch definition.Type {
+	case models.PropertyTypeNumber, models.PropertyTypeCheckbox, models.PropertyTypeDate, models.PropertyTypeSelect:
+		if filter.Operator == models.FilterOperatorContains || filter.Operator == models.FilterOperatorNotContains {
+			return nil
+		}
+		value, err := definition.Normalize(filter.Value)
+		if err != nil {
+			return validationError("filter", definition.Name+": "+err.Error())
+		}
+		filter.Value = value
+	}
+	return nil
+}
+
+// resolveColumn returns the id of a property, referenced by id or name, or the built-in column.
+// The definition is nil for the built-in columns.
+func resolveColumn(schema models.PropertySchema, property string) (string, *models.PropertyDefinition, error) {
+	if definition, ok := schema.Find(property, ""); ok {
+		return definition.Id, definition, nil
+	}
+	switch property {
+	case models.RowColumnName, models.RowColumnCreatedAt, models.RowColumnUpdatedAt:
+		return property, nil, nil
+	}
+	if definition, ok := schema.Find("", property); ok {
+		return definition.Id, definition, nil
+	}
+	return "", nil, fmt.Errorf("unknown property %q", property)
+}
+
+// findProperty returns the property referenced by id or name
+func findProperty(schema models.PropertySchema, property string) (*models.PropertyDefinition, bool) {
+	if definition, ok := schema.Find(property, ""); ok {
+		return definition, true
+	}
+	return schema.Find("", property)
+}
+
+// combineFilters returns a filter matching both filters
+func combineFilters(a *models.DatabaseFilter, b *models.DatabaseFilter) *models.DatabaseFilter {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	return &models.DatabaseFilter{And: []models.DatabaseFilter{*a, *b}}
+}
+
+// pruneViewConfig removes the references to the properties missing from the schema
+func pruneViewConfig(config models.DatabaseViewConfig, schema models.PropertySchema) (models.DatabaseViewConfig, bool) {
+	exists := func(property string) bool {
+		_, _, err := resolveColumn(schema, property)
+		return err == nil
+	}
+	before, _ := json.Marshal(config)
+
+	config.Filter = pruneFilter(config.Filter, exists)
+
+	sorts := []models.DatabaseSort{}
+	for _, sort := range config.Sorts {
+		if exists(sort.Property) {
+			sorts = append(sorts, sort)
+		}
+	}
+	config.Sorts = sorts
+
+	if config.GroupBy != "" && !exists(config.GroupBy) {
+		config.GroupBy = ""
+	}
+	if config.DateProperty != "" && !exists(config.DateProperty) {
+		config.DateProperty = ""
+	}
+
+	visible := []string{}
+	for _, property := range config.VisibleProperties {
+		if exists(property) {
+			visible = append(visible, property)
+		}
+	}
+	config.VisibleProperties = visible
+
+	after, _ := json.Marshal(config)
+	return config, string(before) != string(after)
+}
+
+// pruneFilter removes the conditions on the properties which don't exist, nil is returned when nothing is left
+func pruneFilter(filter *models.DatabaseFilter, exists func(string) bool) *models.DatabaseFilter {
+	if filter == nil {
+		return nil
+	}
+	if len(filter.And) == 0 && len(filter.Or) == 0 {
+		if !exists(filter.Property) {
+			return nil
+		}
+		return filter
+	}
+
+	pruned := &models.DatabaseFilter{}
+	for _, f := range filter.And {
+		if f := pruneFilter(&f, exists); f != nil {
+			pruned.And = append(pruned.And, *f)
+		}
+	}
+	for _, f := range filter.Or {
+		if f := pruneFilter(&f, exists); f != nil {
+			pruned.Or = append(pruned.Or, *f)
+		}
+	}
+	if len(pruned.And) == 0 && len(pruned.Or) == 0 {
+		return nil
+	}
+	return pruned
+}
+
+// validationError returns a validation error of a single property or field
+func validationError(property string, message string) error {
+	return &models.ValidationError{Errors: []models.PropertyError{{Property: property, Error: message}}}
+}
+
+// migrateProperties converts the properties of a row from the old schema to the new one
+func migrateProperties(properties models.Properties, oldSchema models.PropertySchema, newSchema models.PropertySchema) (models.Properties, bool) {
+	values := map[string]string{}
+	for _, property := range properties {
+		oldDefinition, ok := oldSchema.Find(property.Id, property.Name)
+		if !ok {
+			continue
+		}
+		newDefinition, ok := newSchema.Find(oldDefinition.Id, "")
+		if !ok {
+			continue
+		}
+		values[newDefinition.Id] = migrateValue(property.Value, *oldDefinition, newDefinition)
+	}
+
+	migrated := schemaProperties(newSchema, values, nil)
+	if len(migrated) != len(properties) {
+		return migrated, true
+	}
+	for i := range migrated {
+		if migrated[i] != properties[i] {
+			return migrated, true
+		}
+	}
+	return migrated, false
+}
+
+// migrateValue converts a value of the old definition of a property to the new one
+func migrateValue(value string, oldDefinition models.PropertyDefinition, newDefinition *models.PropertyDefinition) string {
+	if value == "" {
+		return ""
+	}
+
+	// the options are found by id to follow their renaming, also between a select and a multi-select
+	if hasOptions(oldDefinition.Type) && hasOptions(newDefinition.Type) {
+		names := []string{value}
+		if oldDefinition.Type.IsList() {
+			names = nil
+			json.Unmarshal([]byte(value), &names)
+		}
+		var renamed []string
+		for _, name := range names {
+			if option, ok := oldDefinition.Option(name); ok {
+				if option, ok := newDefinition.Option(option.Id); ok {
+					renamed = append(renamed, option.Name)
+				}
+			}
+		}
+		if newDefinition.Type.IsList() {
+			if len(renamed) == 0 {
+				return ""
+			}
+			data, _ := json.Marshal(renamed)
+			return string(data)
+		}
+		if len(renamed) == 0 {
+			return ""
+		}
+		return renamed[0]
+	}
+
+	if oldDefinition.Type == newDefinition.Type {
+		if normalized, err := newDefinition.Normalize(value); err == nil {
+			return normalized
+		}
+		return ""
+	}
+	return newDefinition.FromText(oldDefinition.Text(value))
+}
+
+func hasOptions(propertyType models.PropertyType) bool {
+	return propertyType == models.PropertyTypeSelect || propertyType == models.PropertyTypeMultiSelect
+}
+
+// schemaProperties returns the non empty values and the formula errors as properties ordered like the schema
+func schemaProperties(schema models.PropertySchema, values map[string]string, formulaErrors map[string]string) models.Properties {
+	properties := models.Properties{}
+	for i, definition := range schema {
+		value := values[definition.Id]
+		if value == "" && formulaErrors[definition.Id] == "" {
+			continue
+		}
+		properties = append(properties, models.Propertie{
+			Id:    definition.Id,
+			Name:  definition.Name,
+			Type:  string(definition.Type),
+			Value: value,
+			Order: i,
+			Error: formulaErrors[definition.Id],
+		})
+	}
+	return properties
+}
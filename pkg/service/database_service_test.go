@@ -0,0 +1,54 @@
+package service
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/labbs/zotion/pkg/models"
+)
+
+// restrictedRows returns the rows of the database breaking the inheritance
+type restrictedRows struct {
+	models.DocumentRepository
+	rows []models.Document
+}
+
+func (r restrictedRows) GetRestrictedDatabaseRows(databaseId string) ([]models.Document, error) {
+	return r.rows, nil
+}
+
+// groupMembers puts every user in the group g
+type groupMembers struct {
+	models.UserRepository
+}
+
+func (groupMembers) GetGroupsByUserId(userId string) ([]models.Group, error) {
+	return []models.Group{{Id: "g"}}, nil
+}
+
+func TestRestrictedRowIds(t *testing.T) {
+	s := &databaseService{userRepository: groupMembers{}, documentRepository: restrictedRows{rows: []models.Document{
+		{Id: "alice", InheritanceBroken: true, Members: models.Members{{Id: "alice", Type: models.MemberTypeUser, Access: models.AccessTypeViewer}}},
+		{Id: "group", InheritanceBroken: true, Members: models.Members{{Id: "g", Type: models.MemberTypeGroup, Access: models.AccessTypeViewer}}},
+		{Id: "nobody", InheritanceBroken: true},
+	}}}
+	tests := []struct {
+		userId  string
+		wantIds []string
+	}{
+		{"alice", []string{"nobody"}},
+		{"bob", []string{"alice", "nobody"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.userId, func(t *testing.T) {
+			ids, err := s.restrictedRowIds(tt.userId, "db")
+			if err != nil {
+				t.Fatalf("restrictedRowIds() unexpected error: %v", err)
+			}
+			if !slices.Equal(ids, tt.wantIds) {
+				t.Errorf("restrictedRowIds() = %v, want %v", ids, tt.wantIds)
+			}
+		})
+	}
+}
@@ -32,8 +32,31 @@ func (s *documentService) GetDocumentById(id string) (models.Document, error) {
 	return s.documentRepository.GetDocumentById(id)
 }
 
+// UpdateDocument saves the document, the rows related to the document are synced with its previous
+// properties in the same transaction. The collaborative state is removed when the content changed,
+// otherwise the collaborative editors would write the previous content back.
 func (s *documentService) UpdateDocument(document models.Document) (models.Document, error) {
-	return s.documentRepository.UpdateDocument(document)
+	err := s.documentRepository.Transaction(func(documentRepository models.DocumentRepository) error {
+		previous, err := documentRepository.GetDocumentById(document.Id)
+		if err != nil {
+			return err
+		}
+		document, err = documentRepository.UpdateDocument(document)
+		if err != nil {
+			return err
+		}
+		if document.Content != previous.Content {
+			if err := documentRepository.DeleteDocumentState(document.Id); err != nil {
+				return err
+			}
+		}
+		return relations{documentRepository: documentRepository}.syncRow(document, previous.Properties)
+	})
+	return document, err
+}
+
+func (s *documentService) UpdateDocumentContent(id string, content string) error {
+	return s.documentRepository.UpdateDocumentContent(id, content)
 }
 
 func (s *documentService) DeleteDocument(id string) error {
@@ -58,7 +81,43 @@ func (s *documentService) DeleteDocument(id string) error {
 			}
 		}
 	}
-	return s.documentRepository.DeleteDocument(id)
+	if err := s.documentRepository.DeleteDocument(id); err != nil {
+		return err
+	}
+	// the deleted row is no longer aggregated by the rollups of the other rows, it stays in their relations until it's purged
+	return s.documentRepository.Transaction(func(documentRepository models.DocumentRepository) error {
+		return relations{documentRepository: documentRepository}.refreshReferences(document)
+	})
+}
+
+// PurgeDocument permanently deletes the document and its child documents, deleted or not.
+// The ids of the purged documents are returned to clean up their related data.
+func (s *documentService) PurgeDocument(id string) ([]string, error) {
+	childIds, err := s.documentRepository.GetChildDocumentIds(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var purged []string
+	for _, childId := range childIds {
+		ids, err := s.PurgeDocument(childId)
+		purged = append(purged, ids...)
+		if err != nil {
+			return purged, err
+		}
+	}
+
+	document, err := s.documentRepository.PurgeDocument(id)
+	if err != nil {
+		return purged, err
+	}
+	purged = append(purged, id)
+
+	// the purged row is removed from the relations of the other rows
+	err = s.documentRepository.Transaction(func(documentRepository models.DocumentRepository) error {
+		return relations{documentRepository: documentRepository}.refreshReferences(document)
+	})
+	return purged, err
 }
 
 func (s *documentService) GetAllDocuments() ([]models.Document, error) {
@@ -69,10 +128,32 @@ func (s *documentService) GetAllDeletedDocument() ([]models.Document, error) {
 	return s.documentRepository.GetAllDeletedDocument()
 }
 
+// RestoreDocument restores a deleted document, a restored row is aggregated again by the rollups of the rows relating to it
 func (s *documentService) RestoreDocument(id string) error {
-	return s.documentRepository.RestoreDocument(id)
+	return s.documentRepository.Transaction(func(documentRepository models.DocumentRepository) error {
+		if err := documentRepository.RestoreDocument(id); err != nil {
+			return err
+		}
+		document, err := documentRepository.GetDocumentById(id)
+		if err != nil {
+			return err
+		}
+		return relations{documentRepository: documentRepository}.restoreRow(document)
+	})
 }
 
 func (s *documentService) GetDocumentsBySpaceId(spaceId string) ([]models.Document, error) {
 	return s.documentRepository.GetDocumentsBySpaceId(spaceId)
 }
+
+func (s *documentService) GetDocumentsForMember(userId string, groups []models.Group) ([]models.Document, error) {
+	return s.documentRepository.GetDocumentsForMember(userId, groups)
+}
+
+func (s *documentService) UpdateDocumentMembers(id string, members models.Members) error {
+	return s.documentRepository.UpdateDocumentMembers(id, members)
+}
+
+func (s *documentService) UpdateDocumentInheritance(id string, inheritanceBroken bool, members models.Members) error {
+	return s.documentRepository.UpdateDocumentInheritance(id, inheritanceBroken, members)
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+type documentStateService struct {
+	documentStateRepository models.DocumentStateRepository
+}
+
+func NewDocumentStateService(documentStateRepository models.DocumentStateRepository) *documentStateService {
+	return &documentStateService{documentStateRepository: documentStateRepository}
+}
+
+// GetState returns the stored state, an empty state is returned when the document was never edited collaboratively
+func (s *documentStateService) GetState(documentId string) ([]byte, error) {
+	state, err := s.documentStateRepository.GetState(documentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return state.State, nil
+}
+
+func (s *documentStateService) SaveState(documentId string, state []byte) error {
+	return s.documentStateRepository.SaveState(models.DocumentState{
+		DocumentId: documentId,
+		State:      state,
+		UpdatedAt:  time.Now(),
+	})
+}
+
+func (s *documentStateService) DeleteState(documentId string) error {
+	return s.documentStateRepository.DeleteState(documentId)
+}
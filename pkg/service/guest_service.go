@@ -0,0 +1,190 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+type guestService struct {
+	groupRepository    models.GroupRepository
+	documentRepository models.DocumentRepository
+	spaceRepository    models.SpaceRepository
+}
+
+// NewGuestService creates a new guest service
+func NewGuestService(gr models.GroupRepository, dr models.DocumentRepository, sr models.SpaceRepository) *guestService {
+	return &guestService{
+		groupRepository:    gr,
+		documentRepository: dr,
+		spaceRepository:    sr,
+	}
+}
+
+// guests holds the guest users with their groups and the groups with the guest role
+type guests struct {
+	users      map[string]models.User
+	userGroups map[string][]models.Group
+	groups     map[string]bool
+}
+
+// guests returns the users who are guests, the users of a guest group who are not administrators
+func (s *guestService) guests() (guests, error) {
+	groups, err := s.groupRepository.GetAllGroupsWithUsers()
+	if err != nil {
+		return guests{}, err
+	}
+
+	g := guests{users: map[string]models.User{}, userGroups: map[string][]models.Group{}, groups: map[string]bool{}}
+	for _, group := range groups {
+		if group.Role == models.RoleGuest {
+			g.groups[group.Id] = true
+		}
+		for _, user := range group.Users {
+			g.userGroups[user.Id] = append(g.userGroups[user.Id], group)
+			g.users[user.Id] = user
+		}
+	}
+	for id := range g.users {
+		if !models.IsGuest(g.userGroups[id]) {
+			delete(g.users, id)
+		}
+	}
+	return g, nil
+}
+
+// MarkGuests returns a copy of the members with the guest users and the guest groups marked
+func (s *guestService) MarkGuests(members models.Members) (models.Members, error) {
+	g, err := s.guests()
+	if err != nil {
+		return members, err
+	}
+
+	marked := make(models.Members, len(members))
+	for i, member := range members {
+		switch member.Type {
+		case models.MemberTypeUser:
+			_, member.Guest = g.users[member.Id]
+		case models.MemberTypeGroup:
+			member.Guest = g.groups[member.Id]
+		}
+		marked[i] = member
+	}
+	return marked, nil
+}
+
+// MarkGuestUsers returns the users with the guests marked
+func (s *guestService) MarkGuestUsers(users []models.User) ([]models.User, error) {
+	g, err := s.guests()
+	if err != nil {
+		return users, err
+	}
+
+	for i := range users {
+		_, users[i].IsGuest = g.users[users[i].Id]
+	}
+	return users, nil
+}
+
+// GetGuestReport returns all the guests with the documents they are invited to, directly or through their groups,
+// and the subpages inheriting their access
+func (s *guestService) GetGuestReport() ([]models.GuestReport, error) {
+	g, err := s.guests()
+	if err != nil {
+		return nil, err
+	}
+
+	spaces := map[string]models.Space{}
+	ancestors := newAncestorCache(s.documentRepository, nil)
+	reports := make([]models.GuestReport, 0, len(g.users))
+	for id, user := range g.users {
+		groups := g.userGroups[id]
+		report := models.GuestReport{
+			Id:        user.Id,
+			Name:      user.Name,
+			Email:     user.Email,
+			Active:    user.Active,
+			Groups:    make([]string, 0, len(groups)),
+			Documents: []models.GuestDocument{},
+		}
+		for _, group := range groups {
+			report.Groups = append(report.Groups, group.Name)
+		}
+
+		documents, err := s.documentRepository.GetDocumentsForMember(id, groups)
+		if err != nil {
+			return nil, err
+		}
+		reported := map[string]bool{}
+		for _, document := range documents {
+			reported[document.Id] = true
+		}
+		for _, document := range documents {
+			if _, ok := document.Members.GetAccess(id, groups); !ok {
+				continue
+			}
+			space, ok := spaces[document.SpaceId]
+			if !ok {
+				space, err = s.spaceRepository.GetSpaceById(document.SpaceId)
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, err
+				}
+				spaces[document.SpaceId] = space
+			}
+			report.Documents, err = s.guestDocuments(report.Documents, ancestors, document, space, id, groups, "", reported)
+			if err != nil {
+				return nil, err
+			}
+		}
+		reports = append(reports, report)
+	}
+
+	slices.SortFunc(reports, func(a, b models.GuestReport) int {
+		return strings.Compare(strings.ToLower(a.Email), strings.ToLower(b.Email))
+	})
+	return reports, nil
+}
+
+// guestDocuments appends the document and its subpages inheriting its access to the documents of the guest.
+// The subpages breaking the inheritance are left out, they are only reported when the guest is invited to them.
+func (s *guestService) guestDocuments(documents []models.GuestDocument, ancestors *ancestorCache, document models.Document, space models.Space, userId string, groups []models.Group, inheritedFrom string, reported map[string]bool) ([]models.GuestDocument, error) {
+	ancestors.documents[document.Id] = document
+	chain, err := accessChain(ancestors, document, space)
+	if err != nil {
+		return documents, err
+	}
+	access, ok := chain.GetAccess(userId, groups)
+	if !ok {
+		return documents, nil
+	}
+	documents = append(documents, models.GuestDocument{
+		Id:            document.Id,
+		Name:          document.Name,
+		SpaceId:       document.SpaceId,
+		SpaceName:     space.Name,
+		Access:        access,
+		InheritedFrom: inheritedFrom,
+	})
+
+	if inheritedFrom == "" {
+		inheritedFrom = document.Id
+	}
+	children, err := s.documentRepository.GetDocumentsFirstLevelByDocumentId(document.Id)
+	if err != nil {
+		return documents, err
+	}
+	for _, child := range children {
+		// the subpages the guest is invited to are reported with their own members
+		if child.InheritanceBroken || reported[child.Id] {
+			continue
+		}
+		reported[child.Id] = true
+		if documents, err = s.guestDocuments(documents, ancestors, child, space, userId, groups, inheritedFrom, reported); err != nil {
+			return documents, err
+		}
+	}
+	return documents, nil
+}
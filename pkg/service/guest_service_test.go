@@ -0,0 +1,86 @@
+package service
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/labbs/zotion/pkg/models"
+)
+
+// guestGroups has the guest g in a guest group
+type guestGroups struct {
+	models.GroupRepository
+}
+
+func (guestGroups) GetAllGroupsWithUsers() ([]models.Group, error) {
+	return []models.Group{{Id: "guests", Role: models.RoleGuest, Users: []models.User{{Id: "g", Email: "g@zotion.local"}}}}, nil
+}
+
+// memberTree finds the documents of the tree by their members
+type memberTree struct {
+	treeRepository
+}
+
+func (r memberTree) GetDocumentsForMember(userId string, groups []models.Group) ([]models.Document, error) {
+	var documents []models.Document
+	for _, document := range r.documents {
+		if _, ok := document.Members.GetAccess(userId, groups); ok {
+			documents = append(documents, document)
+		}
+	}
+	slices.SortFunc(documents, func(a, b models.Document) int { return strings.Compare(a.Id, b.Id) })
+	return documents, nil
+}
+
+func TestGetGuestReport(t *testing.T) {
+	guest := func(access models.AccessType) models.Members {
+		return models.Members{{Id: "g", Type: models.MemberTypeUser, Access: access}}
+	}
+	documents := map[string]models.Document{
+		"i":   {Id: "i", SpaceId: "s", Members: guest(models.AccessTypeViewer)},
+		"i1":  {Id: "i1", SpaceId: "s", ParentId: "i"},
+		"i11": {Id: "i11", SpaceId: "s", ParentId: "i1", Members: guest(models.AccessTypeEditor)},
+		"i2":  {Id: "i2", SpaceId: "s", ParentId: "i", InheritanceBroken: true},
+		"o":   {Id: "o", SpaceId: "s"},
+	}
+	s := NewGuestService(guestGroups{}, memberTree{treeRepository{documents: documents}}, memberSpaces{})
+
+	reports, err := s.GetGuestReport()
+	if err != nil {
+		t.Fatalf("GetGuestReport() unexpected error: %v", err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("GetGuestReport() = %d reports, want 1", len(reports))
+	}
+
+	reported := map[string]models.GuestDocument{}
+	for _, document := range reports[0].Documents {
+		reported[document.Id] = document
+	}
+
+	tests := []struct {
+		name              string
+		id                string
+		wantReported      bool
+		wantAccess        models.AccessType
+		wantInheritedFrom string
+	}{
+		{"invited document", "i", true, models.AccessTypeViewer, ""},
+		{"subpage inheriting the access", "i1", true, models.AccessTypeViewer, "i"},
+		{"invited subpage", "i11", true, models.AccessTypeEditor, ""},
+		{"subpage breaking the inheritance", "i2", false, "", ""},
+		{"document of the space", "o", false, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			document, ok := reported[tt.id]
+			if ok != tt.wantReported || document.Access != tt.wantAccess || document.InheritedFrom != tt.wantInheritedFrom {
+				t.Errorf("document %s = %+v (reported %v), want access %q inherited from %q (reported %v)", tt.id, document, ok, tt.wantAccess, tt.wantInheritedFrom, tt.wantReported)
+			}
+		})
+	}
+	if len(reports[0].Documents) != len(reported) {
+		t.Errorf("documents = %v, want each document reported once", reports[0].Documents)
+	}
+}
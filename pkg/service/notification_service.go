@@ -0,0 +1,516 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/labbs/zotion/pkg/events"
+	"github.com/labbs/zotion/pkg/mailer"
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+// markdownMention matches the mentions of the markdown contents, @[Name](user id)
+var markdownMention = regexp.MustCompile(`@\[[^\]]*\]\(([0-9a-fA-F-]{36})\)`)
+
+type notificationService struct {
+	notificationRepository models.NotificationRepository
+	userRepository         models.UserRepository
+	spaceRepository        models.SpaceRepository
+	documentRepository     models.DocumentRepository
+	mailer                 mailer.Mailer
+	events                 events.Bus
+}
+
+// NewNotificationService creates a new notification service, the spaces and the groups of the users
+// filter the recipients who can't read the documents. The digests are sent with the mailer
+// and the new notifications are published on the events bus.
+func NewNotificationService(nr models.NotificationRepository, ur models.UserRepository, sr models.SpaceRepository, dr models.DocumentRepository, m mailer.Mailer, bus events.Bus) *notificationService {
+	return &notificationService{
+		notificationRepository: nr,
+		userRepository:         ur,
+		spaceRepository:        sr,
+		documentRepository:     dr,
+		mailer:                 m,
+		events:                 bus,
+	}
+}
+
+// Notify adds the notification to the inbox of its user when the preferences of the user allow it.
+// An update of a page is merged in the unread update notification of the page when there is one.
+func (s *notificationService) Notify(notification models.Notification) error {
+	preferences, err := s.GetPreferences(notification.UserId)
+	if err != nil {
+		return err
+	}
+	if !preferences.Allows(notification.Type) {
+		return nil
+	}
+
+	if notification.Type == models.NotificationTypePageUpdate {
+		unread, err := s.notificationRepository.GetUnreadNotification(notification.UserId, notification.DocumentId, notification.Type)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			unread.Count++
+			unread.ActorId = notification.ActorId
+			unread.DocumentName = notification.DocumentName
+			unread.Data = notification.Data
+			unread.EmailedAt = nil
+			unread, err = s.notificationRepository.UpdateNotification(unread)
+			if err != nil {
+				return err
+			}
+			return s.publish(unread)
+		}
+	}
+
+	notification.Count = 1
+	notification, err = s.notificationRepository.CreateNotification(notification)
+	if err != nil {
+		return err
+	}
+	return s.publish(notification)
+}
+
+// publish sends the notification to the event streams of its user
+func (s *notificationService) publish(notification models.Notification) error {
+	return s.events.Publish(events.Event{
+		Type:       events.NotificationCreated,
+		SpaceId:    notification.SpaceId,
+		DocumentId: notification.DocumentId,
+		UserId:     notification.UserId,
+		ActorId:    notification.ActorId,
+		Data:       notification,
+	})
+}
+
+// NotifyMentions notifies the users mentioned in the content of the document who weren't mentioned
+// in its previous content. The author and the users who can't read the document aren't notified.
+func (s *notificationService) NotifyMentions(actorId string, document models.Document, previousContent string) error {
+	previous := ExtractMentions(previousContent)
+	var mentioned []string
+	for _, userId := range ExtractMentions(document.Content) {
+		if userId != actorId && !slices.Contains(previous, userId) {
+			mentioned = append(mentioned, userId)
+		}
+	}
+
+	readers, err := s.readers(mentioned, document)
+	if err != nil {
+		return err
+	}
+	for _, userId := range readers {
+		if err := s.Notify(documentNotification(models.NotificationTypeMention, userId, actorId, document)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NotifySubscribers notifies the users following the document of the activity, except its author
+func (s *notificationService) NotifySubscribers(activity models.Activity, document models.Document) error {
+	subscribers, err := s.notificationRepository.GetSubscribers(document.Id)
+	if err != nil {
+		return err
+	}
+	subscribers = slices.DeleteFunc(subscribers, func(userId string) bool { return userId == activity.UserId })
+
+	readers, err := s.readers(subscribers, document)
+	if err != nil {
+		return err
+	}
+	for _, userId := range readers {
+		notification := documentNotification(models.NotificationTypePageUpdate, userId, activity.UserId, document)
+		notification.Data = models.JSONB{"activity": activity.Type}
+		if err := s.Notify(notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readers returns the users who can read the document, the unknown users are skipped
+func (s *notificationService) readers(userIds []string, document models.Document) ([]string, error) {
+	if len(userIds) == 0 {
+		return nil, nil
+	}
+
+	space, err := s.spaceRepository.GetSpaceById(document.SpaceId)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := accessChain(s.documentRepository, document, space)
+	if err != nil {
+		return nil, err
+	}
+
+	var readers []string
+	for _, userId := range userIds {
+		if _, err := s.userRepository.GetById(userId); errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		groups, err := s.userRepository.GetGroupsByUserId(userId)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := chain.GetAccess(userId, groups); ok {
+			readers = append(readers, userId)
+		}
+	}
+	return readers, nil
+}
+
+// documentNotification returns a notification of the user about the document
+func documentNotification(notificationType models.NotificationType, userId string, actorId string, document models.Document) models.Notification {
+	return models.Notification{
+		Type:         notificationType,
+		UserId:       userId,
+		ActorId:      actorId,
+		SpaceId:      document.SpaceId,
+		DocumentId:   document.Id,
+		DocumentName: document.Name,
+	}
+}
+
+// ExtractMentions returns the ids of the users mentioned in a content. The json contents are walked for the
+// nodes of type mention with the id of the user in their props or attrs, the texts can use the markdown syntax.
+func ExtractMentions(content string) []string {
+	var mentions []string
+	add := func(userId string) {
+		if userId != "" && !slices.Contains(mentions, userId) {
+			mentions = append(mentions, userId)
+		}
+	}
+
+	for _, match := range markdownMention.FindAllStringSubmatch(content, -1) {
+		add(strings.ToLower(match[1]))
+	}
+
+	var node any
+	if err := json.Unmarshal([]byte(content), &node); err != nil {
+		return mentions
+	}
+	var walk func(node any)
+	walk = func(node any) {
+		switch value := node.(type) {
+		case []any:
+			for _, child := range value {
+				walk(child)
+			}
+		case map[string]any:
+			if value["type"] == "mention" {
+				for _, key := range []string{"props", "attrs"} {
+					attributes, _ := value[key].(map[string]any)
+					for _, name := range []string{"userId", "user", "id"} {
+						if userId, ok := attributes[name].(string); ok {
+							add(userId)
+							break
+						}
+					}
+				}
+			}
+			for _, child := range value {
+				walk(child)
+			}
+		}
+	}
+	walk(node)
+	return mentions
+}
+
+// GetNotifications returns a page of the inbox of the user, the most recent first
+func (s *notificationService) GetNotifications(userId string, unreadOnly bool, limit int, offset int) (models.NotificationPage, error) {
+	page := models.NotificationPage{Results: []models.Notification{}}
+
+	notifications, total, unread, err := s.notificationRepository.GetNotifications(userId, unreadOnly, limit, offset)
+	if err != nil {
+		return page, err
+	}
+	if notifications != nil {
+		page.Results = notifications
+	}
+	page.Total = total
+	page.Unread = unread
+	page.HasMore = int64(offset+len(notifications)) < total
+	return page, nil
+}
+
+// MarkRead marks the notifications of the user as read, all of them without ids
+func (s *notificationService) MarkRead(userId string, ids []string) error {
+	now := time.Now()
+	return s.notificationRepository.SetNotificationsRead(userId, ids, &now)
+}
+
+// MarkUnread marks the notifications of the user as unread, all of them without ids
+func (s *notificationService) MarkUnread(userId string, ids []string) error {
+	return s.notificationRepository.SetNotificationsRead(userId, ids, nil)
+}
+
+// GetPreferences returns the notification preferences of the user, the defaults complete the stored ones
+func (s *notificationService) GetPreferences(userId string) (models.NotificationPreferences, error) {
+	preferences := models.DefaultNotificationPreferences()
+
+	stored, err := s.userRepository.GetPreferencesById(userId)
+	if err != nil {
+		return preferences, err
+	}
+	value, ok := stored[models.NotificationPreferencesKey]
+	if !ok {
+		return preferences, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return preferences, err
+	}
+	if err := json.Unmarshal(data, &preferences); err != nil {
+		return models.DefaultNotificationPreferences(), nil
+	}
+	return preferences, nil
+}
+
+// UpdatePreferences stores the notification preferences in the preferences of the user, the other preferences are kept
+func (s *notificationService) UpdatePreferences(userId string, preferences models.NotificationPreferences) error {
+	stored, err := s.userRepository.GetPreferencesById(userId)
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		stored = models.JSONB{}
+	}
+
+	data, err := json.Marshal(preferences)
+	if err != nil {
+		return err
+	}
+	var value map[string]any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	stored[models.NotificationPreferencesKey] = value
+	return s.userRepository.UpdatePreferences(userId, stored)
+}
+
+// Subscribe adds the document to the pages followed by the user
+func (s *notificationService) Subscribe(userId string, documentId string) error {
+	return s.notificationRepository.Subscribe(models.Subscription{UserId: userId, DocumentId: documentId, CreatedAt: time.Now()})
+}
+
+// Unsubscribe removes the document from the pages followed by the user
+func (s *notificationService) Unsubscribe(userId string, documentId string) error {
+	return s.notificationRepository.Unsubscribe(userId, documentId)
+}
+
+// IsSubscribed returns true when the user follows the document
+func (s *notificationService) IsSubscribed(userId string, documentId string) (bool, error) {
+	return s.notificationRepository.IsSubscribed(userId, documentId)
+}
+
+// GetSubscriptions returns the pages followed by the user
+func (s *notificationService) GetSubscriptions(userId string) ([]models.Subscription, error) {
+	subscriptions, err := s.notificationRepository.GetSubscriptions(userId)
+	if subscriptions == nil {
+		subscriptions = []models.Subscription{}
+	}
+	return subscriptions, err
+}
+
+// CreateReminder creates a reminder of the user about a document
+func (s *notificationService) CreateReminder(userId string, request models.ReminderRequest) (models.Reminder, error) {
+	return s.notificationRepository.CreateReminder(models.Reminder{
+		UserId:     userId,
+		DocumentId: request.DocumentId,
+		Note:       request.Note,
+		RemindAt:   request.RemindAt,
+		CreatedAt:  time.Now(),
+	})
+}
+
+// GetReminders returns the reminders of the user, the next ones first
+func (s *notificationService) GetReminders(userId string) ([]models.Reminder, error) {
+	reminders, err := s.notificationRepository.GetReminders(userId)
+	if reminders == nil {
+		reminders = []models.Reminder{}
+	}
+	return reminders, err
+}
+
+// DeleteReminder deletes the reminder of the user
+func (s *notificationService) DeleteReminder(userId string, id string) error {
+	return s.notificationRepository.DeleteReminder(userId, id)
+}
+
+// SendDueReminders notifies the users of their reminders whose time is passed. The reminders are fired once,
+// the reminders of the documents the user can't read anymore are fired without notification.
+// A failed reminder doesn't stop the others, the errors are returned together.
+func (s *notificationService) SendDueReminders(now time.Time) error {
+	reminders, err := s.notificationRepository.GetDueReminders(now)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, reminder := range reminders {
+		if err := s.sendReminder(reminder, now); err != nil {
+			errs = append(errs, fmt.Errorf("reminder %s: %w", reminder.Id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sendReminder fires a due reminder, it's claimed first so a single instance fires it
+func (s *notificationService) sendReminder(reminder models.Reminder, now time.Time) error {
+	claimed, err := s.notificationRepository.ClaimReminder(reminder.Id, now)
+	if err != nil || !claimed {
+		return err
+	}
+
+	document, err := s.documentRepository.GetDocumentById(reminder.DocumentId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err == nil {
+		err = s.notifyReminder(reminder, document)
+	}
+	if err != nil {
+		// the reminder is fired again at the next check
+		return errors.Join(err, s.notificationRepository.ReleaseReminder(reminder.Id))
+	}
+	return nil
+}
+
+// notifyReminder notifies the user of the reminder if the user can still read the document
+func (s *notificationService) notifyReminder(reminder models.Reminder, document models.Document) error {
+	readers, err := s.readers([]string{reminder.UserId}, document)
+	if err != nil || len(readers) == 0 {
+		return err
+	}
+	notification := documentNotification(models.NotificationTypeReminder, reminder.UserId, reminder.UserId, document)
+	notification.Data = models.JSONB{"reminder_id": reminder.Id, "note": reminder.Note}
+	return s.Notify(notification)
+}
+
+// SendDigests sends by email the unread notifications of the users with digests enabled,
+// a digest is sent when the interval of the user is over since the previous one.
+// A failed digest doesn't stop the others, the errors are returned together.
+func (s *notificationService) SendDigests(now time.Time) error {
+	if !s.mailer.Enabled() {
+		return nil
+	}
+
+	notifications, err := s.notificationRepository.GetUnmailedNotifications([]models.DigestFrequency{models.DigestFrequencyHourly, models.DigestFrequencyDaily})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for start := 0; start < len(notifications); {
+		end := start + 1
+		for end < len(notifications) && notifications[end].UserId == notifications[start].UserId {
+			end++
+		}
+		if err := s.sendDigest(notifications[start:end], now); err != nil {
+			errs = append(errs, fmt.Errorf("digest of user %s: %w", notifications[start].UserId, err))
+		}
+		start = end
+	}
+	return errors.Join(errs...)
+}
+
+// sendDigest sends the notifications of a user by email if the digest of the user is due.
+// The notifications are claimed first so they are sent by a single instance, the claims are released when the email fails.
+func (s *notificationService) sendDigest(notifications []models.Notification, now time.Time) error {
+	userId := notifications[0].UserId
+
+	preferences, err := s.GetPreferences(userId)
+	if err != nil {
+		return err
+	}
+	interval := preferences.EmailDigest.Interval()
+	if interval == 0 {
+		return nil
+	}
+	last, err := s.notificationRepository.GetLastEmailedAt(userId)
+	if err != nil {
+		return err
+	}
+	if last != nil && now.Sub(*last) < interval {
+		return nil
+	}
+
+	user, err := s.userRepository.GetById(userId)
+	if err != nil {
+		return err
+	}
+	if user.Email == "" || !user.Active {
+		return nil
+	}
+
+	var claimed []models.Notification
+	var ids []string
+	for _, notification := range notifications {
+		ok, err := s.notificationRepository.ClaimNotificationEmail(notification.Id, now)
+		if err != nil {
+			if len(ids) > 0 {
+				err = errors.Join(err, s.notificationRepository.ReleaseNotificationEmails(ids))
+			}
+			return err
+		}
+		if ok {
+			claimed = append(claimed, notification)
+			ids = append(ids, notification.Id)
+		}
+	}
+	if len(claimed) == 0 {
+		return nil
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Hello %s,\n\nYou have %d unread notifications:\n\n", user.Name, len(claimed))
+	for _, notification := range claimed {
+		fmt.Fprintf(&body, "- %s\n", digestLine(notification))
+	}
+
+	subject := fmt.Sprintf("You have %d unread notifications", len(claimed))
+	if err := s.mailer.Send(user.Email, subject, body.String()); err != nil {
+		return errors.Join(err, s.notificationRepository.ReleaseNotificationEmails(ids))
+	}
+	return nil
+}
+
+// digestLine describes a notification in a digest
+func digestLine(notification models.Notification) string {
+	actor := notification.ActorName
+	if actor == "" {
+		actor = "Someone"
+	}
+	switch notification.Type {
+	case models.NotificationTypeMention:
+		return fmt.Sprintf("%s mentioned you in %q", actor, notification.DocumentName)
+	case models.NotificationTypePageUpdate:
+		if notification.Count > 1 {
+			return fmt.Sprintf("%q was updated %d times", notification.DocumentName, notification.Count)
+		}
+		return fmt.Sprintf("%s updated %q", actor, notification.DocumentName)
+	case models.NotificationTypeShare:
+		return fmt.Sprintf("%s shared %q with you", actor, notification.DocumentName)
+	case models.NotificationTypeInvite:
+		return fmt.Sprintf("%s invited you to %q", actor, notification.DocumentName)
+	case models.NotificationTypeReminder:
+		if note, _ := notification.Data["note"].(string); note != "" {
+			return fmt.Sprintf("Reminder about %q: %s", notification.DocumentName, note)
+		}
+		return fmt.Sprintf("Reminder about %q", notification.DocumentName)
+	}
+	return fmt.Sprintf("%s: %q", notification.Type, notification.DocumentName)
+}
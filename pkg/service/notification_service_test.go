@@ -0,0 +1,226 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+// digestRepository keeps the notifications and the reminders in memory, the claims are shared like a database
+type digestRepository struct {
+	models.NotificationRepository
+	mu            sync.Mutex
+	notifications []models.Notification
+	reminders     []models.Reminder
+}
+
+func (r *digestRepository) GetUnmailedNotifications(frequencies []models.DigestFrequency) ([]models.Notification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var notifications []models.Notification
+	for _, notification := range r.notifications {
+		if notification.EmailedAt == nil {
+			notifications = append(notifications, notification)
+		}
+	}
+	return notifications, nil
+}
+
+func (r *digestRepository) GetLastEmailedAt(userId string) (*time.Time, error) {
+	return nil, nil
+}
+
+func (r *digestRepository) ClaimNotificationEmail(id string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.notifications {
+		if r.notifications[i].Id == id && r.notifications[i].EmailedAt == nil {
+			r.notifications[i].EmailedAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *digestRepository) ReleaseNotificationEmails(ids []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.notifications {
+		if slices.Contains(ids, r.notifications[i].Id) {
+			r.notifications[i].EmailedAt = nil
+		}
+	}
+	return nil
+}
+
+func (r *digestRepository) GetDueReminders(now time.Time) ([]models.Reminder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var reminders []models.Reminder
+	for _, reminder := range r.reminders {
+		if reminder.FiredAt == nil {
+			reminders = append(reminders, reminder)
+		}
+	}
+	return reminders, nil
+}
+
+func (r *digestRepository) ClaimReminder(id string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.reminders {
+		if r.reminders[i].Id == id && r.reminders[i].FiredAt == nil {
+			r.reminders[i].FiredAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *digestRepository) ReleaseReminder(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.reminders {
+		if r.reminders[i].Id == id {
+			r.reminders[i].FiredAt = nil
+		}
+	}
+	return nil
+}
+
+// digestUsers are the users with daily digests
+type digestUsers struct {
+	models.UserRepository
+}
+
+func (digestUsers) GetPreferencesById(id string) (models.JSONB, error) {
+	return models.JSONB{models.NotificationPreferencesKey: map[string]any{"email_digest": "daily"}}, nil
+}
+
+func (digestUsers) GetById(id string) (models.User, error) {
+	return models.User{Id: id, Name: id, Email: id + "@example.com", Active: true}, nil
+}
+
+// missingDocuments returns gorm.ErrRecordNotFound for every document
+type missingDocuments struct {
+	models.DocumentRepository
+}
+
+func (missingDocuments) GetDocumentById(id string) (models.Document, error) {
+	return models.Document{}, gorm.ErrRecordNotFound
+}
+
+// recordingMailer records the recipients of the emails and fails for some of them
+type recordingMailer struct {
+	mu      sync.Mutex
+	failing map[string]bool
+	sent    []string
+	// lines is the number of notifications sent
+	lines int
+}
+
+func (m *recordingMailer) Send(to string, subject string, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failing[to] {
+		return errors.New("smtp unavailable")
+	}
+	m.sent = append(m.sent, to)
+	m.lines += strings.Count(body, "\n- ")
+	return nil
+}
+
+func (m *recordingMailer) Enabled() bool {
+	return true
+}
+
+func TestSendDigests(t *testing.T) {
+	tests := []struct {
+		name        string
+		failing     map[string]bool
+		wantSent    []string
+		wantErr     bool
+		wantPending []string
+	}{
+		{"every digest is sent", nil, []string{"a@example.com", "b@example.com", "c@example.com"}, false, nil},
+		{"a failed digest doesn't stop the others", map[string]bool{"a@example.com": true}, []string{"b@example.com", "c@example.com"}, true, []string{"n1", "n2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &digestRepository{notifications: []models.Notification{
+				{Id: "n1", UserId: "a"}, {Id: "n2", UserId: "a"}, {Id: "n3", UserId: "b"}, {Id: "n4", UserId: "c"},
+			}}
+			mailer := &recordingMailer{failing: tt.failing}
+			s := &notificationService{notificationRepository: repository, userRepository: digestUsers{}, mailer: mailer}
+
+			err := s.SendDigests(time.Now())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SendDigests() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !slices.Equal(mailer.sent, tt.wantSent) {
+				t.Errorf("sent = %v, want %v", mailer.sent, tt.wantSent)
+			}
+			// the notifications of the failed digests are released to be sent with the next one
+			var pending []string
+			for _, notification := range repository.notifications {
+				if notification.EmailedAt == nil {
+					pending = append(pending, notification.Id)
+				}
+			}
+			if !slices.Equal(pending, tt.wantPending) {
+				t.Errorf("pending = %v, want %v", pending, tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestSendDigestsConcurrently(t *testing.T) {
+	repository := &digestRepository{}
+	for _, id := range []string{"n1", "n2", "n3", "n4", "n5", "n6"} {
+		repository.notifications = append(repository.notifications, models.Notification{Id: id, UserId: "a"})
+	}
+	mailer := &recordingMailer{}
+
+	// the instances load the same notifications, each notification must be sent once
+	notifications, _ := repository.GetUnmailedNotifications(nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := &notificationService{notificationRepository: repository, userRepository: digestUsers{}, mailer: mailer}
+			if err := s.sendDigest(notifications, time.Now()); err != nil {
+				t.Errorf("sendDigest() unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if mailer.lines != len(notifications) {
+		t.Errorf("%d notifications sent in %d digests, want %d", mailer.lines, len(mailer.sent), len(notifications))
+	}
+}
+
+func TestSendDueReminders(t *testing.T) {
+	repository := &digestRepository{reminders: []models.Reminder{{Id: "r1", UserId: "a", DocumentId: "d1"}, {Id: "r2", UserId: "b", DocumentId: "d2"}}}
+	s := &notificationService{notificationRepository: repository, documentRepository: missingDocuments{}}
+
+	if err := s.SendDueReminders(time.Now()); err != nil {
+		t.Fatalf("SendDueReminders() unexpected error: %v", err)
+	}
+	// the reminders of the deleted documents are fired without notification, once
+	reminders, _ := repository.GetDueReminders(time.Now())
+	if len(reminders) != 0 {
+		t.Errorf("due reminders = %v, want none", reminders)
+	}
+	if claimed, _ := repository.ClaimReminder("r1", time.Now()); claimed {
+		t.Errorf("the fired reminder was claimed again")
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/labbs/zotion/pkg/caching"
+	"github.com/labbs/zotion/pkg/models"
+)
+
+type presenceService struct {
+	cache   caching.CacheInterface
+	timeout time.Duration
+}
+
+// NewPresenceService creates a presence service, the presences not refreshed before the timeout are dropped
+func NewPresenceService(cache caching.CacheInterface, timeout time.Duration) *presenceService {
+	return &presenceService{cache: cache, timeout: timeout}
+}
+
+func presenceDocumentKey(documentId string) string {
+	return "presence:document:" + documentId
+}
+
+func presenceSpaceKey(spaceId string) string {
+	return "presence:space:" + spaceId
+}
+
+// Touch creates or refreshes a presence on the document and its space.
+// Each connection is a field of the document and space hashes, the instances write their
+// connections without reading the others so concurrent joins and leaves don't lose updates.
+func (s *presenceService) Touch(presence models.Presence) error {
+	presence.LastSeen = time.Now()
+
+	raw, err := json.Marshal(presence)
+	if err != nil {
+		return err
+	}
+	s.cache.SetField(presenceDocumentKey(presence.DocumentId), presence.ConnectionId, string(raw), s.timeout)
+	s.cache.SetField(presenceSpaceKey(presence.SpaceId), presence.ConnectionId, string(raw), s.timeout)
+	return nil
+}
+
+// Remove drops a presence from the document and its space
+func (s *presenceService) Remove(presence models.Presence) error {
+	s.cache.DeleteField(presenceDocumentKey(presence.DocumentId), presence.ConnectionId)
+	s.cache.DeleteField(presenceSpaceKey(presence.SpaceId), presence.ConnectionId)
+	return nil
+}
+
+// GetDocumentPresence returns the active presences of a document
+func (s *presenceService) GetDocumentPresence(documentId string) ([]models.Presence, error) {
+	return s.load(presenceDocumentKey(documentId))
+}
+
+// GetSpacePresence returns the active presences on the documents of a space
+func (s *presenceService) GetSpacePresence(spaceId string) ([]models.Presence, error) {
+	return s.load(presenceSpaceKey(spaceId))
+}
+
+// load reads the presences stored under the key. The key expires with its last refreshed presence,
+// the connections closed without leaving (crashed instance) are skipped and dropped once expired.
+func (s *presenceService) load(key string) ([]models.Presence, error) {
+	entries := make(map[string]models.Presence)
+
+	deadline := time.Now().Add(-s.timeout)
+	for connectionId, raw := range s.cache.GetFields(key) {
+		var entry models.Presence
+		if err := json.Unmarshal([]byte(raw), &entry); err != nil || entry.LastSeen.Before(deadline) {
+			s.cache.DeleteField(key, connectionId)
+			continue
+		}
+		entries[connectionId] = entry
+	}
+	return sortedPresences(entries), nil
+}
+
+func sortedPresences(entries map[string]models.Presence) []models.Presence {
+	presences := make([]models.Presence, 0, len(entries))
+	for _, entry := range entries {
+		presences = append(presences, entry)
+	}
+	sort.Slice(presences, func(i, j int) bool {
+		if presences[i].UserName != presences[j].UserName {
+			return presences[i].UserName < presences[j].UserName
+		}
+		return presences[i].ConnectionId < presences[j].ConnectionId
+	})
+	return presences
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"slices"
+
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxPublicDepth is the maximum number of ancestors checked to find if a document is published
+	maxPublicDepth = 64
+	// maxPublicNavPages is the maximum number of pages of the navigation of a published tree
+	maxPublicNavPages = 1000
+	// maxPublicListPages is the maximum number of published documents listed in the sitemap and the feeds
+	maxPublicListPages = 50000
+)
+
+type publicService struct {
+	documentRepository models.DocumentRepository
+}
+
+// NewPublicService creates a new public service
+func NewPublicService(dr models.DocumentRepository) *publicService {
+	return &publicService{documentRepository: dr}
+}
+
+// GetPublishedDocumentById returns the document when it's published, gorm.ErrRecordNotFound otherwise
+func (s *publicService) GetPublishedDocumentById(id string) (models.Document, error) {
+	document, err := s.documentRepository.GetDocumentById(id)
+	if err != nil {
+		return models.Document{}, err
+	}
+	return s.published(document)
+}
+
+// GetPublishedDocumentBySlug returns the document when it's published, gorm.ErrRecordNotFound otherwise
+func (s *publicService) GetPublishedDocumentBySlug(slug string) (models.Document, error) {
+	document, err := s.documentRepository.GetDocumentBySlug(slug)
+	if err != nil {
+		return models.Document{}, err
+	}
+	return s.published(document)
+}
+
+// GetPublishedTree returns the navigation of the published tree of the document, from the highest
+// published ancestor of the document down to its published descendants
+func (s *publicService) GetPublishedTree(documentId string) (models.PublicNavNode, error) {
+	document, err := s.GetPublishedDocumentById(documentId)
+	if err != nil {
+		return models.PublicNavNode{}, err
+	}
+
+	root := document
+	for range maxPublicDepth {
+		if root.ParentId == "" {
+			break
+		}
+		parent, err := s.documentRepository.GetDocumentById(root.ParentId)
+		if err != nil {
+			return models.PublicNavNode{}, err
+		}
+		if _, err := s.published(parent); err != nil {
+			break
+		}
+		root = parent
+	}
+
+	ancestorPublished, err := s.publishedByAncestors(root)
+	if err != nil {
+		return models.PublicNavNode{}, err
+	}
+
+	count := 0
+	return s.tree(root, ancestorPublished, &count)
+}
+
+// GetPublishedDocuments returns the published documents of the space, of all the spaces when the space is empty.
+// The published subpages stop at the documents breaking the inheritance.
+// The most recently updated documents come first, all the documents are returned when limit isn't positive.
+func (s *publicService) GetPublishedDocuments(spaceId string, limit int) ([]models.Document, error) {
+	documents, err := s.documentRepository.GetPublicDocuments(spaceId)
+	if err != nil {
+		return nil, err
+	}
+
+	published := map[string]bool{}
+	queue := []models.Document{}
+	for _, document := range documents {
+		published[document.Id] = true
+		if document.Config.PublishSubpages {
+			queue = append(queue, document)
+		}
+	}
+	for len(queue) > 0 && len(documents) < maxPublicListPages {
+		parent := queue[0]
+		queue = queue[1:]
+
+		children, err := s.documentRepository.GetDocumentsFirstLevelByDocumentId(parent.Id)
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			if published[child.Id] || child.InheritanceBroken {
+				continue
+			}
+			published[child.Id] = true
+			documents = append(documents, child)
+			queue = append(queue, child)
+		}
+	}
+
+	slices.SortFunc(documents, func(a, b models.Document) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	if limit > 0 && len(documents) > limit {
+		documents = documents[:limit]
+	}
+	return documents, nil
+}
+
+// tree returns the navigation node of the published document with its published children, the children
+// breaking the inheritance are only shown when they are public
+func (s *publicService) tree(document models.Document, inherited bool, count *int) (models.PublicNavNode, error) {
+	*count++
+	node := models.PublicNavNode{PublicPage: models.NewPublicPage(document), Children: []models.PublicNavNode{}}
+
+	children, err := s.documentRepository.GetDocumentsFirstLevelByDocumentId(document.Id)
+	if err != nil {
+		return node, err
+	}
+
+	inherited = inherited || (document.Public && document.Config.PublishSubpages)
+	for _, child := range children {
+		if *count >= maxPublicNavPages {
+			break
+		}
+		childInherited := inherited && !child.InheritanceBroken
+		if !childInherited && !child.Public {
+			continue
+		}
+		childNode, err := s.tree(child, childInherited, count)
+		if err != nil {
+			return node, err
+		}
+		node.Children = append(node.Children, childNode)
+	}
+	return node, nil
+}
+
+// published returns the document when it's public or published by one of its ancestors
+func (s *publicService) published(document models.Document) (models.Document, error) {
+	if document.Public {
+		return document, nil
+	}
+	ok, err := s.publishedByAncestors(document)
+	if err != nil {
+		return models.Document{}, err
+	}
+	if !ok {
+		return models.Document{}, gorm.ErrRecordNotFound
+	}
+	return document, nil
+}
+
+// publishedByAncestors returns true when an ancestor of the document is public with its subpages published.
+// The documents breaking the inheritance aren't published by their ancestors, nor are their subpages.
+func (s *publicService) publishedByAncestors(document models.Document) (bool, error) {
+	if document.InheritanceBroken {
+		return false, nil
+	}
+	parentId := document.ParentId
+	for range maxPublicDepth {
+		if parentId == "" {
+			return false, nil
+		}
+		parent, err := s.documentRepository.GetDocumentById(parentId)
+		if err != nil {
+			return false, err
+		}
+		if parent.Public && parent.Config.PublishSubpages {
+			return true, nil
+		}
+		if parent.InheritanceBroken {
+			return false, nil
+		}
+		parentId = parent.ParentId
+	}
+	return false, nil
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+// publishedTree is a public document publishing its subpages, with a restricted subpage and a public one
+// under the restricted one:
+//
+//	root (public, subpages published)
+//	├── a
+//	│   └── a1
+//	└── r (inheritance broken)
+//	    ├── r1
+//	    └── p (public)
+func publishedTree() map[string]models.Document {
+	return map[string]models.Document{
+		"root": {Id: "root", SpaceId: "s", Public: true, Config: models.DocumentConfig{PublishSubpages: true}},
+		"a":    {Id: "a", SpaceId: "s", ParentId: "root"},
+		"a1":   {Id: "a1", SpaceId: "s", ParentId: "a"},
+		"r":    {Id: "r", SpaceId: "s", ParentId: "root", InheritanceBroken: true},
+		"r1":   {Id: "r1", SpaceId: "s", ParentId: "r"},
+		"p":    {Id: "p", SpaceId: "s", ParentId: "r", Public: true},
+	}
+}
+
+func TestGetPublishedDocumentById(t *testing.T) {
+	s := NewPublicService(treeRepository{documents: publishedTree()})
+	tests := []struct {
+		id            string
+		wantPublished bool
+	}{
+		{"root", true},
+		{"a", true},
+		{"a1", true},
+		{"r", false},
+		{"r1", false},
+		{"p", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			_, err := s.GetPublishedDocumentById(tt.id)
+			if tt.wantPublished && err != nil {
+				t.Errorf("GetPublishedDocumentById(%s) unexpected error: %v", tt.id, err)
+			}
+			if !tt.wantPublished && !errors.Is(err, gorm.ErrRecordNotFound) {
+				t.Errorf("GetPublishedDocumentById(%s) error = %v, want %v", tt.id, err, gorm.ErrRecordNotFound)
+			}
+		})
+	}
+}
+
+func TestGetPublishedDocuments(t *testing.T) {
+	documents, err := NewPublicService(treeRepository{documents: publishedTree()}).GetPublishedDocuments("", 0)
+	if err != nil {
+		t.Fatalf("GetPublishedDocuments() unexpected error: %v", err)
+	}
+	var ids []string
+	for _, document := range documents {
+		ids = append(ids, document.Id)
+	}
+	slices.Sort(ids)
+	if want := []string{"a", "a1", "p", "root"}; !slices.Equal(ids, want) {
+		t.Errorf("GetPublishedDocuments() = %v, want %v", ids, want)
+	}
+}
+
+func TestGetPublishedTree(t *testing.T) {
+	tree, err := NewPublicService(treeRepository{documents: publishedTree()}).GetPublishedTree("a1")
+	if err != nil {
+		t.Fatalf("GetPublishedTree() unexpected error: %v", err)
+	}
+	var ids []string
+	var walk func(node models.PublicNavNode)
+	walk = func(node models.PublicNavNode) {
+		ids = append(ids, node.Id)
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(tree)
+	if want := []string{"root", "a", "a1"}; !slices.Equal(ids, want) {
+		t.Errorf("GetPublishedTree() = %v, want %v", ids, want)
+	}
+}
+
+func TestGetSharedDocument(t *testing.T) {
+	s := &shareLinkService{documentRepository: treeRepository{documents: publishedTree()}}
+	tests := []struct {
+		name       string
+		link       models.ShareLink
+		id         string
+		wantShared bool
+	}{
+		{"shared document", models.ShareLink{DocumentId: "root", SpaceId: "s"}, "", true},
+		{"subpage without the subpages", models.ShareLink{DocumentId: "root", SpaceId: "s"}, "a", false},
+		{"subpage", models.ShareLink{DocumentId: "root", SpaceId: "s", IncludeSubpages: true}, "a1", true},
+		{"subpage breaking the inheritance", models.ShareLink{DocumentId: "root", SpaceId: "s", IncludeSubpages: true}, "r", false},
+		{"under a subpage breaking the inheritance", models.ShareLink{DocumentId: "root", SpaceId: "s", IncludeSubpages: true}, "r1", false},
+		{"shared document breaking the inheritance", models.ShareLink{DocumentId: "r", SpaceId: "s", IncludeSubpages: true}, "r1", true},
+		{"other space", models.ShareLink{DocumentId: "root", SpaceId: "other", IncludeSubpages: true}, "a", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.GetSharedDocument(tt.link, tt.id)
+			if tt.wantShared && err != nil {
+				t.Errorf("GetSharedDocument(%s) unexpected error: %v", tt.id, err)
+			}
+			if !tt.wantShared && !errors.Is(err, gorm.ErrRecordNotFound) {
+				t.Errorf("GetSharedDocument(%s) error = %v, want %v", tt.id, err, gorm.ErrRecordNotFound)
+			}
+		})
+	}
+}
+
+func TestGetSharedPages(t *testing.T) {
+	s := &shareLinkService{documentRepository: treeRepository{documents: publishedTree()}}
+	pages, err := s.GetSharedPages(models.ShareLink{DocumentId: "root", SpaceId: "s", IncludeSubpages: true}, "")
+	if err != nil {
+		t.Fatalf("GetSharedPages() unexpected error: %v", err)
+	}
+	if len(pages) != 1 || pages[0].Id != "a" {
+		t.Errorf("GetSharedPages() = %v, want a only", pages)
+	}
+}
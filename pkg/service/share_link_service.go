@@ -0,0 +1,194 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"github.com/labbs/zotion/pkg/models"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// maxShareDepth is the maximum number of levels between a shared document and its subpages
+const maxShareDepth = 64
+
+type shareLinkService struct {
+	shareLinkRepository models.ShareLinkRepository
+	documentRepository  models.DocumentRepository
+}
+
+// NewShareLinkService creates a new share link service
+func NewShareLinkService(slr models.ShareLinkRepository, dr models.DocumentRepository) *shareLinkService {
+	return &shareLinkService{
+		shareLinkRepository: slr,
+		documentRepository:  dr,
+	}
+}
+
+// CreateShareLink creates a share link of the document with a random token
+func (s *shareLinkService) CreateShareLink(userId string, document models.Document, request models.ShareLinkRequest) (models.ShareLink, error) {
+	token, err := newShareToken()
+	if err != nil {
+		return models.ShareLink{}, err
+	}
+
+	link := models.ShareLink{
+		Token:      token,
+		DocumentId: document.Id,
+		SpaceId:    document.SpaceId,
+		CreatedBy:  userId,
+	}
+	if err := applyShareLinkRequest(&link, request); err != nil {
+		return models.ShareLink{}, err
+	}
+	return s.shareLinkRepository.CreateShareLink(link)
+}
+
+func (s *shareLinkService) GetShareLinkById(id string) (models.ShareLink, error) {
+	return s.shareLinkRepository.GetShareLinkById(id)
+}
+
+func (s *shareLinkService) GetShareLinks(documentId string) ([]models.ShareLink, error) {
+	return s.shareLinkRepository.GetShareLinksByDocumentId(documentId)
+}
+
+// UpdateShareLink changes the settings of the share link, the token is kept
+func (s *shareLinkService) UpdateShareLink(link models.ShareLink, request models.ShareLinkRequest) (models.ShareLink, error) {
+	if err := applyShareLinkRequest(&link, request); err != nil {
+		return models.ShareLink{}, err
+	}
+	link.UpdatedAt = time.Now()
+	return s.shareLinkRepository.UpdateShareLink(link)
+}
+
+// RevokeShareLink disables the share link for good, it's kept to show its views
+func (s *shareLinkService) RevokeShareLink(link models.ShareLink) (models.ShareLink, error) {
+	if link.RevokedAt != nil {
+		return link, nil
+	}
+	now := time.Now()
+	link.RevokedAt = &now
+	link.UpdatedAt = now
+	return s.shareLinkRepository.UpdateShareLink(link)
+}
+
+// OpenShareLink returns the share link of the token. models.ErrShareLinkExpired is returned when the link is
+// revoked or expired, and models.ErrShareLinkPassword when the password doesn't match.
+func (s *shareLinkService) OpenShareLink(token string, password string) (models.ShareLink, error) {
+	link, err := s.shareLinkRepository.GetShareLinkByToken(token)
+	if err != nil {
+		return models.ShareLink{}, err
+	}
+	if link.IsExpired(time.Now()) {
+		return models.ShareLink{}, models.ErrShareLinkExpired
+	}
+	if link.PasswordHash != "" {
+		if password == "" || bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) != nil {
+			return models.ShareLink{}, models.ErrShareLinkPassword
+		}
+	}
+	return link, nil
+}
+
+// GetSharedDocument returns the shared document, or one of its subpages when the link includes them.
+// The subpages stop at the documents breaking the inheritance, gorm.ErrRecordNotFound is returned
+// for the documents outside of the link.
+func (s *shareLinkService) GetSharedDocument(link models.ShareLink, documentId string) (models.Document, error) {
+	if documentId == "" || documentId == link.DocumentId {
+		return s.documentRepository.GetDocumentById(link.DocumentId)
+	}
+	if !link.IncludeSubpages {
+		return models.Document{}, gorm.ErrRecordNotFound
+	}
+
+	document, err := s.documentRepository.GetDocumentById(documentId)
+	if err != nil {
+		return models.Document{}, err
+	}
+	if document.SpaceId != link.SpaceId || document.InheritanceBroken {
+		return models.Document{}, gorm.ErrRecordNotFound
+	}
+
+	parentId := document.ParentId
+	for range maxShareDepth {
+		if parentId == link.DocumentId {
+			return document, nil
+		}
+		if parentId == "" {
+			break
+		}
+		parent, err := s.documentRepository.GetDocumentById(parentId)
+		if err != nil {
+			return models.Document{}, err
+		}
+		if parent.InheritanceBroken {
+			break
+		}
+		parentId = parent.ParentId
+	}
+	return models.Document{}, gorm.ErrRecordNotFound
+}
+
+// GetSharedPages returns the children of a shared page, the shared document when the parent is empty.
+// The children breaking the inheritance aren't shared.
+func (s *shareLinkService) GetSharedPages(link models.ShareLink, parentId string) ([]models.Document, error) {
+	parent, err := s.GetSharedDocument(link, parentId)
+	if err != nil {
+		return nil, err
+	}
+	if !link.IncludeSubpages {
+		return []models.Document{}, nil
+	}
+	children, err := s.documentRepository.GetDocumentsFirstLevelByDocumentId(parent.Id)
+	if err != nil {
+		return nil, err
+	}
+	shared := []models.Document{}
+	for _, child := range children {
+		if !child.InheritanceBroken {
+			shared = append(shared, child)
+		}
+	}
+	return shared, nil
+}
+
+func (s *shareLinkService) RecordView(link models.ShareLink) error {
+	return s.shareLinkRepository.RecordView(link.Id, time.Now())
+}
+
+// applyShareLinkRequest checks the request and applies it to the share link
+func applyShareLinkRequest(link *models.ShareLink, request models.ShareLinkRequest) error {
+	switch request.Access {
+	case "":
+		request.Access = models.AccessTypeViewer
+	case models.AccessTypeViewer, models.AccessTypeComment:
+	default:
+		return models.ErrShareLinkAccess
+	}
+
+	link.Access = request.Access
+	link.IncludeSubpages = request.IncludeSubpages
+	link.ExpiresAt = request.ExpiresAt
+
+	if request.Password != nil {
+		link.PasswordHash = ""
+		if *request.Password != "" {
+			hash, err := bcrypt.GenerateFromPassword([]byte(*request.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return err
+			}
+			link.PasswordHash = string(hash)
+		}
+	}
+	return nil
+}
+
+// newShareToken returns a random token for the url of a share link
+func newShareToken() (string, error) {
+	token := make([]byte, 24)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/labbs/zotion/pkg/site"
+	"github.com/labbs/zotion/pkg/storage"
+)
+
+// siteExportStaleAfter is the duration after which a running export is considered interrupted and run again
+const siteExportStaleAfter = time.Hour
+
+type siteExportService struct {
+	siteExportRepository models.SiteExportRepository
+	exporter             *site.Exporter
+	storage              storage.Storage
+}
+
+// NewSiteExportService returns the site export service, the zip files are kept in the storage of the attachments
+func NewSiteExportService(ser models.SiteExportRepository, exporter *site.Exporter, storage storage.Storage) *siteExportService {
+	return &siteExportService{
+		siteExportRepository: ser,
+		exporter:             exporter,
+		storage:              storage,
+	}
+}
+
+// CreateExport queues the export of the space, it's run in background
+func (s *siteExportService) CreateExport(spaceId string, userId string) (models.SiteExport, error) {
+	return s.siteExportRepository.CreateExport(models.SiteExport{
+		SpaceId:   spaceId,
+		Status:    models.SiteExportPending,
+		CreatedBy: userId,
+	})
+}
+
+func (s *siteExportService) GetExportById(id string) (models.SiteExport, error) {
+	return s.siteExportRepository.GetExportById(id)
+}
+
+func (s *siteExportService) GetExportsBySpaceId(spaceId string) ([]models.SiteExport, error) {
+	return s.siteExportRepository.GetExportsBySpaceId(spaceId)
+}
+
+// OpenExportArchive returns the zip file of the completed export, the caller must close it
+func (s *siteExportService) OpenExportArchive(export models.SiteExport) (io.ReadCloser, error) {
+	if export.Status != models.SiteExportCompleted {
+		return nil, models.ErrSiteExportNotReady
+	}
+	return s.storage.Get(context.Background(), export.StorageKey)
+}
+
+// DeleteExport removes the zip file and the export, the running exports can't be deleted
+func (s *siteExportService) DeleteExport(export models.SiteExport) error {
+	if export.Status == models.SiteExportRunning {
+		return models.ErrSiteExportRunning
+	}
+	if export.StorageKey != "" {
+		if err := s.storage.Delete(context.Background(), export.StorageKey); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+	}
+	return s.siteExportRepository.DeleteExport(export.Id)
+}
+
+// RunPending runs the pending exports one after the other, the exports claimed by another instance are skipped.
+// The failures of the exports are saved in the exports, the returned error is the one of the repository.
+func (s *siteExportService) RunPending() error {
+	staleBefore := time.Now().Add(-siteExportStaleAfter)
+	exports, err := s.siteExportRepository.GetPendingExports(staleBefore)
+	if err != nil {
+		return err
+	}
+
+	for _, export := range exports {
+		claimed, err := s.siteExportRepository.ClaimExport(export.Id, time.Now(), staleBefore)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+		if err := s.run(export); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run renders the site of the export in a zip file and stores it
+func (s *siteExportService) run(export models.SiteExport) error {
+	result, key, size, err := s.export(export)
+
+	now := time.Now()
+	export.CompletedAt = &now
+	export.UpdatedAt = now
+	export.Pages = result.Pages
+	export.Attachments = result.Attachments
+	if err != nil {
+		export.Status = models.SiteExportFailed
+		export.Error = err.Error()
+	} else {
+		export.Status = models.SiteExportCompleted
+		export.StorageKey = key
+		export.Size = size
+	}
+	return s.siteExportRepository.UpdateExport(export)
+}
+
+// export writes the zip file in a temporary file before storing it, the storages need the size of the objects
+func (s *siteExportService) export(export models.SiteExport) (site.ExportResult, string, int64, error) {
+	file, err := os.CreateTemp("", "zotion-site-*.zip")
+	if err != nil {
+		return site.ExportResult{}, "", 0, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	archive := site.NewZipArchive(file)
+	result, err := s.exporter.Export(export.SpaceId, archive)
+	if err != nil {
+		return result, "", 0, err
+	}
+	if err := archive.Close(); err != nil {
+		return result, "", 0, err
+	}
+
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return result, "", 0, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return result, "", 0, err
+	}
+
+	key := "exports/" + export.SpaceId + "/" + export.Id + ".zip"
+	if err := s.storage.Put(context.Background(), key, file, size, "application/zip"); err != nil {
+		return result, "", 0, err
+	}
+	return result, key, size, nil
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+type tagService struct {
+	tagRepository      models.TagRepository
+	spaceRepository    models.SpaceRepository
+	userRepository     models.UserRepository
+	documentRepository models.DocumentRepository
+}
+
+// NewTagService creates a new tag service, the spaces of the users scope the tags and the access
+// of the users to the documents scopes the tagged documents
+func NewTagService(tr models.TagRepository, sr models.SpaceRepository, ur models.UserRepository, dr models.DocumentRepository) *tagService {
+	return &tagService{
+		tagRepository:      tr,
+		spaceRepository:    sr,
+		userRepository:     ur,
+		documentRepository: dr,
+	}
+}
+
+// CreateTag creates a tag in the space, or an instance tag without space.
+// models.ErrTagExists is returned when the name is already used in the scope, the case is ignored.
+func (s *tagService) CreateTag(userId string, request models.TagRequest) (models.Tag, error) {
+	tag := models.Tag{
+		Name:      strings.TrimSpace(request.Name),
+		Color:     strings.TrimSpace(request.Color),
+		SpaceId:   request.SpaceId,
+		CreatedBy: userId,
+	}
+	if err := s.checkName(tag); err != nil {
+		return tag, err
+	}
+	return s.tagRepository.CreateTag(tag)
+}
+
+func (s *tagService) GetTagById(id string) (models.Tag, error) {
+	return s.tagRepository.GetTagById(id)
+}
+
+// GetTagsForUser returns the instance tags and the tags of the spaces of the user with the number of their documents.
+// With a space, only the tags available in the space are returned and the documents of the space are counted.
+func (s *tagService) GetTagsForUser(userId string, spaceId string) ([]models.Tag, error) {
+	spaceIds, err := s.readableSpaceIds(userId)
+	if err != nil {
+		return nil, err
+	}
+	if spaceId != "" {
+		if !slices.Contains(spaceIds, spaceId) {
+			return nil, models.ErrAccessDenied
+		}
+		spaceIds = []string{spaceId}
+	}
+
+	tags, err := s.tagRepository.GetTags(spaceIds)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.tagRepository.CountDocuments(spaceIds)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tags {
+		tags[i].Count = counts[tags[i].Id]
+	}
+	return tags, nil
+}
+
+// UpdateTag renames the tag and changes its color
+func (s *tagService) UpdateTag(tag models.Tag, request models.TagRequest) (models.Tag, error) {
+	tag.Name = strings.TrimSpace(request.Name)
+	tag.Color = strings.TrimSpace(request.Color)
+	if err := s.checkName(tag); err != nil {
+		return tag, err
+	}
+	tag.UpdatedAt = time.Now()
+	return s.tagRepository.UpdateTag(tag)
+}
+
+// DeleteTag deletes the tag and removes it from the documents
+func (s *tagService) DeleteTag(id string) error {
+	return s.tagRepository.DeleteTag(id)
+}
+
+// MergeTag moves the documents of the source tag to the target tag and deletes the source tag.
+// The target must be available everywhere the source is, an instance tag or a tag of the same space.
+func (s *tagService) MergeTag(source models.Tag, target models.Tag) error {
+	if target.SpaceId != "" && target.SpaceId != source.SpaceId {
+		return models.ErrTagScope
+	}
+	return s.tagRepository.MergeTag(source.Id, target.Id)
+}
+
+func (s *tagService) GetDocumentTags(documentId string) ([]models.Tag, error) {
+	return s.tagRepository.GetDocumentTags(documentId)
+}
+
+// AddDocumentTag tags the document, the tags of a space can only be added to its documents
+func (s *tagService) AddDocumentTag(userId string, document models.Document, tag models.Tag) error {
+	if tag.SpaceId != "" && tag.SpaceId != document.SpaceId {
+		return models.ErrTagScope
+	}
+	return s.tagRepository.AddDocumentTag(models.DocumentTag{
+		DocumentId: document.Id,
+		TagId:      tag.Id,
+		CreatedBy:  userId,
+		CreatedAt:  time.Now(),
+	})
+}
+
+func (s *tagService) RemoveDocumentTag(documentId string, tagId string) error {
+	return s.tagRepository.RemoveDocumentTag(documentId, tagId)
+}
+
+// GetTaggedDocuments returns a page of the documents with the tag the user can access, in the spaces of the user
+// and in the documents the user is invited to. models.ErrAccessDenied is returned for the tag of a space the user
+// can't access any document of.
+func (s *tagService) GetTaggedDocuments(userId string, tagId string, limit int, offset int) (models.TaggedDocuments, error) {
+	result := models.TaggedDocuments{Results: []models.Document{}}
+
+	tag, err := s.tagRepository.GetTagById(tagId)
+	if err != nil {
+		return result, err
+	}
+	scope, err := s.documentScope(userId, tag.SpaceId)
+	if err != nil {
+		return result, err
+	}
+	if tag.SpaceId != "" && len(scope.SpaceIds) == 0 && len(scope.IncludedIds) == 0 {
+		return result, models.ErrAccessDenied
+	}
+
+	documents, total, err := s.tagRepository.GetTaggedDocuments(tag.Id, scope, limit, offset)
+	if err != nil {
+		return result, err
+	}
+	result.Results = append(result.Results, documents...)
+	result.Total = total
+	result.HasMore = int64(offset+len(documents)) < total
+	return result, nil
+}
+
+// documentScope returns the documents the user can access, only in the space when it is set. The documents of the
+// spaces of the user are included except the subtrees breaking the inheritance the user isn't invited to, the guests
+// don't read the spaces. The subtrees the user is invited to in the other spaces are included.
+func (s *tagService) documentScope(userId string, spaceId string) (models.DocumentScope, error) {
+	var scope models.DocumentScope
+	groups, err := s.userRepository.GetGroupsByUserId(userId)
+	if err != nil {
+		return scope, err
+	}
+	if !models.IsGuest(groups) {
+		spaces, err := s.spaceRepository.GetSpacesForUser(userId, groups)
+		if err != nil {
+			return scope, err
+		}
+		for _, space := range spaces {
+			if spaceId == "" || space.Id == spaceId {
+				scope.SpaceIds = append(scope.SpaceIds, space.Id)
+			}
+		}
+	}
+
+	restricted, err := s.documentRepository.GetRestrictedDocuments(scope.SpaceIds)
+	if err != nil {
+		return scope, err
+	}
+	for _, document := range restricted {
+		// the access chain of a document breaking the inheritance stops at the document
+		chain := models.AccessChain{Documents: []models.Document{document}}
+		if _, ok := chain.GetAccess(userId, groups); !ok {
+			if scope.ExcludedIds, err = s.inheritingSubtree(scope.ExcludedIds, document, userId, groups); err != nil {
+				return scope, err
+			}
+		}
+	}
+
+	invited, err := s.documentRepository.GetDocumentsForMember(userId, groups)
+	if err != nil {
+		return scope, err
+	}
+	for _, document := range invited {
+		if slices.Contains(scope.SpaceIds, document.SpaceId) || (spaceId != "" && document.SpaceId != spaceId) {
+			continue
+		}
+		if _, ok := document.Members.GetAccess(userId, groups); ok {
+			if scope.IncludedIds, err = s.inheritingSubtree(scope.IncludedIds, document, userId, groups); err != nil {
+				return scope, err
+			}
+		}
+	}
+	return scope, nil
+}
+
+// inheritingSubtree appends the ids of the document and of its subpages sharing its access to the ids. The subpages
+// breaking the inheritance or the user is invited to are left out with their subtree, their access is checked apart.
+func (s *tagService) inheritingSubtree(ids []string, document models.Document, userId string, groups []models.Group) ([]string, error) {
+	ids = append(ids, document.Id)
+	children, err := s.documentRepository.GetDocumentsFirstLevelByDocumentId(document.Id)
+	if err != nil {
+		return ids, err
+	}
+	for _, child := range children {
+		if child.InheritanceBroken {
+			continue
+		}
+		if _, ok := child.Members.GetAccess(userId, groups); ok {
+			continue
+		}
+		if ids, err = s.inheritingSubtree(ids, child, userId, groups); err != nil {
+			return ids, err
+		}
+	}
+	return ids, nil
+}
+
+// checkName returns models.ErrTagExists when another tag of the scope has the name
+func (s *tagService) checkName(tag models.Tag) error {
+	existing, err := s.tagRepository.GetTagByName(tag.SpaceId, tag.Name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing.Id != tag.Id {
+		return models.ErrTagExists
+	}
+	return nil
+}
+
+// readableSpaceIds returns the ids of the spaces the user is a member of, directly or with a group
+func (s *tagService) readableSpaceIds(userId string) ([]string, error) {
+	groups, err := s.userRepository.GetGroupsByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	spaces, err := s.spaceRepository.GetSpacesForUser(userId, groups)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(spaces))
+	for i, space := range spaces {
+		ids[i] = space.Id
+	}
+	return ids, nil
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/labbs/zotion/pkg/models"
+)
+
+// memberSpaces is a space with alice and bob as members
+type memberSpaces struct {
+	models.SpaceRepository
+}
+
+func (memberSpaces) GetSpacesForUser(userId string, groups []models.Group) ([]models.Space, error) {
+	return []models.Space{memberSpace}, nil
+}
+
+func (memberSpaces) GetSpaceById(spaceId string) (models.Space, error) {
+	return memberSpace, nil
+}
+
+var memberSpace = models.Space{Id: "s", Members: models.Members{
+	{Id: "alice", Type: models.MemberTypeUser, Access: models.AccessTypeFull},
+	{Id: "bob", Type: models.MemberTypeUser, Access: models.AccessTypeFull},
+}}
+
+// noGroups are the users without groups
+type noGroups struct {
+	models.UserRepository
+}
+
+func (noGroups) GetGroupsByUserId(userId string) ([]models.Group, error) {
+	return nil, nil
+}
+
+// guestUsers has the users g and h in a guest group, the other users have no groups
+type guestUsers struct {
+	models.UserRepository
+}
+
+func (guestUsers) GetGroupsByUserId(userId string) ([]models.Group, error) {
+	if userId == "g" || userId == "h" {
+		return []models.Group{{Id: "guests", Role: models.RoleGuest}}, nil
+	}
+	return nil, nil
+}
+
+// taggedTree tags every document of the tree with the tag t
+type taggedTree struct {
+	models.TagRepository
+	documents map[string]models.Document
+}
+
+func (r taggedTree) GetTagById(id string) (models.Tag, error) {
+	return models.Tag{Id: id, SpaceId: "s"}, nil
+}
+
+func (r taggedTree) GetTaggedDocuments(tagId string, scope models.DocumentScope, limit int, offset int) ([]models.Document, int64, error) {
+	var documents []models.Document
+	for _, document := range r.documents {
+		inSpaces := slices.Contains(scope.SpaceIds, document.SpaceId) && !slices.Contains(scope.ExcludedIds, document.Id)
+		if inSpaces || slices.Contains(scope.IncludedIds, document.Id) {
+			documents = append(documents, document)
+		}
+	}
+	slices.SortFunc(documents, func(a, b models.Document) int { return strings.Compare(a.Id, b.Id) })
+	total := int64(len(documents))
+	return documents[min(offset, len(documents)):min(offset+limit, len(documents))], total, nil
+}
+
+// restrictedTree has a document restricted to alice with a subpage
+func restrictedTree() map[string]models.Document {
+	return map[string]models.Document{
+		"a":  {Id: "a", SpaceId: "s"},
+		"b":  {Id: "b", SpaceId: "s"},
+		"r":  {Id: "r", SpaceId: "s", InheritanceBroken: true, Members: models.Members{{Id: "alice", Type: models.MemberTypeUser, Access: models.AccessTypeFull}}},
+		"r1": {Id: "r1", SpaceId: "s", ParentId: "r"},
+	}
+}
+
+func TestGetTaggedDocuments(t *testing.T) {
+	documents := restrictedTree()
+	// a subpage of the restricted document bob and the guest g are invited to, with a subpage
+	documents["r2"] = models.Document{Id: "r2", SpaceId: "s", ParentId: "r", Members: models.Members{
+		{Id: "bob", Type: models.MemberTypeUser, Access: models.AccessTypeViewer},
+		{Id: "g", Type: models.MemberTypeUser, Access: models.AccessTypeViewer},
+	}}
+	documents["r3"] = models.Document{Id: "r3", SpaceId: "s", ParentId: "r2"}
+	s := NewTagService(taggedTree{documents: documents}, memberSpaces{}, guestUsers{}, memberTree{treeRepository{documents: documents}})
+	tests := []struct {
+		name        string
+		userId      string
+		limit       int
+		offset      int
+		wantIds     []string
+		wantTotal   int64
+		wantHasMore bool
+		wantErr     error
+	}{
+		{"member of the restricted document", "alice", 10, 0, []string{"a", "b", "r", "r1", "r2", "r3"}, 6, false, nil},
+		{"restricted documents hidden", "bob", 10, 0, []string{"a", "b", "r2", "r3"}, 4, false, nil},
+		{"page of the accessible documents", "bob", 1, 0, []string{"a"}, 4, true, nil},
+		{"offset past the accessible documents", "bob", 10, 5, nil, 4, false, nil},
+		{"guest invited to a subpage", "g", 10, 0, []string{"r2", "r3"}, 2, false, nil},
+		{"guest not invited", "h", 10, 0, nil, 0, false, models.ErrAccessDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := s.GetTaggedDocuments(tt.userId, "t", tt.limit, tt.offset)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetTaggedDocuments() error = %v, want %v", err, tt.wantErr)
+			}
+			var ids []string
+			for _, document := range result.Results {
+				ids = append(ids, document.Id)
+			}
+			if !slices.Equal(ids, tt.wantIds) || result.Total != tt.wantTotal || result.HasMore != tt.wantHasMore {
+				t.Errorf("GetTaggedDocuments() = %v, total %d, has more %v, want %v, %d, %v", ids, result.Total, result.HasMore, tt.wantIds, tt.wantTotal, tt.wantHasMore)
+			}
+		})
+	}
+}
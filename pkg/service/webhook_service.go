@@ -0,0 +1,417 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/labbs/zotion/pkg/models"
+)
+
+const (
+	// webhookSecretPrefix prefixes the secrets signing the payloads
+	webhookSecretPrefix = "whsec_"
+	// webhookResponseLimit is the number of bytes of a response kept in the delivery log
+	webhookResponseLimit = 4096
+	// webhookBatchSize is the number of deliveries attempted at each check
+	webhookBatchSize = 50
+	// webhookFirstRetry is the delay before the first retry, doubled at each attempt
+	webhookFirstRetry = 30 * time.Second
+	// webhookMaxRetry is the maximum delay between two attempts
+	webhookMaxRetry = 6 * time.Hour
+)
+
+// webhookEventPrefixes are the types of the events that can be sent to the webhooks
+var webhookEventPrefixes = []string{"document.", "space.", "user."}
+
+type webhookService struct {
+	webhookRepository models.WebhookRepository
+	client            *http.Client
+	maxAttempts       int
+	failureThreshold  int
+}
+
+// NewWebhookService creates a new webhook service. A delivery is attempted maxAttempts times with an
+// exponential backoff, and a webhook is disabled after failureThreshold consecutive failed attempts.
+// Unless allowPrivateNetworks, the deliveries can't connect to the loopback, private and link-local addresses.
+func NewWebhookService(wr models.WebhookRepository, timeout time.Duration, maxAttempts int, failureThreshold int, allowPrivateNetworks bool) *webhookService {
+	return &webhookService{
+		webhookRepository: wr,
+		client:            newWebhookClient(timeout, allowPrivateNetworks),
+		maxAttempts:       max(maxAttempts, 1),
+		failureThreshold:  max(failureThreshold, 1),
+	}
+}
+
+// newWebhookClient returns the client of the deliveries, the redirects are not followed.
+// The addresses are checked when the connections are opened, once the host is resolved,
+// so a host resolving to an internal address is rejected too.
+func newWebhookClient(timeout time.Duration, allowPrivateNetworks bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivateNetworks {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: checkWebhookAddress}
+		transport.DialContext = dialer.DialContext
+		// a proxy would connect to the addresses without checking them
+		transport.Proxy = nil
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// webhookBlockedPrefixes are the reserved networks rejected with the loopback, private and link-local addresses
+var webhookBlockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// checkWebhookAddress is the control of the connections of the deliveries, the connections to the loopback,
+// private, link-local, multicast and reserved addresses are rejected
+func checkWebhookAddress(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+
+	blocked := ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+	for _, prefix := range webhookBlockedPrefixes {
+		blocked = blocked || prefix.Contains(ip)
+	}
+	if blocked {
+		return fmt.Errorf("%w: %s", models.ErrWebhookAddress, ip)
+	}
+	return nil
+}
+
+// IsWebhookEvent returns true when the events of the type can be sent to the webhooks
+func IsWebhookEvent(eventType string) bool {
+	for _, prefix := range webhookEventPrefixes {
+		if strings.HasPrefix(eventType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWebhook creates an enabled webhook, the returned webhook contains its secret
+func (s *webhookService) CreateWebhook(userId string, request models.WebhookRequest) (models.Webhook, error) {
+	if err := checkWebhookRequest(request); err != nil {
+		return models.Webhook{}, err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return models.Webhook{}, err
+	}
+
+	if request.Events == nil {
+		request.Events = models.WebhookEvents{}
+	}
+
+	webhook := models.Webhook{
+		Name:      strings.TrimSpace(request.Name),
+		Url:       request.Url,
+		SpaceId:   request.SpaceId,
+		Events:    request.Events,
+		Active:    request.Active == nil || *request.Active,
+		Secret:    secret,
+		CreatedBy: userId,
+	}
+	return s.webhookRepository.CreateWebhook(webhook)
+}
+
+// GetWebhookById returns the webhook without its secret
+func (s *webhookService) GetWebhookById(id string) (models.Webhook, error) {
+	webhook, err := s.webhookRepository.GetWebhookById(id)
+	webhook.Secret = ""
+	return webhook, err
+}
+
+// GetWebhooks returns the webhooks of the space without their secret, the instance webhooks without space
+func (s *webhookService) GetWebhooks(spaceId string) ([]models.Webhook, error) {
+	webhooks, err := s.webhookRepository.GetWebhooks(spaceId)
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, err
+}
+
+// UpdateWebhook changes the name, the url and the events of the webhook. Enabling a webhook
+// resets its failures, the space of a webhook can't be changed.
+func (s *webhookService) UpdateWebhook(webhook models.Webhook, request models.WebhookRequest) (models.Webhook, error) {
+	if err := checkWebhookRequest(request); err != nil {
+		return models.Webhook{}, err
+	}
+
+	webhook.Name = strings.TrimSpace(request.Name)
+	webhook.Url = request.Url
+	webhook.Events = request.Events
+	if request.Events == nil {
+		webhook.Events = models.WebhookEvents{}
+	}
+	if request.Active != nil {
+		if *request.Active && !webhook.Active {
+			webhook.FailureCount = 0
+			webhook.DisabledAt = nil
+		}
+		webhook.Active = *request.Active
+	}
+	webhook.UpdatedAt = time.Now()
+
+	webhook, err := s.webhookRepository.UpdateWebhook(webhook)
+	webhook.Secret = ""
+	return webhook, err
+}
+
+func (s *webhookService) DeleteWebhook(id string) error {
+	return s.webhookRepository.DeleteWebhook(id)
+}
+
+// RotateSecret replaces the secret of the webhook, the returned webhook contains the new secret
+func (s *webhookService) RotateSecret(webhook models.Webhook) (models.Webhook, error) {
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	if err := s.webhookRepository.UpdateWebhookSecret(webhook.Id, secret); err != nil {
+		return models.Webhook{}, err
+	}
+	webhook.Secret = secret
+	return webhook, nil
+}
+
+func (s *webhookService) GetDeliveries(webhookId string, limit int, offset int) (models.WebhookDeliveryPage, error) {
+	deliveries, total, err := s.webhookRepository.GetDeliveries(webhookId, limit, offset)
+	if err != nil {
+		return models.WebhookDeliveryPage{}, err
+	}
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+	return models.WebhookDeliveryPage{
+		Results: deliveries,
+		Total:   total,
+		HasMore: int64(offset+len(deliveries)) < total,
+	}, nil
+}
+
+func (s *webhookService) GetDeliveryById(id string) (models.WebhookDelivery, error) {
+	return s.webhookRepository.GetDeliveryById(id)
+}
+
+// Redeliver queues a copy of the delivery and attempts it right away, even when the webhook is disabled.
+// The failed attempts are retried like the other deliveries.
+func (s *webhookService) Redeliver(delivery models.WebhookDelivery) (models.WebhookDelivery, error) {
+	webhook, err := s.webhookRepository.GetWebhookById(delivery.WebhookId)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	now := time.Now()
+	redelivery := models.WebhookDelivery{
+		WebhookId:     webhook.Id,
+		EventId:       delivery.EventId,
+		EventType:     delivery.EventType,
+		Payload:       delivery.Payload,
+		Status:        models.WebhookDeliveryPending,
+		DedupKey:      webhook.Id + ":" + utils.UUIDv4(),
+		RedeliveryOf:  delivery.Id,
+		NextAttemptAt: &now,
+	}
+	redelivery, err = s.webhookRepository.CreateDelivery(redelivery)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	return s.attempt(webhook, redelivery, now)
+}
+
+// Enqueue queues the delivery of the event to the enabled webhooks of the instance and of its space
+// selecting its type. An event is only queued once per webhook, whatever the instance enqueuing it.
+func (s *webhookService) Enqueue(eventId string, eventType string, spaceId string, payload []byte) error {
+	if !IsWebhookEvent(eventType) {
+		return nil
+	}
+
+	webhooks, err := s.webhookRepository.GetActiveWebhooks()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, webhook := range webhooks {
+		if webhook.SpaceId != "" && webhook.SpaceId != spaceId {
+			continue
+		}
+		if !webhook.Events.Matches(eventType) {
+			continue
+		}
+
+		delivery := models.WebhookDelivery{
+			WebhookId:     webhook.Id,
+			EventId:       eventId,
+			EventType:     eventType,
+			Payload:       string(payload),
+			Status:        models.WebhookDeliveryPending,
+			DedupKey:      webhook.Id + ":" + eventId,
+			NextAttemptAt: &now,
+		}
+		if _, err := s.webhookRepository.CreateDelivery(delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeliverDue attempts the pending deliveries whose next attempt is passed
+func (s *webhookService) DeliverDue(now time.Time) error {
+	deliveries, err := s.webhookRepository.GetDueDeliveries(now, webhookBatchSize)
+	if err != nil {
+		return err
+	}
+
+	webhooks := map[string]models.Webhook{}
+	for _, delivery := range deliveries {
+		webhook, ok := webhooks[delivery.WebhookId]
+		if !ok {
+			webhook, err = s.webhookRepository.GetWebhookById(delivery.WebhookId)
+			if err != nil {
+				return err
+			}
+			webhooks[webhook.Id] = webhook
+		}
+
+		if _, err := s.attempt(webhook, delivery, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attempt sends the delivery to the webhook and records the result. The attempt is claimed first so that
+// the instances sharing the database don't send the same attempt twice.
+func (s *webhookService) attempt(webhook models.Webhook, delivery models.WebhookDelivery, now time.Time) (models.WebhookDelivery, error) {
+	claimed, err := s.webhookRepository.ClaimDelivery(delivery, now.Add(s.client.Timeout+time.Minute))
+	if err != nil || !claimed {
+		return delivery, err
+	}
+	delivery.Attempts++
+
+	code, body, duration, err := s.send(webhook, delivery, now)
+	delivery.ResponseCode = code
+	delivery.ResponseBody = body
+	delivery.Duration = duration.Milliseconds()
+	delivery.Error = ""
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+
+	success := err == nil && code >= 200 && code < 300
+	switch {
+	case success:
+		delivery.Status = models.WebhookDeliverySucceeded
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+	case delivery.Attempts >= s.maxAttempts:
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.NextAttemptAt = nil
+	default:
+		next := now.Add(webhookBackoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+	}
+	delivery.UpdatedAt = time.Now()
+
+	if err := s.webhookRepository.UpdateDelivery(delivery); err != nil {
+		return delivery, err
+	}
+	return delivery, s.webhookRepository.RecordWebhookResult(webhook.Id, success, s.failureThreshold, now)
+}
+
+// send posts the payload of the delivery signed with the secret of the webhook, the signature is the
+// hex HMAC-SHA256 of the timestamp and the payload joined by a dot
+func (s *webhookService) send(webhook models.Webhook, delivery models.WebhookDelivery, now time.Time) (int, string, time.Duration, error) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(webhook.Secret))
+	mac.Write([]byte(timestamp + "." + delivery.Payload))
+
+	request, err := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return 0, "", 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "Zotion-Webhook/1.0")
+	request.Header.Set("X-Zotion-Event", delivery.EventType)
+	request.Header.Set("X-Zotion-Delivery", delivery.Id)
+	request.Header.Set("X-Zotion-Timestamp", timestamp)
+	request.Header.Set("X-Zotion-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	start := time.Now()
+	response, err := s.client.Do(request)
+	if err != nil {
+		return 0, "", time.Since(start), err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, webhookResponseLimit))
+	return response.StatusCode, strings.ToValidUTF8(string(body), ""), time.Since(start), err
+}
+
+// webhookBackoff returns the delay before the next attempt of a delivery
+func webhookBackoff(attempts int) time.Duration {
+	delay := webhookFirstRetry
+	for i := 1; i < attempts && delay < webhookMaxRetry; i++ {
+		delay *= 2
+	}
+	return min(delay, webhookMaxRetry)
+}
+
+// checkWebhookRequest checks the url and the events of a webhook
+func checkWebhookRequest(request models.WebhookRequest) error {
+	u, err := url.Parse(request.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return models.ErrWebhookUrl
+	}
+
+	for _, pattern := range request.Events {
+		if pattern != "*" && !IsWebhookEvent(pattern) {
+			return models.ErrWebhookEvents
+		}
+	}
+	return nil
+}
+
+// newWebhookSecret returns a random secret
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return webhookSecretPrefix + hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labbs/zotion/pkg/models"
+)
+
+func TestCheckWebhookAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.215.14:443", true},
+		{"[2606:2800:21f:cb07:6820:80da:af6b:8b2c]:443", true},
+		{"127.0.0.1:80", false},
+		{"127.1.2.3:8080", false},
+		{"[::1]:80", false},
+		{"10.0.0.1:80", false},
+		{"172.16.5.4:80", false},
+		{"192.168.1.1:80", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"[fd00::1]:80", false},
+		{"0.0.0.0:80", false},
+		{"[::]:80", false},
+		{"100.64.0.1:80", false},
+		{"224.0.0.1:80", false},
+		{"255.255.255.255:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"[::ffff:169.254.169.254]:80", false},
+		{"[64:ff9b::a00:1]:80", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := checkWebhookAddress("tcp", tt.address, nil)
+			if tt.allowed && err != nil {
+				t.Errorf("checkWebhookAddress(%s) unexpected error: %v", tt.address, err)
+			}
+			if !tt.allowed && !errors.Is(err, models.ErrWebhookAddress) {
+				t.Errorf("checkWebhookAddress(%s) error = %v, want %v", tt.address, err, models.ErrWebhookAddress)
+			}
+		})
+	}
+}
+
+func TestWebhookClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/target", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name                 string
+		path                 string
+		allowPrivateNetworks bool
+		wantCode             int
+		wantErr              error
+	}{
+		{"loopback rejected", "/target", false, 0, models.ErrWebhookAddress},
+		{"loopback allowed", "/target", true, http.StatusNoContent, nil},
+		{"redirect not followed", "/redirect", true, http.StatusFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newWebhookClient(5*time.Second, tt.allowPrivateNetworks)
+			response, err := client.Post(server.URL+tt.path, "application/json", nil)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Post() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Post() unexpected error: %v", err)
+			}
+			response.Body.Close()
+			if response.StatusCode != tt.wantCode {
+				t.Errorf("Post() status = %d, want %d", response.StatusCode, tt.wantCode)
+			}
+		})
+	}
+}
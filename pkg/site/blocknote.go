@@ -0,0 +1,361 @@
+package site
+
+import (
+	"html"
+	"html/template"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/goccy/go-json"
+)
+
+// block is a block of a BlockNote document
+type block struct {
+	Type     string          `json:"type"`
+	Props    map[string]any  `json:"props"`
+	Content  json.RawMessage `json:"content"`
+	Children []block         `json:"children"`
+}
+
+// inline is an inline content of a block, a styled text, a link or a mention
+type inline struct {
+	Type    string          `json:"type"`
+	Text    string          `json:"text"`
+	Href    string          `json:"href"`
+	Styles  map[string]any  `json:"styles"`
+	Props   map[string]any  `json:"props"`
+	Content json.RawMessage `json:"content"`
+}
+
+// tableContent is the content of a table block
+type tableContent struct {
+	Type string `json:"type"`
+	Rows []struct {
+		Cells []json.RawMessage `json:"cells"`
+	} `json:"rows"`
+}
+
+// listTags are the html tags of the lists grouping the consecutive list items
+var listTags = map[string]string{
+	"bulletListItem":   "ul",
+	"numberedListItem": "ol",
+	"checkListItem":    "ul",
+}
+
+// RenderHTML renders the content of a document, a BlockNote JSON document. The contents which are not
+// JSON are rendered as plain text paragraphs.
+func RenderHTML(content string) template.HTML {
+	return renderer{}.html(content)
+}
+
+// RenderHTMLWithUrls renders the content like RenderHTML, the urls of the links and the media are
+// replaced by the rewrite function
+func RenderHTMLWithUrls(content string, rewrite func(string) string) template.HTML {
+	return renderer{rewrite: rewrite}.html(content)
+}
+
+// renderer renders the blocks, rewrite is optional
+type renderer struct {
+	rewrite func(string) string
+}
+
+func (r renderer) html(content string) template.HTML {
+	blocks, ok := parseBlocks(content)
+	if !ok {
+		var b strings.Builder
+		for _, paragraph := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n\n") {
+			if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+				b.WriteString("<p>" + strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>") + "</p>\n")
+			}
+		}
+		return template.HTML(b.String())
+	}
+
+	var b strings.Builder
+	r.renderBlocks(&b, blocks)
+	return template.HTML(b.String())
+}
+
+// PlainText returns the text of the content without formatting, cut to limit characters when limit is positive
+func PlainText(content string, limit int) string {
+	var text string
+	if blocks, ok := parseBlocks(content); ok {
+		var b strings.Builder
+		blocksText(&b, blocks)
+		text = b.String()
+	} else {
+		text = content
+	}
+
+	text = strings.Join(strings.Fields(text), " ")
+	if limit > 0 && utf8.RuneCountInString(text) > limit {
+		runes := []rune(text)
+		text = strings.TrimSpace(string(runes[:limit-1])) + "…"
+	}
+	return text
+}
+
+func parseBlocks(content string) ([]block, bool) {
+	trimmed := strings.TrimSpace(content)
+	if !strings.HasPrefix(trimmed, "[") {
+		return nil, false
+	}
+	var blocks []block
+	if err := json.Unmarshal([]byte(trimmed), &blocks); err != nil {
+		return nil, false
+	}
+	return blocks, true
+}
+
+func (r renderer) renderBlocks(b *strings.Builder, blocks []block) {
+	for i := 0; i < len(blocks); i++ {
+		tag, ok := listTags[blocks[i].Type]
+		if !ok {
+			r.renderBlock(b, blocks[i])
+			continue
+		}
+
+		listType := blocks[i].Type
+		if listType == "checkListItem" {
+			b.WriteString(`<ul class="checklist">`)
+		} else {
+			b.WriteString("<" + tag + ">")
+		}
+		for ; i < len(blocks) && blocks[i].Type == listType; i++ {
+			r.renderListItem(b, blocks[i])
+		}
+		i--
+		b.WriteString("</" + tag + ">\n")
+	}
+}
+
+func (r renderer) renderListItem(b *strings.Builder, item block) {
+	b.WriteString("<li>")
+	if item.Type == "checkListItem" {
+		if checked, _ := item.Props["checked"].(bool); checked {
+			b.WriteString(`<input type="checkbox" checked disabled> `)
+		} else {
+			b.WriteString(`<input type="checkbox" disabled> `)
+		}
+	}
+	r.renderInlines(b, item.Content)
+	if len(item.Children) > 0 {
+		r.renderBlocks(b, item.Children)
+	}
+	b.WriteString("</li>\n")
+}
+
+func (r renderer) renderBlock(b *strings.Builder, blk block) {
+	switch blk.Type {
+	case "heading":
+		level := 1
+		if value, ok := blk.Props["level"].(float64); ok && value >= 1 && value <= 3 {
+			level = int(value)
+		}
+		// The title of the page is the h1, the headings of the content start at h2
+		tag := "h" + strconv.Itoa(level+1)
+		b.WriteString("<" + tag + ">")
+		r.renderInlines(b, blk.Content)
+		b.WriteString("</" + tag + ">\n")
+	case "quote":
+		b.WriteString("<blockquote>")
+		r.renderInlines(b, blk.Content)
+		b.WriteString("</blockquote>\n")
+	case "codeBlock":
+		b.WriteString("<pre><code")
+		if language, _ := blk.Props["language"].(string); language != "" {
+			b.WriteString(` class="language-` + html.EscapeString(language) + `"`)
+		}
+		b.WriteString(">")
+		var text strings.Builder
+		inlinesText(&text, blk.Content)
+		b.WriteString(html.EscapeString(text.String()))
+		b.WriteString("</code></pre>\n")
+	case "image", "video", "audio", "file":
+		r.renderMedia(b, blk)
+	case "table":
+		r.renderTable(b, blk.Content)
+	default:
+		b.WriteString("<p>")
+		r.renderInlines(b, blk.Content)
+		b.WriteString("</p>\n")
+	}
+
+	if len(blk.Children) > 0 {
+		b.WriteString(`<div class="children">`)
+		r.renderBlocks(b, blk.Children)
+		b.WriteString("</div>\n")
+	}
+}
+
+func (r renderer) renderMedia(b *strings.Builder, blk block) {
+	source, _ := blk.Props["url"].(string)
+	source, ok := r.url(source)
+	if !ok {
+		return
+	}
+	caption, _ := blk.Props["caption"].(string)
+	name, _ := blk.Props["name"].(string)
+
+	b.WriteString("<figure>")
+	switch blk.Type {
+	case "image":
+		b.WriteString(`<img src="` + html.EscapeString(source) + `" alt="` + html.EscapeString(caption) + `" loading="lazy">`)
+	case "video":
+		b.WriteString(`<video src="` + html.EscapeString(source) + `" controls></video>`)
+	case "audio":
+		b.WriteString(`<audio src="` + html.EscapeString(source) + `" controls></audio>`)
+	default:
+		if name == "" {
+			name = source
+		}
+		b.WriteString(`<a href="` + html.EscapeString(source) + `">` + html.EscapeString(name) + `</a>`)
+	}
+	if caption != "" {
+		b.WriteString("<figcaption>" + html.EscapeString(caption) + "</figcaption>")
+	}
+	b.WriteString("</figure>\n")
+}
+
+func (r renderer) renderTable(b *strings.Builder, content json.RawMessage) {
+	var table tableContent
+	if err := json.Unmarshal(content, &table); err != nil {
+		return
+	}
+	b.WriteString("<table>\n")
+	for _, row := range table.Rows {
+		b.WriteString("<tr>")
+		for _, cell := range row.Cells {
+			b.WriteString("<td>")
+			r.renderInlines(b, cellContent(cell))
+			b.WriteString("</td>")
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</table>\n")
+}
+
+// cellContent returns the inline contents of a table cell, a list of inline contents or a table cell with its content
+func cellContent(cell json.RawMessage) json.RawMessage {
+	var tableCell struct {
+		Type    string          `json:"type"`
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(cell, &tableCell); err == nil && tableCell.Type == "tableCell" {
+		return tableCell.Content
+	}
+	return cell
+}
+
+func (r renderer) renderInlines(b *strings.Builder, content json.RawMessage) {
+	var inlines []inline
+	if len(content) == 0 || json.Unmarshal(content, &inlines) != nil {
+		return
+	}
+
+	for _, item := range inlines {
+		switch item.Type {
+		case "link":
+			if href, ok := r.url(item.Href); ok {
+				b.WriteString(`<a href="` + html.EscapeString(href) + `" rel="nofollow">`)
+				r.renderInlines(b, item.Content)
+				b.WriteString("</a>")
+			} else {
+				r.renderInlines(b, item.Content)
+			}
+		case "mention":
+			name, _ := item.Props["name"].(string)
+			if name == "" {
+				name, _ = item.Props["user"].(string)
+			}
+			b.WriteString(`<span class="mention">@` + html.EscapeString(name) + `</span>`)
+		default:
+			renderText(b, item)
+		}
+	}
+}
+
+// renderText renders a styled text, the styles are nested in a fixed order
+func renderText(b *strings.Builder, item inline) {
+	text := strings.ReplaceAll(html.EscapeString(item.Text), "\n", "<br>")
+	for _, style := range []struct{ name, tag string }{
+		{"code", "code"},
+		{"strike", "s"},
+		{"underline", "u"},
+		{"italic", "em"},
+		{"bold", "strong"},
+	} {
+		if enabled, _ := item.Styles[style.name].(bool); enabled {
+			text = "<" + style.tag + ">" + text + "</" + style.tag + ">"
+		}
+	}
+	b.WriteString(text)
+}
+
+func blocksText(b *strings.Builder, blocks []block) {
+	for _, blk := range blocks {
+		if blk.Type == "table" {
+			var table tableContent
+			if json.Unmarshal(blk.Content, &table) == nil {
+				for _, row := range table.Rows {
+					for _, cell := range row.Cells {
+						inlinesText(b, cellContent(cell))
+						b.WriteString(" ")
+					}
+				}
+			}
+		} else {
+			inlinesText(b, blk.Content)
+		}
+		b.WriteString("\n")
+		blocksText(b, blk.Children)
+	}
+}
+
+func inlinesText(b *strings.Builder, content json.RawMessage) {
+	var inlines []inline
+	if len(content) == 0 || json.Unmarshal(content, &inlines) != nil {
+		return
+	}
+	for _, item := range inlines {
+		switch item.Type {
+		case "link":
+			inlinesText(b, item.Content)
+		case "mention":
+			name, _ := item.Props["name"].(string)
+			b.WriteString("@" + name)
+		default:
+			b.WriteString(item.Text)
+		}
+	}
+}
+
+// url returns the rewritten url when it's safe
+func (r renderer) url(raw string) (string, bool) {
+	safe, ok := safeUrl(raw)
+	if !ok || r.rewrite == nil {
+		return safe, ok
+	}
+	return r.rewrite(safe), true
+}
+
+// safeUrl returns the url when it's a relative url or an http(s) or mailto url
+func safeUrl(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", false
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+		return raw, true
+	case "":
+		return raw, true
+	}
+	return "", false
+}
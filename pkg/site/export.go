@@ -0,0 +1,444 @@
+package site
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/labbs/zotion/pkg/models"
+	"gorm.io/gorm"
+)
+
+// searchTextLength is the maximum number of characters of the text of a page in the search index
+const searchTextLength = 5000
+
+// ErrNothingPublished is returned when the exported space has no published document
+var ErrNothingPublished = errors.New("the space has no published document")
+
+var (
+	// attachmentPath matches the api url of an attachment
+	attachmentPath = regexp.MustCompile(`^/api/v1/attachment/([0-9a-fA-F-]{36})$`)
+	// pagePath matches the url of a public page
+	pagePath = regexp.MustCompile(`^/p/([0-9a-fA-F-]{36})(/|$)`)
+)
+
+// Archive receives the files of an exported site, the writer returned by Create is valid until the next call
+type Archive interface {
+	Create(name string) (io.Writer, error)
+	Close() error
+}
+
+// dirArchive writes the files in a directory
+type dirArchive struct {
+	root    string
+	current *os.File
+}
+
+// NewDirArchive returns an archive writing the files in the directory, it's created when it doesn't exist
+func NewDirArchive(root string) (Archive, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &dirArchive{root: root}, nil
+}
+
+func (a *dirArchive) Create(name string) (io.Writer, error) {
+	if err := a.closeCurrent(); err != nil {
+		return nil, err
+	}
+	target := filepath.Join(a.root, filepath.FromSlash(path.Clean("/"+name)))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(target)
+	if err != nil {
+		return nil, err
+	}
+	a.current = file
+	return file, nil
+}
+
+func (a *dirArchive) Close() error {
+	return a.closeCurrent()
+}
+
+func (a *dirArchive) closeCurrent() error {
+	if a.current == nil {
+		return nil
+	}
+	err := a.current.Close()
+	a.current = nil
+	return err
+}
+
+// zipArchive writes the files in a zip file
+type zipArchive struct {
+	writer *zip.Writer
+}
+
+// NewZipArchive returns an archive writing a zip file in the writer
+func NewZipArchive(w io.Writer) Archive {
+	return &zipArchive{writer: zip.NewWriter(w)}
+}
+
+func (a *zipArchive) Create(name string) (io.Writer, error) {
+	return a.writer.CreateHeader(&zip.FileHeader{Name: strings.TrimPrefix(path.Clean("/"+name), "/"), Method: zip.Deflate, Modified: time.Now()})
+}
+
+func (a *zipArchive) Close() error {
+	return a.writer.Close()
+}
+
+// StaticPageUrl returns the url of a page of an exported site, relative to the other pages
+func StaticPageUrl(documentId string, slug string) string {
+	return url.PathEscape(staticPageName(documentId, slug))
+}
+
+// staticPageName returns the file name of a page of an exported site, all the pages are at the root of the site
+func staticPageName(documentId string, slug string) string {
+	if slug == "" || strings.ContainsAny(slug, `/\`) {
+		slug = documentId
+	}
+	return slug + ".html"
+}
+
+// SearchEntry is a page of the search index of an exported site
+type SearchEntry struct {
+	Id    string `json:"id"`
+	Title string `json:"title"`
+	Url   string `json:"url"`
+	Text  string `json:"text"`
+}
+
+// ExportResult is the summary of an export
+type ExportResult struct {
+	Pages       int
+	Attachments int
+}
+
+// Exporter renders the published documents of a space as a static site served without the application.
+// The pages link to each other with relative links and the attachments of the pages are copied in the site.
+type Exporter struct {
+	publicService     models.PublicService
+	spaceService      models.SpaceService
+	attachmentService models.AttachmentService
+	siteName          string
+}
+
+// NewExporter returns the exporter of the published spaces
+func NewExporter(publicService models.PublicService, spaceService models.SpaceService, attachmentService models.AttachmentService, siteName string) *Exporter {
+	return &Exporter{
+		publicService:     publicService,
+		spaceService:      spaceService,
+		attachmentService: attachmentService,
+		siteName:          siteName,
+	}
+}
+
+// Export writes the site of the space in the archive, the archive isn't closed
+func (e *Exporter) Export(spaceId string, archive Archive) (ExportResult, error) {
+	var result ExportResult
+
+	space, err := e.spaceService.GetSpaceById(spaceId)
+	if err != nil {
+		return result, err
+	}
+	documents, err := e.publicService.GetPublishedDocuments(spaceId, 0)
+	if err != nil {
+		return result, err
+	}
+	if len(documents) == 0 {
+		return result, ErrNothingPublished
+	}
+
+	published := make(map[string]models.Document, len(documents))
+	for _, document := range documents {
+		published[document.Id] = document
+	}
+	nav := staticNav(documents, published)
+
+	links := &staticLinks{exporter: e, published: published, attachments: map[string]string{}}
+	index := make([]SearchEntry, 0, len(documents))
+	for _, document := range documents {
+		page := Page{
+			SiteName:    e.siteName,
+			Title:       document.Name,
+			Description: PlainText(document.Content, DescriptionLength),
+			Icon:        document.Config.Icon,
+			Content:     RenderHTMLWithUrls(document.Content, links.rewrite),
+			UpdatedAt:   document.UpdatedAt,
+			Nav:         nav,
+			CurrentId:   document.Id,
+			Static:      true,
+		}
+		if err := writePage(archive, staticPageName(document.Id, document.Slug), page); err != nil {
+			return result, err
+		}
+		if links.err != nil {
+			return result, links.err
+		}
+
+		index = append(index, SearchEntry{
+			Id:    document.Id,
+			Title: document.Name,
+			Url:   StaticPageUrl(document.Id, document.Slug),
+			Text:  PlainText(document.Content, searchTextLength),
+		})
+		result.Pages++
+	}
+
+	home := Page{
+		SiteName:  e.siteName,
+		Title:     space.Name,
+		Content:   staticIndex(nav),
+		UpdatedAt: lastUpdate(documents),
+		Nav:       nav,
+		Static:    true,
+	}
+	if err := writePage(archive, "index.html", home); err != nil {
+		return result, err
+	}
+	if err := writeJSON(archive, "search-index.json", index); err != nil {
+		return result, err
+	}
+	if err := writeFile(archive, "search.js", []byte(searchScript)); err != nil {
+		return result, err
+	}
+
+	// the attachments are copied in a stable order to get the same archive for the same content
+	ids := make([]string, 0, len(links.attachments))
+	for id := range links.attachments {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		if err := e.copyAttachment(archive, id, links.attachments[id]); err != nil {
+			return result, err
+		}
+		result.Attachments++
+	}
+
+	return result, nil
+}
+
+// copyAttachment copies the file of the attachment in the archive
+func (e *Exporter) copyAttachment(archive Archive, id string, name string) error {
+	attachment, err := e.attachmentService.GetAttachmentById(id)
+	if err != nil {
+		return err
+	}
+	reader, err := e.attachmentService.OpenAttachment(attachment)
+	if err != nil {
+		return fmt.Errorf("open attachment %s: %w", id, err)
+	}
+	defer reader.Close()
+
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, reader)
+	return err
+}
+
+// staticLinks rewrites the urls of the rendered pages, the attachments of the published documents and
+// the published pages are linked in the site, the other urls are kept
+type staticLinks struct {
+	exporter  *Exporter
+	published map[string]models.Document
+	// attachments are the file names in the site of the copied attachments, by attachment id
+	attachments map[string]string
+	// err is the first error of the attachment lookups
+	err error
+}
+
+func (l *staticLinks) rewrite(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+
+	if match := pagePath.FindStringSubmatch(u.Path); match != nil {
+		if document, ok := l.published[match[1]]; ok {
+			return StaticPageUrl(document.Id, document.Slug)
+		}
+		return raw
+	}
+
+	match := attachmentPath.FindStringSubmatch(u.Path)
+	if match == nil {
+		return raw
+	}
+	id := match[1]
+	if name, ok := l.attachments[id]; ok {
+		return staticAttachmentUrl(name)
+	}
+	attachment, err := l.exporter.attachmentService.GetAttachmentById(id)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) && l.err == nil {
+			l.err = err
+		}
+		return raw
+	}
+	// only the attachments of the published documents are copied
+	if _, ok := l.published[attachment.DocumentId]; !ok {
+		return raw
+	}
+
+	name := "attachments/" + attachment.Id + "/" + attachmentFileName(attachment)
+	l.attachments[id] = name
+	return staticAttachmentUrl(name)
+}
+
+// staticAttachmentUrl returns the relative url of an attachment file of the site
+func staticAttachmentUrl(name string) string {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// attachmentFileName returns the file name of the attachment without any directory
+func attachmentFileName(attachment models.Attachment) string {
+	name := filepath.Base(strings.ReplaceAll(attachment.Name, `\`, "/"))
+	if name == "." || name == "/" || name == ".." || name == "" {
+		return attachment.Id
+	}
+	return name
+}
+
+// staticNav returns the navigation of the published trees of the space, the pages are sorted by name
+func staticNav(documents []models.Document, published map[string]models.Document) []models.PublicNavNode {
+	children := map[string][]models.Document{}
+	var roots []models.Document
+	for _, document := range documents {
+		if _, ok := published[document.ParentId]; ok && document.ParentId != document.Id {
+			children[document.ParentId] = append(children[document.ParentId], document)
+		} else {
+			roots = append(roots, document)
+		}
+	}
+
+	visited := map[string]bool{}
+	var build func(documents []models.Document) []models.PublicNavNode
+	build = func(documents []models.Document) []models.PublicNavNode {
+		slices.SortFunc(documents, func(a, b models.Document) int {
+			return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		})
+		nodes := []models.PublicNavNode{}
+		for _, document := range documents {
+			if visited[document.Id] {
+				continue
+			}
+			visited[document.Id] = true
+			nodes = append(nodes, models.PublicNavNode{PublicPage: models.NewPublicPage(document), Children: build(children[document.Id])})
+		}
+		return nodes
+	}
+	return build(roots)
+}
+
+// staticIndex returns the content of the home page of the site, the links to the top level pages
+func staticIndex(nav []models.PublicNavNode) template.HTML {
+	var b strings.Builder
+	b.WriteString("<ul>\n")
+	for _, node := range nav {
+		b.WriteString(`<li><a href="` + html.EscapeString(StaticPageUrl(node.Id, node.Slug)) + `">`)
+		if node.Icon != "" {
+			b.WriteString(html.EscapeString(node.Icon) + " ")
+		}
+		b.WriteString(html.EscapeString(node.Name) + "</a></li>\n")
+	}
+	b.WriteString("</ul>\n")
+	return template.HTML(b.String())
+}
+
+func lastUpdate(documents []models.Document) time.Time {
+	var updated time.Time
+	for _, document := range documents {
+		if document.UpdatedAt.After(updated) {
+			updated = document.UpdatedAt
+		}
+	}
+	return updated
+}
+
+func writePage(archive Archive, name string, page Page) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	return RenderPage(w, page)
+}
+
+func writeJSON(archive Archive, name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return writeFile(archive, name, data)
+}
+
+func writeFile(archive Archive, name string, data []byte) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// searchScript searches the pages of the site in the search index, the matching titles come first
+const searchScript = `(function () {
+  var input = document.getElementById("search");
+  var results = document.getElementById("search-results");
+  if (!input || !results) return;
+  var index = null;
+
+  function load() {
+    if (index) return Promise.resolve(index);
+    return fetch("search-index.json").then(function (r) { return r.json(); }).then(function (entries) {
+      index = entries;
+      return index;
+    });
+  }
+
+  function render(query) {
+    results.innerHTML = "";
+    query = query.trim().toLowerCase();
+    if (!query) return;
+    load().then(function (entries) {
+      var scored = [];
+      entries.forEach(function (entry) {
+        var title = entry.title.toLowerCase();
+        if (title.indexOf(query) !== -1) scored.push([0, entry]);
+        else if (entry.text.toLowerCase().indexOf(query) !== -1) scored.push([1, entry]);
+      });
+      scored.sort(function (a, b) { return a[0] - b[0]; });
+      scored.slice(0, 10).forEach(function (item) {
+        var li = document.createElement("li");
+        var a = document.createElement("a");
+        a.href = item[1].url;
+        a.textContent = item[1].title;
+        li.appendChild(a);
+        results.appendChild(li);
+      });
+    });
+  }
+
+  input.addEventListener("input", function () { render(input.value); });
+})();
+`
@@ -0,0 +1,66 @@
+package site
+
+import (
+	"sync"
+	"time"
+
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/rs/zerolog"
+)
+
+// ExportRunner runs the pending site exports in background, one at a time
+type ExportRunner struct {
+	logger  zerolog.Logger
+	service models.SiteExportService
+
+	wake chan struct{}
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewExportRunner starts the runner, the pending exports are checked at each interval and when it's woken up
+func NewExportRunner(logger zerolog.Logger, service models.SiteExportService, interval time.Duration) *ExportRunner {
+	r := &ExportRunner{
+		logger:  logger.With().Str("component", "site_export").Logger(),
+		service: service,
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+
+	r.wg.Add(1)
+	go r.run(interval)
+	return r
+}
+
+// Wake checks the pending exports without waiting for the next interval, it doesn't block
+func (r *ExportRunner) Wake() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *ExportRunner) run(interval time.Duration) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+		if err := r.service.RunPending(); err != nil {
+			r.logger.Error().Err(err).Msg("failed to run the pending site exports")
+		}
+	}
+}
+
+// Close stops the runner and waits for the running export
+func (r *ExportRunner) Close() {
+	close(r.stop)
+	r.wg.Wait()
+}
@@ -0,0 +1,329 @@
+package site
+
+import (
+	"encoding/xml"
+	"html/template"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/labbs/zotion/pkg/models"
+)
+
+// DescriptionLength is the maximum number of characters of the description of a page
+const DescriptionLength = 160
+
+// Page is a published document rendered as html
+type Page struct {
+	SiteName     string
+	BaseUrl      string
+	Title        string
+	Description  string
+	Icon         string
+	CanonicalUrl string
+	FeedUrl      string
+	Content      template.HTML
+	UpdatedAt    time.Time
+
+	// Nav is the navigation of the published trees of the page, empty when the page has no published subpage
+	Nav       []models.PublicNavNode
+	CurrentId string
+
+	// Static renders the links relative to the exported site, the pages are served without the application
+	Static bool
+}
+
+// PageUrl returns the url of the page of the node
+func (p Page) PageUrl(node models.PublicNavNode) string {
+	if p.Static {
+		return StaticPageUrl(node.Id, node.Slug)
+	}
+	return PageUrl(p.BaseUrl, node.Id, node.Slug)
+}
+
+// PageUrl returns the stable url of the public page of a document, the slug is only informative
+func PageUrl(baseUrl string, documentId string, slug string) string {
+	return strings.TrimRight(baseUrl, "/") + "/p/" + url.PathEscape(documentId) + "/" + url.PathEscape(slug)
+}
+
+// FeedUrl returns the url of the feed of the public pages of a space, the format is rss or atom
+func FeedUrl(baseUrl string, spaceId string, format string) string {
+	return strings.TrimRight(baseUrl, "/") + "/s/" + url.PathEscape(spaceId) + "/" + format + ".xml"
+}
+
+// RenderPage writes the html of the page
+func RenderPage(w io.Writer, page Page) error {
+	return pageTemplate.Execute(w, page)
+}
+
+var pageTemplate = template.Must(template.New("page").Funcs(template.FuncMap{"navItem": newNavItem}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} - {{.SiteName}}</title>
+{{- if .Description}}
+<meta name="description" content="{{.Description}}">
+{{- end}}
+{{- if .CanonicalUrl}}
+<link rel="canonical" href="{{.CanonicalUrl}}">
+{{- end}}
+{{- if .FeedUrl}}
+<link rel="alternate" type="application/atom+xml" title="{{.SiteName}}" href="{{.FeedUrl}}">
+{{- end}}
+<meta property="og:type" content="article">
+<meta property="og:site_name" content="{{.SiteName}}">
+<meta property="og:title" content="{{.Title}}">
+{{- if .Description}}
+<meta property="og:description" content="{{.Description}}">
+{{- end}}
+{{- if .CanonicalUrl}}
+<meta property="og:url" content="{{.CanonicalUrl}}">
+{{- end}}
+<meta property="article:modified_time" content="{{.UpdatedAt.UTC.Format "2006-01-02T15:04:05Z07:00"}}">
+<meta name="twitter:card" content="summary">
+<meta name="twitter:title" content="{{.Title}}">
+{{- if .Description}}
+<meta name="twitter:description" content="{{.Description}}">
+{{- end}}
+<style>
+body{margin:0;font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",Roboto,sans-serif;color:#37352f;line-height:1.6}
+.layout{display:flex;max-width:1100px;margin:0 auto}
+nav{width:240px;padding:24px 16px;font-size:14px;border-right:1px solid #eee}
+nav ul{list-style:none;padding-left:12px;margin:0}
+nav>ul{padding-left:0}
+nav a{color:inherit;text-decoration:none}
+nav a.current{font-weight:600}
+main{flex:1;padding:48px 64px;min-width:0}
+h1 .icon{margin-right:8px}
+pre{background:#f7f6f3;padding:16px;overflow:auto}
+blockquote{border-left:3px solid #37352f;margin:0;padding-left:16px}
+table{border-collapse:collapse}
+td{border:1px solid #e9e9e7;padding:4px 8px}
+img,video{max-width:100%}
+ul.checklist{list-style:none;padding-left:0}
+.children{padding-left:24px}
+footer{margin-top:48px;font-size:12px;color:#999}
+.search input{width:100%;box-sizing:border-box;margin-bottom:12px;padding:4px 8px}
+.search ul{padding-left:0;margin-bottom:12px}
+@media (max-width:720px){.layout{display:block}nav{width:auto;border-right:0;border-bottom:1px solid #eee}main{padding:24px}}
+</style>
+</head>
+<body>
+<div class="layout">
+{{- if .Nav}}
+<nav>
+{{- if .Static}}
+<div class="search"><input type="search" id="search" placeholder="Search" aria-label="Search"><ul id="search-results"></ul></div>
+{{- end}}
+<ul>{{range .Nav}}{{template "nav" (navItem $ .)}}{{end}}</ul>
+</nav>
+{{- end}}
+<main>
+<article>
+<h1>{{if .Icon}}<span class="icon">{{.Icon}}</span>{{end}}{{.Title}}</h1>
+{{.Content}}
+</article>
+<footer>Last updated on <time datetime="{{.UpdatedAt.UTC.Format "2006-01-02T15:04:05Z07:00"}}">{{.UpdatedAt.UTC.Format "January 2, 2006"}}</time> · {{.SiteName}}</footer>
+</main>
+</div>
+{{- if .Static}}
+<script src="search.js"></script>
+{{- end}}
+</body>
+</html>
+{{define "nav"}}{{$page := .Page}}{{with .Node}}<li><a href="{{$page.PageUrl .}}"{{if eq .Id $page.CurrentId}} class="current" aria-current="page"{{end}}>{{if .Icon}}{{.Icon}} {{end}}{{.Name}}</a>
+{{- if .Children}}<ul>{{range .Children}}{{template "nav" (navItem $page .)}}{{end}}</ul>{{end}}</li>{{end}}{{end}}`))
+
+// navItem is the data of the recursive nav template
+type navItem struct {
+	Page Page
+	Node models.PublicNavNode
+}
+
+func newNavItem(page Page, node models.PublicNavNode) navItem {
+	return navItem{Page: page, Node: node}
+}
+
+// Sitemap is the sitemap of the published pages
+type Sitemap struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Urls    []SitemapUrl `xml:"url"`
+}
+
+// SitemapUrl is a page of the sitemap
+type SitemapUrl struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// NewSitemap returns the sitemap of the published documents
+func NewSitemap(baseUrl string, documents []models.Document) Sitemap {
+	sitemap := Sitemap{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9", Urls: make([]SitemapUrl, 0, len(documents))}
+	for _, document := range documents {
+		sitemap.Urls = append(sitemap.Urls, SitemapUrl{
+			Loc:     PageUrl(baseUrl, document.Id, document.Slug),
+			LastMod: document.UpdatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return sitemap
+}
+
+// Feed is a feed of the recently updated public pages, rendered as rss or atom
+type Feed struct {
+	Title   string
+	Link    string
+	SelfUrl string
+	Updated time.Time
+	Items   []FeedItem
+}
+
+// FeedItem is a page of a feed
+type FeedItem struct {
+	Id      string
+	Title   string
+	Link    string
+	Summary string
+	Content string
+	Updated time.Time
+}
+
+// NewFeedItem returns the item of a published document
+func NewFeedItem(baseUrl string, document models.Document) FeedItem {
+	return FeedItem{
+		Id:      document.Id,
+		Title:   document.Name,
+		Link:    PageUrl(baseUrl, document.Id, document.Slug),
+		Summary: PlainText(document.Content, DescriptionLength*2),
+		Content: string(RenderHTML(document.Content)),
+		Updated: document.UpdatedAt,
+	}
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	AtomLink      rssLink   `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Guid        rssGuid `xml:"guid"`
+	Description string  `xml:"description"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// RSS returns the feed as an rss 2.0 document
+func (f Feed) RSS() ([]byte, error) {
+	channel := rssChannel{
+		Title:         f.Title,
+		Link:          f.Link,
+		Description:   f.Title,
+		LastBuildDate: f.Updated.UTC().Format(time.RFC1123Z),
+		AtomLink:      rssLink{Href: f.SelfUrl, Rel: "self", Type: "application/rss+xml"},
+		Items:         make([]rssItem, 0, len(f.Items)),
+	}
+	for _, item := range f.Items {
+		channel.Items = append(channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Guid:        rssGuid{IsPermaLink: true, Value: item.Link},
+			Description: item.Summary,
+			PubDate:     item.Updated.UTC().Format(time.RFC1123Z),
+		})
+	}
+	return marshalXML(rss{Version: "2.0", Atom: "http://www.w3.org/2005/Atom", Channel: channel})
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Id      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Id      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary"`
+	Content atomText `xml:"content"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Atom returns the feed as an atom 1.0 document
+func (f Feed) Atom() ([]byte, error) {
+	feed := atomFeed{
+		Xmlns:   "http://www.w3.org/2005/Atom",
+		Id:      f.SelfUrl,
+		Title:   f.Title,
+		Updated: f.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.Link},
+			{Href: f.SelfUrl, Rel: "self", Type: "application/atom+xml"},
+		},
+		Entries: make([]atomEntry, 0, len(f.Items)),
+	}
+	for _, item := range f.Items {
+		feed.Entries = append(feed.Entries, atomEntry{
+			Id:      "urn:uuid:" + item.Id,
+			Title:   item.Title,
+			Updated: item.Updated.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: item.Link, Rel: "alternate", Type: "text/html"},
+			Summary: item.Summary,
+			Content: atomText{Type: "html", Value: item.Content},
+		})
+	}
+	return marshalXML(feed)
+}
+
+// Marshal returns the sitemap as an xml document
+func (s Sitemap) Marshal() ([]byte, error) {
+	return marshalXML(s)
+}
+
+func marshalXML(v any) ([]byte, error) {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Storage stores the objects in a S3 compatible bucket (aws, minio, ...)
+type S3Storage struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3Storage connects to the endpoint and creates the bucket if it doesn't exist
+func NewS3Storage(endpoint, region, bucket, accessKey, secretKey string, useSSL bool) (*S3Storage, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+		Region: region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	exists, err := client.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: region}); err != nil {
+			return nil, err
+		}
+	}
+
+	return &S3Storage{client: client, bucket: bucket}, nil
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, reader, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+// Get returns the object, it's checked first because minio only fails on the first read
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if _, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{}); err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+}
+
+// Delete removes the object, deleting a missing object is not an error
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
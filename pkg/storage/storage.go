@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/labbs/zotion/pkg/config"
+	"github.com/rs/zerolog"
+)
+
+// ErrNotFound is returned when the object doesn't exist in the storage
+var ErrNotFound = errors.New("storage object not found")
+
+// Storage stores the files of the attachments, the objects are identified by a slash separated key
+type Storage interface {
+	Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type StorageType string
+
+const (
+	LocalStorageType StorageType = "local"
+	S3StorageType    StorageType = "s3"
+)
+
+// New returns the storage configured for the attachments
+func New(logger zerolog.Logger) (Storage, error) {
+	switch config.Attachment.Storage {
+	case string(LocalStorageType):
+		logger.Info().Msgf("Using local attachment storage in %s", config.Attachment.Local.Path)
+		return NewLocalStorage(config.Attachment.Local.Path)
+	case string(S3StorageType):
+		logger.Info().Msgf("Using S3 attachment storage at %s with bucket %s", config.Attachment.S3.Endpoint, config.Attachment.S3.Bucket)
+		return NewS3Storage(config.Attachment.S3.Endpoint, config.Attachment.S3.Region, config.Attachment.S3.Bucket, config.Attachment.S3.AccessKey, config.Attachment.S3.SecretKey, config.Attachment.S3.UseSSL)
+	default:
+		return nil, fmt.Errorf("unsupported attachment storage: %s", config.Attachment.Storage)
+	}
+}
@@ -0,0 +1,208 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/labbs/zotion/pkg/storage"
+	"github.com/rs/zerolog"
+)
+
+// Generator creates the width-limited variants of the images and stores them next to the originals.
+// The variants are generated in background after the upload or lazily on the first request.
+type Generator struct {
+	logger  zerolog.Logger
+	storage storage.Storage
+	widths  []int
+
+	queue chan job
+	wg    sync.WaitGroup
+
+	// mutex guards inflight and closed, the queue is closed once closed is set
+	mutex    sync.Mutex
+	inflight map[string]*generation
+	closed   bool
+}
+
+// generation is a variant being generated, err is set before done is closed
+type generation struct {
+	done chan struct{}
+	err  error
+}
+
+type job struct {
+	key   string
+	width int
+}
+
+// NewGenerator starts the workers generating the variants in background,
+// no worker is started when workers is zero and the variants are only generated lazily
+func NewGenerator(logger zerolog.Logger, storage storage.Storage, widths []int, workers int) *Generator {
+	widths = slices.Clone(widths)
+	slices.Sort(widths)
+
+	g := &Generator{
+		logger:   logger.With().Str("component", "thumbnail").Logger(),
+		storage:  storage,
+		widths:   widths,
+		queue:    make(chan job, 100),
+		inflight: make(map[string]*generation),
+	}
+
+	for range workers {
+		g.wg.Add(1)
+		go g.work()
+	}
+	return g
+}
+
+// VariantKey returns the storage key of a variant
+func VariantKey(key string, width int) string {
+	return key + "_w" + strconv.Itoa(width)
+}
+
+// Width returns the configured width to serve for the requested one, the smallest one larger or equal.
+// The width of the original is returned when it's not wider, the variant keeps its size but not its
+// metadata. Zero is returned when no width is configured and the width of the original is unknown.
+func (g *Generator) Width(requested, original int) int {
+	if len(g.widths) == 0 {
+		return original
+	}
+
+	width := g.widths[len(g.widths)-1]
+	for _, w := range g.widths {
+		if w >= requested {
+			width = w
+			break
+		}
+	}
+	if original > 0 && width >= original {
+		return original
+	}
+	return width
+}
+
+// Enqueue schedules the generation of the variants narrower than the original image.
+// The jobs are dropped when the queue is full or the generator is closed, they will be generated lazily.
+func (g *Generator) Enqueue(key string, original int) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.closed {
+		return
+	}
+	for _, width := range g.widths {
+		if original > 0 && width >= original {
+			break
+		}
+		select {
+		case g.queue <- job{key: key, width: width}:
+		default:
+			g.logger.Warn().Str("key", key).Msg("Thumbnail queue is full, the variant will be generated on demand")
+		}
+	}
+}
+
+// Open returns the variant of the image, it's generated if it doesn't exist yet
+func (g *Generator) Open(ctx context.Context, key string, width int) (io.ReadCloser, error) {
+	reader, err := g.storage.Get(ctx, VariantKey(key, width))
+	if !errors.Is(err, storage.ErrNotFound) {
+		return reader, err
+	}
+
+	if err := g.generate(ctx, key, width); err != nil {
+		return nil, err
+	}
+	return g.storage.Get(ctx, VariantKey(key, width))
+}
+
+// Delete removes the variants of the image and the one with its original width, a missing variant is not an error
+func (g *Generator) Delete(ctx context.Context, key string, original int) error {
+	var errs []error
+	for _, width := range g.widths {
+		errs = append(errs, g.storage.Delete(ctx, VariantKey(key, width)))
+	}
+	if original > 0 && !slices.Contains(g.widths, original) {
+		errs = append(errs, g.storage.Delete(ctx, VariantKey(key, original)))
+	}
+	return errors.Join(errs...)
+}
+
+// Close stops the workers once the queued variants are generated
+func (g *Generator) Close() error {
+	g.mutex.Lock()
+	if g.closed {
+		g.mutex.Unlock()
+		return nil
+	}
+	g.closed = true
+	close(g.queue)
+	g.mutex.Unlock()
+
+	g.wg.Wait()
+	return nil
+}
+
+func (g *Generator) work() {
+	defer g.wg.Done()
+	for job := range g.queue {
+		if err := g.generate(context.Background(), job.key, job.width); err != nil {
+			g.logger.Error().Err(err).Str("key", job.key).Int("width", job.width).Msg("Failed to generate the variant")
+		}
+	}
+}
+
+// generate creates the variant, the concurrent generations of the same variant wait for the first one
+// and get its error
+func (g *Generator) generate(ctx context.Context, key string, width int) (err error) {
+	variantKey := VariantKey(key, width)
+
+	g.mutex.Lock()
+	if pending, ok := g.inflight[variantKey]; ok {
+		g.mutex.Unlock()
+		select {
+		case <-pending.done:
+			return pending.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	pending := &generation{done: make(chan struct{})}
+	g.inflight[variantKey] = pending
+	g.mutex.Unlock()
+
+	defer func() {
+		g.mutex.Lock()
+		delete(g.inflight, variantKey)
+		g.mutex.Unlock()
+		pending.err = err
+		close(pending.done)
+	}()
+
+	// the variant may have been generated since the lookup
+	if reader, err := g.storage.Get(ctx, variantKey); err == nil {
+		return reader.Close()
+	}
+
+	reader, err := g.storage.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return err
+	}
+
+	variant, err := Resize(data, width)
+	if err != nil {
+		return err
+	}
+	return g.storage.Put(ctx, variantKey, bytes.NewReader(variant), int64(len(variant)), http.DetectContentType(variant))
+}
@@ -0,0 +1,121 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/labbs/zotion/pkg/storage"
+	"github.com/rs/zerolog"
+)
+
+func newTestGenerator(t *testing.T, widths []int, workers int) (*Generator, storage.Storage) {
+	t.Helper()
+	s, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return NewGenerator(zerolog.Nop(), s, widths, workers), s
+}
+
+func putImage(t *testing.T, s storage.Storage, key string, width, height int) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.Put(context.Background(), key, bytes.NewReader(buf.Bytes()), int64(buf.Len()), "image/png"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestGeneratorWidth(t *testing.T) {
+	g, _ := newTestGenerator(t, []int{800, 200, 400}, 0)
+
+	tests := []struct {
+		name      string
+		requested int
+		original  int
+		want      int
+	}{
+		{"smallest larger width", 300, 1000, 400},
+		{"exact width", 400, 1000, 400},
+		{"larger than the widths", 2000, 1000, 800},
+		{"original narrower than the width", 800, 600, 600},
+		{"original narrower than the request", 2000, 150, 150},
+		{"unknown original", 300, 0, 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if width := g.Width(tt.requested, tt.original); width != tt.want {
+				t.Fatalf("width %d, want %d", width, tt.want)
+			}
+		})
+	}
+
+	noWidths, _ := newTestGenerator(t, nil, 0)
+	if width := noWidths.Width(300, 1000); width != 1000 {
+		t.Fatalf("width without configured widths %d, want 1000", width)
+	}
+}
+
+func TestGeneratorOpen(t *testing.T) {
+	g, s := newTestGenerator(t, []int{100}, 0)
+	putImage(t, s, "image", 300, 150)
+
+	reader, err := g.Open(context.Background(), "image", 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+
+	width, height, err := Dimensions(data)
+	if err != nil || width != 100 || height != 50 {
+		t.Fatalf("variant is %dx%d (%v), want 100x50", width, height, err)
+	}
+}
+
+func TestGeneratorOpenError(t *testing.T) {
+	g, s := newTestGenerator(t, []int{100}, 0)
+	if err := s.Put(context.Background(), "broken", bytes.NewReader([]byte("not an image")), -1, "image/png"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the concurrent requests wait for the same generation and all get its error
+	errs := make(chan error, 4)
+	for range 4 {
+		go func() {
+			_, err := g.Open(context.Background(), "broken", 100)
+			errs <- err
+		}()
+	}
+	for range 4 {
+		if err := <-errs; err == nil {
+			t.Fatal("expected an error for an invalid image")
+		}
+	}
+}
+
+func TestGeneratorEnqueueAfterClose(t *testing.T) {
+	g, s := newTestGenerator(t, []int{100}, 1)
+	putImage(t, s, "image", 300, 150)
+
+	g.Enqueue("image", 300)
+	if err := g.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.Get(context.Background(), VariantKey("image", 100)); err != nil {
+		t.Fatalf("queued variant not generated before the close: %v", err)
+	}
+
+	// the jobs enqueued once closed are dropped
+	g.Enqueue("image", 300)
+	if err := g.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
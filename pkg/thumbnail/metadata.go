@@ -0,0 +1,153 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// StripMetadata removes the metadata of a jpeg, png or webp image without re-encoding it, like the exif
+// location, the xmp and the comments. The exif orientation of the jpeg images is kept to display them the same way.
+// The image is returned unchanged when its format isn't supported or its structure can't be read.
+func StripMetadata(data []byte) []byte {
+	var stripped []byte
+	var ok bool
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		stripped, ok = stripJpeg(data)
+	case bytes.HasPrefix(data, pngSignature):
+		stripped, ok = stripPng(data)
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		stripped, ok = stripWebp(data)
+	}
+	if !ok {
+		return data
+	}
+	return stripped
+}
+
+// stripJpeg keeps the segments needed to decode the image: JFIF (APP0), the icc profile (APP2),
+// the adobe color transform (APP14) and the segments which aren't application data or comments
+func stripJpeg(data []byte) ([]byte, bool) {
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+	if orientation := jpegOrientation(data); orientation > 1 {
+		out = append(out, exifOrientationSegment(orientation)...)
+	}
+
+	for i := 2; i+2 <= len(data); {
+		if data[i] != 0xFF {
+			return nil, false
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF:
+			i++
+			continue
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			out = append(out, data[i:i+2]...)
+			i += 2
+			continue
+		case marker == 0xDA || marker == 0xD9:
+			// the image data is copied as is
+			return append(out, data[i:]...), true
+		}
+
+		if i+4 > len(data) {
+			return nil, false
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return nil, false
+		}
+		application := marker >= 0xE0 && marker <= 0xEF
+		if marker == 0xFE || (application && marker != 0xE0 && marker != 0xE2 && marker != 0xEE) {
+			i += 2 + size
+			continue
+		}
+		out = append(out, data[i:i+2+size]...)
+		i += 2 + size
+	}
+	return nil, false
+}
+
+// exifOrientationSegment returns an exif segment with the orientation tag only
+func exifOrientationSegment(orientation int) []byte {
+	segment := []byte{0xFF, 0xE1, 0x00, 0x22}
+	segment = append(segment, "Exif\x00\x00"...)
+	// big endian tiff header with the first IFD right after it
+	segment = append(segment, 'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08)
+	// one entry: orientation, short, count 1, value padded to 4 bytes
+	segment = append(segment, 0x00, 0x01, 0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, byte(orientation), 0x00, 0x00)
+	// no next IFD
+	return append(segment, 0x00, 0x00, 0x00, 0x00)
+}
+
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}
+
+// pngMetadataChunks are the chunks removed from the png images
+var pngMetadataChunks = map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+
+// stripPng removes the exif, text and time chunks, the other chunks are kept with their checksum
+func stripPng(data []byte) ([]byte, bool) {
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	for i := len(pngSignature); i+8 <= len(data); {
+		size := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + size
+		if end > len(data) {
+			return nil, false
+		}
+		chunkType := string(data[i+4 : i+8])
+		if !pngMetadataChunks[chunkType] {
+			out = append(out, data[i:end]...)
+		}
+		if chunkType == "IEND" {
+			return out, true
+		}
+		i = end
+	}
+	return nil, false
+}
+
+// webp flags of the VP8X chunk announcing the metadata chunks
+const (
+	webpExifFlag = 0x08
+	webpXmpFlag  = 0x04
+)
+
+// stripWebp removes the exif and xmp chunks and their flags, the size of the container is updated
+func stripWebp(data []byte) ([]byte, bool) {
+	// the data following the container are dropped
+	length := int(binary.LittleEndian.Uint32(data[4:])) + 8
+	if length > len(data) {
+		return nil, false
+	}
+	data = data[:length]
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:12]...)
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, false
+		}
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2
+		if end > len(data) {
+			return nil, false
+		}
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[i:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= webpExifFlag | webpXmpFlag
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, true
+}
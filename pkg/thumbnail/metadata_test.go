@@ -0,0 +1,138 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// withExif inserts an exif segment with the orientation and a location after the start of a jpeg image,
+// followed by a comment
+func withExif(t *testing.T, orientation int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 20)), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	exif := exifOrientationSegment(orientation)
+	exif = append(exif[:len(exif)-4], "GPS 48.8584 2.2945"...)
+	binary.BigEndian.PutUint16(exif[2:], uint16(len(exif)-2))
+	comment := append([]byte{0xFF, 0xFE, 0x00, 0x08}, "secret"...)
+
+	data := append([]byte{0xFF, 0xD8}, exif...)
+	data = append(data, comment...)
+	return append(data, buf.Bytes()[2:]...)
+}
+
+// withText inserts a text chunk after the header of a png image
+func withText(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 20))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data := buf.Bytes()
+	headerEnd := len(pngSignature) + 12 + 13
+
+	text := []byte("Comment\x00secret")
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(text)))
+	chunk = append(chunk, "tEXt"...)
+	chunk = append(chunk, text...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	out := append([]byte(nil), data[:headerEnd]...)
+	out = append(out, chunk...)
+	return append(out, data[headerEnd:]...)
+}
+
+// webpChunk encodes a chunk of a webp container with its padding
+func webpChunk(fourcc string, payload []byte) []byte {
+	chunk := append([]byte(fourcc), binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))...)
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// withWebpExif builds an extended webp container announcing an exif chunk
+func withWebpExif() []byte {
+	chunks := webpChunk("VP8X", []byte{webpExifFlag | webpXmpFlag, 0, 0, 0, 39, 0, 0, 19, 0, 0})
+	chunks = append(chunks, webpChunk("VP8L", []byte{0x2F, 1, 2})...)
+	chunks = append(chunks, webpChunk("EXIF", []byte("GPS 48.8584 2.2945"))...)
+	chunks = append(chunks, webpChunk("XMP ", []byte("<x:xmpmeta>secret</x:xmpmeta>"))...)
+
+	data := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(4+len(chunks)))...)
+	data = append(data, "WEBP"...)
+	return append(data, chunks...)
+}
+
+func TestStripMetadata(t *testing.T) {
+	jpegImage := withExif(t, 6)
+	pngImage := withText(t)
+	webpImage := withWebpExif()
+
+	tests := []struct {
+		name            string
+		data            []byte
+		wantRemoved     []string
+		wantOrientation int
+		wantDecoded     bool
+	}{
+		{"jpeg", jpegImage, []string{"GPS", "secret"}, 6, true},
+		{"png", pngImage, []string{"secret"}, 1, true},
+		{"webp", webpImage, []string{"GPS", "secret", "EXIF", "XMP"}, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripped := StripMetadata(tt.data)
+			for _, removed := range tt.wantRemoved {
+				if bytes.Contains(stripped, []byte(removed)) {
+					t.Errorf("stripped image still contains %q", removed)
+				}
+			}
+			if got := jpegOrientation(stripped); got != tt.wantOrientation {
+				t.Errorf("orientation = %d, want %d", got, tt.wantOrientation)
+			}
+			if tt.wantDecoded {
+				if _, _, err := image.Decode(bytes.NewReader(stripped)); err != nil {
+					t.Errorf("stripped image not decoded: %v", err)
+				}
+			}
+		})
+	}
+
+	// the flags and the size of the webp container are updated
+	stripped := StripMetadata(webpImage)
+	if flags := stripped[20]; flags&(webpExifFlag|webpXmpFlag) != 0 {
+		t.Errorf("webp flags = %#x, want the metadata flags cleared", flags)
+	}
+	if size := binary.LittleEndian.Uint32(stripped[4:]); int(size) != len(stripped)-8 {
+		t.Errorf("webp size = %d, want %d", size, len(stripped)-8)
+	}
+}
+
+func TestStripMetadataUnchanged(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not an image", []byte("%PDF-1.7")},
+		{"truncated jpeg", withExif(t, 1)[:10]},
+		{"truncated png", withText(t)[:40]},
+		{"truncated webp", withWebpExif()[:30]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripMetadata(tt.data); !bytes.Equal(got, tt.data) {
+				t.Errorf("StripMetadata() changed the data: %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// maxPixels limits the size of the decoded images to protect the memory against decompression bombs
+const maxPixels = 50_000_000
+
+// jpegQuality is the quality of the jpeg variants
+const jpegQuality = 85
+
+// ErrImageTooLarge is returned when the image has too many pixels to be resized
+var ErrImageTooLarge = errors.New("image too large")
+
+// Supported returns true if variants can be generated for the content type
+func Supported(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/webp":
+		return true
+	}
+	return false
+}
+
+// Dimensions returns the displayed dimensions of the image from its header, the exif orientation is applied
+func Dimensions(data []byte) (width int, height int, err error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, err
+	}
+	if format == "jpeg" && jpegOrientation(data) >= 5 {
+		return config.Height, config.Width, nil
+	}
+	return config.Width, config.Height, nil
+}
+
+// Resize returns the image scaled down to the width, it's never scaled up.
+// The exif orientation is applied and the metadata are dropped by the re-encoding.
+// The png images and the images with transparency are encoded in png, the others in jpeg.
+func Resize(data []byte, width int) ([]byte, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	orientation := 1
+	if format == "jpeg" {
+		orientation = jpegOrientation(data)
+	}
+
+	// the width is the displayed one, the image is rotated by 90 degrees for the orientations 5 to 8
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	if orientation >= 5 {
+		srcWidth, srcHeight = srcHeight, srcWidth
+	}
+	if width <= 0 || width > srcWidth {
+		width = srcWidth
+	}
+	height := max(1, srcHeight*width/srcWidth)
+	if orientation >= 5 {
+		width, height = height, width
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+	dst = orient(dst, orientation)
+
+	var buf bytes.Buffer
+	if format == "png" || !dst.Opaque() {
+		err = png.Encode(&buf, dst)
+	} else {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: jpegQuality})
+	}
+	return buf.Bytes(), err
+}
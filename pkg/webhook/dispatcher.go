@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/labbs/zotion/pkg/events"
+	"github.com/labbs/zotion/pkg/models"
+	"github.com/labbs/zotion/pkg/service"
+	"github.com/rs/zerolog"
+)
+
+// queueSize is the number of events waiting to be queued for the webhooks, the next ones are dropped
+const queueSize = 256
+
+// Dispatcher queues the deliveries of the events published on the bus and sends the pending
+// deliveries in background
+type Dispatcher struct {
+	logger  zerolog.Logger
+	service models.WebhookService
+
+	queue       chan events.Event
+	unsubscribe func()
+	stop        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// NewDispatcher starts the dispatcher, the pending deliveries are checked at each interval
+func NewDispatcher(logger zerolog.Logger, service models.WebhookService, bus events.Bus, interval time.Duration) *Dispatcher {
+	d := &Dispatcher{
+		logger:  logger.With().Str("component", "webhook").Logger(),
+		service: service,
+		queue:   make(chan events.Event, queueSize),
+		stop:    make(chan struct{}),
+	}
+	d.unsubscribe = bus.Subscribe(d.receive)
+
+	d.wg.Add(2)
+	go d.enqueue()
+	go d.run(interval)
+	return d
+}
+
+// receive is called by the bus, it must not block
+func (d *Dispatcher) receive(event events.Event) {
+	if !service.IsWebhookEvent(string(event.Type)) {
+		return
+	}
+	select {
+	case d.queue <- event:
+	default:
+		d.logger.Warn().Str("type", string(event.Type)).Str("id", event.Id).Msg("webhook queue is full, event dropped")
+	}
+}
+
+func (d *Dispatcher) enqueue() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case event := <-d.queue:
+			payload, err := json.Marshal(event)
+			if err != nil {
+				d.logger.Error().Err(err).Msg("failed to encode the event")
+				continue
+			}
+			if err := d.service.Enqueue(event.Id, string(event.Type), event.SpaceId, payload); err != nil {
+				d.logger.Error().Err(err).Str("id", event.Id).Msg("failed to queue the event deliveries")
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) run(interval time.Duration) {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case now := <-ticker.C:
+			if err := d.service.DeliverDue(now); err != nil {
+				d.logger.Error().Err(err).Msg("failed to send the pending deliveries")
+			}
+		}
+	}
+}
+
+// Close stops the dispatcher and waits for the running deliveries
+func (d *Dispatcher) Close() {
+	d.unsubscribe()
+	close(d.stop)
+	d.wg.Wait()
+}